@@ -7,19 +7,35 @@ import (
 )
 
 type AgriculturalUnitSurvey struct {
-	ID         uuid.UUID
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
-	ArchivedAt *time.Time
-	IDNum      int
-	Year       int
-	Data       map[string]interface{}
+	ID         uuid.UUID              `json:"id"`
+	CreatedAt  time.Time              `json:"createdAt"`
+	UpdatedAt  time.Time              `json:"updatedAt"`
+	ArchivedAt *time.Time             `json:"archivedAt,omitempty"`
+	IDNum      int                    `json:"idNum"`
+	Year       int                    `json:"year"`
+	Data       map[string]interface{} `json:"data"`
+
+	// Revision starts at 1 and grows each time a republished file changes the survey.
+	Revision int          `json:"revision"`
+	Source   SurveySource `json:"source"`
+	// SchemaVersion names the RICA dictionary Data was parsed with.
+	SchemaVersion string `json:"schemaVersion"`
+}
+
+// SurveySource identifies the file a survey revision was read from.
+type SurveySource struct {
+	URL      string `json:"url"`
+	FileName string `json:"fileName"`
+	Version  string `json:"version"`
 }
 
 type AgriculturalUnitSurveyValue struct {
-	IDNum int                    `json:"idNum"`
-	Year  int                    `json:"Year"`
-	Data  map[string]interface{} `json:"data"`
+	IDNum  int                    `json:"idNum"`
+	Year   int                    `json:"Year"`
+	Data   map[string]interface{} `json:"data"`
+	Source SurveySource           `json:"source"`
+
+	SchemaVersion string `json:"schemaVersion"`
 }
 
 func CreateAgriculturalUnitSurvey(value AgriculturalUnitSurveyValue) AgriculturalUnitSurvey {
@@ -32,5 +48,55 @@ func CreateAgriculturalUnitSurvey(value AgriculturalUnitSurveyValue) Agricultura
 		Year:       value.Year,
 		Data:       value.Data,
 		ArchivedAt: nil,
+		Revision:   1,
+		Source:     value.Source,
+
+		SchemaVersion: value.SchemaVersion,
+	}
+}
+
+// Revise returns the next revision of the survey and the archived revision it replaces.
+func (s AgriculturalUnitSurvey) Revise(data map[string]interface{}, source SurveySource, schemaVersion string) (AgriculturalUnitSurvey, AgriculturalUnitSurveyRevision) {
+	now := time.Now()
+	previous := s.CurrentRevision()
+	previous.ID = uuid.New()
+	previous.ArchivedAt = &now
+	previous.UpdatedAt = now
+
+	revised := s
+	revised.Data = data
+	revised.Source = source
+	revised.SchemaVersion = schemaVersion
+	revised.Revision = s.Revision + 1
+	revised.UpdatedAt = now
+	return revised, previous
+}
+
+// CurrentRevision describes the survey's live data as a revision.
+func (s AgriculturalUnitSurvey) CurrentRevision() AgriculturalUnitSurveyRevision {
+	return AgriculturalUnitSurveyRevision{
+		ID:        s.ID,
+		CreatedAt: s.UpdatedAt,
+		UpdatedAt: s.UpdatedAt,
+		SurveyID:  s.ID,
+		IDNum:     s.IDNum,
+		Year:      s.Year,
+		Revision:  s.Revision,
+		Data:      s.Data,
+		Source:    s.Source,
+
+		SchemaVersion: s.SchemaVersion,
+	}
+}
+
+// Project returns the survey with only the given Data keys it carries.
+func (s AgriculturalUnitSurvey) Project(fields []string) AgriculturalUnitSurvey {
+	data := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		if value, found := s.Data[field]; found {
+			data[field] = value
+		}
 	}
+	s.Data = data
+	return s
 }
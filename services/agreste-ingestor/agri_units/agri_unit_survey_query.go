@@ -0,0 +1,64 @@
+package agri_units
+
+import (
+	"common/agri"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+// SurveyKey is the natural key of a survey.
+type SurveyKey struct {
+	IDNum int
+	Year  int
+}
+
+// SurveyQuery filters and pages surveys, ordered by IDNUM then year.
+type SurveyQuery struct {
+	IDs      []uuid.UUID
+	IDNums   []int
+	Years    []int
+	IDNumMin *int
+	IDNumMax *int
+	BBox     *agri.BoundingBox
+	Archived agri.ArchivedFilter
+	After    *SurveyKey
+	Limit    uint64
+	Offset   uint64
+}
+
+func (q SurveyQuery) apply(builder sq.SelectBuilder) (sq.SelectBuilder, error) {
+	archived, err := q.Archived.Condition()
+	if err != nil {
+		return builder, err
+	}
+	if archived != nil {
+		builder = builder.Where(archived)
+	}
+	for _, condition := range agri.IDConditions(q.IDs, q.IDNums, q.IDNumMin, q.IDNumMax) {
+		builder = builder.Where(condition)
+	}
+	if q.Years != nil {
+		builder = builder.Where(sq.Eq{"year": q.Years})
+	}
+	if q.BBox != nil {
+		units, args, err := sq.Select("id_num").From("agricultural_units").Where(q.BBox.Condition()).ToSql()
+		if err != nil {
+			return builder, fmt.Errorf("failed to build bounding box subquery: %w", err)
+		}
+		builder = builder.Where("id_num IN ("+units+")", args...)
+	}
+	if q.After != nil {
+		builder = builder.Where("(id_num, year) > (?, ?)", q.After.IDNum, q.After.Year)
+	}
+
+	builder = builder.OrderBy("id_num", "year")
+	if q.Limit > 0 {
+		builder = builder.Limit(q.Limit)
+	}
+	if q.Offset > 0 {
+		builder = builder.Offset(q.Offset)
+	}
+	return builder, nil
+}
@@ -0,0 +1,110 @@
+package agri_units
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AgriculturalUnitSurveyRevision is a past version of a survey.
+type AgriculturalUnitSurveyRevision struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	ArchivedAt *time.Time `json:"archivedAt,omitempty"`
+
+	SurveyID uuid.UUID              `json:"surveyId"`
+	IDNum    int                    `json:"idNum"`
+	Year     int                    `json:"year"`
+	Revision int                    `json:"revision"`
+	Data     map[string]interface{} `json:"data"`
+	Source   SurveySource           `json:"source"`
+
+	SchemaVersion string `json:"schemaVersion"`
+}
+
+type SurveyFieldChangeKind string
+
+const (
+	SurveyFieldAdded   SurveyFieldChangeKind = "added"
+	SurveyFieldRemoved SurveyFieldChangeKind = "removed"
+	SurveyFieldChanged SurveyFieldChangeKind = "changed"
+)
+
+type SurveyFieldChange struct {
+	Field string                `json:"field"`
+	Kind  SurveyFieldChangeKind `json:"kind"`
+	From  interface{}           `json:"from,omitempty"`
+	To    interface{}           `json:"to,omitempty"`
+}
+
+// DiffSurveyData lists, by field name, what differs between two survey payloads.
+func DiffSurveyData(from, to map[string]interface{}) []SurveyFieldChange {
+	changes := []SurveyFieldChange{}
+	for field, fromValue := range from {
+		toValue, exists := to[field]
+		switch {
+		case !exists:
+			changes = append(changes, SurveyFieldChange{Field: field, Kind: SurveyFieldRemoved, From: fromValue})
+		case !sameSurveyValue(fromValue, toValue):
+			changes = append(changes, SurveyFieldChange{Field: field, Kind: SurveyFieldChanged, From: fromValue, To: toValue})
+		}
+	}
+	for field, toValue := range to {
+		if _, exists := from[field]; !exists {
+			changes = append(changes, SurveyFieldChange{Field: field, Kind: SurveyFieldAdded, To: toValue})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+// JSONB payloads hold float64 where freshly parsed ones hold int64.
+func sameSurveyValue(a, b interface{}) bool {
+	aNumber, aIsNumber := surveyNumber(a)
+	bNumber, bIsNumber := surveyNumber(b)
+	if aIsNumber && bIsNumber {
+		return aNumber == bNumber
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func surveyNumber(value interface{}) (float64, bool) {
+	switch number := value.(type) {
+	case int:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	case float64:
+		return number, true
+	}
+	return 0, false
+}
+
+var sourceVersionPattern = regexp.MustCompile(`(?i)[_-](v\d+)(?:\.[a-z0-9]+)*$`)
+
+// SourceVersion extracts the publication version of a file name, e.g. "v2" for "Rica2023_v2.zip".
+func SourceVersion(fileName string) string {
+	if match := sourceVersionPattern.FindStringSubmatch(fileName); match != nil {
+		return strings.ToLower(match[1])
+	}
+	return "v1"
+}
+
+// SurveyRevisionRef identifies a revision without its data.
+type SurveyRevisionRef struct {
+	Revision  int          `json:"revision"`
+	CreatedAt time.Time    `json:"createdAt"`
+	Source    SurveySource `json:"source"`
+
+	SchemaVersion string `json:"schemaVersion"`
+}
+
+func (r AgriculturalUnitSurveyRevision) Ref() SurveyRevisionRef {
+	return SurveyRevisionRef{Revision: r.Revision, CreatedAt: r.CreatedAt, Source: r.Source, SchemaVersion: r.SchemaVersion}
+}
@@ -0,0 +1,239 @@
+package agri_units
+
+import (
+	"common/storage"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+
+	_ "github.com/lib/pq"
+)
+
+type AgriculturalUnitSurveyRevisionSqlView struct {
+	ID            string       `db:"id"`
+	CreatedAt     time.Time    `db:"created_at"`
+	UpdatedAt     time.Time    `db:"updated_at"`
+	ArchivedAt    sql.NullTime `db:"archived_at"`
+	SurveyID      string       `db:"survey_id"`
+	IDNum         int          `db:"id_num"`
+	Year          int          `db:"year"`
+	Revision      int          `db:"revision"`
+	Data          []byte       `db:"data"`
+	SourceURL     string       `db:"source_url"`
+	SourceFile    string       `db:"source_file"`
+	SourceVersion string       `db:"source_version"`
+	SchemaVersion string       `db:"schema_version"`
+}
+
+func AgriculturalUnitSurveyRevisionToSqlView(revision AgriculturalUnitSurveyRevision) (AgriculturalUnitSurveyRevisionSqlView, error) {
+	jsonData, err := json.Marshal(revision.Data)
+	if err != nil {
+		return AgriculturalUnitSurveyRevisionSqlView{}, fmt.Errorf("failed to marshal survey revision data to JSON for SQL view: %w", err)
+	}
+
+	sqlView := AgriculturalUnitSurveyRevisionSqlView{
+		ID:            revision.ID.String(),
+		CreatedAt:     revision.CreatedAt,
+		UpdatedAt:     revision.UpdatedAt,
+		SurveyID:      revision.SurveyID.String(),
+		IDNum:         revision.IDNum,
+		Year:          revision.Year,
+		Revision:      revision.Revision,
+		Data:          jsonData,
+		SourceURL:     revision.Source.URL,
+		SourceFile:    revision.Source.FileName,
+		SourceVersion: revision.Source.Version,
+		SchemaVersion: revision.SchemaVersion,
+	}
+	if revision.ArchivedAt != nil {
+		sqlView.ArchivedAt = sql.NullTime{Time: *revision.ArchivedAt, Valid: true}
+	}
+
+	return sqlView, nil
+}
+
+func AgriculturalUnitSurveyRevisionFromSqlView(sqlView AgriculturalUnitSurveyRevisionSqlView) (AgriculturalUnitSurveyRevision, error) {
+	parsedID, err := uuid.Parse(sqlView.ID)
+	if err != nil {
+		return AgriculturalUnitSurveyRevision{}, fmt.Errorf("failed to parse UUID from SQL view '%s': %w", sqlView.ID, err)
+	}
+	parsedSurveyID, err := uuid.Parse(sqlView.SurveyID)
+	if err != nil {
+		return AgriculturalUnitSurveyRevision{}, fmt.Errorf("failed to parse survey UUID from SQL view '%s': %w", sqlView.SurveyID, err)
+	}
+
+	revision := AgriculturalUnitSurveyRevision{
+		ID:        parsedID,
+		CreatedAt: sqlView.CreatedAt,
+		UpdatedAt: sqlView.UpdatedAt,
+		SurveyID:  parsedSurveyID,
+		IDNum:     sqlView.IDNum,
+		Year:      sqlView.Year,
+		Revision:  sqlView.Revision,
+		Source: SurveySource{
+			URL:      sqlView.SourceURL,
+			FileName: sqlView.SourceFile,
+			Version:  sqlView.SourceVersion,
+		},
+		SchemaVersion: sqlView.SchemaVersion,
+	}
+	if sqlView.ArchivedAt.Valid {
+		revision.ArchivedAt = &sqlView.ArchivedAt.Time
+	}
+
+	if len(sqlView.Data) > 0 {
+		if err := json.Unmarshal(sqlView.Data, &revision.Data); err != nil {
+			return AgriculturalUnitSurveyRevision{}, fmt.Errorf("failed to unmarshal JSON data from SQL view: %w", err)
+		}
+	}
+
+	return revision, nil
+}
+
+type AgriculturalUnitSurveyRevisionStorage interface {
+	InsertBatch(revisions []AgriculturalUnitSurveyRevision) error
+	SelectBySurveyID(surveyID uuid.UUID) ([]AgriculturalUnitSurveyRevision, error)
+	WithQuerier(querier storage.DBQuerier) AgriculturalUnitSurveyRevisionStorage
+}
+
+var agriculturalUnitSurveyRevisionColumns = []string{
+	"id",
+	"created_at",
+	"updated_at",
+	"archived_at",
+	"survey_id",
+	"id_num",
+	"year",
+	"revision",
+	"data",
+	"source_url",
+	"source_file",
+	"source_version",
+	"schema_version",
+}
+
+type agriculturalUnitSurveyRevisionStorage struct {
+	querier storage.DBQuerier
+	builder sq.StatementBuilderType
+}
+
+func NewAgriculturalUnitSurveyRevisionStorage(querier storage.DBQuerier) AgriculturalUnitSurveyRevisionStorage {
+	return &agriculturalUnitSurveyRevisionStorage{
+		querier: querier,
+		builder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+}
+
+func (s *agriculturalUnitSurveyRevisionStorage) WithQuerier(querier storage.DBQuerier) AgriculturalUnitSurveyRevisionStorage {
+	return &agriculturalUnitSurveyRevisionStorage{
+		querier: querier,
+		builder: s.builder,
+	}
+}
+
+func (s *agriculturalUnitSurveyRevisionStorage) InsertBatch(revisions []AgriculturalUnitSurveyRevision) error {
+	for start := 0; start < len(revisions); start += maxSurveysPerStatement {
+		end := min(start+maxSurveysPerStatement, len(revisions))
+
+		builder := s.builder.Insert("agricultural_unit_survey_revisions").
+			Columns(agriculturalUnitSurveyRevisionColumns...)
+		for _, revision := range revisions[start:end] {
+			sqlView, err := AgriculturalUnitSurveyRevisionToSqlView(revision)
+			if err != nil {
+				return fmt.Errorf("failed to convert domain model to SQL view: %w", err)
+			}
+			builder = builder.Values(
+				sqlView.ID,
+				sqlView.CreatedAt,
+				sqlView.UpdatedAt,
+				sqlView.ArchivedAt,
+				sqlView.SurveyID,
+				sqlView.IDNum,
+				sqlView.Year,
+				sqlView.Revision,
+				sqlView.Data,
+				sqlView.SourceURL,
+				sqlView.SourceFile,
+				sqlView.SourceVersion,
+				sqlView.SchemaVersion,
+			)
+		}
+
+		query, args, err := builder.Suffix("ON CONFLICT (survey_id, revision) DO NOTHING").ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build InsertBatch SQL for AgriculturalUnitSurveyRevision: %w", err)
+		}
+
+		_, err = s.querier.Exec(query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to execute InsertBatch for %d agricultural unit survey revisions: %w", end-start, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *agriculturalUnitSurveyRevisionStorage) SelectBySurveyID(surveyID uuid.UUID) ([]AgriculturalUnitSurveyRevision, error) {
+	sqlQuery, args, err := s.builder.Select(agriculturalUnitSurveyRevisionColumns...).
+		From("agricultural_unit_survey_revisions").
+		Where(sq.Eq{"survey_id": surveyID.String()}).
+		OrderBy("revision ASC").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build SQL query with squirrel: %w", err)
+	}
+
+	rows, err := s.querier.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute SelectBySurveyID query: %w", err)
+	}
+	defer rows.Close()
+
+	var revisions []AgriculturalUnitSurveyRevision
+	for rows.Next() {
+		var sqlView AgriculturalUnitSurveyRevisionSqlView
+		err := rows.Scan(
+			&sqlView.ID,
+			&sqlView.CreatedAt,
+			&sqlView.UpdatedAt,
+			&sqlView.ArchivedAt,
+			&sqlView.SurveyID,
+			&sqlView.IDNum,
+			&sqlView.Year,
+			&sqlView.Revision,
+			&sqlView.Data,
+			&sqlView.SourceURL,
+			&sqlView.SourceFile,
+			&sqlView.SourceVersion,
+			&sqlView.SchemaVersion,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan agricultural unit survey revision row: %w", err)
+		}
+
+		revision, err := AgriculturalUnitSurveyRevisionFromSqlView(sqlView)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert SQL view to domain model: %w", err)
+		}
+		revisions = append(revisions, revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during row iteration: %w", err)
+	}
+
+	return revisions, nil
+}
+
+// SurveyHistory returns every revision of a survey, oldest first, ending with its current data.
+func SurveyHistory(survey AgriculturalUnitSurvey, revisionStorage AgriculturalUnitSurveyRevisionStorage) ([]AgriculturalUnitSurveyRevision, error) {
+	revisions, err := revisionStorage.SelectBySurveyID(survey.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select revisions of survey %s: %w", survey.ID, err)
+	}
+	return append(revisions, survey.CurrentRevision()), nil
+}
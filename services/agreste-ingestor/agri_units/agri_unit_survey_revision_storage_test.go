@@ -0,0 +1,100 @@
+package agri_units
+
+import (
+	"common/testutils"
+	"database/sql"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+var agriculturalUnitSurveyRevisionTestColumns = []string{"id", "created_at", "updated_at", "archived_at", "survey_id", "id_num", "year", "revision", "data", "source_url", "source_file", "source_version", "schema_version"}
+
+func TestAgriculturalUnitSurveyRevisionStorage_InsertBatch(t *testing.T) {
+	mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+	if err != nil {
+		t.Fatalf("Failed to create mock querier: %v", err)
+	}
+	defer mockQuerierInstance.Db.Close()
+
+	now := time.Now().Truncate(time.Millisecond)
+	revision := AgriculturalUnitSurveyRevision{
+		ID:            uuid.New(),
+		CreatedAt:     now.Add(-time.Hour),
+		UpdatedAt:     now,
+		ArchivedAt:    &now,
+		SurveyID:      uuid.New(),
+		IDNum:         101,
+		Year:          2023,
+		Revision:      1,
+		Data:          map[string]interface{}{"SAU": 80.0},
+		Source:        SurveySource{URL: "https://example.org/rica.zip", FileName: "rica.csv", Version: "v1"},
+		SchemaVersion: "rica-2020.1",
+	}
+
+	sqlMock.ExpectExec(regexp.QuoteMeta(`INSERT INTO agricultural_unit_survey_revisions (id,created_at,updated_at,archived_at,survey_id,id_num,year,revision,data,source_url,source_file,source_version,schema_version) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13) ON CONFLICT (survey_id, revision) DO NOTHING`)).
+		WithArgs(
+			revision.ID.String(), revision.CreatedAt, revision.UpdatedAt, sql.NullTime{Time: now, Valid: true},
+			revision.SurveyID.String(), 101, 2023, 1, []byte(`{"SAU":80}`),
+			"https://example.org/rica.zip", "rica.csv", "v1", "rica-2020.1",
+		).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = NewAgriculturalUnitSurveyRevisionStorage(mockQuerierInstance).InsertBatch([]AgriculturalUnitSurveyRevision{revision})
+	if err != nil {
+		t.Fatalf("InsertBatch returned an unexpected error: %v", err)
+	}
+
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("There were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSurveyHistory(t *testing.T) {
+	mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+	if err != nil {
+		t.Fatalf("Failed to create mock querier: %v", err)
+	}
+	defer mockQuerierInstance.Db.Close()
+
+	now := time.Now().Truncate(time.Millisecond)
+	survey := AgriculturalUnitSurvey{
+		ID:        uuid.New(),
+		CreatedAt: now.Add(-48 * time.Hour),
+		UpdatedAt: now,
+		IDNum:     101,
+		Year:      2023,
+		Data:      map[string]interface{}{"SAU": 82.0},
+		Revision:  2,
+		Source:    SurveySource{URL: "https://example.org/rica_v2.zip", FileName: "rica.csv", Version: "v2"},
+	}
+	firstData, _ := json.Marshal(map[string]interface{}{"SAU": 80.0})
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta("SELECT id, created_at, updated_at, archived_at, survey_id, id_num, year, revision, data, source_url, source_file, source_version, schema_version FROM agricultural_unit_survey_revisions WHERE survey_id = $1 ORDER BY revision ASC")).
+		WithArgs(survey.ID.String()).
+		WillReturnRows(sqlMock.NewRows(agriculturalUnitSurveyRevisionTestColumns).
+			AddRow(uuid.New().String(), survey.CreatedAt, now, now, survey.ID.String(), 101, 2023, 1, firstData, "https://example.org/rica.zip", "rica.csv", "v1", "rica-2020.1"))
+
+	history, err := SurveyHistory(survey, NewAgriculturalUnitSurveyRevisionStorage(mockQuerierInstance))
+	if err != nil {
+		t.Fatalf("SurveyHistory returned an unexpected error: %v", err)
+	}
+
+	if len(history) != 2 {
+		t.Fatalf("expected 2 revisions, got %d", len(history))
+	}
+	if history[0].Revision != 1 || history[0].Data["SAU"] != 80.0 || history[0].ArchivedAt == nil || history[0].Source.Version != "v1" || history[0].SchemaVersion != "rica-2020.1" {
+		t.Errorf("unexpected first revision %+v", history[0])
+	}
+	if history[1].Revision != 2 || history[1].ID != survey.ID || history[1].ArchivedAt != nil || history[1].Source.Version != "v2" {
+		t.Errorf("the last revision should be the survey's current data, got %+v", history[1])
+	}
+
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("There were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,84 @@
+package agri_units
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestDiffSurveyData(t *testing.T) {
+	from := map[string]interface{}{"SAU": 80.0, "OTEFDD": 1500.0, "REGION": "84", "OLD": true}
+	to := map[string]interface{}{"SAU": 82.0, "OTEFDD": 1500.0, "REGION": "84", "NEW": "x"}
+
+	expected := []SurveyFieldChange{
+		{Field: "NEW", Kind: SurveyFieldAdded, To: "x"},
+		{Field: "OLD", Kind: SurveyFieldRemoved, From: true},
+		{Field: "SAU", Kind: SurveyFieldChanged, From: 80.0, To: 82.0},
+	}
+	if changes := DiffSurveyData(from, to); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("diff mismatch. Expected %+v, got %+v", expected, changes)
+	}
+
+	if changes := DiffSurveyData(from, from); len(changes) != 0 {
+		t.Errorf("identical payloads should have no changes, got %+v", changes)
+	}
+
+	stored := map[string]interface{}{"IDNUM": 101.0, "SAU": 80.0}
+	parsed := map[string]interface{}{"IDNUM": int64(101), "SAU": 80.0}
+	if changes := DiffSurveyData(stored, parsed); len(changes) != 0 {
+		t.Errorf("numbers read back from JSON should equal parsed integers, got %+v", changes)
+	}
+}
+
+func TestSourceVersion(t *testing.T) {
+	cases := map[string]string{
+		"RicaMicrodonnées2023_v2.zip":    "v2",
+		"RicaMicrodonnées2023_V3.csv.gz": "v3",
+		"rica-v10":                       "v10",
+		"RicaMicrodonnées2023.zip":       "v1",
+		"":                               "v1",
+	}
+	for fileName, expected := range cases {
+		if version := SourceVersion(fileName); version != expected {
+			t.Errorf("SourceVersion(%q): expected %s, got %s", fileName, expected, version)
+		}
+	}
+}
+
+func TestAgriculturalUnitSurvey_Revise(t *testing.T) {
+	updatedAt := time.Now().Add(-time.Hour)
+	survey := AgriculturalUnitSurvey{
+		ID:        uuid.New(),
+		CreatedAt: updatedAt,
+		UpdatedAt: updatedAt,
+		IDNum:     101,
+		Year:      2023,
+		Data:      map[string]interface{}{"SAU": 80.0},
+		Revision:  1,
+		Source:    SurveySource{URL: "https://example.org/rica.zip", FileName: "rica.csv", Version: "v1"},
+
+		SchemaVersion: "rica-2020.1",
+	}
+	source := SurveySource{URL: "https://example.org/rica_v2.zip", FileName: "rica.csv", Version: "v2"}
+
+	revised, previous := survey.Revise(map[string]interface{}{"SAU": 82.0}, source, "rica-2020.2")
+
+	if revised.ID != survey.ID || !revised.CreatedAt.Equal(survey.CreatedAt) {
+		t.Errorf("a revised survey should keep its ID and creation time, got %+v", revised)
+	}
+	if revised.Revision != 2 || revised.Source != source || revised.Data["SAU"] != 82.0 || revised.SchemaVersion != "rica-2020.2" || !revised.UpdatedAt.After(updatedAt) {
+		t.Errorf("unexpected revised survey %+v", revised)
+	}
+
+	if previous.ID == survey.ID || previous.SurveyID != survey.ID {
+		t.Errorf("the archived revision needs its own ID and must point to the survey, got %+v", previous)
+	}
+	if previous.Revision != 1 || previous.Source != survey.Source || previous.Data["SAU"] != 80.0 || previous.SchemaVersion != "rica-2020.1" {
+		t.Errorf("the archived revision should hold the previous data, got %+v", previous)
+	}
+	if !previous.CreatedAt.Equal(updatedAt) || previous.ArchivedAt == nil || !previous.ArchivedAt.Equal(revised.UpdatedAt) {
+		t.Errorf("the archived revision should span from the last update to the revision, got %+v", previous)
+	}
+}
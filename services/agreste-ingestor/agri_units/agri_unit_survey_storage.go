@@ -1,9 +1,10 @@
 package agri_units
 
 import (
-	"agreste-ingestor/storage"
+	"common/storage"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -13,23 +14,35 @@ import (
 	_ "github.com/lib/pq"
 )
 
+var ErrAgriculturalUnitSurveyNotFound = errors.New("agricultural unit survey not found")
+
 type AgriculturalUnitSurveySqlView struct {
-	ID         string       `db:"id"`
-	CreatedAt  time.Time    `db:"created_at"`
-	UpdatedAt  time.Time    `db:"updated_at"`
-	ArchivedAt sql.NullTime `db:"archived_at"`
-	IDNum      int          `db:"id_num"`
-	Year       int          `db:"year"`
-	Data       []byte       `db:"data"`
+	ID            string       `db:"id"`
+	CreatedAt     time.Time    `db:"created_at"`
+	UpdatedAt     time.Time    `db:"updated_at"`
+	ArchivedAt    sql.NullTime `db:"archived_at"`
+	IDNum         int          `db:"id_num"`
+	Year          int          `db:"year"`
+	Data          []byte       `db:"data"`
+	Revision      int          `db:"revision"`
+	SourceURL     string       `db:"source_url"`
+	SourceFile    string       `db:"source_file"`
+	SourceVersion string       `db:"source_version"`
+	SchemaVersion string       `db:"schema_version"`
 }
 
 func AgriculturalUnitSurveyToSqlView(survey AgriculturalUnitSurvey) (AgriculturalUnitSurveySqlView, error) {
 	sqlView := AgriculturalUnitSurveySqlView{
-		ID:        survey.ID.String(),
-		CreatedAt: survey.CreatedAt,
-		UpdatedAt: survey.UpdatedAt,
-		IDNum:     survey.IDNum,
-		Year:      survey.Year,
+		ID:            survey.ID.String(),
+		CreatedAt:     survey.CreatedAt,
+		UpdatedAt:     survey.UpdatedAt,
+		IDNum:         survey.IDNum,
+		Year:          survey.Year,
+		Revision:      survey.Revision,
+		SourceURL:     survey.Source.URL,
+		SourceFile:    survey.Source.FileName,
+		SourceVersion: survey.Source.Version,
+		SchemaVersion: survey.SchemaVersion,
 	}
 
 	if survey.ArchivedAt != nil {
@@ -59,6 +72,13 @@ func AgriculturalUnitSurveyFromSqlView(sqlView AgriculturalUnitSurveySqlView) (A
 		UpdatedAt: sqlView.UpdatedAt,
 		IDNum:     sqlView.IDNum,
 		Year:      sqlView.Year,
+		Revision:  sqlView.Revision,
+		Source: SurveySource{
+			URL:      sqlView.SourceURL,
+			FileName: sqlView.SourceFile,
+			Version:  sqlView.SourceVersion,
+		},
+		SchemaVersion: sqlView.SchemaVersion,
 	}
 
 	if sqlView.ArchivedAt.Valid {
@@ -81,7 +101,42 @@ func AgriculturalUnitSurveyFromSqlView(sqlView AgriculturalUnitSurveySqlView) (A
 
 type AgriculturalUnitSurveyStorage interface {
 	InsertOrUpdate(survey AgriculturalUnitSurvey) error
+	InsertOrUpdateBatch(surveys []AgriculturalUnitSurvey) error
 	SelectAll() ([]AgriculturalUnitSurvey, error)
+	Select(query SurveyQuery) ([]AgriculturalUnitSurvey, error)
+	// Each calls visit with every survey matching query, stopping at its first error.
+	Each(query SurveyQuery, visit func(AgriculturalUnitSurvey) error) error
+	SelectByID(id uuid.UUID) (AgriculturalUnitSurvey, error)
+	WithQuerier(querier storage.DBQuerier) AgriculturalUnitSurveyStorage
+}
+
+const maxSurveysPerStatement = 250
+
+const agriculturalUnitSurveyUpsertSuffix = `
+            ON CONFLICT (id_num, year) DO UPDATE SET
+                updated_at = EXCLUDED.updated_at,
+                archived_at = EXCLUDED.archived_at,
+                data = EXCLUDED.data,
+                revision = EXCLUDED.revision,
+                source_url = EXCLUDED.source_url,
+                source_file = EXCLUDED.source_file,
+                source_version = EXCLUDED.source_version,
+                schema_version = EXCLUDED.schema_version
+        `
+
+var agriculturalUnitSurveyColumns = []string{
+	"id",
+	"created_at",
+	"updated_at",
+	"archived_at",
+	"id_num",
+	"year",
+	"data",
+	"revision",
+	"source_url",
+	"source_file",
+	"source_version",
+	"schema_version",
 }
 
 type agriculturalUnitSurveyStorage struct {
@@ -96,22 +151,25 @@ func NewAgriculturalUnitSurveyStorage(querier storage.DBQuerier) AgriculturalUni
 	}
 }
 
+func (s *agriculturalUnitSurveyStorage) WithQuerier(querier storage.DBQuerier) AgriculturalUnitSurveyStorage {
+	return &agriculturalUnitSurveyStorage{
+		querier: querier,
+		builder: s.builder,
+	}
+}
+
+func (s *agriculturalUnitSurveyStorage) insertBuilder() sq.InsertBuilder {
+	return s.builder.Insert("agricultural_unit_surveys").
+		Columns(agriculturalUnitSurveyColumns...)
+}
+
 func (s *agriculturalUnitSurveyStorage) InsertOrUpdate(survey AgriculturalUnitSurvey) error {
 	sqlView, err := AgriculturalUnitSurveyToSqlView(survey)
 	if err != nil {
 		return fmt.Errorf("failed to convert domain model to SQL view: %w", err)
 	}
 
-	builder := s.builder.Insert("agricultural_unit_surveys").
-		Columns(
-			"id",
-			"created_at",
-			"updated_at",
-			"archived_at",
-			"id_num",
-			"year",
-			"data",
-		).
+	builder := s.insertBuilder().
 		Values(
 			sqlView.ID,
 			sqlView.CreatedAt,
@@ -120,15 +178,13 @@ func (s *agriculturalUnitSurveyStorage) InsertOrUpdate(survey AgriculturalUnitSu
 			sqlView.IDNum,
 			sqlView.Year,
 			sqlView.Data,
+			sqlView.Revision,
+			sqlView.SourceURL,
+			sqlView.SourceFile,
+			sqlView.SourceVersion,
+			sqlView.SchemaVersion,
 		).
-		Suffix(`
-            ON CONFLICT (id) DO UPDATE SET
-                updated_at = EXCLUDED.updated_at,
-                archived_at = EXCLUDED.archived_at,
-                id_num = EXCLUDED.id_num,
-                year = EXCLUDED.year,
-                data = EXCLUDED.data
-        `)
+		Suffix(agriculturalUnitSurveyUpsertSuffix)
 
 	query, args, err := builder.ToSql()
 	if err != nil {
@@ -143,29 +199,105 @@ func (s *agriculturalUnitSurveyStorage) InsertOrUpdate(survey AgriculturalUnitSu
 	return nil
 }
 
+func (s *agriculturalUnitSurveyStorage) InsertOrUpdateBatch(surveys []AgriculturalUnitSurvey) error {
+	for start := 0; start < len(surveys); start += maxSurveysPerStatement {
+		end := min(start+maxSurveysPerStatement, len(surveys))
+
+		builder := s.insertBuilder()
+		for _, survey := range surveys[start:end] {
+			sqlView, err := AgriculturalUnitSurveyToSqlView(survey)
+			if err != nil {
+				return fmt.Errorf("failed to convert domain model to SQL view: %w", err)
+			}
+			builder = builder.Values(
+				sqlView.ID,
+				sqlView.CreatedAt,
+				sqlView.UpdatedAt,
+				sqlView.ArchivedAt,
+				sqlView.IDNum,
+				sqlView.Year,
+				sqlView.Data,
+				sqlView.Revision,
+				sqlView.SourceURL,
+				sqlView.SourceFile,
+				sqlView.SourceVersion,
+				sqlView.SchemaVersion,
+			)
+		}
+
+		query, args, err := builder.Suffix(agriculturalUnitSurveyUpsertSuffix).ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build InsertOrUpdateBatch SQL for AgriculturalUnitSurvey: %w", err)
+		}
+
+		_, err = s.querier.Exec(query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to execute InsertOrUpdateBatch for %d agricultural unit surveys: %w", end-start, err)
+		}
+	}
+
+	return nil
+}
+
 func (s *agriculturalUnitSurveyStorage) SelectAll() ([]AgriculturalUnitSurvey, error) {
-	queryBuilder := s.builder.Select(
-		"id",
-		"created_at",
-		"updated_at",
-		"archived_at",
-		"id_num",
-		"year",
-		"data",
-	).From("agricultural_unit_surveys")
+	return s.Select(SurveyQuery{})
+}
+
+func (s *agriculturalUnitSurveyStorage) Select(query SurveyQuery) ([]AgriculturalUnitSurvey, error) {
+	queryBuilder, err := query.apply(s.builder.Select(agriculturalUnitSurveyColumns...).
+		From("agricultural_unit_surveys"))
+	if err != nil {
+		return nil, err
+	}
+	return s.selectSurveys(queryBuilder)
+}
 
+func (s *agriculturalUnitSurveyStorage) Each(query SurveyQuery, visit func(AgriculturalUnitSurvey) error) error {
+	queryBuilder, err := query.apply(s.builder.Select(agriculturalUnitSurveyColumns...).
+		From("agricultural_unit_surveys"))
+	if err != nil {
+		return err
+	}
+	return s.eachSurvey(queryBuilder, visit)
+}
+
+func (s *agriculturalUnitSurveyStorage) SelectByID(id uuid.UUID) (AgriculturalUnitSurvey, error) {
+	surveys, err := s.selectSurveys(s.builder.Select(agriculturalUnitSurveyColumns...).
+		From("agricultural_unit_surveys").
+		Where(sq.Eq{"id": id.String()}))
+	if err != nil {
+		return AgriculturalUnitSurvey{}, err
+	}
+	if len(surveys) == 0 {
+		return AgriculturalUnitSurvey{}, fmt.Errorf("%w: %s", ErrAgriculturalUnitSurveyNotFound, id)
+	}
+	return surveys[0], nil
+}
+
+func (s *agriculturalUnitSurveyStorage) selectSurveys(queryBuilder sq.SelectBuilder) ([]AgriculturalUnitSurvey, error) {
+	var surveys []AgriculturalUnitSurvey
+	err := s.eachSurvey(queryBuilder, func(survey AgriculturalUnitSurvey) error {
+		surveys = append(surveys, survey)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return surveys, nil
+}
+
+func (s *agriculturalUnitSurveyStorage) eachSurvey(queryBuilder sq.SelectBuilder, visit func(AgriculturalUnitSurvey) error) error {
 	sqlQuery, args, err := queryBuilder.ToSql()
 	if err != nil {
-		return nil, fmt.Errorf("failed to build SQL query with squirrel: %w", err)
+		return fmt.Errorf("failed to build SQL query with squirrel: %w", err)
 	}
 
 	rows, err := s.querier.Query(sqlQuery, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute SelectAll query: %w", err)
+		return fmt.Errorf("failed to execute agricultural unit survey query: %w", err)
 	}
 	defer rows.Close()
 
-	var surveys []AgriculturalUnitSurvey
 	for rows.Next() {
 		var sqlView AgriculturalUnitSurveySqlView
 		err := rows.Scan(
@@ -176,21 +308,28 @@ func (s *agriculturalUnitSurveyStorage) SelectAll() ([]AgriculturalUnitSurvey, e
 			&sqlView.IDNum,
 			&sqlView.Year,
 			&sqlView.Data,
+			&sqlView.Revision,
+			&sqlView.SourceURL,
+			&sqlView.SourceFile,
+			&sqlView.SourceVersion,
+			&sqlView.SchemaVersion,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan agricultural unit survey row: %w", err)
+			return fmt.Errorf("failed to scan agricultural unit survey row: %w", err)
 		}
 
 		domainSurvey, err := AgriculturalUnitSurveyFromSqlView(sqlView)
 		if err != nil {
-			return nil, fmt.Errorf("failed to convert SQL view to domain model: %w", err)
+			return fmt.Errorf("failed to convert SQL view to domain model: %w", err)
+		}
+		if err := visit(domainSurvey); err != nil {
+			return err
 		}
-		surveys = append(surveys, domainSurvey)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error during row iteration: %w", err)
+		return fmt.Errorf("error during row iteration: %w", err)
 	}
 
-	return surveys, nil
+	return nil
 }
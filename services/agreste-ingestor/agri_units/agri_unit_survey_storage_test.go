@@ -1,7 +1,9 @@
 package agri_units
 
 import (
-	"agreste-ingestor/misc"
+	"common/agri"
+	"common/storage"
+	"common/testutils"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -202,9 +204,11 @@ func TestAgriculturalUnitSurveyFromSqlView(t *testing.T) {
 	})
 }
 
+var agriculturalUnitSurveyTestColumns = []string{"id", "created_at", "updated_at", "archived_at", "id_num", "year", "data", "revision", "source_url", "source_file", "source_version", "schema_version"}
+
 func TestAgriculturalUnitSurveyStorage_SelectAll(t *testing.T) {
 	t.Run("SuccessfulSelectAll", func(t *testing.T) {
-		mockQuerierInstance, sqlMock, err := misc.NewMockQuerier(t)
+		mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
 		if err != nil {
 			t.Fatalf("failed to create mock querier: %v", err)
 		}
@@ -226,11 +230,11 @@ func TestAgriculturalUnitSurveyStorage_SelectAll(t *testing.T) {
 		data2 := map[string]interface{}{"region": "south", "yield": 50.2}
 		dataJSON2, _ := json.Marshal(data2)
 
-		rows := sqlMock.NewRows([]string{"id", "created_at", "updated_at", "archived_at", "id_num", "year", "data"}).
-			AddRow(id1.String(), createdAt1, updatedAt1, archivedAt1, 1, 2023, dataJSON1).
-			AddRow(id2.String(), createdAt2, updatedAt2, archivedAt2, 2, 2024, dataJSON2)
+		rows := sqlMock.NewRows(agriculturalUnitSurveyTestColumns).
+			AddRow(id1.String(), createdAt1, updatedAt1, archivedAt1, 1, 2023, dataJSON1, 2, "https://example.org/rica_v2.zip", "rica.csv", "v2", "rica-2020.1").
+			AddRow(id2.String(), createdAt2, updatedAt2, archivedAt2, 2, 2024, dataJSON2, 1, "", "", "", "")
 
-		sqlMock.ExpectQuery("SELECT id, created_at, updated_at, archived_at, id_num, year, data FROM agricultural_unit_surveys").
+		sqlMock.ExpectQuery(regexp.QuoteMeta("SELECT id, created_at, updated_at, archived_at, id_num, year, data, revision, source_url, source_file, source_version, schema_version FROM agricultural_unit_surveys WHERE archived_at IS NULL ORDER BY id_num, year")).
 			WillReturnRows(rows)
 
 		surveys, err := storage.SelectAll()
@@ -264,6 +268,10 @@ func TestAgriculturalUnitSurveyStorage_SelectAll(t *testing.T) {
 		if surveys[0].Year != 2023 {
 			t.Errorf("Survey 1 Year mismatch. Expected %d, got %d", 2023, surveys[0].Year)
 		}
+		expectedSource1 := SurveySource{URL: "https://example.org/rica_v2.zip", FileName: "rica.csv", Version: "v2"}
+		if surveys[0].Revision != 2 || surveys[0].Source != expectedSource1 {
+			t.Errorf("Survey 1 revision mismatch. Expected 2 from %+v, got %d from %+v", expectedSource1, surveys[0].Revision, surveys[0].Source)
+		}
 
 		expectedData1 := map[string]interface{}{"crop": "corn", "area": float64(100.5)}
 		if !reflect.DeepEqual(surveys[0].Data, expectedData1) {
@@ -289,14 +297,14 @@ func TestAgriculturalUnitSurveyStorage_SelectAll(t *testing.T) {
 	})
 
 	t.Run("QueryError", func(t *testing.T) {
-		mockQuerierInstance, sqlMock, err := misc.NewMockQuerier(t)
+		mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
 		if err != nil {
 			t.Fatalf("Failed to create mock querier: %v", err)
 		}
 		defer mockQuerierInstance.Db.Close()
 
 		expectedErr := errors.New("database query failed")
-		sqlMock.ExpectQuery("SELECT id, created_at, updated_at, archived_at, id_num, year, data FROM agricultural_unit_surveys").
+		sqlMock.ExpectQuery(regexp.QuoteMeta("SELECT id, created_at, updated_at, archived_at, id_num, year, data, revision, source_url, source_file, source_version, schema_version FROM agricultural_unit_surveys WHERE archived_at IS NULL ORDER BY id_num, year")).
 			WillReturnError(expectedErr)
 
 		storage := NewAgriculturalUnitSurveyStorage(mockQuerierInstance)
@@ -315,18 +323,18 @@ func TestAgriculturalUnitSurveyStorage_SelectAll(t *testing.T) {
 	})
 
 	t.Run("RowsError", func(t *testing.T) {
-		mockQuerierInstance, sqlMock, err := misc.NewMockQuerier(t)
+		mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
 		if err != nil {
 			t.Fatalf("Failed to create mock querier: %v", err)
 		}
 		defer mockQuerierInstance.Db.Close()
 
 		expectedErr := errors.New("rows iteration error")
-		rows := sqlMock.NewRows([]string{"id", "created_at", "updated_at", "archived_at", "id_num", "year", "data"}).
-			AddRow(uuid.New().String(), time.Now(), time.Now(), sql.NullTime{Valid: false}, 1, 2023, []byte("{}")).
+		rows := sqlMock.NewRows(agriculturalUnitSurveyTestColumns).
+			AddRow(uuid.New().String(), time.Now(), time.Now(), sql.NullTime{Valid: false}, 1, 2023, []byte("{}"), 1, "", "", "", "").
 			RowError(0, expectedErr)
 
-		sqlMock.ExpectQuery("SELECT id, created_at, updated_at, archived_at, id_num, year, data FROM agricultural_unit_surveys").
+		sqlMock.ExpectQuery(regexp.QuoteMeta("SELECT id, created_at, updated_at, archived_at, id_num, year, data, revision, source_url, source_file, source_version, schema_version FROM agricultural_unit_surveys WHERE archived_at IS NULL ORDER BY id_num, year")).
 			WillReturnRows(rows)
 
 		storage := NewAgriculturalUnitSurveyStorage(mockQuerierInstance)
@@ -348,7 +356,7 @@ func TestAgriculturalUnitSurveyStorage_SelectAll(t *testing.T) {
 func TestAgriculturalUnitSurveyStorage_InsertOrUpdate(t *testing.T) {
 
 	t.Run("SuccessfulInsertOrUpdate", func(t *testing.T) {
-		mockQuerierInstance, sqlMock, err := misc.NewMockQuerier(t)
+		mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
 		if err != nil {
 			t.Fatalf("Failed to create mock querier: %v", err)
 		}
@@ -381,7 +389,7 @@ func TestAgriculturalUnitSurveyStorage_InsertOrUpdate(t *testing.T) {
 		}
 
 		sqlMock.ExpectExec(
-			regexp.QuoteMeta(`INSERT INTO agricultural_unit_surveys (id,created_at,updated_at,archived_at,id_num,year,data) VALUES ($1,$2,$3,$4,$5,$6,$7) ON CONFLICT (id) DO UPDATE SET updated_at = EXCLUDED.updated_at, archived_at = EXCLUDED.archived_at, id_num = EXCLUDED.id_num, year = EXCLUDED.year, data = EXCLUDED.data`),
+			regexp.QuoteMeta(`INSERT INTO agricultural_unit_surveys (id,created_at,updated_at,archived_at,id_num,year,data,revision,source_url,source_file,source_version,schema_version) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12) ON CONFLICT (id_num, year) DO UPDATE SET updated_at = EXCLUDED.updated_at, archived_at = EXCLUDED.archived_at, data = EXCLUDED.data, revision = EXCLUDED.revision, source_url = EXCLUDED.source_url, source_file = EXCLUDED.source_file, source_version = EXCLUDED.source_version, schema_version = EXCLUDED.schema_version`),
 		).WithArgs(
 			sqlView.ID,
 			sqlView.CreatedAt,
@@ -390,6 +398,11 @@ func TestAgriculturalUnitSurveyStorage_InsertOrUpdate(t *testing.T) {
 			sqlView.IDNum,
 			sqlView.Year,
 			sqlView.Data,
+			sqlView.Revision,
+			sqlView.SourceURL,
+			sqlView.SourceFile,
+			sqlView.SourceVersion,
+			sqlView.SchemaVersion,
 		).WillReturnResult(sqlmock.NewResult(1, 1))
 
 		err = storage.InsertOrUpdate(domainSurvey)
@@ -402,3 +415,153 @@ func TestAgriculturalUnitSurveyStorage_InsertOrUpdate(t *testing.T) {
 		}
 	})
 }
+
+func TestAgriculturalUnitSurveyStorage_InsertOrUpdateBatch(t *testing.T) {
+	mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+	if err != nil {
+		t.Fatalf("Failed to create mock querier: %v", err)
+	}
+	defer mockQuerierInstance.Db.Close()
+
+	surveyStorage := NewAgriculturalUnitSurveyStorage(mockQuerierInstance)
+
+	surveys := make([]AgriculturalUnitSurvey, maxSurveysPerStatement+2)
+	for i := range surveys {
+		surveys[i] = CreateAgriculturalUnitSurvey(AgriculturalUnitSurveyValue{IDNum: i + 1, Year: 2023, Data: map[string]interface{}{"SAU": float64(i)}})
+	}
+
+	firstView, err := AgriculturalUnitSurveyToSqlView(surveys[maxSurveysPerStatement])
+	if err != nil {
+		t.Fatalf("Failed to convert domain survey to SQL view: %v", err)
+	}
+	secondView, err := AgriculturalUnitSurveyToSqlView(surveys[maxSurveysPerStatement+1])
+	if err != nil {
+		t.Fatalf("Failed to convert domain survey to SQL view: %v", err)
+	}
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`INSERT INTO agricultural_unit_surveys`)).
+		WillReturnResult(sqlmock.NewResult(0, maxSurveysPerStatement))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`INSERT INTO agricultural_unit_surveys (id,created_at,updated_at,archived_at,id_num,year,data,revision,source_url,source_file,source_version,schema_version) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12),($13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24) ON CONFLICT (id_num, year) DO UPDATE SET`)).
+		WithArgs(
+			firstView.ID, firstView.CreatedAt, firstView.UpdatedAt, firstView.ArchivedAt, firstView.IDNum, firstView.Year, firstView.Data, 1, "", "", "", "",
+			secondView.ID, secondView.CreatedAt, secondView.UpdatedAt, secondView.ArchivedAt, secondView.IDNum, secondView.Year, secondView.Data, 1, "", "", "", "",
+		).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	sqlMock.ExpectCommit()
+
+	err = mockQuerierInstance.InTransaction(func(tx storage.DBQuerier) error {
+		return surveyStorage.WithQuerier(tx).InsertOrUpdateBatch(surveys)
+	})
+	if err != nil {
+		t.Fatalf("InsertOrUpdateBatch returned an unexpected error: %v", err)
+	}
+
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("There were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestAgriculturalUnitSurveyStorage_Select(t *testing.T) {
+	mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+	if err != nil {
+		t.Fatalf("Failed to create mock querier: %v", err)
+	}
+	defer mockQuerierInstance.Db.Close()
+
+	storage := NewAgriculturalUnitSurveyStorage(mockQuerierInstance)
+
+	id := uuid.New()
+	now := time.Now().Truncate(time.Millisecond)
+	idNumMin := 100
+	query := SurveyQuery{
+		IDNumMin: &idNumMin,
+		Years:    []int{2023},
+		BBox:     &agri.BoundingBox{MinLatitude: 43, MinLongitude: 1, MaxLatitude: 46, MaxLongitude: 4},
+		Archived: agri.ArchivedIncluded,
+		Limit:    50,
+	}
+
+	expectedSQL := "SELECT id, created_at, updated_at, archived_at, id_num, year, data, revision, source_url, source_file, source_version, schema_version FROM agricultural_unit_surveys " +
+		"WHERE id_num >= $1 AND year IN ($2) " +
+		"AND id_num IN (SELECT id_num FROM agricultural_units WHERE (latitude >= $3 AND latitude <= $4 AND longitude >= $5 AND longitude <= $6)) " +
+		"ORDER BY id_num, year LIMIT 50"
+	sqlMock.ExpectQuery(regexp.QuoteMeta(expectedSQL)).
+		WithArgs(100, 2023, 43.0, 46.0, 1.0, 4.0).
+		WillReturnRows(sqlMock.NewRows(agriculturalUnitSurveyTestColumns).
+			AddRow(id.String(), now, now, sql.NullTime{Valid: false}, 150, 2023, []byte(`{"SAU": 80.5}`), 1, "", "", "", "rica-2020.1"))
+
+	surveys, err := storage.Select(query)
+	if err != nil {
+		t.Fatalf("Select returned an unexpected error: %v", err)
+	}
+	if len(surveys) != 1 || surveys[0].ID != id || surveys[0].Data["SAU"] != 80.5 {
+		t.Errorf("Expected survey %s with SAU 80.5, got %+v", id, surveys)
+	}
+
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("There were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestAgriculturalUnitSurveyStorage_Select_UnitSurveys(t *testing.T) {
+	mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+	if err != nil {
+		t.Fatalf("Failed to create mock querier: %v", err)
+	}
+	defer mockQuerierInstance.Db.Close()
+
+	now := time.Now().Truncate(time.Millisecond)
+	sqlMock.ExpectQuery(regexp.QuoteMeta("SELECT id, created_at, updated_at, archived_at, id_num, year, data, revision, source_url, source_file, source_version, schema_version FROM agricultural_unit_surveys WHERE archived_at IS NULL AND id_num IN ($1) ORDER BY id_num, year")).
+		WithArgs(101).
+		WillReturnRows(sqlMock.NewRows(agriculturalUnitSurveyTestColumns).
+			AddRow(uuid.New().String(), now, now, sql.NullTime{Valid: false}, 101, 2022, []byte("{}"), 1, "", "", "", "").
+			AddRow(uuid.New().String(), now, now, sql.NullTime{Valid: false}, 101, 2023, []byte("{}"), 1, "", "", "", ""))
+
+	surveys, err := NewAgriculturalUnitSurveyStorage(mockQuerierInstance).Select(SurveyQuery{IDNums: []int{101}})
+	if err != nil {
+		t.Fatalf("Select returned an unexpected error: %v", err)
+	}
+	if len(surveys) != 2 || surveys[0].Year != 2022 || surveys[1].Year != 2023 {
+		t.Errorf("Expected the 2022 and 2023 surveys of IDNUM 101, got %+v", surveys)
+	}
+
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("There were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestAgriculturalUnitSurveyStorage_Each(t *testing.T) {
+	mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+	if err != nil {
+		t.Fatalf("Failed to create mock querier: %v", err)
+	}
+	defer mockQuerierInstance.Db.Close()
+
+	now := time.Now().Truncate(time.Millisecond)
+	expectedSQL := "SELECT id, created_at, updated_at, archived_at, id_num, year, data, revision, source_url, source_file, source_version, schema_version FROM agricultural_unit_surveys " +
+		"WHERE archived_at IS NULL AND id_num IN ($1,$2) AND (id_num, year) > ($3, $4) ORDER BY id_num, year LIMIT 2"
+	sqlMock.ExpectQuery(regexp.QuoteMeta(expectedSQL)).
+		WithArgs(101, 102, 101, 2022).
+		WillReturnRows(sqlMock.NewRows(agriculturalUnitSurveyTestColumns).
+			AddRow(uuid.New().String(), now, now, sql.NullTime{Valid: false}, 101, 2023, []byte("{}"), 1, "", "", "", "").
+			AddRow(uuid.New().String(), now, now, sql.NullTime{Valid: false}, 102, 2022, []byte("{}"), 1, "", "", "", ""))
+
+	query := SurveyQuery{IDNums: []int{101, 102}, After: &SurveyKey{IDNum: 101, Year: 2022}, Limit: 2}
+	stop := errors.New("stop")
+	var visited []SurveyKey
+	err = NewAgriculturalUnitSurveyStorage(mockQuerierInstance).Each(query, func(survey AgriculturalUnitSurvey) error {
+		visited = append(visited, SurveyKey{IDNum: survey.IDNum, Year: survey.Year})
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("Expected Each to return the error of visit, got %v", err)
+	}
+	if len(visited) != 1 || visited[0] != (SurveyKey{IDNum: 101, Year: 2023}) {
+		t.Errorf("Expected Each to stop after the first survey, visited %v", visited)
+	}
+
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("There were unfulfilled expectations: %s", err)
+	}
+}
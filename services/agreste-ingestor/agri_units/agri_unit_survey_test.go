@@ -67,3 +67,25 @@ func TestCreateAgriculturalUnitSurvey(t *testing.T) {
 		t.Errorf("Expected ArchivedAt to be nil for a new record, but got %v", *newSurvey.ArchivedAt)
 	}
 }
+
+func TestAgriculturalUnitSurvey_Project(t *testing.T) {
+	survey := AgriculturalUnitSurvey{
+		ID:    uuid.New(),
+		IDNum: 101,
+		Year:  2023,
+		Data:  map[string]interface{}{"SAU": 80.5, "OTEFDA": "1500", "UGBTO": 12.0},
+	}
+
+	projected := survey.Project([]string{"SAU", "OTEFDA", "MISSING"})
+
+	expected := map[string]interface{}{"SAU": 80.5, "OTEFDA": "1500"}
+	if !reflect.DeepEqual(projected.Data, expected) {
+		t.Errorf("Data mismatch: Expected %v, got %v", expected, projected.Data)
+	}
+	if projected.ID != survey.ID || projected.IDNum != survey.IDNum || projected.Year != survey.Year {
+		t.Errorf("Project should keep the survey's other fields, got %+v", projected)
+	}
+	if len(survey.Data) != 3 {
+		t.Errorf("Project should not modify the original survey, got %v", survey.Data)
+	}
+}
@@ -0,0 +1,178 @@
+package agri_units
+
+import (
+	"common/storage"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// ArchiveReport counts the rows an archival, or its undoing, changed.
+type ArchiveReport struct {
+	Years   []int `json:"years,omitempty"`
+	Units   int64 `json:"units"`
+	Surveys int64 `json:"surveys"`
+}
+
+var archiveBuilder = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+const unitArchivedSurveysSQL = `archived_at = (SELECT u.archived_at FROM agricultural_units u WHERE u.id_num = agricultural_unit_surveys.id_num)`
+
+// ArchiveUnits archives the active units with the given IDNUMs and their active surveys.
+func ArchiveUnits(transactor storage.Transactor, idNums []int, at time.Time) (ArchiveReport, error) {
+	var report ArchiveReport
+	at = at.Truncate(time.Microsecond)
+
+	err := transactor.InTransaction(func(tx storage.DBQuerier) error {
+		units, err := execCount(tx, archiveBuilder.Update("agricultural_units").
+			Set("archived_at", at).
+			Set("updated_at", at).
+			Where(sq.Eq{"id_num": idNums, "archived_at": nil}))
+		if err != nil {
+			return fmt.Errorf("failed to archive agricultural units: %w", err)
+		}
+		report.Units = units
+
+		report.Surveys, err = archiveUnitSurveys(tx, at)
+		return err
+	})
+	if err != nil {
+		return ArchiveReport{}, err
+	}
+	return report, nil
+}
+
+// UnarchiveUnits restores the archived units with the given IDNUMs and the surveys archived with them.
+func UnarchiveUnits(transactor storage.Transactor, idNums []int, at time.Time) (ArchiveReport, error) {
+	var report ArchiveReport
+	at = at.Truncate(time.Microsecond)
+
+	err := transactor.InTransaction(func(tx storage.DBQuerier) error {
+		surveys, err := execCount(tx, archiveBuilder.Update("agricultural_unit_surveys").
+			Set("archived_at", nil).
+			Set("updated_at", at).
+			Where(sq.Eq{"id_num": idNums}).
+			Where(unitArchivedSurveysSQL))
+		if err != nil {
+			return fmt.Errorf("failed to unarchive agricultural unit surveys: %w", err)
+		}
+		report.Surveys = surveys
+
+		report.Units, err = execCount(tx, archiveBuilder.Update("agricultural_units").
+			Set("archived_at", nil).
+			Set("updated_at", at).
+			Where(sq.Eq{"id_num": idNums}).
+			Where(sq.NotEq{"archived_at": nil}))
+		if err != nil {
+			return fmt.Errorf("failed to unarchive agricultural units: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return ArchiveReport{}, err
+	}
+	return report, nil
+}
+
+// ArchiveAbsentUnits archives the active units absent from the latest years survey years.
+func ArchiveAbsentUnits(transactor storage.Transactor, years int, at time.Time) (ArchiveReport, error) {
+	if years <= 0 {
+		return ArchiveReport{}, fmt.Errorf("the number of survey years must be positive, got %d", years)
+	}
+	var report ArchiveReport
+	at = at.Truncate(time.Microsecond)
+
+	err := transactor.InTransaction(func(tx storage.DBQuerier) error {
+		latestYears, err := selectLatestSurveyYears(tx, years)
+		if err != nil {
+			return err
+		}
+		report.Years = latestYears
+		if len(latestYears) == 0 {
+			return nil
+		}
+
+		present, args, err := sq.Select("1").
+			From("agricultural_unit_surveys s").
+			Where("s.id_num = agricultural_units.id_num").
+			Where(sq.Eq{"s.year": latestYears}).
+			ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build survey presence subquery: %w", err)
+		}
+		report.Units, err = execCount(tx, archiveBuilder.Update("agricultural_units").
+			Set("archived_at", at).
+			Set("updated_at", at).
+			Where(sq.Eq{"archived_at": nil}).
+			Where("NOT EXISTS ("+present+")", args...))
+		if err != nil {
+			return fmt.Errorf("failed to archive agricultural units absent from %v: %w", latestYears, err)
+		}
+
+		report.Surveys, err = archiveUnitSurveys(tx, at)
+		return err
+	})
+	if err != nil {
+		return ArchiveReport{}, err
+	}
+	return report, nil
+}
+
+func archiveUnitSurveys(tx storage.DBQuerier, at time.Time) (int64, error) {
+	surveys, err := execCount(tx, archiveBuilder.Update("agricultural_unit_surveys").
+		Set("archived_at", at).
+		Set("updated_at", at).
+		Where(sq.Eq{"archived_at": nil}).
+		Where("id_num IN (SELECT id_num FROM agricultural_units WHERE archived_at = ?)", at))
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive the surveys of archived agricultural units: %w", err)
+	}
+	return surveys, nil
+}
+
+func selectLatestSurveyYears(tx storage.DBQuerier, years int) ([]int, error) {
+	query, args, err := archiveBuilder.Select("DISTINCT year").
+		From("agricultural_unit_surveys").
+		OrderBy("year DESC").
+		Limit(uint64(years)).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build latest survey years SQL: %w", err)
+	}
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select the latest survey years: %w", err)
+	}
+	defer rows.Close()
+
+	var latestYears []int
+	for rows.Next() {
+		var year int
+		if err := rows.Scan(&year); err != nil {
+			return nil, fmt.Errorf("failed to scan survey year: %w", err)
+		}
+		latestYears = append(latestYears, year)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during row iteration: %w", err)
+	}
+	return latestYears, nil
+}
+
+func execCount(tx storage.DBQuerier, builder sq.UpdateBuilder) (int64, error) {
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build SQL with squirrel: %w", err)
+	}
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count updated rows: %w", err)
+	}
+	return count, nil
+}
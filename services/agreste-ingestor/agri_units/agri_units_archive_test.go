@@ -0,0 +1,166 @@
+package agri_units
+
+import (
+	"common/testutils"
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	go_sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+const archiveUnitSurveysTestSQL = "UPDATE agricultural_unit_surveys SET archived_at = $1, updated_at = $2 WHERE archived_at IS NULL AND id_num IN (SELECT id_num FROM agricultural_units WHERE archived_at = $3)"
+
+func TestArchiveUnits(t *testing.T) {
+	mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+	if err != nil {
+		t.Fatalf("failed to create mock querier: %v", err)
+	}
+	defer mockQuerierInstance.Db.Close()
+
+	at := time.Date(2025, 3, 1, 10, 0, 0, 123456789, time.UTC)
+	stored := at.Truncate(time.Microsecond)
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta("UPDATE agricultural_units SET archived_at = $1, updated_at = $2 WHERE archived_at IS NULL AND id_num IN ($3,$4)")).
+		WithArgs(stored, stored, 101, 102).
+		WillReturnResult(go_sqlmock.NewResult(0, 2))
+	sqlMock.ExpectExec(regexp.QuoteMeta(archiveUnitSurveysTestSQL)).
+		WithArgs(stored, stored, stored).
+		WillReturnResult(go_sqlmock.NewResult(0, 5))
+	sqlMock.ExpectCommit()
+
+	report, err := ArchiveUnits(mockQuerierInstance, []int{101, 102}, at)
+	if err != nil {
+		t.Fatalf("ArchiveUnits returned an unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(report, ArchiveReport{Units: 2, Surveys: 5}) {
+		t.Errorf("expected 2 units and 5 surveys archived, got %+v", report)
+	}
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUnarchiveUnits(t *testing.T) {
+	mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+	if err != nil {
+		t.Fatalf("failed to create mock querier: %v", err)
+	}
+	defer mockQuerierInstance.Db.Close()
+
+	at := time.Date(2025, 3, 2, 10, 0, 0, 0, time.UTC)
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta("UPDATE agricultural_unit_surveys SET archived_at = $1, updated_at = $2 WHERE id_num IN ($3) AND "+unitArchivedSurveysSQL)).
+		WithArgs(nil, at, 101).
+		WillReturnResult(go_sqlmock.NewResult(0, 3))
+	sqlMock.ExpectExec(regexp.QuoteMeta("UPDATE agricultural_units SET archived_at = $1, updated_at = $2 WHERE id_num IN ($3) AND archived_at IS NOT NULL")).
+		WithArgs(nil, at, 101).
+		WillReturnResult(go_sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	report, err := UnarchiveUnits(mockQuerierInstance, []int{101}, at)
+	if err != nil {
+		t.Fatalf("UnarchiveUnits returned an unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(report, ArchiveReport{Units: 1, Surveys: 3}) {
+		t.Errorf("expected 1 unit and 3 surveys restored, got %+v", report)
+	}
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestArchiveAbsentUnits(t *testing.T) {
+	at := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	latestYearsSQL := "SELECT DISTINCT year FROM agricultural_unit_surveys ORDER BY year DESC LIMIT 2"
+
+	t.Run("Success", func(t *testing.T) {
+		mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+		if err != nil {
+			t.Fatalf("failed to create mock querier: %v", err)
+		}
+		defer mockQuerierInstance.Db.Close()
+
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectQuery(regexp.QuoteMeta(latestYearsSQL)).
+			WillReturnRows(sqlMock.NewRows([]string{"year"}).AddRow(2023).AddRow(2022))
+		sqlMock.ExpectExec(regexp.QuoteMeta("UPDATE agricultural_units SET archived_at = $1, updated_at = $2 WHERE archived_at IS NULL "+
+			"AND NOT EXISTS (SELECT 1 FROM agricultural_unit_surveys s WHERE s.id_num = agricultural_units.id_num AND s.year IN ($3,$4))")).
+			WithArgs(at, at, 2023, 2022).
+			WillReturnResult(go_sqlmock.NewResult(0, 4))
+		sqlMock.ExpectExec(regexp.QuoteMeta(archiveUnitSurveysTestSQL)).
+			WithArgs(at, at, at).
+			WillReturnResult(go_sqlmock.NewResult(0, 9))
+		sqlMock.ExpectCommit()
+
+		report, err := ArchiveAbsentUnits(mockQuerierInstance, 2, at)
+		if err != nil {
+			t.Fatalf("ArchiveAbsentUnits returned an unexpected error: %v", err)
+		}
+		expected := ArchiveReport{Years: []int{2023, 2022}, Units: 4, Surveys: 9}
+		if !reflect.DeepEqual(report, expected) {
+			t.Errorf("report mismatch. Expected %+v, got %+v", expected, report)
+		}
+		if err := sqlMock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("NoSurveys", func(t *testing.T) {
+		mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+		if err != nil {
+			t.Fatalf("failed to create mock querier: %v", err)
+		}
+		defer mockQuerierInstance.Db.Close()
+
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectQuery(regexp.QuoteMeta(latestYearsSQL)).
+			WillReturnRows(sqlMock.NewRows([]string{"year"}))
+		sqlMock.ExpectCommit()
+
+		report, err := ArchiveAbsentUnits(mockQuerierInstance, 2, at)
+		if err != nil {
+			t.Fatalf("ArchiveAbsentUnits returned an unexpected error: %v", err)
+		}
+		if report.Units != 0 || report.Surveys != 0 {
+			t.Errorf("nothing should be archived without surveys, got %+v", report)
+		}
+		if err := sqlMock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("RollsBackOnError", func(t *testing.T) {
+		mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+		if err != nil {
+			t.Fatalf("failed to create mock querier: %v", err)
+		}
+		defer mockQuerierInstance.Db.Close()
+
+		expectedError := errors.New("simulated update error")
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectQuery(regexp.QuoteMeta(latestYearsSQL)).
+			WillReturnRows(sqlMock.NewRows([]string{"year"}).AddRow(2023))
+		sqlMock.ExpectExec(regexp.QuoteMeta("UPDATE agricultural_units")).WillReturnResult(go_sqlmock.NewResult(0, 1))
+		sqlMock.ExpectExec(regexp.QuoteMeta("UPDATE agricultural_unit_surveys")).WillReturnError(expectedError)
+		sqlMock.ExpectRollback()
+
+		_, err = ArchiveAbsentUnits(mockQuerierInstance, 2, at)
+		if !errors.Is(err, expectedError) {
+			t.Errorf("expected error %v, got %v", expectedError, err)
+		}
+		if err := sqlMock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("InvalidYears", func(t *testing.T) {
+		if _, err := ArchiveAbsentUnits(nil, 0, at); err == nil {
+			t.Error("expected an error for 0 survey years")
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package agri_units
+
+import (
+	"common/storage"
+	"fmt"
+)
+
+// DedupReport counts what MergeDuplicates changed.
+type DedupReport struct {
+	UnitsRemoved     int64 `json:"unitsRemoved"`
+	SurveysRemoved   int64 `json:"surveysRemoved"`
+	SurveysMerged    int64 `json:"surveysMerged"`
+	WeatherRowsMoved int64 `json:"weatherRowsMoved"`
+}
+
+// The oldest row of each natural key is kept, so the UUID other services saw survives.
+const (
+	lockAgriUnitTablesSQL = `LOCK TABLE agricultural_units, agricultural_unit_surveys, weather IN SHARE ROW EXCLUSIVE MODE`
+
+	duplicateUnitsSQL = `
+		SELECT id, FIRST_VALUE(id) OVER (PARTITION BY id_num ORDER BY created_at, id) AS keep_id
+		FROM agricultural_units`
+
+	moveDuplicateUnitWeatherSQL = `
+		UPDATE weather w SET agricultural_unit_id = d.keep_id
+		FROM (` + duplicateUnitsSQL + `) d
+		WHERE w.agricultural_unit_id = d.id AND d.id <> d.keep_id`
+
+	deleteDuplicateUnitsSQL = `
+		DELETE FROM agricultural_units u
+		USING (` + duplicateUnitsSQL + `) d
+		WHERE u.id = d.id AND d.id <> d.keep_id`
+
+	mergeDuplicateSurveysSQL = `
+		UPDATE agricultural_unit_surveys s SET data = d.latest_data, updated_at = d.latest_updated_at
+		FROM (
+			SELECT id,
+				FIRST_VALUE(id) OVER (PARTITION BY id_num, year ORDER BY created_at, id) AS keep_id,
+				FIRST_VALUE(data) OVER (PARTITION BY id_num, year ORDER BY updated_at DESC, id) AS latest_data,
+				MAX(updated_at) OVER (PARTITION BY id_num, year) AS latest_updated_at,
+				COUNT(*) OVER (PARTITION BY id_num, year) AS copies
+			FROM agricultural_unit_surveys
+		) d
+		WHERE s.id = d.id AND d.id = d.keep_id AND d.copies > 1`
+
+	deleteDuplicateSurveysSQL = `
+		DELETE FROM agricultural_unit_surveys s
+		USING (
+			SELECT id, FIRST_VALUE(id) OVER (PARTITION BY id_num, year ORDER BY created_at, id) AS keep_id
+			FROM agricultural_unit_surveys
+		) d
+		WHERE s.id = d.id AND d.id <> d.keep_id`
+
+	addNaturalKeyConstraintsSQL = `
+		DO $$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'agricultural_units_id_num_key') THEN
+				ALTER TABLE agricultural_units ADD CONSTRAINT agricultural_units_id_num_key UNIQUE (id_num);
+			END IF;
+			IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'agricultural_unit_surveys_id_num_year_key') THEN
+				ALTER TABLE agricultural_unit_surveys ADD CONSTRAINT agricultural_unit_surveys_id_num_year_key UNIQUE (id_num, year);
+			END IF;
+		END
+		$$`
+)
+
+// MergeDuplicates collapses units and surveys sharing a natural key and adds its unique constraints.
+func MergeDuplicates(transactor storage.Transactor) (DedupReport, error) {
+	var report DedupReport
+
+	err := transactor.InTransaction(func(tx storage.DBQuerier) error {
+		if _, err := tx.Exec(lockAgriUnitTablesSQL); err != nil {
+			return fmt.Errorf("failed to lock agricultural unit tables: %w", err)
+		}
+
+		steps := []struct {
+			description string
+			query       string
+			count       *int64
+		}{
+			{"move weather rows of duplicate agricultural units", moveDuplicateUnitWeatherSQL, &report.WeatherRowsMoved},
+			{"delete duplicate agricultural units", deleteDuplicateUnitsSQL, &report.UnitsRemoved},
+			{"merge duplicate agricultural unit surveys", mergeDuplicateSurveysSQL, &report.SurveysMerged},
+			{"delete duplicate agricultural unit surveys", deleteDuplicateSurveysSQL, &report.SurveysRemoved},
+		}
+		for _, step := range steps {
+			result, err := tx.Exec(step.query)
+			if err != nil {
+				return fmt.Errorf("failed to %s: %w", step.description, err)
+			}
+			affected, err := result.RowsAffected()
+			if err != nil {
+				return fmt.Errorf("failed to count rows affected by '%s': %w", step.description, err)
+			}
+			*step.count = affected
+		}
+
+		if _, err := tx.Exec(addNaturalKeyConstraintsSQL); err != nil {
+			return fmt.Errorf("failed to add natural key constraints: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return DedupReport{}, fmt.Errorf("dedup rolled back: %w", err)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,73 @@
+package agri_units
+
+import (
+	"common/testutils"
+	"errors"
+	"regexp"
+	"testing"
+
+	go_sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMergeDuplicates(t *testing.T) {
+
+	t.Run("Success", func(t *testing.T) {
+		mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+		if err != nil {
+			t.Fatalf("failed to create mock querier: %v", err)
+		}
+		defer mockQuerierInstance.Db.Close()
+
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectExec(regexp.QuoteMeta(lockAgriUnitTablesSQL)).WillReturnResult(go_sqlmock.NewResult(0, 0))
+		sqlMock.ExpectExec(regexp.QuoteMeta("UPDATE weather w SET agricultural_unit_id = d.keep_id")).WillReturnResult(go_sqlmock.NewResult(0, 4))
+		sqlMock.ExpectExec(regexp.QuoteMeta("DELETE FROM agricultural_units u")).WillReturnResult(go_sqlmock.NewResult(0, 2))
+		sqlMock.ExpectExec(regexp.QuoteMeta("UPDATE agricultural_unit_surveys s SET data = d.latest_data")).WillReturnResult(go_sqlmock.NewResult(0, 3))
+		sqlMock.ExpectExec(regexp.QuoteMeta("DELETE FROM agricultural_unit_surveys s")).WillReturnResult(go_sqlmock.NewResult(0, 5))
+		sqlMock.ExpectExec(regexp.QuoteMeta("ADD CONSTRAINT agricultural_units_id_num_key UNIQUE (id_num)")).WillReturnResult(go_sqlmock.NewResult(0, 0))
+		sqlMock.ExpectCommit()
+
+		report, err := MergeDuplicates(mockQuerierInstance)
+		if err != nil {
+			t.Fatalf("MergeDuplicates returned an unexpected error: %v", err)
+		}
+
+		expected := DedupReport{UnitsRemoved: 2, SurveysRemoved: 5, SurveysMerged: 3, WeatherRowsMoved: 4}
+		if report != expected {
+			t.Errorf("report mismatch. Expected %+v, got %+v", expected, report)
+		}
+		if err := sqlMock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("RollsBackOnError", func(t *testing.T) {
+		mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+		if err != nil {
+			t.Fatalf("failed to create mock querier: %v", err)
+		}
+		defer mockQuerierInstance.Db.Close()
+
+		expectedError := errors.New("could not create unique index")
+
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectExec(regexp.QuoteMeta(lockAgriUnitTablesSQL)).WillReturnResult(go_sqlmock.NewResult(0, 0))
+		sqlMock.ExpectExec(regexp.QuoteMeta("UPDATE weather w")).WillReturnResult(go_sqlmock.NewResult(0, 0))
+		sqlMock.ExpectExec(regexp.QuoteMeta("DELETE FROM agricultural_units u")).WillReturnResult(go_sqlmock.NewResult(0, 1))
+		sqlMock.ExpectExec(regexp.QuoteMeta("UPDATE agricultural_unit_surveys s")).WillReturnResult(go_sqlmock.NewResult(0, 0))
+		sqlMock.ExpectExec(regexp.QuoteMeta("DELETE FROM agricultural_unit_surveys s")).WillReturnResult(go_sqlmock.NewResult(0, 0))
+		sqlMock.ExpectExec(regexp.QuoteMeta("ADD CONSTRAINT")).WillReturnError(expectedError)
+		sqlMock.ExpectRollback()
+
+		report, err := MergeDuplicates(mockQuerierInstance)
+		if !errors.Is(err, expectedError) {
+			t.Errorf("expected error %v, got %v", expectedError, err)
+		}
+		if report != (DedupReport{}) {
+			t.Errorf("a rolled back dedup should report nothing, got %+v", report)
+		}
+		if err := sqlMock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
@@ -1,157 +1,643 @@
 package agri_units
 
 import (
+	"agreste-ingestor/anonymization"
+	"agreste-ingestor/filters"
 	"agreste-ingestor/misc"
+	"agreste-ingestor/schema"
+	"agreste-ingestor/sources"
+	"common/agri"
+	"common/storage"
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	HeaderIDNum  = "IDNUM"
+	HeaderYear   = "MILEX"
+	HeaderOTEFDD = "OTEFDD"
 )
 
+// DefaultFilterExpression keeps cereal and oilseed specialist farms.
+const DefaultFilterExpression = "OTEFDD in (1500)"
+
+// IngestBatchSize bounds the rows, units and surveys held before they are written.
+const IngestBatchSize = 500
+
+// IngestSummary counts what happened to the rows of a run.
+type IngestSummary struct {
+	RowsRead         int    `json:"rowsRead"`
+	RowsFiltered     int    `json:"rowsFiltered"`
+	RowsRejected     int    `json:"rowsRejected"`
+	UnitsCreated     int    `json:"unitsCreated"`
+	UnitsRelocated   int    `json:"unitsRelocated"`
+	SurveysCreated   int    `json:"surveysCreated"`
+	SurveysRevised   int    `json:"surveysRevised"`
+	SurveysUnchanged int    `json:"surveysUnchanged"`
+	SourceSHA256     string `json:"sourceSha256,omitempty"`
+	// UnchangedSince is the run that already ingested the file, when this one was skipped.
+	UnchangedSince *uuid.UUID `json:"unchangedSince,omitempty"`
+	// Dialect is how the CSV files were written, nil when they differ.
+	Dialect *misc.CSVDialect `json:"dialect,omitempty"`
+	// Files details the counts of each CSV the run read.
+	Files []IngestFileSummary `json:"files,omitempty"`
+}
+
+// IngestFileSummary is the outcome of one CSV of a run.
+type IngestFileSummary struct {
+	File string `json:"file"`
+	IngestSummary
+	Error string `json:"error,omitempty"`
+}
+
+func (s *IngestSummary) add(file IngestSummary) {
+	s.RowsRead += file.RowsRead
+	s.RowsFiltered += file.RowsFiltered
+	s.RowsRejected += file.RowsRejected
+	s.UnitsCreated += file.UnitsCreated
+	s.UnitsRelocated += file.UnitsRelocated
+	s.SurveysCreated += file.SurveysCreated
+	s.SurveysRevised += file.SurveysRevised
+	s.SurveysUnchanged += file.SurveysUnchanged
+}
+
+// ErrSourceChecksumMismatch fails runs whose file is not the expected one.
+var ErrSourceChecksumMismatch = errors.New("source checksum mismatch")
+
+type IngestMode string
+
+const (
+	// IngestModeInsert only adds surveys not stored yet.
+	IngestModeInsert IngestMode = "insert"
+	// IngestModeRevise also writes a new revision of the stored surveys whose data changed.
+	IngestModeRevise IngestMode = "revise"
+)
+
+// ParseIngestMode accepts an empty value as IngestModeInsert.
+func ParseIngestMode(value string) (IngestMode, error) {
+	switch IngestMode(value) {
+	case "", IngestModeInsert:
+		return IngestModeInsert, nil
+	case IngestModeRevise:
+		return IngestModeRevise, nil
+	}
+	return "", fmt.Errorf("unknown ingest mode '%s', expected '%s' or '%s'", value, IngestModeInsert, IngestModeRevise)
+}
+
+// UnitLocator gives a unit its published coordinates.
+type UnitLocator interface {
+	Locate(idNum int, row anonymization.RowValues) (latitude, longitude float64, err error)
+}
+
+// SurveyFileOpener fetches a survey file from its URI and opens its CSV rows.
+type SurveyFileOpener interface {
+	Fetch(uri string, progress sources.ProgressFunc) (sources.Artifact, error)
+	MatchMembers(artifact sources.Artifact, patterns []string) ([]string, error)
+	OpenArtifact(artifact sources.Artifact, member string, dialect misc.CSVDialect) (*misc.CSVStream, error)
+}
+
+// SourceRun is a past successful run and the checksum of the file it read.
+type SourceRun struct {
+	RunID  uuid.UUID
+	SHA256 string
+}
+
+type IngestOptions struct {
+	Filter  *filters.Filter
+	Locator UnitLocator
+	// RelocateUnits moves stored units whose coordinates differ from the locator's.
+	RelocateUnits bool
+	Opener        SurveyFileOpener
+	Progress      sources.ProgressFunc
+	// Dialect overrides the fields it sets of the detected dialect.
+	Dialect misc.CSVDialect
+	// Dictionaries types the columns of each row by its survey year.
+	Dictionaries *schema.Registry
+	Mode         IngestMode
+	// Source is recorded on every survey revision the run writes.
+	Source SurveySource
+	// RunID tags the rejections of the run.
+	RunID          uuid.UUID
+	ExpectedSHA256 string
+	// PreviousRun is the last successful run of the same source, skipped when the file is unchanged.
+	PreviousRun *SourceRun
+}
+
+type SurveyRowReader interface {
+	Header() []string
+	HasColumn(column string) bool
+	Dialect() misc.CSVDialect
+	Next() (misc.CSVRecord, error)
+}
+
+// HandleAgriUnitSurveyIngest ingests each CSV of the survey file matching patterns in its own transaction.
 func HandleAgriUnitSurveyIngest(
-	endpointURL string,
-	fileName string,
-	agriUnitStorage AgriUnitStorage,
+	sourceURI string,
+	patterns []string,
+	options IngestOptions,
+	transactor storage.Transactor,
+	agriUnitStorage agri.AgriUnitStorage,
 	agriUnitSurveyStorage AgriculturalUnitSurveyStorage,
-) error {
-	fmt.Printf("Starting ingestion process for endpoint: %s\n", endpointURL)
+	agriUnitSurveyRevisionStorage AgriculturalUnitSurveyRevisionStorage,
+	ingestRejectionStorage IngestRejectionStorage,
+) (IngestSummary, error) {
+	fmt.Printf("Starting ingestion process for source: %s\n", sourceURI)
 
-	existingAgriUnits, err := agriUnitStorage.SelectAll()
-	if err != nil {
-		return fmt.Errorf("failed to select all agricultural units: %w", err)
+	if options.Opener == nil {
+		return IngestSummary{}, errors.New("ingest requires a survey file opener")
 	}
-	fmt.Printf("Fetched %d existing agricultural units.\n", len(existingAgriUnits))
 
-	existingAgriUnitSurveys, err := agriUnitSurveyStorage.SelectAll()
+	artifact, err := options.Opener.Fetch(sourceURI, options.Progress)
 	if err != nil {
-		return fmt.Errorf("failed to select all agricultural unit surveys: %w", err)
+		return IngestSummary{}, fmt.Errorf("failed to fetch csv survey: %w", err)
+	}
+	summary := IngestSummary{SourceSHA256: artifact.SHA256}
+	if artifact.Cached {
+		fmt.Printf("survey not modified, using the copy cached at %s\n", artifact.FetchedAt.Format(time.RFC3339))
+	}
+	fmt.Printf("survey fetched, sha256 %s\n", artifact.SHA256)
+
+	if options.ExpectedSHA256 != "" && !strings.EqualFold(options.ExpectedSHA256, artifact.SHA256) {
+		return summary, fmt.Errorf("%w: expected %s, got %s", ErrSourceChecksumMismatch, options.ExpectedSHA256, artifact.SHA256)
+	}
+	if previous := options.PreviousRun; previous != nil && previous.SHA256 == artifact.SHA256 {
+		fmt.Printf("source unchanged since run %s, skipping ingestion\n", previous.RunID)
+		summary.UnchangedSince = &previous.RunID
+		return summary, nil
 	}
-	fmt.Printf("Fetched %d existing agricultural unit surveys.\n", len(existingAgriUnitSurveys))
 
-	agriUnitSurveyCSVData, err := misc.DownloadZipAndReadSpecificCSV(endpointURL, fileName)
+	members, err := options.Opener.MatchMembers(artifact, patterns)
 	if err != nil {
-		return fmt.Errorf("failed to fetch csv survey: %w", err)
+		return summary, fmt.Errorf("failed to select csv survey files: %w", err)
 	}
-	fmt.Printf("survey fetched")
 
-	agriUnitsByIDNum := make(map[int]AgriculturalUnit)
-	for _, unit := range existingAgriUnits {
-		agriUnitsByIDNum[unit.IDNum] = unit
+	var failures []error
+	for _, member := range members {
+		fileOptions := options
+		if fileOptions.Source.URL == "" {
+			fileOptions.Source = SurveySource{URL: sourceURI, FileName: member, Version: SourceVersion(sourceFileName(sourceURI))}
+			if member == "" {
+				fileOptions.Source.FileName = sourceFileName(sourceURI)
+			}
+		}
+		fmt.Printf("Ingesting csv survey file %s\n", fileOptions.Source.FileName)
+
+		fileSummary, err := ingestSurveyFile(artifact, member, fileOptions, transactor, agriUnitStorage, agriUnitSurveyStorage, agriUnitSurveyRevisionStorage, ingestRejectionStorage)
+		result := IngestFileSummary{File: fileOptions.Source.FileName, IngestSummary: fileSummary}
+		if err != nil {
+			result.Error = err.Error()
+			failures = append(failures, fmt.Errorf("%s: %w", result.File, err))
+		}
+		summary.add(fileSummary)
+		summary.Files = append(summary.Files, result)
 	}
 
-	agriUnitSurveysByIDNumAndYear := make(map[string]AgriculturalUnitSurvey)
-	for _, survey := range existingAgriUnitSurveys {
-		compositeKey := fmt.Sprintf("%d_%d", survey.IDNum, survey.Year)
-		agriUnitSurveysByIDNumAndYear[compositeKey] = survey
+	summary.Dialect = commonDialect(summary.Files)
+
+	if len(failures) > 0 {
+		return summary, fmt.Errorf("failed to ingest %d of %d csv survey files: %w", len(failures), len(members), errors.Join(failures...))
 	}
+	return summary, nil
+}
 
-	headerRow := agriUnitSurveyCSVData[0]
-	headerMap := make(map[string]int)
-	for idx, colName := range headerRow {
-		headerMap[colName] = idx
+func ingestSurveyFile(
+	artifact sources.Artifact,
+	member string,
+	options IngestOptions,
+	transactor storage.Transactor,
+	agriUnitStorage agri.AgriUnitStorage,
+	agriUnitSurveyStorage AgriculturalUnitSurveyStorage,
+	agriUnitSurveyRevisionStorage AgriculturalUnitSurveyRevisionStorage,
+	ingestRejectionStorage IngestRejectionStorage,
+) (IngestSummary, error) {
+	stream, err := options.Opener.OpenArtifact(artifact, member, options.Dialect)
+	if err != nil {
+		return IngestSummary{}, fmt.Errorf("failed to open csv survey: %w", err)
 	}
+	defer stream.Close()
 
-	const (
-		HeaderIDNum  = "IDNUM"
-		HeaderYear   = "MILEX"
-		HeaderOTEFDD = "OTEFDD"
-	)
+	return IngestAgriUnitSurveyRows(stream, options, transactor, agriUnitStorage, agriUnitSurveyStorage, agriUnitSurveyRevisionStorage, ingestRejectionStorage)
+}
 
-	idNumColIdx, idNumExists := headerMap[HeaderIDNum]
-	if !idNumExists {
-		return fmt.Errorf("CSV header missing required column: %s", HeaderIDNum)
-	}
-	yearColIdx, yearExists := headerMap[HeaderYear]
-	if !yearExists {
-		return fmt.Errorf("CSV header missing required column: %s", HeaderYear)
+func commonDialect(files []IngestFileSummary) *misc.CSVDialect {
+	var common *misc.CSVDialect
+	for _, file := range files {
+		switch {
+		case file.Dialect == nil:
+		case common == nil:
+			common = file.Dialect
+		case *common != *file.Dialect:
+			return nil
+		}
 	}
-	otefddColIdx, otefddExists := headerMap[HeaderOTEFDD]
-	if !otefddExists {
-		return fmt.Errorf("CSV header missing required column: %s", HeaderOTEFDD)
+	return common
+}
+
+func sourceFileName(sourceURI string) string {
+	if parsed, err := url.Parse(sourceURI); err == nil {
+		return path.Base(parsed.Path)
 	}
+	return path.Base(sourceURI)
+}
 
-	var newAgriUnits []AgriculturalUnit
-	var newAgriUnitSurveys []AgriculturalUnitSurvey
-	seenIDNumsInCsv := make(map[int]struct{})
+// IngestAgriUnitSurveyRows writes the units and surveys of the matching rows in a single transaction.
+func IngestAgriUnitSurveyRows(
+	rows SurveyRowReader,
+	options IngestOptions,
+	transactor storage.Transactor,
+	agriUnitStorage agri.AgriUnitStorage,
+	agriUnitSurveyStorage AgriculturalUnitSurveyStorage,
+	agriUnitSurveyRevisionStorage AgriculturalUnitSurveyRevisionStorage,
+	ingestRejectionStorage IngestRejectionStorage,
+) (IngestSummary, error) {
+	var summary IngestSummary
 
-	for i, row := range agriUnitSurveyCSVData {
-		if i == 0 {
-			continue
-		}
+	if options.Locator == nil {
+		return summary, errors.New("ingest requires a unit locator")
+	}
+	if options.Dictionaries == nil {
+		return summary, errors.New("ingest requires RICA dictionaries")
+	}
+	filter := options.Filter
+	mode, err := ParseIngestMode(string(options.Mode))
+	if err != nil {
+		return summary, err
+	}
 
-		idNumStr := row[idNumColIdx]
-		idNum, err := strconv.Atoi(idNumStr)
-		if err != nil {
-			fmt.Printf("Skipping row %d: Invalid %s '%s' - %v\n", i, HeaderIDNum, idNumStr, err)
-			continue
+	dialect := rows.Dialect()
+	summary.Dialect = &dialect
+	for _, column := range []string{HeaderIDNum, HeaderYear} {
+		if !rows.HasColumn(column) {
+			return summary, fmt.Errorf("CSV header missing required column: %s (read as %s with delimiter '%s', set the dialect if it is wrong)", column, dialect.Encoding, dialect.Delimiter)
 		}
-		yearStr := row[yearColIdx]
-		year, err := strconv.Atoi(yearStr)
-		if err != nil {
-			fmt.Printf("Skipping row %d: Invalid %s '%s' - %v\n", i, HeaderYear, yearStr, err)
-			continue
+	}
+	if err := filter.Validate(rows.Header()); err != nil {
+		return summary, err
+	}
+
+	header := rows.Header()
+	rowsByKey := make(map[string]int)
+	var batch *ingestBatch
+
+	err = transactor.InTransaction(func(tx storage.DBQuerier) error {
+		batch = newIngestBatch(
+			agriUnitStorage.WithQuerier(tx),
+			agriUnitSurveyStorage.WithQuerier(tx),
+			agriUnitSurveyRevisionStorage.WithQuerier(tx),
+			ingestRejectionStorage,
+		)
+
+		reject := func(record misc.CSVRecord, rejections ...IngestRejectionValue) {
+			summary.RowsRejected++
+			rawValues := rawRowValues(header, record.Values)
+			for _, rejection := range rejections {
+				rejection.RunID = options.RunID
+				rejection.File = options.Source.FileName
+				rejection.Row = record.Row
+				rejection.RawValues = rawValues
+				batch.reject(CreateIngestRejection(rejection))
+			}
 		}
 
-		val := row[otefddColIdx]
-		num, err := strconv.Atoi(val)
-		if err != nil || num != 1500 {
-			continue
+		flush := func() error {
+			unchanged, err := batch.resolve(mode, options, reject)
+			if err != nil {
+				return err
+			}
+			summary.SurveysUnchanged += unchanged
+			return batch.flush()
 		}
 
-		if _, seen := seenIDNumsInCsv[idNum]; !seen {
-			seenIDNumsInCsv[idNum] = struct{}{}
-			if _, exists := agriUnitsByIDNum[idNum]; !exists {
-				lat, lon := misc.GenerateRandomCoordinates(0, 0, 0, 0)
-				newUnit := CreateAgriculturalUnit(AgriculturalUnitValue{
-					IDNum:     idNum,
-					Latitude:  lat,
-					Longitude: lon,
+		for {
+			record, err := rows.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read csv survey: %w", err)
+			}
+			summary.RowsRead++
+
+			idNumStr, _ := record.Get(HeaderIDNum)
+			idNum, err := strconv.Atoi(idNumStr)
+			if err != nil {
+				reject(record, IngestRejectionValue{Column: HeaderIDNum, Value: idNumStr, Reason: RejectionInvalidIDNum, Message: "not an integer"})
+				continue
+			}
+			yearStr, _ := record.Get(HeaderYear)
+			year, err := strconv.Atoi(yearStr)
+			if err != nil {
+				reject(record, IngestRejectionValue{Column: HeaderYear, Value: yearStr, Reason: RejectionInvalidYear, Message: "not an integer"})
+				continue
+			}
+
+			if !filter.Match(record) {
+				summary.RowsFiltered++
+				continue
+			}
+
+			compositeKey := surveyKey(idNum, year)
+			if firstRow, seen := rowsByKey[compositeKey]; seen {
+				reject(record, IngestRejectionValue{
+					Column:  HeaderIDNum,
+					Value:   idNumStr,
+					Reason:  RejectionDuplicateKey,
+					Message: fmt.Sprintf("%s %d and %s %d were already read at row %d", HeaderIDNum, idNum, HeaderYear, year, firstRow),
 				})
-				newAgriUnits = append(newAgriUnits, newUnit)
+				continue
+			}
+			rowsByKey[compositeKey] = record.Row
+
+			dictionary, err := options.Dictionaries.ForYear(year)
+			if err != nil {
+				reject(record, IngestRejectionValue{Column: HeaderYear, Value: yearStr, Reason: RejectionNoDictionary, Message: err.Error()})
+				continue
+			}
+			data, issues := dictionary.ParseRow(header, record.Values, dialect.DecimalSeparator)
+			if len(issues) > 0 {
+				rejections := make([]IngestRejectionValue, len(issues))
+				for i, issue := range issues {
+					rejections[i] = IngestRejectionValue{Column: issue.Column, Value: issue.Value, Reason: RejectionInvalidValue, Message: issue.Reason}
+				}
+				reject(record, rejections...)
+				continue
+			}
+
+			batch.addRow(pendingSurveyRow{record: record, idNum: idNum, year: year, data: data, schemaVersion: dictionary.Version})
+
+			if batch.size() >= IngestBatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
 			}
 		}
 
-		compositeKey := fmt.Sprintf("%d_%d", idNum, year)
-
-		if _, exists := agriUnitSurveysByIDNumAndYear[compositeKey]; !exists {
-			surveyDataPayload := make(map[string]interface{})
-			for colName, colIdx := range headerMap {
-				if colIdx < len(row) {
-					valStr := row[colIdx]
-					var parsedValue interface{} = valStr
-					if f, err := strconv.ParseFloat(valStr, 64); err == nil {
-						parsedValue = f
-					} else if b, err := strconv.ParseBool(valStr); err == nil {
-						parsedValue = b
-					}
-					surveyDataPayload[colName] = parsedValue
+		return flush()
+	})
+	if err != nil {
+		if batch != nil {
+			err = errors.Join(err, batch.flushRejections())
+		}
+		return summary, fmt.Errorf("ingest rolled back after reading %d rows: %w", summary.RowsRead, err)
+	}
+	summary = batch.summarize(summary)
+
+	fmt.Printf("Matched %d stored agricultural units and %d stored surveys.\n", batch.unitsLookedUp, batch.surveysLookedUp)
+	fmt.Printf("Created %d new Agricultural Units.\n", summary.UnitsCreated)
+	fmt.Printf("Relocated %d existing Agricultural Units.\n", summary.UnitsRelocated)
+	fmt.Printf("Inserted %d new Agricultural Unit Surveys.\n", summary.SurveysCreated)
+	fmt.Printf("Revised %d existing Agricultural Unit Surveys.\n", summary.SurveysRevised)
+	fmt.Printf("Left %d existing Agricultural Unit Surveys unchanged.\n", summary.SurveysUnchanged)
+	fmt.Printf("Filtered out %d rows and rejected %d rows.\n", summary.RowsFiltered, summary.RowsRejected)
+
+	return summary, nil
+}
+
+func surveyKey(idNum, year int) string {
+	return fmt.Sprintf("%d_%d", idNum, year)
+}
+
+type ingestBatch struct {
+	agriUnitStorage               agri.AgriUnitStorage
+	agriUnitSurveyStorage         AgriculturalUnitSurveyStorage
+	agriUnitSurveyRevisionStorage AgriculturalUnitSurveyRevisionStorage
+	ingestRejectionStorage        IngestRejectionStorage
+
+	rows           []pendingSurveyRow
+	units          []agri.AgriculturalUnit
+	relocatedUnits int
+	surveys        []AgriculturalUnitSurvey
+	revisions      []AgriculturalUnitSurveyRevision
+	rejections     []IngestRejection
+
+	unitsCreated    int
+	unitsRelocated  int
+	surveysCreated  int
+	surveysRevised  int
+	unitsLookedUp   int
+	surveysLookedUp int
+
+	archivedUnits map[int]time.Time
+	locatedUnits  map[int]bool
+}
+
+type pendingSurveyRow struct {
+	record        misc.CSVRecord
+	idNum         int
+	year          int
+	data          map[string]interface{}
+	schemaVersion string
+}
+
+func newIngestBatch(
+	agriUnitStorage agri.AgriUnitStorage,
+	agriUnitSurveyStorage AgriculturalUnitSurveyStorage,
+	agriUnitSurveyRevisionStorage AgriculturalUnitSurveyRevisionStorage,
+	ingestRejectionStorage IngestRejectionStorage,
+) *ingestBatch {
+	return &ingestBatch{
+		agriUnitStorage:               agriUnitStorage,
+		agriUnitSurveyStorage:         agriUnitSurveyStorage,
+		agriUnitSurveyRevisionStorage: agriUnitSurveyRevisionStorage,
+		ingestRejectionStorage:        ingestRejectionStorage,
+		rows:                          make([]pendingSurveyRow, 0, IngestBatchSize),
+		units:                         make([]agri.AgriculturalUnit, 0, IngestBatchSize),
+		surveys:                       make([]AgriculturalUnitSurvey, 0, IngestBatchSize),
+		archivedUnits:                 make(map[int]time.Time),
+		locatedUnits:                  make(map[int]bool),
+	}
+}
+
+func (b *ingestBatch) addRow(row pendingSurveyRow) {
+	b.rows = append(b.rows, row)
+}
+
+func (b *ingestBatch) resolve(mode IngestMode, options IngestOptions, reject func(record misc.CSVRecord, rejections ...IngestRejectionValue)) (int, error) {
+	if len(b.rows) == 0 {
+		return 0, nil
+	}
+
+	unitIDNums := make(map[int]bool)
+	surveyIDNums := make(map[int]bool)
+	surveyYears := make(map[int]bool)
+	for _, row := range b.rows {
+		if !b.locatedUnits[row.idNum] {
+			unitIDNums[row.idNum] = true
+		}
+		surveyIDNums[row.idNum] = true
+		surveyYears[row.year] = true
+	}
+
+	storedUnits := make(map[int]agri.AgriculturalUnit)
+	if len(unitIDNums) > 0 {
+		err := b.agriUnitStorage.Each(agri.AgriUnitQuery{IDNums: sortedKeys(unitIDNums), Archived: agri.ArchivedIncluded}, func(unit agri.AgriculturalUnit) error {
+			storedUnits[unit.IDNum] = unit
+			return nil
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to look up %d agricultural units: %w", len(unitIDNums), err)
+		}
+		b.unitsLookedUp += len(storedUnits)
+	}
+
+	storedSurveys := make(map[string]AgriculturalUnitSurvey)
+	surveyQuery := SurveyQuery{IDNums: sortedKeys(surveyIDNums), Years: sortedKeys(surveyYears), Archived: agri.ArchivedIncluded}
+	err := b.agriUnitSurveyStorage.Each(surveyQuery, func(survey AgriculturalUnitSurvey) error {
+		storedSurveys[surveyKey(survey.IDNum, survey.Year)] = survey
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up agricultural unit surveys of %d rows: %w", len(b.rows), err)
+	}
+
+	unchanged := 0
+	for _, row := range b.rows {
+		if !b.locatedUnits[row.idNum] {
+			storedUnit, exists := storedUnits[row.idNum]
+			if !exists || options.RelocateUnits {
+				latitude, longitude, err := options.Locator.Locate(row.idNum, row.record)
+				if err != nil {
+					idNumStr, _ := row.record.Get(HeaderIDNum)
+					reject(row.record, IngestRejectionValue{Column: HeaderIDNum, Value: idNumStr, Reason: RejectionNoLocation, Message: err.Error()})
+					continue
 				}
+				if !exists {
+					b.addUnit(agri.CreateAgriculturalUnit(agri.AgriculturalUnitValue{
+						IDNum:     row.idNum,
+						Latitude:  latitude,
+						Longitude: longitude,
+					}))
+				} else if storedUnit.Latitude != latitude || storedUnit.Longitude != longitude {
+					storedUnit.Latitude = latitude
+					storedUnit.Longitude = longitude
+					storedUnit.UpdatedAt = time.Now()
+					b.relocateUnit(storedUnit)
+				}
+			}
+			if exists && storedUnit.ArchivedAt != nil {
+				b.archivedUnits[row.idNum] = *storedUnit.ArchivedAt
 			}
+			b.locatedUnits[row.idNum] = true
+		}
 
-			newSurvey := CreateAgriculturalUnitSurvey(AgriculturalUnitSurveyValue{
-				IDNum: idNum,
-				Year:  year,
-				Data:  surveyDataPayload,
+		storedSurvey, exists := storedSurveys[surveyKey(row.idNum, row.year)]
+		switch {
+		case !exists:
+			survey := CreateAgriculturalUnitSurvey(AgriculturalUnitSurveyValue{
+				IDNum:         row.idNum,
+				Year:          row.year,
+				Data:          row.data,
+				Source:        options.Source,
+				SchemaVersion: row.schemaVersion,
 			})
-			newAgriUnitSurveys = append(newAgriUnitSurveys, newSurvey)
+			if archivedAt, archived := b.archivedUnits[row.idNum]; archived {
+				survey.ArchivedAt = &archivedAt
+			}
+			b.addSurvey(survey)
+		case mode == IngestModeRevise && len(DiffSurveyData(storedSurvey.Data, row.data)) > 0:
+			b.reviseSurvey(storedSurvey.Revise(row.data, options.Source, row.schemaVersion))
+		default:
+			unchanged++
 		}
+		if exists {
+			b.surveysLookedUp++
+		}
+	}
+
+	b.rows = b.rows[:0]
+	return unchanged, nil
+}
+
+func sortedKeys(set map[int]bool) []int {
+	keys := make([]int, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
 	}
+	sort.Ints(keys)
+	return keys
+}
 
-	fmt.Printf("Identified %d new Agricultural Units to potentially create.\n", len(newAgriUnits))
-	fmt.Printf("Identified %d new Agricultural Unit Surveys to insert.\n", len(newAgriUnitSurveys))
+func (b *ingestBatch) addUnit(unit agri.AgriculturalUnit) {
+	b.units = append(b.units, unit)
+}
 
-	for _, unit := range newAgriUnits {
-		err := agriUnitStorage.InsertOrUpdate(unit)
-		if err != nil {
-			fmt.Printf("Error inserting/updating AgriculturalUnit %d: %v\n", unit.IDNum, err)
-			return err
+func (b *ingestBatch) relocateUnit(unit agri.AgriculturalUnit) {
+	b.units = append(b.units, unit)
+	b.relocatedUnits++
+}
+
+func (b *ingestBatch) addSurvey(survey AgriculturalUnitSurvey) {
+	b.surveys = append(b.surveys, survey)
+}
+
+func (b *ingestBatch) reviseSurvey(survey AgriculturalUnitSurvey, previous AgriculturalUnitSurveyRevision) {
+	b.surveys = append(b.surveys, survey)
+	b.revisions = append(b.revisions, previous)
+}
+
+func (b *ingestBatch) reject(rejection IngestRejection) {
+	b.rejections = append(b.rejections, rejection)
+}
+
+func (b *ingestBatch) summarize(summary IngestSummary) IngestSummary {
+	summary.UnitsCreated = b.unitsCreated
+	summary.UnitsRelocated = b.unitsRelocated
+	summary.SurveysRevised = b.surveysRevised
+	summary.SurveysCreated = b.surveysCreated
+	return summary
+}
+
+func (b *ingestBatch) size() int {
+	return len(b.rows) + len(b.units) + len(b.surveys) + len(b.revisions) + len(b.rejections)
+}
+
+func (b *ingestBatch) flush() error {
+	if err := b.flushRejections(); err != nil {
+		return err
+	}
+	if len(b.units) > 0 {
+		if err := b.agriUnitStorage.InsertOrUpdateBatch(b.units); err != nil {
+			return fmt.Errorf("failed to insert %d agricultural units: %w", len(b.units), err)
 		}
+		b.unitsCreated += len(b.units) - b.relocatedUnits
+		b.unitsRelocated += b.relocatedUnits
 	}
-	for _, survey := range newAgriUnitSurveys {
-		err := agriUnitSurveyStorage.InsertOrUpdate(survey)
-		if err != nil {
-			fmt.Printf("Error inserting/updating AgriculturalUnitSurvey %d_%d: %v\n", survey.IDNum, survey.Year, err)
-			return err
+	if len(b.surveys) > 0 {
+		if err := b.agriUnitSurveyStorage.InsertOrUpdateBatch(b.surveys); err != nil {
+			return fmt.Errorf("failed to insert %d agricultural unit surveys: %w", len(b.surveys), err)
 		}
+		b.surveysCreated += len(b.surveys) - len(b.revisions)
+	}
+	if len(b.revisions) > 0 {
+		if err := b.agriUnitSurveyRevisionStorage.InsertBatch(b.revisions); err != nil {
+			return fmt.Errorf("failed to archive %d agricultural unit survey revisions: %w", len(b.revisions), err)
+		}
+		b.surveysRevised += len(b.revisions)
 	}
 
+	b.units = b.units[:0]
+	b.relocatedUnits = 0
+	b.surveys = b.surveys[:0]
+	b.revisions = b.revisions[:0]
+	return nil
+}
+
+func (b *ingestBatch) flushRejections() error {
+	if len(b.rejections) == 0 {
+		return nil
+	}
+	if err := b.ingestRejectionStorage.InsertBatch(b.rejections); err != nil {
+		return fmt.Errorf("failed to record %d ingest rejections: %w", len(b.rejections), err)
+	}
+	b.rejections = b.rejections[:0]
 	return nil
 }
@@ -1,6 +1,20 @@
 package agri_units
 
 import (
+	"agreste-ingestor/anonymization"
+	"agreste-ingestor/filters"
+	"agreste-ingestor/misc"
+	"agreste-ingestor/schema"
+	"agreste-ingestor/sources"
+	"common/agri"
+	"common/storage"
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -8,18 +22,65 @@ import (
 )
 
 type MockAgriUnitStorage struct {
-	Units []AgriculturalUnit
-	Error error
+	Units   []agri.AgriculturalUnit
+	Error   error
+	Queries []agri.AgriUnitQuery
 }
 
-func (m *MockAgriUnitStorage) SelectAll() ([]AgriculturalUnit, error) {
+func (m *MockAgriUnitStorage) SelectAll() ([]agri.AgriculturalUnit, error) {
 	if m.Error != nil {
 		return nil, m.Error
 	}
 	return m.Units, nil
 }
 
-func (m *MockAgriUnitStorage) InsertOrUpdate(unit AgriculturalUnit) error {
+// Select only honours the IDNUM set of query.
+func (m *MockAgriUnitStorage) Select(query agri.AgriUnitQuery) ([]agri.AgriculturalUnit, error) {
+	m.Queries = append(m.Queries, query)
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	var units []agri.AgriculturalUnit
+	for _, unit := range m.Units {
+		if query.IDNums == nil || slices.Contains(query.IDNums, unit.IDNum) {
+			units = append(units, unit)
+		}
+	}
+	return units, nil
+}
+
+func (m *MockAgriUnitStorage) Each(query agri.AgriUnitQuery, visit func(agri.AgriculturalUnit) error) error {
+	units, err := m.Select(query)
+	if err != nil {
+		return err
+	}
+	for _, unit := range units {
+		if err := visit(unit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MockAgriUnitStorage) SelectByID(id uuid.UUID) (agri.AgriculturalUnit, error) {
+	for _, unit := range m.Units {
+		if unit.ID == id {
+			return unit, nil
+		}
+	}
+	return agri.AgriculturalUnit{}, agri.ErrAgriculturalUnitNotFound
+}
+
+func (m *MockAgriUnitStorage) SelectByIDNum(idNum int) (agri.AgriculturalUnit, error) {
+	for _, unit := range m.Units {
+		if unit.IDNum == idNum {
+			return unit, nil
+		}
+	}
+	return agri.AgriculturalUnit{}, agri.ErrAgriculturalUnitNotFound
+}
+
+func (m *MockAgriUnitStorage) InsertOrUpdate(unit agri.AgriculturalUnit) error {
 	if m.Error != nil {
 		return m.Error
 	}
@@ -34,9 +95,23 @@ func (m *MockAgriUnitStorage) InsertOrUpdate(unit AgriculturalUnit) error {
 	return nil
 }
 
+func (m *MockAgriUnitStorage) InsertOrUpdateBatch(units []agri.AgriculturalUnit) error {
+	for _, unit := range units {
+		if err := m.InsertOrUpdate(unit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MockAgriUnitStorage) WithQuerier(querier storage.DBQuerier) agri.AgriUnitStorage {
+	return m
+}
+
 type MockAgriculturalUnitSurveyStorage struct {
 	Surveys []AgriculturalUnitSurvey
 	Error   error
+	Queries []SurveyQuery
 }
 
 func (m *MockAgriculturalUnitSurveyStorage) SelectAll() ([]AgriculturalUnitSurvey, error) {
@@ -61,29 +136,787 @@ func (m *MockAgriculturalUnitSurveyStorage) InsertOrUpdate(survey AgriculturalUn
 	return nil
 }
 
-var mockCSVData [][]string
-var mockCSVError error
+func (m *MockAgriculturalUnitSurveyStorage) InsertOrUpdateBatch(surveys []AgriculturalUnitSurvey) error {
+	for _, survey := range surveys {
+		if err := m.InsertOrUpdate(survey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-func MockDownloadZipAndReadSpecificCSV(endpointURL string, fileName string) ([][]string, error) {
-	return mockCSVData, mockCSVError
+// Select only honours the IDNUM and year sets of query.
+func (m *MockAgriculturalUnitSurveyStorage) Select(query SurveyQuery) ([]AgriculturalUnitSurvey, error) {
+	m.Queries = append(m.Queries, query)
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	var surveys []AgriculturalUnitSurvey
+	for _, survey := range m.Surveys {
+		if (query.IDNums == nil || slices.Contains(query.IDNums, survey.IDNum)) && (query.Years == nil || slices.Contains(query.Years, survey.Year)) {
+			surveys = append(surveys, survey)
+		}
+	}
+	return surveys, nil
+}
+
+func (m *MockAgriculturalUnitSurveyStorage) Each(query SurveyQuery, visit func(AgriculturalUnitSurvey) error) error {
+	surveys, err := m.Select(query)
+	if err != nil {
+		return err
+	}
+	for _, survey := range surveys {
+		if err := visit(survey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MockAgriculturalUnitSurveyStorage) SelectByID(id uuid.UUID) (AgriculturalUnitSurvey, error) {
+	for _, survey := range m.Surveys {
+		if survey.ID == id {
+			return survey, nil
+		}
+	}
+	return AgriculturalUnitSurvey{}, ErrAgriculturalUnitSurveyNotFound
+}
+
+func (m *MockAgriculturalUnitSurveyStorage) WithQuerier(querier storage.DBQuerier) AgriculturalUnitSurveyStorage {
+	return m
+}
+
+type MockAgriculturalUnitSurveyRevisionStorage struct {
+	Revisions []AgriculturalUnitSurveyRevision
+}
+
+func (m *MockAgriculturalUnitSurveyRevisionStorage) InsertBatch(revisions []AgriculturalUnitSurveyRevision) error {
+	m.Revisions = append(m.Revisions, revisions...)
+	return nil
+}
+
+func (m *MockAgriculturalUnitSurveyRevisionStorage) SelectBySurveyID(surveyID uuid.UUID) ([]AgriculturalUnitSurveyRevision, error) {
+	var revisions []AgriculturalUnitSurveyRevision
+	for _, revision := range m.Revisions {
+		if revision.SurveyID == surveyID {
+			revisions = append(revisions, revision)
+		}
+	}
+	return revisions, nil
+}
+
+func (m *MockAgriculturalUnitSurveyRevisionStorage) WithQuerier(querier storage.DBQuerier) AgriculturalUnitSurveyRevisionStorage {
+	return m
+}
+
+type MockIngestRejectionStorage struct {
+	Rejections []IngestRejection
+}
+
+func (m *MockIngestRejectionStorage) InsertBatch(rejections []IngestRejection) error {
+	m.Rejections = append(m.Rejections, rejections...)
+	return nil
+}
+
+func (m *MockIngestRejectionStorage) SelectByRunID(runID uuid.UUID) ([]IngestRejection, error) {
+	var rejections []IngestRejection
+	for _, rejection := range m.Rejections {
+		if rejection.RunID == runID {
+			rejections = append(rejections, rejection)
+		}
+	}
+	return rejections, nil
+}
+
+func (m *MockIngestRejectionStorage) WithQuerier(querier storage.DBQuerier) IngestRejectionStorage {
+	return m
+}
+
+type MockTransactor struct {
+	Committed  int
+	RolledBack int
+}
+
+func (m *MockTransactor) InTransaction(fn func(tx storage.DBQuerier) error) error {
+	if err := fn(nil); err != nil {
+		m.RolledBack++
+		return err
+	}
+	m.Committed++
+	return nil
+}
+
+// MockUnitLocator places units on a line so tests can predict coordinates.
+type MockUnitLocator struct{}
+
+func (MockUnitLocator) Locate(idNum int, row anonymization.RowValues) (latitude, longitude float64, err error) {
+	if idNum < 0 {
+		return 0, 0, anonymization.ErrRegionNotSampled
+	}
+	return 45 + float64(idNum)/1000, 2, nil
+}
+
+func defaultTestOptions(t *testing.T) IngestOptions {
+	filter, err := filters.Parse(DefaultFilterExpression)
+	if err != nil {
+		t.Fatalf("failed to parse default filter: %v", err)
+	}
+	return IngestOptions{Filter: filter, Locator: MockUnitLocator{}, Dictionaries: testDictionaries(t)}
+}
+
+func testDictionaries(t *testing.T) *schema.Registry {
+	registry, err := schema.LoadRegistry("")
+	if err != nil {
+		t.Fatalf("failed to load RICA dictionaries: %v", err)
+	}
+	return registry
 }
 
 func TestHandleAgriUnitSurveyIngest_Success(t *testing.T) {
-	existingUnit1 := AgriculturalUnit{ID: uuid.New(), IDNum: 101, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	existingUnit1 := agri.AgriculturalUnit{ID: uuid.New(), IDNum: 101, CreatedAt: time.Now(), UpdatedAt: time.Now()}
 	existingSurvey1 := AgriculturalUnitSurvey{ID: uuid.New(), IDNum: 101, Year: 2023, Data: map[string]interface{}{"foo": "bar"}, CreatedAt: time.Now(), UpdatedAt: time.Now()}
 
 	mockAgriUnitStorage := &MockAgriUnitStorage{
-		Units: []AgriculturalUnit{existingUnit1},
+		Units: []agri.AgriculturalUnit{existingUnit1},
 	}
 	mockAgriUnitSurveyStorage := &MockAgriculturalUnitSurveyStorage{
 		Surveys: []AgriculturalUnitSurvey{existingSurvey1},
 	}
 
 	agresteZipURL := "https://agreste.agriculture.gouv.fr/agreste-web/download/service/SV-Accès micro données RICA/RicaMicrodonnées2023_v2.zip"
-	err := HandleAgriUnitSurveyIngest(agresteZipURL, "Rica_France_micro_Donnees_ex2023.csv", mockAgriUnitStorage, mockAgriUnitSurveyStorage)
+	options := defaultTestOptions(t)
+	options.Opener = sources.NewOpener(sources.Config{})
+	_, err := HandleAgriUnitSurveyIngest(agresteZipURL, []string{"Rica_France_micro_Donnees_ex2023.csv"}, options, &MockTransactor{}, mockAgriUnitStorage, mockAgriUnitSurveyStorage, &MockAgriculturalUnitSurveyRevisionStorage{}, &MockIngestRejectionStorage{})
+
+	if err != nil {
+		t.Fatalf("HandleAgriUnitSurveyIngest failed with error: %v", err)
+	}
+
+}
+
+// mockSurveyFileOpener serves content as a plain CSV, or members as the files of an archive.
+type mockSurveyFileOpener struct {
+	content  string
+	members  map[string]string
+	sha256   string
+	uri      string
+	patterns []string
+	opened   []string
+}
+
+func (m *mockSurveyFileOpener) Fetch(uri string, progress sources.ProgressFunc) (sources.Artifact, error) {
+	m.uri = uri
+	return sources.Artifact{URI: uri, SHA256: m.sha256}, nil
+}
+
+func (m *mockSurveyFileOpener) MatchMembers(artifact sources.Artifact, patterns []string) ([]string, error) {
+	m.patterns = patterns
+	if m.members == nil {
+		return []string{""}, nil
+	}
+	var names []string
+	for name := range m.members {
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, name); matched {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (m *mockSurveyFileOpener) OpenArtifact(artifact sources.Artifact, member string, dialect misc.CSVDialect) (*misc.CSVStream, error) {
+	m.opened = append(m.opened, member)
+	content := m.content
+	if m.members != nil {
+		content = m.members[member]
+	}
+	return misc.NewCSVStream(strings.NewReader(content), ';')
+}
+
+func TestHandleAgriUnitSurveyIngest_Opener(t *testing.T) {
+	opener := &mockSurveyFileOpener{content: "IDNUM;MILEX;OTEFDD\n101;2023;1500\n"}
+	options := defaultTestOptions(t)
+	options.Opener = opener
+	mockAgriUnitSurveyStorage := &MockAgriculturalUnitSurveyStorage{}
+
+	summary, err := HandleAgriUnitSurveyIngest("s3://rica/extracts/rica2023_v3.csv.gz", nil, options, &MockTransactor{}, &MockAgriUnitStorage{}, mockAgriUnitSurveyStorage, &MockAgriculturalUnitSurveyRevisionStorage{}, &MockIngestRejectionStorage{})
+	if err != nil {
+		t.Fatalf("HandleAgriUnitSurveyIngest failed with error: %v", err)
+	}
+	if opener.uri != "s3://rica/extracts/rica2023_v3.csv.gz" || len(opener.opened) != 1 || opener.opened[0] != "" {
+		t.Errorf("unexpected opener calls (%s, %q)", opener.uri, opener.opened)
+	}
+	if summary.SurveysCreated != 1 {
+		t.Fatalf("expected 1 survey, got %+v", summary)
+	}
+
+	expectedSource := SurveySource{URL: "s3://rica/extracts/rica2023_v3.csv.gz", FileName: "rica2023_v3.csv.gz", Version: "v3"}
+	if source := mockAgriUnitSurveyStorage.Surveys[0].Source; source != expectedSource {
+		t.Errorf("expected source %+v, got %+v", expectedSource, source)
+	}
+}
+
+func TestHandleAgriUnitSurveyIngest_SourceChecksum(t *testing.T) {
+	previousRunID := uuid.New()
+	ingest := func(expected string, previous *SourceRun) (*mockSurveyFileOpener, IngestSummary, error) {
+		opener := &mockSurveyFileOpener{content: "IDNUM;MILEX;OTEFDD\n101;2023;1500\n", sha256: "ab12"}
+		options := defaultTestOptions(t)
+		options.Opener = opener
+		options.ExpectedSHA256 = expected
+		options.PreviousRun = previous
+		summary, err := HandleAgriUnitSurveyIngest("https://example.org/rica.csv", nil, options, &MockTransactor{}, &MockAgriUnitStorage{}, &MockAgriculturalUnitSurveyStorage{}, &MockAgriculturalUnitSurveyRevisionStorage{}, &MockIngestRejectionStorage{})
+		return opener, summary, err
+	}
+
+	opener, summary, err := ingest("", &SourceRun{RunID: previousRunID, SHA256: "ab12"})
+	if err != nil {
+		t.Fatalf("HandleAgriUnitSurveyIngest failed with error: %v", err)
+	}
+	if len(opener.opened) > 0 || summary.UnchangedSince == nil || *summary.UnchangedSince != previousRunID || summary.RowsRead != 0 {
+		t.Errorf("expected the run to be skipped as unchanged since %s, got %+v", previousRunID, summary)
+	}
 
+	opener, summary, err = ingest("AB12", &SourceRun{RunID: previousRunID, SHA256: "cd34"})
 	if err != nil {
 		t.Fatalf("HandleAgriUnitSurveyIngest failed with error: %v", err)
 	}
+	if len(opener.opened) == 0 || summary.UnchangedSince != nil || summary.SurveysCreated != 1 || summary.SourceSHA256 != "ab12" {
+		t.Errorf("expected a changed source to be ingested, got %+v", summary)
+	}
+
+	opener, summary, err = ingest("cd34", nil)
+	if !errors.Is(err, ErrSourceChecksumMismatch) {
+		t.Fatalf("expected ErrSourceChecksumMismatch, got %v", err)
+	}
+	if len(opener.opened) > 0 || summary.SourceSHA256 != "ab12" {
+		t.Errorf("expected the mismatching file to be left unread, got %+v", summary)
+	}
+}
+
+func TestHandleAgriUnitSurveyIngest_SeveralFiles(t *testing.T) {
+	opener := &mockSurveyFileOpener{
+		members: map[string]string{
+			"2022/rica.csv": "IDNUM;MILEX;OTEFDD\n101;2022;1500\n102;2022;4500\n",
+			"2023/rica.csv": "IDNUM;MILEX;OTEFDD\n101;2023;1500\n102;2023;1500\n",
+			"2024/rica.csv": "IDNUM;OTEFDD\n101;1500\n",
+		},
+		sha256: "ab12",
+	}
+	options := defaultTestOptions(t)
+	options.Opener = opener
+	transactor := &MockTransactor{}
+	mockAgriUnitSurveyStorage := &MockAgriculturalUnitSurveyStorage{}
+
+	summary, err := HandleAgriUnitSurveyIngest("https://example.org/rica_v2.zip", []string{"*/rica.csv"}, options, transactor, &MockAgriUnitStorage{}, mockAgriUnitSurveyStorage, &MockAgriculturalUnitSurveyRevisionStorage{}, &MockIngestRejectionStorage{})
+	if err == nil || !strings.Contains(err.Error(), "1 of 3") || !strings.Contains(err.Error(), "2024/rica.csv") {
+		t.Fatalf("expected the run to fail on 2024/rica.csv only, got %v", err)
+	}
+	if transactor.Committed != 2 {
+		t.Errorf("expected the two valid files to be committed, got %d commits", transactor.Committed)
+	}
+
+	if summary.RowsRead != 4 || summary.RowsFiltered != 1 || summary.SurveysCreated != 3 || summary.UnitsCreated != 2 || len(summary.Files) != 3 {
+		t.Fatalf("unexpected run summary %+v", summary)
+	}
+	if file := summary.Files[0]; file.File != "2022/rica.csv" || file.RowsRead != 2 || file.SurveysCreated != 1 || file.Error != "" {
+		t.Errorf("unexpected summary of 2022/rica.csv: %+v", file)
+	}
+	if file := summary.Files[2]; file.File != "2024/rica.csv" || !strings.Contains(file.Error, HeaderYear) {
+		t.Errorf("expected 2024/rica.csv to report the missing %s column, got %+v", HeaderYear, file)
+	}
+
+	expectedSource := SurveySource{URL: "https://example.org/rica_v2.zip", FileName: "2023/rica.csv", Version: "v2"}
+	if source := mockAgriUnitSurveyStorage.Surveys[len(mockAgriUnitSurveyStorage.Surveys)-1].Source; source != expectedSource {
+		t.Errorf("expected source %+v, got %+v", expectedSource, source)
+	}
+}
+
+func TestIngestAgriUnitSurveyRows(t *testing.T) {
+	existingUnit := agri.AgriculturalUnit{ID: uuid.New(), IDNum: 101, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	existingSurvey := AgriculturalUnitSurvey{ID: uuid.New(), IDNum: 101, Year: 2022, Data: map[string]interface{}{"foo": "bar"}, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+
+	mockAgriUnitStorage := &MockAgriUnitStorage{Units: []agri.AgriculturalUnit{existingUnit}}
+	mockAgriUnitSurveyStorage := &MockAgriculturalUnitSurveyStorage{Surveys: []AgriculturalUnitSurvey{existingSurvey}}
+
+	csvInput := strings.Join([]string{
+		"IDNUM;MILEX;OTEFDD;SAU",
+		"101;2022;1500;80.5",
+		"101;2023;1500;82",
+		"102;2023;1500;40",
+		"103;2023;4500;12",
+		"abc;2023;1500;1",
+		"104;20x3;1500;1",
+	}, "\n")
+
+	stream, err := misc.NewCSVStream(strings.NewReader(csvInput), ';')
+	if err != nil {
+		t.Fatalf("NewCSVStream returned an unexpected error: %v", err)
+	}
+
+	transactor := &MockTransactor{}
+	mockRejectionStorage := &MockIngestRejectionStorage{}
+	options := defaultTestOptions(t)
+	options.RunID = uuid.New()
+	summary, err := IngestAgriUnitSurveyRows(stream, options, transactor, mockAgriUnitStorage, mockAgriUnitSurveyStorage, &MockAgriculturalUnitSurveyRevisionStorage{}, mockRejectionStorage)
+	if err != nil {
+		t.Fatalf("IngestAgriUnitSurveyRows failed with error: %v", err)
+	}
+	if transactor.Committed != 1 || transactor.RolledBack != 0 {
+		t.Errorf("expected a single committed transaction, got %d commits and %d rollbacks", transactor.Committed, transactor.RolledBack)
+	}
+
+	expectedSummary := IngestSummary{RowsRead: 6, RowsFiltered: 1, RowsRejected: 2, UnitsCreated: 1, SurveysCreated: 2, SurveysUnchanged: 1, Dialect: &misc.CSVDialect{Encoding: misc.EncodingUTF8, Delimiter: ";", DecimalSeparator: ","}}
+	if !reflect.DeepEqual(summary, expectedSummary) {
+		t.Errorf("summary mismatch. Expected %+v, got %+v", expectedSummary, summary)
+	}
+
+	if len(mockRejectionStorage.Rejections) != 2 {
+		t.Fatalf("expected 2 rejections, got %d", len(mockRejectionStorage.Rejections))
+	}
+	rejection := mockRejectionStorage.Rejections[0]
+	if rejection.RunID != options.RunID || rejection.Row != 5 || rejection.Column != HeaderIDNum || rejection.Value != "abc" || rejection.Reason != RejectionInvalidIDNum {
+		t.Errorf("unexpected rejection of row 5: %+v", rejection)
+	}
+	if rejection.RawValues["SAU"] != "1" || rejection.RawValues["MILEX"] != "2023" {
+		t.Errorf("the rejection should keep the raw row, got %v", rejection.RawValues)
+	}
+	if rejection := mockRejectionStorage.Rejections[1]; rejection.Row != 6 || rejection.Reason != RejectionInvalidYear {
+		t.Errorf("unexpected rejection of row 6: %+v", rejection)
+	}
+
+	if len(mockAgriUnitStorage.Units) != 2 {
+		t.Fatalf("expected 2 agricultural units, got %d", len(mockAgriUnitStorage.Units))
+	}
+	if storedUnit := mockAgriUnitStorage.Units[0]; storedUnit != existingUnit {
+		t.Errorf("expected unit 101 to keep its coordinates without RelocateUnits, got %+v", storedUnit)
+	}
+	newUnit := mockAgriUnitStorage.Units[1]
+	if newUnit.IDNum != 102 || newUnit.Latitude != 45.102 || newUnit.Longitude != 2 {
+		t.Errorf("expected new unit 102 at (45.102, 2), got %+v", newUnit)
+	}
+
+	if len(mockAgriUnitSurveyStorage.Surveys) != 3 {
+		t.Fatalf("expected 3 surveys, got %d", len(mockAgriUnitSurveyStorage.Surveys))
+	}
+	if mockAgriUnitSurveyStorage.Surveys[0].Data["foo"] != "bar" {
+		t.Errorf("existing survey should not have been overwritten, got %v", mockAgriUnitSurveyStorage.Surveys[0].Data)
+	}
+	newSurvey := mockAgriUnitSurveyStorage.Surveys[1]
+	if newSurvey.IDNum != 101 || newSurvey.Year != 2023 {
+		t.Errorf("expected survey 101_2023, got %d_%d", newSurvey.IDNum, newSurvey.Year)
+	}
+	if newSurvey.Data["SAU"] != 82.0 {
+		t.Errorf("expected SAU to be parsed as 82.0, got %v", newSurvey.Data["SAU"])
+	}
+}
+
+func TestIngestAgriUnitSurveyRows_TypesColumns(t *testing.T) {
+	csvInput := strings.Join([]string{
+		"IDNUM;MILEX;OTEFDD;SAU;AGBIO;DEP;EXTRA",
+		"101;2023;1500;12,5;1;01;x",
+		"102;2023;1500;abc;0;02;y",
+		"103;2023;1500;4;;2A;z",
+	}, "\n")
+
+	stream, err := misc.NewCSVStream(strings.NewReader(csvInput), ';')
+	if err != nil {
+		t.Fatalf("NewCSVStream returned an unexpected error: %v", err)
+	}
+
+	mockAgriUnitStorage := &MockAgriUnitStorage{}
+	mockAgriUnitSurveyStorage := &MockAgriculturalUnitSurveyStorage{}
+	mockRejectionStorage := &MockIngestRejectionStorage{}
+	summary, err := IngestAgriUnitSurveyRows(stream, defaultTestOptions(t), &MockTransactor{}, mockAgriUnitStorage, mockAgriUnitSurveyStorage, &MockAgriculturalUnitSurveyRevisionStorage{}, mockRejectionStorage)
+	if err != nil {
+		t.Fatalf("IngestAgriUnitSurveyRows failed with error: %v", err)
+	}
+	if summary.UnitsCreated != 2 || summary.SurveysCreated != 2 || summary.RowsRejected != 1 {
+		t.Errorf("the row with an invalid SAU should be rejected, got %+v", summary)
+	}
+	if rejections := mockRejectionStorage.Rejections; len(rejections) != 1 || rejections[0].Column != "SAU" || rejections[0].Value != "abc" || rejections[0].Reason != RejectionInvalidValue {
+		t.Errorf("expected a rejection of SAU 'abc', got %+v", rejections)
+	}
 
+	survey := mockAgriUnitSurveyStorage.Surveys[0]
+	expected := map[string]interface{}{
+		"IDNUM": int64(101), "MILEX": int64(2023), "OTEFDD": "1500", "SAU": 12.5, "AGBIO": true, "DEP": "01", "EXTRA": "x",
+	}
+	if !reflect.DeepEqual(survey.Data, expected) {
+		t.Errorf("payload mismatch. Expected %v, got %v", expected, survey.Data)
+	}
+	if survey.SchemaVersion != "rica-2020.1" {
+		t.Errorf("expected the survey to record schema rica-2020.1, got %q", survey.SchemaVersion)
+	}
+	if data := mockAgriUnitSurveyStorage.Surveys[1].Data; data["AGBIO"] != nil || data["DEP"] != "2A" {
+		t.Errorf("expected an empty AGBIO to be null and DEP 2A to be kept, got %v", data)
+	}
+}
+
+func TestIngestAgriUnitSurveyRows_PreviousLayout(t *testing.T) {
+	stream, err := misc.NewCSVStream(strings.NewReader("IDNUM;MILEX;OTEFDD;SAU;DEP\n101;2015;1500;12,5;01\n"), ';')
+	if err != nil {
+		t.Fatalf("NewCSVStream returned an unexpected error: %v", err)
+	}
+
+	mockAgriUnitSurveyStorage := &MockAgriculturalUnitSurveyStorage{}
+	summary, err := IngestAgriUnitSurveyRows(stream, defaultTestOptions(t), &MockTransactor{}, &MockAgriUnitStorage{}, mockAgriUnitSurveyStorage, &MockAgriculturalUnitSurveyRevisionStorage{}, &MockIngestRejectionStorage{})
+	if err != nil {
+		t.Fatalf("IngestAgriUnitSurveyRows failed with error: %v", err)
+	}
+	if summary.SurveysCreated != 1 || summary.RowsRejected != 0 {
+		t.Fatalf("expected the 2015 row to be ingested, got %+v", summary)
+	}
+
+	survey := mockAgriUnitSurveyStorage.Surveys[0]
+	expected := map[string]interface{}{"IDNUM": int64(101), "MILEX": int64(2015), "OTEFDD": "1500", "SAU": 12.5, "DEP": "01"}
+	if !reflect.DeepEqual(survey.Data, expected) || survey.SchemaVersion != "rica-base.1" {
+		t.Errorf("expected %v parsed with rica-base.1, got %v with %q", expected, survey.Data, survey.SchemaVersion)
+	}
+}
+
+func TestIngestAgriUnitSurveyRows_DetectedDialect(t *testing.T) {
+	csvInput := "\xef\xbb\xbfIDNUM,MILEX,OTEFDD,SAU,LIB\r\n101,2023,1500,12.5,C\xf4te\r\n102,2023,1500,\"1,250\",x\r\n"
+	stream, err := misc.OpenCSVStream(strings.NewReader(csvInput), misc.CSVDialect{})
+	if err != nil {
+		t.Fatalf("OpenCSVStream returned an unexpected error: %v", err)
+	}
+
+	mockAgriUnitSurveyStorage := &MockAgriculturalUnitSurveyStorage{}
+	summary, err := IngestAgriUnitSurveyRows(stream, defaultTestOptions(t), &MockTransactor{}, &MockAgriUnitStorage{}, mockAgriUnitSurveyStorage, &MockAgriculturalUnitSurveyRevisionStorage{}, &MockIngestRejectionStorage{})
+	if err != nil {
+		t.Fatalf("IngestAgriUnitSurveyRows failed with error: %v", err)
+	}
+
+	expectedDialect := misc.CSVDialect{Encoding: misc.EncodingWindows1252, BOM: true, LineEnding: misc.LineEndingCRLF, Delimiter: ",", DecimalSeparator: "."}
+	if summary.Dialect == nil || *summary.Dialect != expectedDialect {
+		t.Errorf("expected dialect %+v, got %+v", expectedDialect, summary.Dialect)
+	}
+	if summary.SurveysCreated != 1 || summary.RowsRejected != 1 {
+		t.Errorf("expected the SAU with a thousands separator to be rejected, got %+v", summary)
+	}
+	if data := mockAgriUnitSurveyStorage.Surveys[0].Data; data["SAU"] != 12.5 || data["LIB"] != "Côte" {
+		t.Errorf("expected a decimal point and transcoded text, got %v", data)
+	}
+}
+
+func TestIngestAgriUnitSurveyRows_KeepsLocatedUnits(t *testing.T) {
+	existingUnit := agri.AgriculturalUnit{ID: uuid.New(), IDNum: 101, Latitude: 45.101, Longitude: 2, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	mockAgriUnitStorage := &MockAgriUnitStorage{Units: []agri.AgriculturalUnit{existingUnit}}
+
+	stream, err := misc.NewCSVStream(strings.NewReader("IDNUM;MILEX;OTEFDD\n101;2023;1500\n101;2024;1500\n"), ';')
+	if err != nil {
+		t.Fatalf("NewCSVStream returned an unexpected error: %v", err)
+	}
+
+	summary, err := IngestAgriUnitSurveyRows(stream, defaultTestOptions(t), &MockTransactor{}, mockAgriUnitStorage, &MockAgriculturalUnitSurveyStorage{}, &MockAgriculturalUnitSurveyRevisionStorage{}, &MockIngestRejectionStorage{})
+	if err != nil {
+		t.Fatalf("IngestAgriUnitSurveyRows failed with error: %v", err)
+	}
+	if summary.UnitsCreated != 0 || summary.UnitsRelocated != 0 {
+		t.Errorf("a unit already at its pseudo location should not be rewritten, got %+v", summary)
+	}
+	if !mockAgriUnitStorage.Units[0].UpdatedAt.Equal(existingUnit.UpdatedAt) {
+		t.Errorf("unit 101 should not have been updated")
+	}
+}
+
+func TestIngestAgriUnitSurveyRows_RelocateUnits(t *testing.T) {
+	existingUnit := agri.AgriculturalUnit{ID: uuid.New(), IDNum: 101, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	mockAgriUnitStorage := &MockAgriUnitStorage{Units: []agri.AgriculturalUnit{existingUnit}}
+
+	stream, err := misc.NewCSVStream(strings.NewReader("IDNUM;MILEX;OTEFDD\n101;2023;1500\n102;2023;1500\n"), ';')
+	if err != nil {
+		t.Fatalf("NewCSVStream returned an unexpected error: %v", err)
+	}
+
+	options := defaultTestOptions(t)
+	options.RelocateUnits = true
+	summary, err := IngestAgriUnitSurveyRows(stream, options, &MockTransactor{}, mockAgriUnitStorage, &MockAgriculturalUnitSurveyStorage{}, &MockAgriculturalUnitSurveyRevisionStorage{}, &MockIngestRejectionStorage{})
+	if err != nil {
+		t.Fatalf("IngestAgriUnitSurveyRows failed with error: %v", err)
+	}
+	if summary.UnitsCreated != 1 || summary.UnitsRelocated != 1 {
+		t.Errorf("expected 1 created and 1 relocated unit, got %+v", summary)
+	}
+	relocatedUnit := mockAgriUnitStorage.Units[0]
+	if relocatedUnit.ID != existingUnit.ID || relocatedUnit.Latitude != 45.101 || relocatedUnit.Longitude != 2 {
+		t.Errorf("expected unit 101 to keep its ID and move to (45.101, 2), got %+v", relocatedUnit)
+	}
+}
+
+func TestIngestAgriUnitSurveyRows_UnlocatedUnit(t *testing.T) {
+	stream, err := misc.NewCSVStream(strings.NewReader("IDNUM;MILEX;OTEFDD\n-7;2023;1500\n102;2023;1500\n"), ';')
+	if err != nil {
+		t.Fatalf("NewCSVStream returned an unexpected error: %v", err)
+	}
+
+	mockAgriUnitStorage := &MockAgriUnitStorage{}
+	mockRejectionStorage := &MockIngestRejectionStorage{}
+	summary, err := IngestAgriUnitSurveyRows(stream, defaultTestOptions(t), &MockTransactor{}, mockAgriUnitStorage, &MockAgriculturalUnitSurveyStorage{}, &MockAgriculturalUnitSurveyRevisionStorage{}, mockRejectionStorage)
+	if err != nil {
+		t.Fatalf("IngestAgriUnitSurveyRows failed with error: %v", err)
+	}
+	if summary.RowsRejected != 1 || summary.UnitsCreated != 1 || summary.SurveysCreated != 1 {
+		t.Errorf("expected the row of unit -7 to be rejected, got %+v", summary)
+	}
+	if len(mockRejectionStorage.Rejections) != 1 || mockRejectionStorage.Rejections[0].Reason != RejectionNoLocation {
+		t.Errorf("expected a %s rejection, got %+v", RejectionNoLocation, mockRejectionStorage.Rejections)
+	}
+	if len(mockAgriUnitStorage.Units) != 1 || mockAgriUnitStorage.Units[0].IDNum != 102 {
+		t.Errorf("expected only unit 102 to be stored, got %+v", mockAgriUnitStorage.Units)
+	}
+}
+
+func TestIngestAgriUnitSurveyRows_StoredUnitNotLocated(t *testing.T) {
+	for _, relocate := range []bool{false, true} {
+		existingUnit := agri.AgriculturalUnit{ID: uuid.New(), IDNum: -7, Latitude: 45, Longitude: 2, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+		stream, err := misc.NewCSVStream(strings.NewReader("IDNUM;MILEX;OTEFDD\n-7;2023;1500\n-7;2024;1500\n"), ';')
+		if err != nil {
+			t.Fatalf("NewCSVStream returned an unexpected error: %v", err)
+		}
+
+		options := defaultTestOptions(t)
+		options.RelocateUnits = relocate
+		mockRejectionStorage := &MockIngestRejectionStorage{}
+		summary, err := IngestAgriUnitSurveyRows(stream, options, &MockTransactor{}, &MockAgriUnitStorage{Units: []agri.AgriculturalUnit{existingUnit}}, &MockAgriculturalUnitSurveyStorage{}, &MockAgriculturalUnitSurveyRevisionStorage{}, mockRejectionStorage)
+		if err != nil {
+			t.Fatalf("IngestAgriUnitSurveyRows failed with error: %v", err)
+		}
+		if relocate && (summary.RowsRejected != 2 || summary.SurveysCreated != 0) {
+			t.Errorf("expected both rows of unit -7 to be rejected when relocating, got %+v", summary)
+		}
+		if !relocate && (summary.RowsRejected != 0 || summary.SurveysCreated != 2 || len(mockRejectionStorage.Rejections) != 0) {
+			t.Errorf("expected the surveys of stored unit -7 to be ingested without locating it, got %+v", summary)
+		}
+	}
+}
+
+func TestIngestAgriUnitSurveyRows_ArchivedUnit(t *testing.T) {
+	archivedAt := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	archivedUnit := agri.AgriculturalUnit{ID: uuid.New(), IDNum: 101, Latitude: 45.101, Longitude: 2, CreatedAt: time.Now(), UpdatedAt: time.Now(), ArchivedAt: &archivedAt}
+	mockAgriUnitStorage := &MockAgriUnitStorage{Units: []agri.AgriculturalUnit{archivedUnit}}
+	mockAgriUnitSurveyStorage := &MockAgriculturalUnitSurveyStorage{}
+
+	stream, err := misc.NewCSVStream(strings.NewReader("IDNUM;MILEX;OTEFDD\n101;2023;1500\n101;2024;1500\n102;2024;1500\n"), ';')
+	if err != nil {
+		t.Fatalf("NewCSVStream returned an unexpected error: %v", err)
+	}
+
+	_, err = IngestAgriUnitSurveyRows(stream, defaultTestOptions(t), &MockTransactor{}, mockAgriUnitStorage, mockAgriUnitSurveyStorage, &MockAgriculturalUnitSurveyRevisionStorage{}, &MockIngestRejectionStorage{})
+	if err != nil {
+		t.Fatalf("IngestAgriUnitSurveyRows failed with error: %v", err)
+	}
+
+	if len(mockAgriUnitSurveyStorage.Surveys) != 3 {
+		t.Fatalf("expected 3 surveys, got %d", len(mockAgriUnitSurveyStorage.Surveys))
+	}
+	for _, survey := range mockAgriUnitSurveyStorage.Surveys {
+		switch {
+		case survey.IDNum == 101 && (survey.ArchivedAt == nil || !survey.ArchivedAt.Equal(archivedAt)):
+			t.Errorf("survey %d/%d of the archived unit should be archived at %v, got %v", survey.IDNum, survey.Year, archivedAt, survey.ArchivedAt)
+		case survey.IDNum == 102 && survey.ArchivedAt != nil:
+			t.Errorf("survey %d/%d of an active unit should not be archived", survey.IDNum, survey.Year)
+		}
+	}
+}
+
+func TestIngestAgriUnitSurveyRows_ReviseMode(t *testing.T) {
+	source := SurveySource{URL: "https://example.org/rica_v1.zip", FileName: "rica.csv", Version: "v1"}
+	changedSurvey := AgriculturalUnitSurvey{
+		ID: uuid.New(), IDNum: 101, Year: 2023, Revision: 1, Source: source, CreatedAt: time.Now(), UpdatedAt: time.Now(),
+		Data: map[string]interface{}{"IDNUM": 101.0, "MILEX": 2023.0, "OTEFDD": "1500", "SAU": 80.0},
+	}
+	unchangedSurvey := AgriculturalUnitSurvey{
+		ID: uuid.New(), IDNum: 102, Year: 2023, Revision: 1, Source: source, CreatedAt: time.Now(), UpdatedAt: time.Now(),
+		Data: map[string]interface{}{"IDNUM": 102.0, "MILEX": 2023.0, "OTEFDD": "1500", "SAU": 40.0},
+	}
+	mockAgriUnitSurveyStorage := &MockAgriculturalUnitSurveyStorage{Surveys: []AgriculturalUnitSurvey{changedSurvey, unchangedSurvey}}
+	mockRevisionStorage := &MockAgriculturalUnitSurveyRevisionStorage{}
+
+	stream, err := misc.NewCSVStream(strings.NewReader("IDNUM;MILEX;OTEFDD;SAU\n101;2023;1500;82\n102;2023;1500;40\n101;2023;1500;83\n"), ';')
+	if err != nil {
+		t.Fatalf("NewCSVStream returned an unexpected error: %v", err)
+	}
+
+	options := defaultTestOptions(t)
+	options.Mode = IngestModeRevise
+	options.Source = SurveySource{URL: "https://example.org/rica_v2.zip", FileName: "rica.csv", Version: "v2"}
+
+	summary, err := IngestAgriUnitSurveyRows(stream, options, &MockTransactor{}, &MockAgriUnitStorage{}, mockAgriUnitSurveyStorage, mockRevisionStorage, &MockIngestRejectionStorage{})
+	if err != nil {
+		t.Fatalf("IngestAgriUnitSurveyRows failed with error: %v", err)
+	}
+	if summary.SurveysCreated != 0 || summary.SurveysRevised != 1 || summary.SurveysUnchanged != 1 || summary.RowsRejected != 1 {
+		t.Errorf("expected 1 revised and 1 unchanged survey, and the repeated row rejected, got %+v", summary)
+	}
+
+	revised := mockAgriUnitSurveyStorage.Surveys[0]
+	if revised.ID != changedSurvey.ID || revised.Revision != 2 || revised.Source != options.Source || revised.Data["SAU"] != 82.0 {
+		t.Errorf("expected survey %s at revision 2 from %+v with SAU 82, got %+v", changedSurvey.ID, options.Source, revised)
+	}
+	if mockAgriUnitSurveyStorage.Surveys[1].Revision != 1 {
+		t.Errorf("unchanged survey should stay at revision 1, got %d", mockAgriUnitSurveyStorage.Surveys[1].Revision)
+	}
+
+	if len(mockRevisionStorage.Revisions) != 1 {
+		t.Fatalf("expected 1 archived revision, got %d", len(mockRevisionStorage.Revisions))
+	}
+	archived := mockRevisionStorage.Revisions[0]
+	if archived.SurveyID != changedSurvey.ID || archived.Revision != 1 || archived.Source != source || archived.Data["SAU"] != 80.0 || archived.ArchivedAt == nil {
+		t.Errorf("expected revision 1 of survey %s with SAU 80 to be archived, got %+v", changedSurvey.ID, archived)
+	}
+}
+
+func TestIngestAgriUnitSurveyRows_FlushesInBatches(t *testing.T) {
+	mockAgriUnitStorage := &MockAgriUnitStorage{}
+	mockAgriUnitSurveyStorage := &MockAgriculturalUnitSurveyStorage{}
+
+	var builder strings.Builder
+	builder.WriteString("IDNUM;MILEX;OTEFDD\n")
+	rowCount := IngestBatchSize*2 + 7
+	for i := 0; i < rowCount; i++ {
+		builder.WriteString(fmt.Sprintf("%d;2023;1500\n", i+1))
+	}
+
+	stream, err := misc.NewCSVStream(strings.NewReader(builder.String()), ';')
+	if err != nil {
+		t.Fatalf("NewCSVStream returned an unexpected error: %v", err)
+	}
+
+	_, err = IngestAgriUnitSurveyRows(stream, defaultTestOptions(t), &MockTransactor{}, mockAgriUnitStorage, mockAgriUnitSurveyStorage, &MockAgriculturalUnitSurveyRevisionStorage{}, &MockIngestRejectionStorage{})
+	if err != nil {
+		t.Fatalf("IngestAgriUnitSurveyRows failed with error: %v", err)
+	}
+
+	if len(mockAgriUnitStorage.Units) != rowCount {
+		t.Errorf("expected %d units, got %d", rowCount, len(mockAgriUnitStorage.Units))
+	}
+	if len(mockAgriUnitSurveyStorage.Surveys) != rowCount {
+		t.Errorf("expected %d surveys, got %d", rowCount, len(mockAgriUnitSurveyStorage.Surveys))
+	}
+
+	// Stored rows are looked up batch by batch, for the keys of the batch only.
+	if len(mockAgriUnitStorage.Queries) != 3 || len(mockAgriUnitSurveyStorage.Queries) != 3 {
+		t.Fatalf("expected 3 unit and 3 survey lookups, got %d and %d", len(mockAgriUnitStorage.Queries), len(mockAgriUnitSurveyStorage.Queries))
+	}
+	for i, query := range mockAgriUnitStorage.Queries {
+		surveyQuery := mockAgriUnitSurveyStorage.Queries[i]
+		if len(query.IDNums) > IngestBatchSize || query.IDNums[0] != i*IngestBatchSize+1 || query.Archived != agri.ArchivedIncluded {
+			t.Errorf("lookup %d: expected at most %d IDNUMs from %d, archived included, got %d from %d", i, IngestBatchSize, i*IngestBatchSize+1, len(query.IDNums), query.IDNums[0])
+		}
+		if !reflect.DeepEqual(surveyQuery.IDNums, query.IDNums) || !reflect.DeepEqual(surveyQuery.Years, []int{2023}) {
+			t.Errorf("lookup %d: expected the surveys of the same IDNUMs in 2023, got %d IDNUMs in %v", i, len(surveyQuery.IDNums), surveyQuery.Years)
+		}
+	}
+}
+
+func TestIngestAgriUnitSurveyRows_CustomFilter(t *testing.T) {
+	csvInput := strings.Join([]string{
+		"IDNUM;MILEX;OTEFDD;REGION",
+		"101;2023;1500;84",
+		"102;2023;4500;84",
+		"103;2023;3500;93",
+		"104;2023;4500;11",
+	}, "\n")
+
+	stream, err := misc.NewCSVStream(strings.NewReader(csvInput), ';')
+	if err != nil {
+		t.Fatalf("NewCSVStream returned an unexpected error: %v", err)
+	}
+
+	filter, err := filters.Parse("OTEFDD in (4500, 3500) and REGION in ('84', '93')")
+	if err != nil {
+		t.Fatalf("filters.Parse returned an unexpected error: %v", err)
+	}
+
+	mockAgriUnitSurveyStorage := &MockAgriculturalUnitSurveyStorage{}
+	_, err = IngestAgriUnitSurveyRows(stream, IngestOptions{Filter: filter, Locator: MockUnitLocator{}, Dictionaries: testDictionaries(t)}, &MockTransactor{}, &MockAgriUnitStorage{}, mockAgriUnitSurveyStorage, &MockAgriculturalUnitSurveyRevisionStorage{}, &MockIngestRejectionStorage{})
+	if err != nil {
+		t.Fatalf("IngestAgriUnitSurveyRows failed with error: %v", err)
+	}
+
+	var idNums []int
+	for _, survey := range mockAgriUnitSurveyStorage.Surveys {
+		idNums = append(idNums, survey.IDNum)
+	}
+	if fmt.Sprint(idNums) != "[102 103]" {
+		t.Errorf("expected surveys for units [102 103], got %v", idNums)
+	}
+}
+
+func TestIngestAgriUnitSurveyRows_FilterUnknownColumn(t *testing.T) {
+	stream, err := misc.NewCSVStream(strings.NewReader("IDNUM;MILEX\n101;2023\n"), ';')
+	if err != nil {
+		t.Fatalf("NewCSVStream returned an unexpected error: %v", err)
+	}
+
+	_, err = IngestAgriUnitSurveyRows(stream, defaultTestOptions(t), &MockTransactor{}, &MockAgriUnitStorage{}, &MockAgriculturalUnitSurveyStorage{}, &MockAgriculturalUnitSurveyRevisionStorage{}, &MockIngestRejectionStorage{})
+	if err == nil || !strings.Contains(err.Error(), HeaderOTEFDD) {
+		t.Errorf("expected a missing %s column error, got %v", HeaderOTEFDD, err)
+	}
+}
+
+type failingSurveyStorage struct {
+	MockAgriculturalUnitSurveyStorage
+}
+
+func (m *failingSurveyStorage) InsertOrUpdateBatch(surveys []AgriculturalUnitSurvey) error {
+	return errors.New("simulated batch failure")
+}
+
+func (m *failingSurveyStorage) WithQuerier(querier storage.DBQuerier) AgriculturalUnitSurveyStorage {
+	return m
+}
+
+func TestIngestAgriUnitSurveyRows_RollsBackOnError(t *testing.T) {
+	stream, err := misc.NewCSVStream(strings.NewReader("IDNUM;MILEX;OTEFDD\n101;2023;1500\n"), ';')
+	if err != nil {
+		t.Fatalf("NewCSVStream returned an unexpected error: %v", err)
+	}
+
+	transactor := &MockTransactor{}
+	summary, err := IngestAgriUnitSurveyRows(stream, defaultTestOptions(t), transactor, &MockAgriUnitStorage{}, &failingSurveyStorage{}, &MockAgriculturalUnitSurveyRevisionStorage{}, &MockIngestRejectionStorage{})
+	if err == nil {
+		t.Fatal("IngestAgriUnitSurveyRows expected an error, but got none")
+	}
+	if transactor.RolledBack != 1 || transactor.Committed != 0 {
+		t.Errorf("expected a single rolled back transaction, got %d commits and %d rollbacks", transactor.Committed, transactor.RolledBack)
+	}
+	if summary.UnitsCreated != 0 || summary.SurveysCreated != 0 {
+		t.Errorf("a rolled back run should not report created rows, got %+v", summary)
+	}
+}
+
+// transactionalRejectionStorage drops what is written through the rolled back transaction.
+type transactionalRejectionStorage struct {
+	MockIngestRejectionStorage
+}
+
+func (m *transactionalRejectionStorage) WithQuerier(querier storage.DBQuerier) IngestRejectionStorage {
+	return &MockIngestRejectionStorage{}
+}
+
+func TestIngestAgriUnitSurveyRows_KeepsRejectionsOnRollback(t *testing.T) {
+	stream, err := misc.NewCSVStream(strings.NewReader("IDNUM;MILEX;OTEFDD\n101;2023;1500\nabc;2023;1500\n102;20x3;1500\n"), ';')
+	if err != nil {
+		t.Fatalf("NewCSVStream returned an unexpected error: %v", err)
+	}
+
+	transactor := &MockTransactor{}
+	mockRejectionStorage := &transactionalRejectionStorage{}
+	_, err = IngestAgriUnitSurveyRows(stream, defaultTestOptions(t), transactor, &MockAgriUnitStorage{}, &failingSurveyStorage{}, &MockAgriculturalUnitSurveyRevisionStorage{}, mockRejectionStorage)
+	if err == nil {
+		t.Fatal("IngestAgriUnitSurveyRows expected an error, but got none")
+	}
+	if transactor.RolledBack != 1 {
+		t.Errorf("expected the ingest to be rolled back, got %d rollbacks", transactor.RolledBack)
+	}
+
+	rejections := mockRejectionStorage.Rejections
+	if len(rejections) != 2 || rejections[0].Reason != RejectionInvalidIDNum || rejections[1].Reason != RejectionInvalidYear {
+		t.Errorf("expected the rejections of rows 3 and 4 to outlive the rollback, got %+v", rejections)
+	}
 }
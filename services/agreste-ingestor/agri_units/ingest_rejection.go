@@ -0,0 +1,108 @@
+package agri_units
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type IngestRejectionReason string
+
+const (
+	RejectionInvalidIDNum IngestRejectionReason = "invalid_idnum"
+	RejectionInvalidYear  IngestRejectionReason = "invalid_year"
+	RejectionNoDictionary IngestRejectionReason = "no_dictionary"
+	RejectionInvalidValue IngestRejectionReason = "invalid_value"
+	RejectionDuplicateKey IngestRejectionReason = "duplicate_key"
+	RejectionNoLocation   IngestRejectionReason = "no_location"
+)
+
+// IngestRejection records why a cell of a CSV row was not written.
+type IngestRejection struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	ArchivedAt *time.Time `json:"archivedAt,omitempty"`
+
+	RunID     uuid.UUID             `json:"runId"`
+	File      string                `json:"file,omitempty"`
+	Row       int                   `json:"row"`
+	Column    string                `json:"column,omitempty"`
+	Value     string                `json:"value"`
+	Reason    IngestRejectionReason `json:"reason"`
+	Message   string                `json:"message"`
+	RawValues map[string]string     `json:"rawValues"`
+}
+
+type IngestRejectionValue struct {
+	RunID     uuid.UUID
+	File      string
+	Row       int
+	Column    string
+	Value     string
+	Reason    IngestRejectionReason
+	Message   string
+	RawValues map[string]string
+}
+
+func CreateIngestRejection(value IngestRejectionValue) IngestRejection {
+	now := time.Now()
+	return IngestRejection{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		RunID:     value.RunID,
+		File:      value.File,
+		Row:       value.Row,
+		Column:    value.Column,
+		Value:     value.Value,
+		Reason:    value.Reason,
+		Message:   value.Message,
+		RawValues: value.RawValues,
+	}
+}
+
+func rawRowValues(header []string, values []string) map[string]string {
+	raw := make(map[string]string, len(header))
+	for colIdx, colName := range header {
+		if colIdx < len(values) {
+			raw[colName] = values[colIdx]
+		}
+	}
+	return raw
+}
+
+var ingestRejectionCSVHeader = []string{"file", "row", "column", "value", "reason", "message", "raw_values"}
+
+// WriteIngestRejectionsCSV writes one line per rejection, the raw row as JSON in the last column.
+func WriteIngestRejectionsCSV(w io.Writer, rejections []IngestRejection) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(ingestRejectionCSVHeader); err != nil {
+		return fmt.Errorf("failed to write rejection CSV header: %w", err)
+	}
+	for _, rejection := range rejections {
+		rawValues, err := json.Marshal(rejection.RawValues)
+		if err != nil {
+			return fmt.Errorf("failed to encode raw values of row %d: %w", rejection.Row, err)
+		}
+		err = writer.Write([]string{
+			rejection.File,
+			strconv.Itoa(rejection.Row),
+			rejection.Column,
+			rejection.Value,
+			string(rejection.Reason),
+			rejection.Message,
+			string(rawValues),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write rejection of row %d: %w", rejection.Row, err)
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
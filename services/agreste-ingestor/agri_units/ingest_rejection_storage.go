@@ -0,0 +1,224 @@
+package agri_units
+
+import (
+	"common/storage"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+
+	_ "github.com/lib/pq"
+)
+
+type IngestRejectionSqlView struct {
+	ID         string       `db:"id"`
+	CreatedAt  time.Time    `db:"created_at"`
+	UpdatedAt  time.Time    `db:"updated_at"`
+	ArchivedAt sql.NullTime `db:"archived_at"`
+	RunID      string       `db:"run_id"`
+	FileName   string       `db:"file_name"`
+	RowNumber  int          `db:"row_number"`
+	ColumnName string       `db:"column_name"`
+	Value      string       `db:"value"`
+	Reason     string       `db:"reason"`
+	Message    string       `db:"message"`
+	RawValues  []byte       `db:"raw_values"`
+}
+
+func IngestRejectionToSqlView(rejection IngestRejection) (IngestRejectionSqlView, error) {
+	rawValues, err := json.Marshal(rejection.RawValues)
+	if err != nil {
+		return IngestRejectionSqlView{}, fmt.Errorf("failed to marshal raw values to JSON for SQL view: %w", err)
+	}
+
+	sqlView := IngestRejectionSqlView{
+		ID:         rejection.ID.String(),
+		CreatedAt:  rejection.CreatedAt,
+		UpdatedAt:  rejection.UpdatedAt,
+		RunID:      rejection.RunID.String(),
+		FileName:   rejection.File,
+		RowNumber:  rejection.Row,
+		ColumnName: rejection.Column,
+		Value:      rejection.Value,
+		Reason:     string(rejection.Reason),
+		Message:    rejection.Message,
+		RawValues:  rawValues,
+	}
+	if rejection.ArchivedAt != nil {
+		sqlView.ArchivedAt = sql.NullTime{Time: *rejection.ArchivedAt, Valid: true}
+	}
+
+	return sqlView, nil
+}
+
+func IngestRejectionFromSqlView(sqlView IngestRejectionSqlView) (IngestRejection, error) {
+	parsedID, err := uuid.Parse(sqlView.ID)
+	if err != nil {
+		return IngestRejection{}, fmt.Errorf("failed to parse UUID from SQL view '%s': %w", sqlView.ID, err)
+	}
+	parsedRunID, err := uuid.Parse(sqlView.RunID)
+	if err != nil {
+		return IngestRejection{}, fmt.Errorf("failed to parse run UUID from SQL view '%s': %w", sqlView.RunID, err)
+	}
+
+	rejection := IngestRejection{
+		ID:        parsedID,
+		CreatedAt: sqlView.CreatedAt,
+		UpdatedAt: sqlView.UpdatedAt,
+		RunID:     parsedRunID,
+		File:      sqlView.FileName,
+		Row:       sqlView.RowNumber,
+		Column:    sqlView.ColumnName,
+		Value:     sqlView.Value,
+		Reason:    IngestRejectionReason(sqlView.Reason),
+		Message:   sqlView.Message,
+	}
+	if sqlView.ArchivedAt.Valid {
+		rejection.ArchivedAt = &sqlView.ArchivedAt.Time
+	}
+
+	if len(sqlView.RawValues) > 0 {
+		if err := json.Unmarshal(sqlView.RawValues, &rejection.RawValues); err != nil {
+			return IngestRejection{}, fmt.Errorf("failed to unmarshal raw values from SQL view: %w", err)
+		}
+	}
+
+	return rejection, nil
+}
+
+type IngestRejectionStorage interface {
+	InsertBatch(rejections []IngestRejection) error
+	SelectByRunID(runID uuid.UUID) ([]IngestRejection, error)
+	WithQuerier(querier storage.DBQuerier) IngestRejectionStorage
+}
+
+const maxRejectionsPerStatement = 250
+
+var ingestRejectionColumns = []string{
+	"id",
+	"created_at",
+	"updated_at",
+	"archived_at",
+	"run_id",
+	"file_name",
+	"row_number",
+	"column_name",
+	"value",
+	"reason",
+	"message",
+	"raw_values",
+}
+
+type ingestRejectionStorage struct {
+	querier storage.DBQuerier
+	builder sq.StatementBuilderType
+}
+
+func NewIngestRejectionStorage(querier storage.DBQuerier) IngestRejectionStorage {
+	return &ingestRejectionStorage{
+		querier: querier,
+		builder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+}
+
+func (s *ingestRejectionStorage) WithQuerier(querier storage.DBQuerier) IngestRejectionStorage {
+	return &ingestRejectionStorage{
+		querier: querier,
+		builder: s.builder,
+	}
+}
+
+func (s *ingestRejectionStorage) InsertBatch(rejections []IngestRejection) error {
+	for start := 0; start < len(rejections); start += maxRejectionsPerStatement {
+		end := min(start+maxRejectionsPerStatement, len(rejections))
+
+		builder := s.builder.Insert("ingest_rejections").
+			Columns(ingestRejectionColumns...)
+		for _, rejection := range rejections[start:end] {
+			sqlView, err := IngestRejectionToSqlView(rejection)
+			if err != nil {
+				return fmt.Errorf("failed to convert domain model to SQL view: %w", err)
+			}
+			builder = builder.Values(
+				sqlView.ID,
+				sqlView.CreatedAt,
+				sqlView.UpdatedAt,
+				sqlView.ArchivedAt,
+				sqlView.RunID,
+				sqlView.FileName,
+				sqlView.RowNumber,
+				sqlView.ColumnName,
+				sqlView.Value,
+				sqlView.Reason,
+				sqlView.Message,
+				sqlView.RawValues,
+			)
+		}
+
+		query, args, err := builder.ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build InsertBatch SQL for IngestRejection: %w", err)
+		}
+
+		_, err = s.querier.Exec(query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to execute InsertBatch for %d ingest rejections: %w", end-start, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *ingestRejectionStorage) SelectByRunID(runID uuid.UUID) ([]IngestRejection, error) {
+	sqlQuery, args, err := s.builder.Select(ingestRejectionColumns...).
+		From("ingest_rejections").
+		Where(sq.Eq{"run_id": runID.String()}).
+		OrderBy("file_name ASC", "row_number ASC", "column_name ASC").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build SQL query with squirrel: %w", err)
+	}
+
+	rows, err := s.querier.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute SelectByRunID query: %w", err)
+	}
+	defer rows.Close()
+
+	var rejections []IngestRejection
+	for rows.Next() {
+		var sqlView IngestRejectionSqlView
+		err := rows.Scan(
+			&sqlView.ID,
+			&sqlView.CreatedAt,
+			&sqlView.UpdatedAt,
+			&sqlView.ArchivedAt,
+			&sqlView.RunID,
+			&sqlView.FileName,
+			&sqlView.RowNumber,
+			&sqlView.ColumnName,
+			&sqlView.Value,
+			&sqlView.Reason,
+			&sqlView.Message,
+			&sqlView.RawValues,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan ingest rejection row: %w", err)
+		}
+
+		rejection, err := IngestRejectionFromSqlView(sqlView)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert SQL view to domain model: %w", err)
+		}
+		rejections = append(rejections, rejection)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during row iteration: %w", err)
+	}
+
+	return rejections, nil
+}
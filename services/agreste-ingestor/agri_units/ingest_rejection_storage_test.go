@@ -0,0 +1,83 @@
+package agri_units
+
+import (
+	"common/testutils"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+var ingestRejectionTestColumns = []string{"id", "created_at", "updated_at", "archived_at", "run_id", "file_name", "row_number", "column_name", "value", "reason", "message", "raw_values"}
+
+func TestIngestRejectionStorage_InsertBatch(t *testing.T) {
+	mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+	if err != nil {
+		t.Fatalf("Failed to create mock querier: %v", err)
+	}
+	defer mockQuerierInstance.Db.Close()
+
+	rejection := CreateIngestRejection(IngestRejectionValue{
+		RunID:     uuid.New(),
+		File:      "2023/rica.csv",
+		Row:       5,
+		Column:    HeaderIDNum,
+		Value:     "abc",
+		Reason:    RejectionInvalidIDNum,
+		Message:   "not an integer",
+		RawValues: map[string]string{"IDNUM": "abc", "MILEX": "2023"},
+	})
+
+	sqlMock.ExpectExec(regexp.QuoteMeta(`INSERT INTO ingest_rejections (id,created_at,updated_at,archived_at,run_id,file_name,row_number,column_name,value,reason,message,raw_values) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)`)).
+		WithArgs(
+			rejection.ID.String(), rejection.CreatedAt, rejection.UpdatedAt, sql.NullTime{},
+			rejection.RunID.String(), "2023/rica.csv", 5, "IDNUM", "abc", "invalid_idnum", "not an integer",
+			[]byte(`{"IDNUM":"abc","MILEX":"2023"}`),
+		).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = NewIngestRejectionStorage(mockQuerierInstance).InsertBatch([]IngestRejection{rejection})
+	if err != nil {
+		t.Fatalf("InsertBatch returned an unexpected error: %v", err)
+	}
+
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("There were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestIngestRejectionStorage_SelectByRunID(t *testing.T) {
+	mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+	if err != nil {
+		t.Fatalf("Failed to create mock querier: %v", err)
+	}
+	defer mockQuerierInstance.Db.Close()
+
+	runID := uuid.New()
+	now := time.Now().Truncate(time.Millisecond)
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta("SELECT id, created_at, updated_at, archived_at, run_id, file_name, row_number, column_name, value, reason, message, raw_values FROM ingest_rejections WHERE run_id = $1 ORDER BY file_name ASC, row_number ASC, column_name ASC")).
+		WithArgs(runID.String()).
+		WillReturnRows(sqlMock.NewRows(ingestRejectionTestColumns).
+			AddRow(uuid.New().String(), now, now, sql.NullTime{}, runID.String(), "2023/rica.csv", 7, "SAU", "n/a", "invalid_value", "not a decimal number", []byte(`{"SAU":"n/a"}`)))
+
+	rejections, err := NewIngestRejectionStorage(mockQuerierInstance).SelectByRunID(runID)
+	if err != nil {
+		t.Fatalf("SelectByRunID returned an unexpected error: %v", err)
+	}
+
+	if len(rejections) != 1 {
+		t.Fatalf("expected 1 rejection, got %d", len(rejections))
+	}
+	rejection := rejections[0]
+	if rejection.RunID != runID || rejection.File != "2023/rica.csv" || rejection.Row != 7 || rejection.Reason != RejectionInvalidValue || rejection.RawValues["SAU"] != "n/a" {
+		t.Errorf("unexpected rejection %+v", rejection)
+	}
+
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("There were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,31 @@
+package agri_units
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteIngestRejectionsCSV(t *testing.T) {
+	rejections := []IngestRejection{
+		CreateIngestRejection(IngestRejectionValue{
+			File:      "rica.csv",
+			Row:       3,
+			Column:    "SAU",
+			Value:     "12;5",
+			Reason:    RejectionInvalidValue,
+			Message:   "not a decimal number",
+			RawValues: map[string]string{"IDNUM": "101", "SAU": "12;5"},
+		}),
+	}
+
+	var output strings.Builder
+	if err := WriteIngestRejectionsCSV(&output, rejections); err != nil {
+		t.Fatalf("WriteIngestRejectionsCSV returned an unexpected error: %v", err)
+	}
+
+	expected := "file,row,column,value,reason,message,raw_values\n" +
+		`rica.csv,3,SAU,12;5,invalid_value,not a decimal number,"{""IDNUM"":""101"",""SAU"":""12;5""}"` + "\n"
+	if output.String() != expected {
+		t.Errorf("CSV mismatch.\nExpected:\n%s\nGot:\n%s", expected, output.String())
+	}
+}
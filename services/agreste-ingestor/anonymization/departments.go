@@ -0,0 +1,62 @@
+package anonymization
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var legacyRegionCodes = map[string]string{
+	"21": "44", "22": "32", "23": "28", "25": "28", "26": "27",
+	"31": "32", "41": "44", "42": "44", "43": "27", "54": "75",
+	"72": "75", "73": "76", "74": "75", "82": "84", "83": "84",
+	"91": "76",
+}
+
+var departmentRegionCodes = map[string]string{
+	"01": "84", "02": "32", "03": "84", "04": "93", "05": "93", "06": "93",
+	"07": "84", "08": "44", "09": "76", "10": "44", "11": "76", "12": "76",
+	"13": "93", "14": "28", "15": "84", "16": "75", "17": "75", "18": "24",
+	"19": "75", "20": "94", "2A": "94", "2B": "94", "21": "27", "22": "53",
+	"23": "75", "24": "75", "25": "27", "26": "84", "27": "28", "28": "24",
+	"29": "53", "30": "76", "31": "76", "32": "76", "33": "75", "34": "76",
+	"35": "53", "36": "24", "37": "24", "38": "84", "39": "27", "40": "75",
+	"41": "24", "42": "84", "43": "84", "44": "52", "45": "24", "46": "76",
+	"47": "75", "48": "76", "49": "52", "50": "28", "51": "44", "52": "44",
+	"53": "52", "54": "44", "55": "44", "56": "53", "57": "44", "58": "27",
+	"59": "32", "60": "32", "61": "28", "62": "32", "63": "84", "64": "75",
+	"65": "76", "66": "76", "67": "44", "68": "44", "69": "84", "70": "27",
+	"71": "27", "72": "52", "73": "84", "74": "84", "75": "11", "76": "28",
+	"77": "11", "78": "11", "79": "75", "80": "32", "81": "76", "82": "76",
+	"83": "93", "84": "93", "85": "52", "86": "75", "87": "75", "88": "44",
+	"89": "27", "90": "27", "91": "11", "92": "11", "93": "11", "94": "11",
+	"95": "11",
+}
+
+func normalizeCode(raw string) string {
+	code := strings.ToUpper(strings.TrimSpace(raw))
+	if number, err := strconv.Atoi(code); err == nil {
+		return fmt.Sprintf("%02d", number)
+	}
+	if number, err := strconv.ParseFloat(strings.ReplaceAll(code, ",", "."), 64); err == nil && number == float64(int(number)) {
+		return fmt.Sprintf("%02d", int(number))
+	}
+	return code
+}
+
+// RegionCodeForRegion resolves a current or legacy region code.
+func RegionCodeForRegion(raw string) (string, bool) {
+	code := normalizeCode(raw)
+	if code == "" {
+		return "", false
+	}
+	if current, isLegacy := legacyRegionCodes[code]; isLegacy {
+		return current, true
+	}
+	return code, true
+}
+
+func RegionCodeForDepartment(raw string) (string, bool) {
+	code, exists := departmentRegionCodes[normalizeCode(raw)]
+	return code, exists
+}
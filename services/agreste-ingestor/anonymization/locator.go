@@ -0,0 +1,199 @@
+package anonymization
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+const (
+	DefaultRegionColumn     = "REGION"
+	DefaultDepartmentColumn = "DEP"
+	DefaultMinJitterKm      = 1.0
+
+	earthRadiusKm       = 6371.0
+	maxSamplingAttempts = 1000
+)
+
+var ErrRegionNotSampled = errors.New("no point could be drawn inside the region")
+
+type RowValues interface {
+	Get(column string) (string, bool)
+}
+
+type LocatorConfig struct {
+	// Salt keeps pseudo locations unpredictable; changing it moves every unit.
+	Salt string
+	// MinJitterKm is the minimum distance between the drawn and published points.
+	MinJitterKm      float64
+	RegionColumn     string
+	DepartmentColumn string
+}
+
+// Locator gives units a reproducible pseudo location inside their region.
+type Locator struct {
+	config  LocatorConfig
+	regions map[string]Region
+}
+
+func NewLocator(config LocatorConfig) (*Locator, error) {
+	regions, err := FranceRegions()
+	if err != nil {
+		return nil, err
+	}
+	return NewLocatorWithRegions(config, regions)
+}
+
+func NewLocatorWithRegions(config LocatorConfig, regions map[string]Region) (*Locator, error) {
+	if config.Salt == "" {
+		return nil, errors.New("anonymization salt must not be empty")
+	}
+	if config.MinJitterKm < 0 || math.IsNaN(config.MinJitterKm) || math.IsInf(config.MinJitterKm, 0) {
+		return nil, fmt.Errorf("invalid minimum jitter radius: %v km", config.MinJitterKm)
+	}
+	if _, exists := regions[FranceCode]; !exists {
+		return nil, fmt.Errorf("regions must include the fallback region '%s'", FranceCode)
+	}
+	if config.RegionColumn == "" {
+		config.RegionColumn = DefaultRegionColumn
+	}
+	if config.DepartmentColumn == "" {
+		config.DepartmentColumn = DefaultDepartmentColumn
+	}
+	return &Locator{config: config, regions: regions}, nil
+}
+
+// Region picks the region of a row, falling back to its department, then to France.
+func (l *Locator) Region(row RowValues) Region {
+	if raw, exists := row.Get(l.config.RegionColumn); exists {
+		if code, ok := RegionCodeForRegion(raw); ok {
+			if region, known := l.regions[code]; known {
+				return region
+			}
+		}
+	}
+	if raw, exists := row.Get(l.config.DepartmentColumn); exists {
+		if code, ok := RegionCodeForDepartment(raw); ok {
+			if region, known := l.regions[code]; known {
+				return region
+			}
+		}
+	}
+	return l.regions[FranceCode]
+}
+
+// Locate draws a point inside the row's region and moves it at least MinJitterKm.
+func (l *Locator) Locate(idNum int, row RowValues) (latitude, longitude float64, err error) {
+	region := l.Region(row)
+	rng := rand.New(rand.NewSource(l.seed(idNum, region.Code)))
+
+	var unjittered Point
+	for attempt := 0; attempt < maxSamplingAttempts; attempt++ {
+		point, err := samplePoint(rng, region)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to locate unit %d in region %s: %w", idNum, region.Code, err)
+		}
+		if l.config.MinJitterKm == 0 {
+			return point.Latitude, point.Longitude, nil
+		}
+		if attempt == 0 {
+			unjittered = point
+		}
+		distance := l.config.MinJitterKm * (1 + rng.Float64())
+		bearing := rng.Float64() * 2 * math.Pi
+		point = destination(point, distance, bearing)
+		if region.Contains(point) {
+			return point.Latitude, point.Longitude, nil
+		}
+	}
+	return unjittered.Latitude, unjittered.Longitude, nil
+}
+
+func (l *Locator) seed(idNum int, regionCode string) int64 {
+	mac := hmac.New(sha256.New, []byte(l.config.Salt))
+	mac.Write([]byte(strconv.Itoa(idNum) + "|" + regionCode))
+	return int64(binary.BigEndian.Uint64(mac.Sum(nil)[:8]))
+}
+
+func samplePoint(rng *rand.Rand, region Region) (Point, error) {
+	bounds := region.Bounds
+	for attempt := 0; attempt < maxSamplingAttempts; attempt++ {
+		candidate := Point{
+			Longitude: bounds.MinLongitude + rng.Float64()*(bounds.MaxLongitude-bounds.MinLongitude),
+			Latitude:  bounds.MinLatitude + rng.Float64()*(bounds.MaxLatitude-bounds.MinLatitude),
+		}
+		if region.Contains(candidate) {
+			return candidate, nil
+		}
+	}
+	return sampleScanline(rng, region)
+}
+
+func sampleScanline(rng *rand.Rand, region Region) (Point, error) {
+	bounds := region.Bounds
+	for attempt := 0; attempt < maxSamplingAttempts; attempt++ {
+		latitude := bounds.MinLatitude + rng.Float64()*(bounds.MaxLatitude-bounds.MinLatitude)
+
+		var crossings []float64
+		for _, polygon := range region.Polygons {
+			for _, ring := range polygon {
+				for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+					a, b := ring[i], ring[j]
+					if (a.Latitude > latitude) != (b.Latitude > latitude) {
+						crossings = append(crossings, (b.Longitude-a.Longitude)*(latitude-a.Latitude)/(b.Latitude-a.Latitude)+a.Longitude)
+					}
+				}
+			}
+		}
+		sort.Float64s(crossings)
+
+		inside := 0.0
+		for i := 0; i+1 < len(crossings); i += 2 {
+			inside += crossings[i+1] - crossings[i]
+		}
+		if inside <= 0 {
+			continue
+		}
+		offset := rng.Float64() * inside
+		for i := 0; i+1 < len(crossings); i += 2 {
+			if length := crossings[i+1] - crossings[i]; offset > length {
+				offset -= length
+				continue
+			}
+			candidate := Point{Longitude: crossings[i] + offset, Latitude: latitude}
+			if region.Contains(candidate) {
+				return candidate, nil
+			}
+			break
+		}
+	}
+	return Point{}, ErrRegionNotSampled
+}
+
+func destination(origin Point, distanceKm, bearing float64) Point {
+	angular := distanceKm / earthRadiusKm
+	lat1 := origin.Latitude * math.Pi / 180
+	lon1 := origin.Longitude * math.Pi / 180
+
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(angular) + math.Cos(lat1)*math.Sin(angular)*math.Cos(bearing))
+	lon2 := lon1 + math.Atan2(math.Sin(bearing)*math.Sin(angular)*math.Cos(lat1), math.Cos(angular)-math.Sin(lat1)*math.Sin(lat2))
+
+	return Point{Longitude: lon2 * 180 / math.Pi, Latitude: lat2 * 180 / math.Pi}
+}
+
+// DistanceKm is the haversine distance between two points.
+func DistanceKm(a, b Point) float64 {
+	lat1 := a.Latitude * math.Pi / 180
+	lat2 := b.Latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
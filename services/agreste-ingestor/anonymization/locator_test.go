@@ -0,0 +1,221 @@
+package anonymization
+
+import (
+	"errors"
+	"testing"
+)
+
+type testRow map[string]string
+
+func (r testRow) Get(column string) (string, bool) {
+	value, exists := r[column]
+	return value, exists
+}
+
+func newTestLocator(t *testing.T, salt string, minJitterKm float64) *Locator {
+	t.Helper()
+	locator, err := NewLocator(LocatorConfig{Salt: salt, MinJitterKm: minJitterKm})
+	if err != nil {
+		t.Fatalf("NewLocator returned an unexpected error: %v", err)
+	}
+	return locator
+}
+
+func locate(t *testing.T, locator *Locator, idNum int, row testRow) (latitude, longitude float64) {
+	t.Helper()
+	latitude, longitude, err := locator.Locate(idNum, row)
+	if err != nil {
+		t.Fatalf("Locate returned an unexpected error: %v", err)
+	}
+	return latitude, longitude
+}
+
+// stripRegions holds a diagonal strip far thinner than the jitter.
+func stripRegions(t *testing.T) map[string]Region {
+	t.Helper()
+	regions, err := ParseRegions([]byte(`{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "properties": {"code": "FR", "name": "Strip"}, "geometry": {"type": "Polygon",
+			"coordinates": [[[0, 0], [1, 1], [1, 1.0000001], [0, 0.0000001], [0, 0]]]}},
+		{"type": "Feature", "properties": {"code": "00", "name": "Line"}, "geometry": {"type": "Polygon",
+			"coordinates": [[[0, 0], [1, 0], [2, 0], [0, 0]]]}}
+	]}`))
+	if err != nil {
+		t.Fatalf("ParseRegions returned an unexpected error: %v", err)
+	}
+	return regions
+}
+
+func TestFranceRegions(t *testing.T) {
+	regions, err := FranceRegions()
+	if err != nil {
+		t.Fatalf("FranceRegions returned an unexpected error: %v", err)
+	}
+
+	for _, code := range []string{"11", "24", "27", "28", "32", "44", "52", "53", "75", "76", "84", "93", "94", FranceCode} {
+		if _, exists := regions[code]; !exists {
+			t.Errorf("region %s is missing from the embedded GeoJSON", code)
+		}
+	}
+
+	cities := []struct {
+		name   string
+		region string
+		point  Point
+	}{
+		{"Paris", "11", Point{Longitude: 2.35, Latitude: 48.86}},
+		{"Lyon", "84", Point{Longitude: 4.84, Latitude: 45.76}},
+		{"Toulouse", "76", Point{Longitude: 1.44, Latitude: 43.60}},
+		{"Rennes", "53", Point{Longitude: -1.68, Latitude: 48.11}},
+		{"Ajaccio", "94", Point{Longitude: 8.74, Latitude: 41.93}},
+	}
+	for _, city := range cities {
+		if !regions[city.region].Contains(city.point) {
+			t.Errorf("%s should be inside region %s", city.name, city.region)
+		}
+		if !regions[FranceCode].Contains(city.point) {
+			t.Errorf("%s should be inside metropolitan France", city.name)
+		}
+	}
+
+	atlantic := Point{Longitude: -6.0, Latitude: 46.0}
+	if regions[FranceCode].Contains(atlantic) {
+		t.Errorf("a point in the Atlantic should not be inside metropolitan France")
+	}
+}
+
+func TestRegionCodes(t *testing.T) {
+	cases := []struct {
+		name     string
+		lookup   func(string) (string, bool)
+		raw      string
+		expected string
+		found    bool
+	}{
+		{"CurrentRegion", RegionCodeForRegion, "84", "84", true},
+		{"LegacyRegion", RegionCodeForRegion, "82", "84", true},
+		{"RegionWithoutLeadingZero", RegionCodeForRegion, "11", "11", true},
+		{"EmptyRegion", RegionCodeForRegion, " ", "", false},
+		{"Department", RegionCodeForDepartment, "35", "53", true},
+		{"DepartmentWithoutLeadingZero", RegionCodeForDepartment, "1", "84", true},
+		{"CorsicanDepartment", RegionCodeForDepartment, "2a", "94", true},
+		{"OverseasDepartment", RegionCodeForDepartment, "971", "", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			code, found := tc.lookup(tc.raw)
+			if code != tc.expected || found != tc.found {
+				t.Errorf("expected (%q, %t), got (%q, %t)", tc.expected, tc.found, code, found)
+			}
+		})
+	}
+}
+
+func TestLocator(t *testing.T) {
+
+	t.Run("Deterministic", func(t *testing.T) {
+		row := testRow{"REGION": "84"}
+		lat1, lon1 := locate(t, newTestLocator(t, "salt", 1), 1234, row)
+		lat2, lon2 := locate(t, newTestLocator(t, "salt", 1), 1234, row)
+		if lat1 != lat2 || lon1 != lon2 {
+			t.Errorf("expected identical coordinates across runs, got (%f, %f) and (%f, %f)", lat1, lon1, lat2, lon2)
+		}
+	})
+
+	t.Run("SaltChangesLocation", func(t *testing.T) {
+		row := testRow{"REGION": "84"}
+		lat1, lon1 := locate(t, newTestLocator(t, "salt", 1), 1234, row)
+		lat2, lon2 := locate(t, newTestLocator(t, "other salt", 1), 1234, row)
+		if lat1 == lat2 && lon1 == lon2 {
+			t.Errorf("expected a different salt to move the unit")
+		}
+	})
+
+	t.Run("StaysInsideRegion", func(t *testing.T) {
+		locator := newTestLocator(t, "salt", 2)
+		rows := []testRow{
+			{"REGION": "11"},
+			{"REGION": "94"},
+			{"REGION": "73"},
+			{"DEP": "29"},
+			{"REGION": "unknown", "DEP": "67"},
+		}
+		for _, row := range rows {
+			region := locator.Region(row)
+			for idNum := 1; idNum <= 50; idNum++ {
+				lat, lon := locate(t, locator, idNum, row)
+				if !region.Contains(Point{Longitude: lon, Latitude: lat}) {
+					t.Errorf("unit %d of row %v located outside region %s: (%f, %f)", idNum, row, region.Code, lat, lon)
+				}
+			}
+		}
+	})
+
+	t.Run("RegionResolution", func(t *testing.T) {
+		locator := newTestLocator(t, "salt", 1)
+		cases := []struct {
+			row      testRow
+			expected string
+		}{
+			{testRow{"REGION": "93", "DEP": "29"}, "93"},
+			{testRow{"REGION": "73"}, "76"},
+			{testRow{"REGION": "99", "DEP": "29"}, "53"},
+			{testRow{"DEP": "971"}, FranceCode},
+			{testRow{}, FranceCode},
+		}
+		for _, tc := range cases {
+			if code := locator.Region(tc.row).Code; code != tc.expected {
+				t.Errorf("row %v: expected region %s, got %s", tc.row, tc.expected, code)
+			}
+		}
+	})
+
+	t.Run("MinimumJitter", func(t *testing.T) {
+		row := testRow{"REGION": "76"}
+		unjittered := newTestLocator(t, "salt", 0)
+		minJitterKm := 5.0
+		jittered := newTestLocator(t, "salt", minJitterKm)
+
+		for idNum := 1; idNum <= 50; idNum++ {
+			anchorLat, anchorLon := locate(t, unjittered, idNum, row)
+			lat, lon := locate(t, jittered, idNum, row)
+			distance := DistanceKm(Point{Longitude: anchorLon, Latitude: anchorLat}, Point{Longitude: lon, Latitude: lat})
+			if distance < minJitterKm-1e-6 {
+				t.Errorf("unit %d moved %f km, expected at least %f km", idNum, distance, minJitterKm)
+			}
+		}
+	})
+
+	t.Run("ThinRegion", func(t *testing.T) {
+		regions := stripRegions(t)
+		locator, err := NewLocatorWithRegions(LocatorConfig{Salt: "salt", MinJitterKm: 1}, regions)
+		if err != nil {
+			t.Fatalf("NewLocatorWithRegions returned an unexpected error: %v", err)
+		}
+		for idNum := 1; idNum <= 10; idNum++ {
+			lat, lon := locate(t, locator, idNum, testRow{})
+			if !regions[FranceCode].Contains(Point{Longitude: lon, Latitude: lat}) {
+				t.Errorf("unit %d located outside the strip: (%f, %f)", idNum, lat, lon)
+			}
+		}
+	})
+
+	t.Run("RegionWithoutArea", func(t *testing.T) {
+		locator, err := NewLocatorWithRegions(LocatorConfig{Salt: "salt", MinJitterKm: 1}, stripRegions(t))
+		if err != nil {
+			t.Fatalf("NewLocatorWithRegions returned an unexpected error: %v", err)
+		}
+		if _, _, err := locator.Locate(1234, testRow{"REGION": "00"}); !errors.Is(err, ErrRegionNotSampled) {
+			t.Errorf("expected ErrRegionNotSampled, got %v", err)
+		}
+	})
+
+	t.Run("InvalidConfig", func(t *testing.T) {
+		if _, err := NewLocator(LocatorConfig{MinJitterKm: 1}); err == nil {
+			t.Errorf("expected an error for an empty salt")
+		}
+		if _, err := NewLocator(LocatorConfig{Salt: "salt", MinJitterKm: -1}); err == nil {
+			t.Errorf("expected an error for a negative jitter radius")
+		}
+	})
+}
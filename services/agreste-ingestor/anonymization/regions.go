@@ -0,0 +1,156 @@
+package anonymization
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+//go:embed france_regions.geojson
+var franceRegionsGeoJSON []byte
+
+const FranceCode = "FR"
+
+type Point struct {
+	Longitude float64
+	Latitude  float64
+}
+
+type BoundingBox struct {
+	MinLongitude float64
+	MinLatitude  float64
+	MaxLongitude float64
+	MaxLatitude  float64
+}
+
+// Polygon is an outer ring optionally followed by holes, as in GeoJSON.
+type Polygon [][]Point
+
+type Region struct {
+	Code     string
+	Name     string
+	Polygons []Polygon
+	Bounds   BoundingBox
+}
+
+func (r Region) Contains(p Point) bool {
+	if p.Longitude < r.Bounds.MinLongitude || p.Longitude > r.Bounds.MaxLongitude ||
+		p.Latitude < r.Bounds.MinLatitude || p.Latitude > r.Bounds.MaxLatitude {
+		return false
+	}
+	for _, polygon := range r.Polygons {
+		if polygonContains(polygon, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func polygonContains(polygon Polygon, p Point) bool {
+	if len(polygon) == 0 || !ringContains(polygon[0], p) {
+		return false
+	}
+	for _, hole := range polygon[1:] {
+		if ringContains(hole, p) {
+			return false
+		}
+	}
+	return true
+}
+
+func ringContains(ring []Point, p Point) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Latitude > p.Latitude) != (b.Latitude > p.Latitude) &&
+			p.Longitude < (b.Longitude-a.Longitude)*(p.Latitude-a.Latitude)/(b.Latitude-a.Latitude)+a.Longitude {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+type geoJSONFeatureCollection struct {
+	Features []struct {
+		Properties struct {
+			Code string `json:"code"`
+			Name string `json:"name"`
+		} `json:"properties"`
+		Geometry struct {
+			Type        string          `json:"type"`
+			Coordinates json.RawMessage `json:"coordinates"`
+		} `json:"geometry"`
+	} `json:"features"`
+}
+
+// ParseRegions reads a GeoJSON FeatureCollection of regions with a code and a name.
+func ParseRegions(geoJSON []byte) (map[string]Region, error) {
+	var collection geoJSONFeatureCollection
+	if err := json.Unmarshal(geoJSON, &collection); err != nil {
+		return nil, fmt.Errorf("failed to decode region GeoJSON: %w", err)
+	}
+
+	regions := make(map[string]Region, len(collection.Features))
+	for _, feature := range collection.Features {
+		if feature.Properties.Code == "" {
+			return nil, fmt.Errorf("region feature '%s' has no code", feature.Properties.Name)
+		}
+
+		var rawPolygons [][][][2]float64
+		switch feature.Geometry.Type {
+		case "Polygon":
+			var rawPolygon [][][2]float64
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &rawPolygon); err != nil {
+				return nil, fmt.Errorf("failed to decode polygon of region %s: %w", feature.Properties.Code, err)
+			}
+			rawPolygons = [][][][2]float64{rawPolygon}
+		case "MultiPolygon":
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &rawPolygons); err != nil {
+				return nil, fmt.Errorf("failed to decode multipolygon of region %s: %w", feature.Properties.Code, err)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported geometry type '%s' for region %s", feature.Geometry.Type, feature.Properties.Code)
+		}
+
+		region := Region{
+			Code: feature.Properties.Code,
+			Name: feature.Properties.Name,
+			Bounds: BoundingBox{
+				MinLongitude: math.Inf(1),
+				MinLatitude:  math.Inf(1),
+				MaxLongitude: math.Inf(-1),
+				MaxLatitude:  math.Inf(-1),
+			},
+		}
+		for _, rawPolygon := range rawPolygons {
+			polygon := make(Polygon, 0, len(rawPolygon))
+			for _, rawRing := range rawPolygon {
+				if len(rawRing) < 4 {
+					return nil, fmt.Errorf("region %s has a ring with fewer than 4 positions", region.Code)
+				}
+				ring := make([]Point, len(rawRing))
+				for i, position := range rawRing {
+					ring[i] = Point{Longitude: position[0], Latitude: position[1]}
+					region.Bounds.MinLongitude = math.Min(region.Bounds.MinLongitude, position[0])
+					region.Bounds.MaxLongitude = math.Max(region.Bounds.MaxLongitude, position[0])
+					region.Bounds.MinLatitude = math.Min(region.Bounds.MinLatitude, position[1])
+					region.Bounds.MaxLatitude = math.Max(region.Bounds.MaxLatitude, position[1])
+				}
+				polygon = append(polygon, ring)
+			}
+			region.Polygons = append(region.Polygons, polygon)
+		}
+		if len(region.Polygons) == 0 {
+			return nil, fmt.Errorf("region %s has no polygon", region.Code)
+		}
+
+		regions[region.Code] = region
+	}
+
+	return regions, nil
+}
+
+func FranceRegions() (map[string]Region, error) {
+	return ParseRegions(franceRegionsGeoJSON)
+}
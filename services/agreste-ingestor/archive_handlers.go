@@ -0,0 +1,81 @@
+package main
+
+import (
+	"agreste-ingestor/agri_units"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ArchiveUnitHandler archives a unit and its active surveys.
+func (a *App) ArchiveUnitHandler(w http.ResponseWriter, r *http.Request) {
+	unit, ok := a.loadUnit(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := agri_units.ArchiveUnits(a.Transactor, []int{unit.IDNum}, time.Now())
+	if err != nil {
+		log.Printf("Error archiving agricultural unit %d: %v\n", unit.IDNum, err)
+		http.Error(w, fmt.Sprintf("Error archiving agricultural unit: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Archived agricultural unit %d with %d surveys.\n", unit.IDNum, report.Surveys)
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// UnarchiveUnitHandler restores a unit and the surveys archived with it.
+func (a *App) UnarchiveUnitHandler(w http.ResponseWriter, r *http.Request) {
+	unit, ok := a.loadUnit(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := agri_units.UnarchiveUnits(a.Transactor, []int{unit.IDNum}, time.Now())
+	if err != nil {
+		log.Printf("Error unarchiving agricultural unit %d: %v\n", unit.IDNum, err)
+		http.Error(w, fmt.Sprintf("Error unarchiving agricultural unit: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Unarchived agricultural unit %d with %d surveys.\n", unit.IDNum, report.Surveys)
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// ArchiveAbsentUnitsHandler applies the archival policy on demand.
+func (a *App) ArchiveAbsentUnitsHandler(w http.ResponseWriter, r *http.Request) {
+	years := a.ArchiveAbsentYears
+	if raw := r.URL.Query().Get("years"); raw != "" {
+		parsedYears, err := strconv.Atoi(raw)
+		if err != nil || parsedYears <= 0 {
+			http.Error(w, fmt.Sprintf("Invalid 'years' query parameter: '%s', expected a positive integer", raw), http.StatusBadRequest)
+			return
+		}
+		years = parsedYears
+	}
+	if years <= 0 {
+		http.Error(w, "Query parameter 'years' is required when ARCHIVE_ABSENT_UNITS_YEARS is not set", http.StatusBadRequest)
+		return
+	}
+
+	report, err := a.archiveAbsentUnits(years)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error archiving absent agricultural units: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (a *App) archiveAbsentUnits(years int) (agri_units.ArchiveReport, error) {
+	report, err := agri_units.ArchiveAbsentUnits(a.Transactor, years, time.Now())
+	if err != nil {
+		log.Printf("Error archiving agricultural units absent from the latest %d survey years: %v\n", years, err)
+		return report, err
+	}
+	log.Printf("Archived %d agricultural units absent from survey years %v, with %d surveys.\n", report.Units, report.Years, report.Surveys)
+	return report, nil
+}
@@ -0,0 +1,373 @@
+package filters
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Filter is a boolean expression over the columns of a CSV row; a nil *Filter matches every row.
+type Filter struct {
+	source string
+	root   node
+}
+
+type RowValues interface {
+	Get(column string) (string, bool)
+}
+
+func Parse(expression string) (*Filter, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter '%s': %w", expression, err)
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter '%s': %w", expression, err)
+	}
+	if next := p.peek(); next.kind != tokenEOF {
+		return nil, fmt.Errorf("invalid filter '%s': unexpected %s", expression, next.describe())
+	}
+
+	return &Filter{source: strings.TrimSpace(expression), root: root}, nil
+}
+
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.source
+}
+
+func (f *Filter) Columns() []string {
+	if f == nil {
+		return nil
+	}
+	seen := make(map[string]struct{})
+	f.root.collectColumns(seen)
+
+	columns := make([]string, 0, len(seen))
+	for column := range seen {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// Validate checks that every column the filter references exists in header.
+func (f *Filter) Validate(header []string) error {
+	known := make(map[string]struct{}, len(header))
+	for _, column := range header {
+		known[column] = struct{}{}
+	}
+
+	var missing []string
+	for _, column := range f.Columns() {
+		if _, exists := known[column]; !exists {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("filter '%s' references unknown column(s): %s", f.source, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func (f *Filter) Match(row RowValues) bool {
+	if f == nil {
+		return true
+	}
+	return f.root.match(row)
+}
+
+type node interface {
+	match(row RowValues) bool
+	collectColumns(columns map[string]struct{})
+}
+
+type literal struct {
+	text     string
+	number   float64
+	isNumber bool
+}
+
+func newLiteral(t token) (literal, error) {
+	if t.kind == tokenNumber {
+		number, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return literal{}, fmt.Errorf("invalid number %s", t.describe())
+		}
+		return literal{text: t.value, number: number, isNumber: true}, nil
+	}
+	return literal{text: t.value}, nil
+}
+
+func parseNumber(value string) (float64, bool) {
+	value = strings.ReplaceAll(strings.TrimSpace(value), ",", ".")
+	if value == "" {
+		return 0, false
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	return number, err == nil
+}
+
+func compare(cell string, lit literal) (int, bool) {
+	if lit.isNumber {
+		number, ok := parseNumber(cell)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case number < lit.number:
+			return -1, true
+		case number > lit.number:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+	return strings.Compare(strings.TrimSpace(cell), lit.text), true
+}
+
+func cellValue(row RowValues, column string) string {
+	value, _ := row.Get(column)
+	return value
+}
+
+type orNode struct{ left, right node }
+
+func (n orNode) match(row RowValues) bool { return n.left.match(row) || n.right.match(row) }
+func (n orNode) collectColumns(columns map[string]struct{}) {
+	n.left.collectColumns(columns)
+	n.right.collectColumns(columns)
+}
+
+type andNode struct{ left, right node }
+
+func (n andNode) match(row RowValues) bool { return n.left.match(row) && n.right.match(row) }
+func (n andNode) collectColumns(columns map[string]struct{}) {
+	n.left.collectColumns(columns)
+	n.right.collectColumns(columns)
+}
+
+type notNode struct{ inner node }
+
+func (n notNode) match(row RowValues) bool                   { return !n.inner.match(row) }
+func (n notNode) collectColumns(columns map[string]struct{}) { n.inner.collectColumns(columns) }
+
+type compareNode struct {
+	column   string
+	operator string
+	value    literal
+}
+
+func (n compareNode) match(row RowValues) bool {
+	order, ok := compare(cellValue(row, n.column), n.value)
+	if !ok {
+		return n.operator == "!="
+	}
+	switch n.operator {
+	case "=":
+		return order == 0
+	case "!=":
+		return order != 0
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	case ">=":
+		return order >= 0
+	}
+	return false
+}
+
+func (n compareNode) collectColumns(columns map[string]struct{}) { columns[n.column] = struct{}{} }
+
+type inNode struct {
+	column string
+	values []literal
+}
+
+func (n inNode) match(row RowValues) bool {
+	cell := cellValue(row, n.column)
+	for _, value := range n.values {
+		if order, ok := compare(cell, value); ok && order == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (n inNode) collectColumns(columns map[string]struct{}) { columns[n.column] = struct{}{} }
+
+type betweenNode struct {
+	column    string
+	low, high literal
+}
+
+func (n betweenNode) match(row RowValues) bool {
+	cell := cellValue(row, n.column)
+	lowOrder, lowOk := compare(cell, n.low)
+	highOrder, highOk := compare(cell, n.high)
+	return lowOk && highOk && lowOrder >= 0 && highOrder <= 0
+}
+
+func (n betweenNode) collectColumns(columns map[string]struct{}) { columns[n.column] = struct{}{} }
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	t := p.advance()
+	if t.kind != kind {
+		return t, fmt.Errorf("expected %s, got %s", what, t.describe())
+	}
+	return t, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("or") {
+		p.advance()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("and") {
+		p.advance()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.peek().isKeyword("not") {
+		p.advance()
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner: inner}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	if p.peek().kind == tokenLParen {
+		p.advance()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	}
+
+	columnToken, err := p.expect(tokenIdent, "a column name")
+	if err != nil {
+		return nil, err
+	}
+	for _, keyword := range []string{"and", "or", "not", "in", "between"} {
+		if columnToken.isKeyword(keyword) {
+			return nil, fmt.Errorf("expected a column name, got keyword %s", columnToken.describe())
+		}
+	}
+	column := columnToken.text
+
+	next := p.peek()
+	switch {
+	case next.kind == tokenOperator:
+		p.advance()
+		value, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		return compareNode{column: column, operator: next.text, value: value}, nil
+
+	case next.isKeyword("in"):
+		p.advance()
+		if _, err := p.expect(tokenLParen, "'(' after 'in'"); err != nil {
+			return nil, err
+		}
+		var values []literal
+		for {
+			value, err := p.parseLiteral()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			if p.peek().kind != tokenComma {
+				break
+			}
+			p.advance()
+		}
+		if _, err := p.expect(tokenRParen, "')' to close the 'in' list"); err != nil {
+			return nil, err
+		}
+		return inNode{column: column, values: values}, nil
+
+	case next.isKeyword("between"):
+		p.advance()
+		low, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peek().isKeyword("and") {
+			return nil, fmt.Errorf("expected 'and' in 'between', got %s", p.peek().describe())
+		}
+		p.advance()
+		high, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		return betweenNode{column: column, low: low, high: high}, nil
+	}
+
+	return nil, fmt.Errorf("expected a comparison operator, 'in' or 'between' after column '%s', got %s", column, next.describe())
+}
+
+func (p *parser) parseLiteral() (literal, error) {
+	t := p.advance()
+	if t.kind != tokenNumber && t.kind != tokenString {
+		return literal{}, fmt.Errorf("expected a number or a quoted string, got %s", t.describe())
+	}
+	return newLiteral(t)
+}
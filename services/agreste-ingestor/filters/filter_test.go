@@ -0,0 +1,110 @@
+package filters
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testRow map[string]string
+
+func (r testRow) Get(column string) (string, bool) {
+	value, exists := r[column]
+	return value, exists
+}
+
+func TestParseAndMatch(t *testing.T) {
+	cases := []struct {
+		name       string
+		expression string
+		row        testRow
+		expected   bool
+	}{
+		{"EqualNumber", "OTEFDD = 1500", testRow{"OTEFDD": "1500"}, true},
+		{"EqualNumberMismatch", "OTEFDD = 1500", testRow{"OTEFDD": "4500"}, false},
+		{"NumericComparisonIgnoresFormatting", "OTEFDD == 1500", testRow{"OTEFDD": " 1500.0 "}, true},
+		{"DecimalComma", "SAU > 12.4", testRow{"SAU": "12,5"}, true},
+		{"InList", "OTEFDD in (1500, 4500, 3500)", testRow{"OTEFDD": "4500"}, true},
+		{"InListMismatch", "OTEFDD IN (1500, 4500)", testRow{"OTEFDD": "3500"}, false},
+		{"Between", "SAU between 10 and 100", testRow{"SAU": "100"}, true},
+		{"BetweenOutside", "SAU between 10 and 100", testRow{"SAU": "9.99"}, false},
+		{"StringKeepsLeadingZeros", "REGION = '093'", testRow{"REGION": "093"}, true},
+		{"StringDoesNotMatchNumber", "REGION = '093'", testRow{"REGION": "93"}, false},
+		{"AndBindsTighterThanOr", "OTEFDD = 1500 or OTEFDD = 4500 and REGION = '84'", testRow{"OTEFDD": "1500", "REGION": "11"}, true},
+		{"Parentheses", "(OTEFDD = 1500 or OTEFDD = 4500) and REGION = '84'", testRow{"OTEFDD": "1500", "REGION": "11"}, false},
+		{"Not", "not OTEFDD in (1500)", testRow{"OTEFDD": "4500"}, true},
+		{"NonNumericCellNeverEqualsNumber", "OTEFDD = 1500", testRow{"OTEFDD": "n/a"}, false},
+		{"NonNumericCellDiffersFromNumber", "OTEFDD != 1500", testRow{"OTEFDD": ""}, true},
+		{"MissingCellIsEmpty", "REGION = ''", testRow{}, true},
+		{"NegativeNumber", "RESULT < -1", testRow{"RESULT": "-12"}, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			filter, err := Parse(tc.expression)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned an unexpected error: %v", tc.expression, err)
+			}
+			if got := filter.Match(tc.row); got != tc.expected {
+				t.Errorf("Match(%v) for %q = %t, expected %t", tc.row, tc.expression, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expression := range []string{
+		"",
+		"OTEFDD",
+		"OTEFDD = ",
+		"OTEFDD in 1500",
+		"OTEFDD in (1500",
+		"SAU between 1 or 2",
+		"(OTEFDD = 1500",
+		"OTEFDD = 1500 REGION = 1",
+		"and = 1",
+		"OTEFDD ! 1500",
+		"REGION = 'unterminated",
+		"OTEFDD = 1500 ; drop",
+	} {
+		if _, err := Parse(expression); err == nil {
+			t.Errorf("Parse(%q) expected an error, but got none", expression)
+		}
+	}
+}
+
+func TestFilterColumnsAndValidate(t *testing.T) {
+	filter, err := Parse("  OTEFDD in (1500) and (REGION = '84' or SAU > 10 or OTEFDD = 4500)  ")
+	if err != nil {
+		t.Fatalf("Parse returned an unexpected error: %v", err)
+	}
+
+	if filter.String() != "OTEFDD in (1500) and (REGION = '84' or SAU > 10 or OTEFDD = 4500)" {
+		t.Errorf("String() should return the trimmed expression, got %q", filter.String())
+	}
+	if got := filter.Columns(); !reflect.DeepEqual(got, []string{"OTEFDD", "REGION", "SAU"}) {
+		t.Errorf("Columns() mismatch, got %v", got)
+	}
+
+	if err := filter.Validate([]string{"IDNUM", "OTEFDD", "REGION", "SAU"}); err != nil {
+		t.Errorf("Validate returned an unexpected error: %v", err)
+	}
+
+	err = filter.Validate([]string{"IDNUM", "OTEFDD"})
+	if err == nil || !strings.Contains(err.Error(), "REGION, SAU") {
+		t.Errorf("Validate expected an error listing REGION, SAU, got %v", err)
+	}
+}
+
+func TestNilFilterMatchesEverything(t *testing.T) {
+	var filter *Filter
+	if !filter.Match(testRow{}) {
+		t.Errorf("a nil filter should match every row")
+	}
+	if filter.String() != "" || filter.Columns() != nil {
+		t.Errorf("a nil filter should have no expression and no columns")
+	}
+	if err := filter.Validate(nil); err != nil {
+		t.Errorf("a nil filter should always be valid, got %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package filters
+
+import (
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tokens, err := tokenize(`OTEFDD<>1500 and LABEL = "say ""hi""" or X >= -2.5`)
+	if err != nil {
+		t.Fatalf("tokenize returned an unexpected error: %v", err)
+	}
+
+	expected := []struct {
+		kind tokenKind
+		text string
+	}{
+		{tokenIdent, "OTEFDD"},
+		{tokenOperator, "!="},
+		{tokenNumber, "1500"},
+		{tokenIdent, "and"},
+		{tokenIdent, "LABEL"},
+		{tokenOperator, "="},
+		{tokenString, `"say ""hi"""`},
+		{tokenIdent, "or"},
+		{tokenIdent, "X"},
+		{tokenOperator, ">="},
+		{tokenNumber, "-2.5"},
+		{tokenEOF, ""},
+	}
+
+	if len(tokens) != len(expected) {
+		t.Fatalf("expected %d tokens, got %d: %v", len(expected), len(tokens), tokens)
+	}
+	for i, want := range expected {
+		if tokens[i].kind != want.kind || tokens[i].text != want.text {
+			t.Errorf("token %d mismatch. Expected %v %q, got %v %q", i, want.kind, want.text, tokens[i].kind, tokens[i].text)
+		}
+	}
+	if tokens[6].value != `say "hi"` {
+		t.Errorf("string value should unescape doubled quotes, got %q", tokens[6].value)
+	}
+}
+
+func TestTokenizeErrors(t *testing.T) {
+	for _, input := range []string{"A = 'open", "A ! 1", "A = #"} {
+		if _, err := tokenize(input); err == nil {
+			t.Errorf("tokenize(%q) expected an error, but got none", input)
+		}
+	}
+}
@@ -3,6 +3,7 @@ module agreste-ingestor
 go 1.23.1
 
 require (
+	common v0.0.0
 	github.com/Masterminds/squirrel v1.5.4
 	github.com/google/uuid v1.6.0
 	github.com/lib/pq v1.10.9
@@ -13,3 +14,5 @@ require (
 	github.com/lann/builder v0.0.0-20180802200727-47ae307949d0 // indirect
 	github.com/lann/ps v0.0.0-20150810152359-62de8c46ede0 // indirect
 )
+
+replace common => ../common
@@ -0,0 +1,156 @@
+package jobs
+
+import (
+	"agreste-ingestor/agri_units"
+	"agreste-ingestor/misc"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type IngestJobState string
+
+const (
+	IngestJobQueued    IngestJobState = "queued"
+	IngestJobRunning   IngestJobState = "running"
+	IngestJobSucceeded IngestJobState = "succeeded"
+	IngestJobFailed    IngestJobState = "failed"
+)
+
+func (s IngestJobState) IsActive() bool {
+	return s == IngestJobQueued || s == IngestJobRunning
+}
+
+type IngestJob struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	ArchivedAt *time.Time `json:"archivedAt,omitempty"`
+
+	State       IngestJobState `json:"state"`
+	ZipURL      string         `json:"zipUrl"`
+	CSVFileName string         `json:"csvFileName"`
+	CSVFiles    []string       `json:"csvFiles,omitempty"`
+	// Dialect holds the requested overrides of the detected CSV dialect.
+	Dialect        misc.CSVDialect `json:"dialect"`
+	Filter         string          `json:"filter"`
+	Mode           string          `json:"mode"`
+	ExpectedSHA256 string          `json:"expectedSha256,omitempty"`
+	Force          bool            `json:"force"`
+	StartedAt      *time.Time      `json:"startedAt,omitempty"`
+	FinishedAt     *time.Time      `json:"finishedAt,omitempty"`
+
+	// DownloadTotalBytes is 0 until known.
+	DownloadedBytes    int64 `json:"downloadedBytes"`
+	DownloadTotalBytes int64 `json:"downloadTotalBytes"`
+
+	RowsRead         int        `json:"rowsRead"`
+	RowsFiltered     int        `json:"rowsFiltered"`
+	RowsRejected     int        `json:"rowsRejected"`
+	UnitsCreated     int        `json:"unitsCreated"`
+	UnitsRelocated   int        `json:"unitsRelocated"`
+	SurveysCreated   int        `json:"surveysCreated"`
+	SurveysRevised   int        `json:"surveysRevised"`
+	SurveysUnchanged int        `json:"surveysUnchanged"`
+	SourceSHA256     string     `json:"sourceSha256,omitempty"`
+	UnchangedSince   *uuid.UUID `json:"unchangedSince,omitempty"`
+	// DetectedDialect is the dialect the CSV files were read with.
+	DetectedDialect *misc.CSVDialect               `json:"detectedDialect,omitempty"`
+	Files           []agri_units.IngestFileSummary `json:"files,omitempty"`
+	Error           string                         `json:"error,omitempty"`
+}
+
+type IngestJobValue struct {
+	ZipURL      string `json:"zipUrl"`
+	CSVFileName string `json:"csvFileName"`
+	// CSVFiles lists names and globs of CSV files to ingest together, in place of CSVFileName.
+	CSVFiles []string `json:"csvFiles,omitempty"`
+	// Dialect overrides the fields it sets of the detected dialect.
+	Dialect        misc.CSVDialect `json:"dialect,omitempty"`
+	Filter         string          `json:"filter"`
+	Mode           string          `json:"mode"`
+	ExpectedSHA256 string          `json:"expectedSha256,omitempty"`
+	// Force ingests the file even when it has not changed since the last run.
+	Force bool `json:"force"`
+}
+
+func CreateIngestJob(value IngestJobValue) IngestJob {
+	now := time.Now()
+	return IngestJob{
+		ID:             uuid.New(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		State:          IngestJobQueued,
+		ZipURL:         value.ZipURL,
+		CSVFileName:    value.CSVFileName,
+		CSVFiles:       value.CSVFiles,
+		Dialect:        value.Dialect,
+		Filter:         value.Filter,
+		Mode:           value.Mode,
+		ExpectedSHA256: value.ExpectedSHA256,
+		Force:          value.Force,
+	}
+}
+
+// DedupKey identifies the work of a job; two active jobs cannot share one.
+func (j IngestJob) DedupKey() string {
+	dialect := j.Dialect.Encoding + "," + j.Dialect.LineEnding + "," + j.Dialect.Delimiter + "," + j.Dialect.DecimalSeparator
+	return j.ZipURL + "|" + strings.Join(j.MemberPatterns(), ",") + "|" + dialect + "|" + j.Filter + "|" + j.Mode
+}
+
+// MemberPatterns returns the names and globs selecting the CSV files of the job's archive.
+func (j IngestJob) MemberPatterns() []string {
+	if len(j.CSVFiles) > 0 {
+		return j.CSVFiles
+	}
+	if j.CSVFileName != "" {
+		return []string{j.CSVFileName}
+	}
+	return nil
+}
+
+func (j *IngestJob) MarkRunning() {
+	now := time.Now()
+	j.State = IngestJobRunning
+	j.StartedAt = &now
+	j.UpdatedAt = now
+}
+
+func (j *IngestJob) RecordDownload(received, total int64) {
+	j.DownloadedBytes = received
+	j.DownloadTotalBytes = max(total, 0)
+	j.UpdatedAt = time.Now()
+}
+
+func (j *IngestJob) MarkSucceeded(summary agri_units.IngestSummary) {
+	now := time.Now()
+	j.applySummary(summary)
+	j.State = IngestJobSucceeded
+	j.FinishedAt = &now
+	j.UpdatedAt = now
+}
+
+func (j *IngestJob) MarkFailed(summary agri_units.IngestSummary, err error) {
+	now := time.Now()
+	j.applySummary(summary)
+	j.State = IngestJobFailed
+	j.Error = err.Error()
+	j.FinishedAt = &now
+	j.UpdatedAt = now
+}
+
+func (j *IngestJob) applySummary(summary agri_units.IngestSummary) {
+	j.RowsRead = summary.RowsRead
+	j.RowsFiltered = summary.RowsFiltered
+	j.RowsRejected = summary.RowsRejected
+	j.UnitsCreated = summary.UnitsCreated
+	j.UnitsRelocated = summary.UnitsRelocated
+	j.SurveysCreated = summary.SurveysCreated
+	j.SurveysRevised = summary.SurveysRevised
+	j.SurveysUnchanged = summary.SurveysUnchanged
+	j.SourceSHA256 = summary.SourceSHA256
+	j.UnchangedSince = summary.UnchangedSince
+	j.DetectedDialect = summary.Dialect
+	j.Files = summary.Files
+}
@@ -0,0 +1,269 @@
+package jobs
+
+import (
+	"agreste-ingestor/agri_units"
+	"agreste-ingestor/sources"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var errLeaseExpired = errors.New("ingestion interrupted: its process stopped renewing the job's lease")
+
+const (
+	ingestJobLease  = time.Minute
+	persistAttempts = 3
+)
+
+// IngestFunc runs a job, reporting the download of its source to progress.
+type IngestFunc func(job IngestJob, progress sources.ProgressFunc) (agri_units.IngestSummary, error)
+
+// IngestJobRunner runs persisted ingest jobs one at a time in the background.
+type IngestJobRunner struct {
+	storage    IngestJobStorage
+	ingest     IngestFunc
+	lease      time.Duration
+	retryDelay time.Duration
+
+	mu      sync.Mutex
+	pending []uuid.UUID
+	active  map[string]uuid.UUID
+	wake    chan struct{}
+}
+
+func NewIngestJobRunner(storage IngestJobStorage, ingest IngestFunc) *IngestJobRunner {
+	return &IngestJobRunner{
+		storage:    storage,
+		ingest:     ingest,
+		lease:      ingestJobLease,
+		retryDelay: time.Second,
+		active:     make(map[string]uuid.UUID),
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// Submit queues a new job, or returns the active job for the same file with created false.
+func (r *IngestJobRunner) Submit(value IngestJobValue) (job IngestJob, created bool, err error) {
+	job = CreateIngestJob(value)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existingID, exists := r.active[job.DedupKey()]; exists {
+		existing, err := r.storage.SelectByID(existingID)
+		if err != nil {
+			return IngestJob{}, false, fmt.Errorf("failed to load deduplicated ingest job: %w", err)
+		}
+		return existing, false, nil
+	}
+
+	existing, found, err := r.findActive(job.DedupKey())
+	if err != nil {
+		return IngestJob{}, false, err
+	}
+	if found {
+		return existing, false, nil
+	}
+
+	err = r.storage.InsertOrUpdate(job)
+	if errors.Is(err, ErrActiveIngestJobExists) {
+		existing, found, err = r.findActive(job.DedupKey())
+		if err != nil {
+			return IngestJob{}, false, err
+		}
+		if !found {
+			return IngestJob{}, false, fmt.Errorf("failed to load deduplicated ingest job: %w", ErrIngestJobNotFound)
+		}
+		return existing, false, nil
+	}
+	if err != nil {
+		return IngestJob{}, false, fmt.Errorf("failed to persist ingest job: %w", err)
+	}
+
+	r.enqueueLocked(job)
+	return job, true, nil
+}
+
+func (r *IngestJobRunner) findActive(dedupKey string) (IngestJob, bool, error) {
+	activeJobs, err := r.storage.SelectActive()
+	if err != nil {
+		return IngestJob{}, false, fmt.Errorf("failed to select active ingest jobs: %w", err)
+	}
+	for _, existing := range activeJobs {
+		if existing.DedupKey() == dedupKey {
+			return existing, true, nil
+		}
+	}
+	return IngestJob{}, false, nil
+}
+
+// Recover fails the running jobs whose lease expired and queues the queued ones.
+func (r *IngestJobRunner) Recover() error {
+	expired, err := r.storage.FailExpired(errLeaseExpired.Error())
+	if err != nil {
+		return fmt.Errorf("failed to fail expired ingest jobs: %w", err)
+	}
+	if expired > 0 {
+		log.Printf("Failed %d ingest jobs whose lease expired.\n", expired)
+	}
+
+	activeJobs, err := r.storage.SelectActive()
+	if err != nil {
+		return fmt.Errorf("failed to select active ingest jobs: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, job := range activeJobs {
+		if job.State != IngestJobQueued {
+			continue
+		}
+		if _, exists := r.active[job.DedupKey()]; exists {
+			continue
+		}
+		r.enqueueLocked(job)
+	}
+
+	return nil
+}
+
+func (r *IngestJobRunner) enqueueLocked(job IngestJob) {
+	r.active[job.DedupKey()] = job.ID
+	r.pending = append(r.pending, job.ID)
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run processes queued jobs until ctx is cancelled.
+func (r *IngestJobRunner) Run(ctx context.Context) {
+	recovery := time.NewTicker(r.lease)
+	defer recovery.Stop()
+
+	for {
+		jobID, ok := r.next()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-r.wake:
+				continue
+			case <-recovery.C:
+				if err := r.Recover(); err != nil {
+					log.Printf("Failed to recover ingest jobs: %v\n", err)
+				}
+				continue
+			}
+		}
+
+		r.runJob(jobID)
+	}
+}
+
+func (r *IngestJobRunner) next() (uuid.UUID, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.pending) == 0 {
+		return uuid.Nil, false
+	}
+	jobID := r.pending[0]
+	r.pending = r.pending[1:]
+	return jobID, true
+}
+
+func (r *IngestJobRunner) runJob(jobID uuid.UUID) {
+	defer r.release(jobID)
+
+	job, err := r.storage.Claim(jobID, r.lease)
+	if errors.Is(err, ErrIngestJobNotClaimed) {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to claim ingest job %s: %v\n", jobID, err)
+		r.fail(jobID, fmt.Errorf("failed to start: %w", err))
+		return
+	}
+	stopRenewing := r.renewLease(job.ID)
+	defer stopRenewing()
+
+	log.Printf("Running ingest job %s: ZipURL='%s', CSVFileName='%s'\n", job.ID, job.ZipURL, job.CSVFileName)
+	progress := func(received, total int64) {
+		job.RecordDownload(received, total)
+		if err := r.storage.InsertOrUpdate(job); err != nil {
+			log.Printf("Failed to record download progress of ingest job %s: %v\n", job.ID, err)
+		}
+	}
+	summary, err := r.ingest(job, progress)
+	if err != nil {
+		log.Printf("Ingest job %s failed: %v\n", job.ID, err)
+		job.MarkFailed(summary, err)
+	} else {
+		log.Printf("Ingest job %s succeeded.\n", job.ID)
+		job.MarkSucceeded(summary)
+	}
+
+	err = r.retry("persist final state of", job.ID, func() error { return r.storage.InsertOrUpdate(job) })
+	if err != nil {
+		r.fail(job.ID, fmt.Errorf("failed to persist final state: %w", err))
+	}
+}
+
+func (r *IngestJobRunner) renewLease(jobID uuid.UUID) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(r.lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := r.storage.RenewLease(jobID, r.lease); err != nil {
+					log.Printf("Failed to renew the lease of ingest job %s: %v\n", jobID, err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func (r *IngestJobRunner) fail(jobID uuid.UUID, cause error) {
+	err := r.retry("mark failed", jobID, func() error { return r.storage.Fail(jobID, cause.Error()) })
+	if err != nil {
+		log.Printf("Giving up on ingest job %s: %v\n", jobID, err)
+	}
+}
+
+func (r *IngestJobRunner) retry(action string, jobID uuid.UUID, write func() error) error {
+	var err error
+	for attempt := 1; attempt <= persistAttempts; attempt++ {
+		if err = write(); err == nil {
+			return nil
+		}
+		log.Printf("Failed to %s ingest job %s, attempt %d of %d: %v\n", action, jobID, attempt, persistAttempts, err)
+		if attempt < persistAttempts {
+			time.Sleep(time.Duration(attempt) * r.retryDelay)
+		}
+	}
+	return err
+}
+
+func (r *IngestJobRunner) release(jobID uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, id := range r.active {
+		if id == jobID {
+			delete(r.active, key)
+		}
+	}
+}
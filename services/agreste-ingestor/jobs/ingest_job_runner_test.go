@@ -0,0 +1,368 @@
+package jobs
+
+import (
+	"agreste-ingestor/agri_units"
+	"agreste-ingestor/sources"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type MockIngestJobStorage struct {
+	mu     sync.Mutex
+	Jobs   map[uuid.UUID]IngestJob
+	Leases map[uuid.UUID]time.Time
+}
+
+func NewMockIngestJobStorage(jobs ...IngestJob) *MockIngestJobStorage {
+	m := &MockIngestJobStorage{Jobs: make(map[uuid.UUID]IngestJob), Leases: make(map[uuid.UUID]time.Time)}
+	for _, job := range jobs {
+		m.Jobs[job.ID] = job
+	}
+	return m
+}
+
+// InsertOrUpdate rejects a second active job with the same dedup key, like the table.
+func (m *MockIngestJobStorage) InsertOrUpdate(job IngestJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, stored := range m.Jobs {
+		if stored.ID != job.ID && stored.State.IsActive() && job.State.IsActive() && stored.DedupKey() == job.DedupKey() {
+			return fmt.Errorf("%w: %s", ErrActiveIngestJobExists, job.DedupKey())
+		}
+	}
+	m.Jobs[job.ID] = job
+	return nil
+}
+
+// racingIngestJobStorage stores other right after the first lookup of the active jobs.
+type racingIngestJobStorage struct {
+	*MockIngestJobStorage
+	other IngestJob
+	raced bool
+}
+
+func (s *racingIngestJobStorage) SelectActive() ([]IngestJob, error) {
+	active, err := s.MockIngestJobStorage.SelectActive()
+	if !s.raced {
+		s.raced = true
+		if err := s.MockIngestJobStorage.InsertOrUpdate(s.other); err != nil {
+			return nil, err
+		}
+	}
+	return active, err
+}
+
+func (m *MockIngestJobStorage) SelectByID(id uuid.UUID) (IngestJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, exists := m.Jobs[id]
+	if !exists {
+		return IngestJob{}, fmt.Errorf("%w: %s", ErrIngestJobNotFound, id)
+	}
+	return job, nil
+}
+
+func (m *MockIngestJobStorage) SelectRecent(limit uint64) ([]IngestJob, error) {
+	return m.SelectActive()
+}
+
+func (m *MockIngestJobStorage) SelectActive() ([]IngestJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var active []IngestJob
+	for _, job := range m.Jobs {
+		if job.State.IsActive() {
+			active = append(active, job)
+		}
+	}
+	return active, nil
+}
+
+func (m *MockIngestJobStorage) SelectLastIngested(job IngestJob) (IngestJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var last *IngestJob
+	for _, stored := range m.Jobs {
+		if stored.State != IngestJobSucceeded || stored.UnchangedSince != nil || stored.SourceSHA256 == "" ||
+			stored.ZipURL != job.ZipURL || stored.CSVFileName != job.CSVFileName || stored.Filter != job.Filter || stored.Mode != job.Mode {
+			continue
+		}
+		if last == nil || stored.FinishedAt.After(*last.FinishedAt) {
+			last = &stored
+		}
+	}
+	if last == nil {
+		return IngestJob{}, fmt.Errorf("%w: no ingested run of %s", ErrIngestJobNotFound, job.ZipURL)
+	}
+	return *last, nil
+}
+
+func (m *MockIngestJobStorage) Claim(id uuid.UUID, lease time.Duration) (IngestJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, exists := m.Jobs[id]
+	if !exists || job.State != IngestJobQueued {
+		return IngestJob{}, fmt.Errorf("%w: %s", ErrIngestJobNotClaimed, id)
+	}
+	job.MarkRunning()
+	m.Jobs[id] = job
+	m.Leases[id] = time.Now().Add(lease)
+	return job, nil
+}
+
+func (m *MockIngestJobStorage) RenewLease(id uuid.UUID, lease time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Jobs[id].State == IngestJobRunning {
+		m.Leases[id] = time.Now().Add(lease)
+	}
+	return nil
+}
+
+func (m *MockIngestJobStorage) Fail(id uuid.UUID, message string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if job, exists := m.Jobs[id]; exists && job.State.IsActive() {
+		job.MarkFailed(agri_units.IngestSummary{}, errors.New(message))
+		m.Jobs[id] = job
+	}
+	return nil
+}
+
+func (m *MockIngestJobStorage) FailExpired(message string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var expired int64
+	for id, job := range m.Jobs {
+		if lease, leased := m.Leases[id]; job.State == IngestJobRunning && (!leased || lease.Before(time.Now())) {
+			job.MarkFailed(agri_units.IngestSummary{}, errors.New(message))
+			m.Jobs[id] = job
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// flakyIngestJobStorage fails as many claims, final states and Fail calls as its counters hold.
+type flakyIngestJobStorage struct {
+	*MockIngestJobStorage
+	claimFailures int
+	finalFailures int
+	failFailures  int
+}
+
+func (s *flakyIngestJobStorage) Claim(id uuid.UUID, lease time.Duration) (IngestJob, error) {
+	if s.claimFailures > 0 {
+		s.claimFailures--
+		return IngestJob{}, errors.New("connection reset")
+	}
+	return s.MockIngestJobStorage.Claim(id, lease)
+}
+
+func (s *flakyIngestJobStorage) InsertOrUpdate(job IngestJob) error {
+	if !job.State.IsActive() && s.finalFailures > 0 {
+		s.finalFailures--
+		return errors.New("connection reset")
+	}
+	return s.MockIngestJobStorage.InsertOrUpdate(job)
+}
+
+func (s *flakyIngestJobStorage) Fail(id uuid.UUID, message string) error {
+	if s.failFailures > 0 {
+		s.failFailures--
+		return errors.New("connection reset")
+	}
+	return s.MockIngestJobStorage.Fail(id, message)
+}
+
+func waitForState(t *testing.T, storage *MockIngestJobStorage, id uuid.UUID, state IngestJobState) IngestJob {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		job, _ := storage.SelectByID(id)
+		if job.State == state {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	job, _ := storage.SelectByID(id)
+	t.Fatalf("job %s did not reach state %s, last state %s", id, state, job.State)
+	return job
+}
+
+func TestIngestJobRunner_RunsSubmittedJobs(t *testing.T) {
+	storage := NewMockIngestJobStorage()
+	release := make(chan struct{})
+
+	runner := NewIngestJobRunner(storage, func(job IngestJob, progress sources.ProgressFunc) (agri_units.IngestSummary, error) {
+		<-release
+		progress(1024, 2048)
+		progress(2048, 2048)
+		if job.CSVFileName == "broken.csv" {
+			return agri_units.IngestSummary{RowsRead: 1}, errors.New("broken file")
+		}
+		return agri_units.IngestSummary{RowsRead: 3, UnitsCreated: 1, SurveysCreated: 2}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go runner.Run(ctx)
+
+	first, created, err := runner.Submit(IngestJobValue{ZipURL: "https://example.org/rica.zip", CSVFileName: "rica.csv"})
+	if err != nil || !created {
+		t.Fatalf("Submit expected to create a job, got created=%t err=%v", created, err)
+	}
+
+	duplicate, created, err := runner.Submit(IngestJobValue{ZipURL: "https://example.org/rica.zip", CSVFileName: "rica.csv"})
+	if err != nil {
+		t.Fatalf("Submit returned an unexpected error: %v", err)
+	}
+	if created || duplicate.ID != first.ID {
+		t.Errorf("duplicate submission should return job %s, got %s (created=%t)", first.ID, duplicate.ID, created)
+	}
+
+	broken, created, err := runner.Submit(IngestJobValue{ZipURL: "https://example.org/rica.zip", CSVFileName: "broken.csv"})
+	if err != nil || !created {
+		t.Fatalf("Submit expected to create a job for another file, got created=%t err=%v", created, err)
+	}
+
+	close(release)
+
+	done := waitForState(t, storage, first.ID, IngestJobSucceeded)
+	if done.RowsRead != 3 || done.SurveysCreated != 2 || done.StartedAt == nil || done.FinishedAt == nil || done.DownloadedBytes != 2048 || done.DownloadTotalBytes != 2048 {
+		t.Errorf("unexpected succeeded job: %+v", done)
+	}
+
+	failed := waitForState(t, storage, broken.ID, IngestJobFailed)
+	if failed.Error != "broken file" {
+		t.Errorf("expected error 'broken file', got '%s'", failed.Error)
+	}
+
+	filtered, created, err := runner.Submit(IngestJobValue{ZipURL: "https://example.org/rica.zip", CSVFileName: "rica.csv", Filter: "OTEFDD = 4500"})
+	if err != nil || !created {
+		t.Errorf("a different filter should not be deduplicated, got created=%t err=%v", created, err)
+	}
+	waitForState(t, storage, filtered.ID, IngestJobSucceeded)
+
+	again, created, err := runner.Submit(IngestJobValue{ZipURL: "https://example.org/rica.zip", CSVFileName: "rica.csv"})
+	if err != nil || !created || again.ID == first.ID {
+		t.Errorf("a finished job should not deduplicate new submissions, got created=%t err=%v", created, err)
+	}
+}
+
+func TestIngestJobRunner_SubmitReturnsJobQueuedConcurrently(t *testing.T) {
+	other := CreateIngestJob(IngestJobValue{ZipURL: "https://example.org/rica.zip", CSVFileName: "rica.csv"})
+	storage := &racingIngestJobStorage{MockIngestJobStorage: NewMockIngestJobStorage(), other: other}
+	runner := NewIngestJobRunner(storage, func(job IngestJob, progress sources.ProgressFunc) (agri_units.IngestSummary, error) {
+		return agri_units.IngestSummary{}, nil
+	})
+
+	job, created, err := runner.Submit(IngestJobValue{ZipURL: "https://example.org/rica.zip", CSVFileName: "rica.csv"})
+	if err != nil {
+		t.Fatalf("Submit returned an unexpected error: %v", err)
+	}
+	if created || job.ID != other.ID {
+		t.Errorf("Submit should return the job %s queued concurrently, got %s (created=%t)", other.ID, job.ID, created)
+	}
+	if len(storage.Jobs) != 1 {
+		t.Errorf("expected only the concurrently queued job to be stored, got %d jobs", len(storage.Jobs))
+	}
+}
+
+func TestIngestJobRunner_Recover(t *testing.T) {
+	queued := CreateIngestJob(IngestJobValue{ZipURL: "https://example.org/a.zip", CSVFileName: "a.csv"})
+	expired := CreateIngestJob(IngestJobValue{ZipURL: "https://example.org/b.zip", CSVFileName: "b.csv"})
+	expired.MarkRunning()
+	leased := CreateIngestJob(IngestJobValue{ZipURL: "https://example.org/c.zip", CSVFileName: "c.csv"})
+	leased.MarkRunning()
+	storage := NewMockIngestJobStorage(queued, expired, leased)
+	storage.Leases[expired.ID] = time.Now().Add(-time.Second)
+	// leased is run by another process, which keeps renewing its lease.
+	storage.Leases[leased.ID] = time.Now().Add(time.Hour)
+
+	runner := NewIngestJobRunner(storage, func(job IngestJob, progress sources.ProgressFunc) (agri_units.IngestSummary, error) {
+		return agri_units.IngestSummary{}, nil
+	})
+
+	if err := runner.Recover(); err != nil {
+		t.Fatalf("Recover returned an unexpected error: %v", err)
+	}
+
+	interrupted, _ := storage.SelectByID(expired.ID)
+	if interrupted.State != IngestJobFailed || interrupted.Error == "" {
+		t.Errorf("running job with an expired lease should be failed after recovery, got %s / '%s'", interrupted.State, interrupted.Error)
+	}
+	if live, _ := storage.SelectByID(leased.ID); live.State != IngestJobRunning {
+		t.Errorf("running job of another process should be left alone, got %s", live.State)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go runner.Run(ctx)
+
+	waitForState(t, storage, queued.ID, IngestJobSucceeded)
+}
+
+func TestIngestJobRunner_SkipsJobClaimedElsewhere(t *testing.T) {
+	job := CreateIngestJob(IngestJobValue{ZipURL: "https://example.org/a.zip", CSVFileName: "a.csv"})
+	storage := NewMockIngestJobStorage(job)
+	runner := NewIngestJobRunner(storage, func(job IngestJob, progress sources.ProgressFunc) (agri_units.IngestSummary, error) {
+		t.Errorf("job %s claimed by another process should not run here", job.ID)
+		return agri_units.IngestSummary{}, nil
+	})
+	if err := runner.Recover(); err != nil {
+		t.Fatalf("Recover returned an unexpected error: %v", err)
+	}
+	if _, err := storage.Claim(job.ID, time.Hour); err != nil {
+		t.Fatalf("Claim returned an unexpected error: %v", err)
+	}
+
+	jobID, _ := runner.next()
+	runner.runJob(jobID)
+
+	if claimed, _ := storage.SelectByID(job.ID); claimed.State != IngestJobRunning {
+		t.Errorf("job should stay running for the process that claimed it, got %s", claimed.State)
+	}
+}
+
+func TestIngestJobRunner_PersistFailures(t *testing.T) {
+	succeed := func(job IngestJob, progress sources.ProgressFunc) (agri_units.IngestSummary, error) {
+		return agri_units.IngestSummary{RowsRead: 3}, nil
+	}
+	cases := []struct {
+		name     string
+		storage  func(*MockIngestJobStorage) *flakyIngestJobStorage
+		expected IngestJobState
+	}{
+		{"FinalStateRetried", func(m *MockIngestJobStorage) *flakyIngestJobStorage {
+			return &flakyIngestJobStorage{MockIngestJobStorage: m, finalFailures: persistAttempts - 1}
+		}, IngestJobSucceeded},
+		{"FinalStateLost", func(m *MockIngestJobStorage) *flakyIngestJobStorage {
+			return &flakyIngestJobStorage{MockIngestJobStorage: m, finalFailures: persistAttempts}
+		}, IngestJobFailed},
+		{"ClaimFailed", func(m *MockIngestJobStorage) *flakyIngestJobStorage {
+			return &flakyIngestJobStorage{MockIngestJobStorage: m, claimFailures: 1, failFailures: persistAttempts - 1}
+		}, IngestJobFailed},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			job := CreateIngestJob(IngestJobValue{ZipURL: "https://example.org/a.zip", CSVFileName: "a.csv"})
+			storage := NewMockIngestJobStorage(job)
+			runner := NewIngestJobRunner(tc.storage(storage), succeed)
+			runner.retryDelay = time.Millisecond
+
+			runner.runJob(job.ID)
+
+			if stored, _ := storage.SelectByID(job.ID); stored.State != tc.expected {
+				t.Errorf("expected job in state %s, got %s (%s)", tc.expected, stored.State, stored.Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,556 @@
+package jobs
+
+import (
+	"agreste-ingestor/agri_units"
+	"agreste-ingestor/misc"
+	"common/storage"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var ErrIngestJobNotFound = errors.New("ingest job not found")
+
+var ErrActiveIngestJobExists = errors.New("an ingest job with the same dedup key is already queued or running")
+
+var ErrIngestJobNotClaimed = errors.New("ingest job is no longer queued")
+
+const activeDedupKeyIndex = "ingest_jobs_active_dedup_key_idx"
+
+type IngestJobSqlView struct {
+	ID         string       `db:"id"`
+	CreatedAt  time.Time    `db:"created_at"`
+	UpdatedAt  time.Time    `db:"updated_at"`
+	ArchivedAt sql.NullTime `db:"archived_at"`
+
+	State              string         `db:"state"`
+	ZipURL             string         `db:"zip_url"`
+	CSVFileName        string         `db:"csv_file_name"`
+	CSVFiles           []byte         `db:"csv_files"`
+	Dialect            []byte         `db:"dialect"`
+	Filter             string         `db:"filter"`
+	Mode               string         `db:"mode"`
+	ExpectedSHA256     string         `db:"expected_sha256"`
+	Force              bool           `db:"force"`
+	StartedAt          sql.NullTime   `db:"started_at"`
+	FinishedAt         sql.NullTime   `db:"finished_at"`
+	DownloadedBytes    int64          `db:"downloaded_bytes"`
+	DownloadTotalBytes int64          `db:"download_total_bytes"`
+	RowsRead           int            `db:"rows_read"`
+	RowsFiltered       int            `db:"rows_filtered"`
+	RowsRejected       int            `db:"rows_rejected"`
+	UnitsCreated       int            `db:"units_created"`
+	UnitsRelocated     int            `db:"units_relocated"`
+	SurveysCreated     int            `db:"surveys_created"`
+	SurveysRevised     int            `db:"surveys_revised"`
+	SurveysUnchanged   int            `db:"surveys_unchanged"`
+	SourceSHA256       string         `db:"source_sha256"`
+	UnchangedSince     sql.NullString `db:"unchanged_since"`
+	DetectedDialect    []byte         `db:"detected_dialect"`
+	Files              []byte         `db:"files"`
+	Error              sql.NullString `db:"error"`
+	DedupKey           sql.NullString `db:"dedup_key"`
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{Valid: false}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func nullUUID(id *uuid.UUID) sql.NullString {
+	if id == nil {
+		return sql.NullString{Valid: false}
+	}
+	return sql.NullString{String: id.String(), Valid: true}
+}
+
+func marshalCSVFiles(csvFiles []string) ([]byte, error) {
+	if len(csvFiles) == 0 {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(csvFiles)
+}
+
+func IngestJobToSqlView(job IngestJob) (IngestJobSqlView, error) {
+	csvFiles, err := marshalCSVFiles(job.CSVFiles)
+	if err != nil {
+		return IngestJobSqlView{}, fmt.Errorf("failed to marshal CSV files to JSON for SQL view: %w", err)
+	}
+	dialect, err := json.Marshal(job.Dialect)
+	if err != nil {
+		return IngestJobSqlView{}, fmt.Errorf("failed to marshal dialect to JSON for SQL view: %w", err)
+	}
+	var detectedDialect []byte
+	if job.DetectedDialect != nil {
+		detectedDialect, err = json.Marshal(job.DetectedDialect)
+		if err != nil {
+			return IngestJobSqlView{}, fmt.Errorf("failed to marshal detected dialect to JSON for SQL view: %w", err)
+		}
+	}
+	var files []byte
+	if len(job.Files) > 0 {
+		files, err = json.Marshal(job.Files)
+		if err != nil {
+			return IngestJobSqlView{}, fmt.Errorf("failed to marshal file summaries to JSON for SQL view: %w", err)
+		}
+	}
+
+	return IngestJobSqlView{
+		ID:                 job.ID.String(),
+		CreatedAt:          job.CreatedAt,
+		UpdatedAt:          job.UpdatedAt,
+		ArchivedAt:         nullTime(job.ArchivedAt),
+		State:              string(job.State),
+		ZipURL:             job.ZipURL,
+		CSVFileName:        job.CSVFileName,
+		CSVFiles:           csvFiles,
+		Dialect:            dialect,
+		Filter:             job.Filter,
+		Mode:               job.Mode,
+		ExpectedSHA256:     job.ExpectedSHA256,
+		Force:              job.Force,
+		StartedAt:          nullTime(job.StartedAt),
+		FinishedAt:         nullTime(job.FinishedAt),
+		DownloadedBytes:    job.DownloadedBytes,
+		DownloadTotalBytes: job.DownloadTotalBytes,
+		RowsRead:           job.RowsRead,
+		RowsFiltered:       job.RowsFiltered,
+		RowsRejected:       job.RowsRejected,
+		UnitsCreated:       job.UnitsCreated,
+		UnitsRelocated:     job.UnitsRelocated,
+		SurveysCreated:     job.SurveysCreated,
+		SurveysRevised:     job.SurveysRevised,
+		SurveysUnchanged:   job.SurveysUnchanged,
+		SourceSHA256:       job.SourceSHA256,
+		UnchangedSince:     nullUUID(job.UnchangedSince),
+		DetectedDialect:    detectedDialect,
+		Files:              files,
+		Error:              sql.NullString{String: job.Error, Valid: job.Error != ""},
+		DedupKey:           sql.NullString{String: job.DedupKey(), Valid: true},
+	}, nil
+}
+
+func IngestJobFromSqlView(sqlView IngestJobSqlView) (IngestJob, error) {
+	parsedID, err := uuid.Parse(sqlView.ID)
+	if err != nil {
+		return IngestJob{}, fmt.Errorf("failed to parse UUID from SQL view '%s': %w", sqlView.ID, err)
+	}
+	var unchangedSince *uuid.UUID
+	if sqlView.UnchangedSince.Valid {
+		parsedRunID, err := uuid.Parse(sqlView.UnchangedSince.String)
+		if err != nil {
+			return IngestJob{}, fmt.Errorf("failed to parse unchanged_since UUID from SQL view '%s': %w", sqlView.UnchangedSince.String, err)
+		}
+		unchangedSince = &parsedRunID
+	}
+	var csvFiles []string
+	if len(sqlView.CSVFiles) > 0 {
+		if err := json.Unmarshal(sqlView.CSVFiles, &csvFiles); err != nil {
+			return IngestJob{}, fmt.Errorf("failed to unmarshal CSV files JSON from SQL view: %w", err)
+		}
+	}
+	var dialect misc.CSVDialect
+	if len(sqlView.Dialect) > 0 {
+		if err := json.Unmarshal(sqlView.Dialect, &dialect); err != nil {
+			return IngestJob{}, fmt.Errorf("failed to unmarshal dialect JSON from SQL view: %w", err)
+		}
+	}
+	var detectedDialect *misc.CSVDialect
+	if len(sqlView.DetectedDialect) > 0 {
+		if err := json.Unmarshal(sqlView.DetectedDialect, &detectedDialect); err != nil {
+			return IngestJob{}, fmt.Errorf("failed to unmarshal detected dialect JSON from SQL view: %w", err)
+		}
+	}
+	var files []agri_units.IngestFileSummary
+	if len(sqlView.Files) > 0 {
+		if err := json.Unmarshal(sqlView.Files, &files); err != nil {
+			return IngestJob{}, fmt.Errorf("failed to unmarshal file summaries JSON from SQL view: %w", err)
+		}
+	}
+
+	return IngestJob{
+		ID:                 parsedID,
+		CreatedAt:          sqlView.CreatedAt,
+		UpdatedAt:          sqlView.UpdatedAt,
+		ArchivedAt:         timePtr(sqlView.ArchivedAt),
+		State:              IngestJobState(sqlView.State),
+		ZipURL:             sqlView.ZipURL,
+		CSVFileName:        sqlView.CSVFileName,
+		CSVFiles:           csvFiles,
+		Dialect:            dialect,
+		Filter:             sqlView.Filter,
+		Mode:               sqlView.Mode,
+		ExpectedSHA256:     sqlView.ExpectedSHA256,
+		Force:              sqlView.Force,
+		StartedAt:          timePtr(sqlView.StartedAt),
+		FinishedAt:         timePtr(sqlView.FinishedAt),
+		DownloadedBytes:    sqlView.DownloadedBytes,
+		DownloadTotalBytes: sqlView.DownloadTotalBytes,
+		RowsRead:           sqlView.RowsRead,
+		RowsFiltered:       sqlView.RowsFiltered,
+		RowsRejected:       sqlView.RowsRejected,
+		UnitsCreated:       sqlView.UnitsCreated,
+		UnitsRelocated:     sqlView.UnitsRelocated,
+		SurveysCreated:     sqlView.SurveysCreated,
+		SurveysRevised:     sqlView.SurveysRevised,
+		SurveysUnchanged:   sqlView.SurveysUnchanged,
+		SourceSHA256:       sqlView.SourceSHA256,
+		UnchangedSince:     unchangedSince,
+		DetectedDialect:    detectedDialect,
+		Files:              files,
+		Error:              sqlView.Error.String,
+	}, nil
+}
+
+type IngestJobStorage interface {
+	InsertOrUpdate(job IngestJob) error
+	SelectByID(id uuid.UUID) (IngestJob, error)
+	SelectRecent(limit uint64) ([]IngestJob, error)
+	SelectActive() ([]IngestJob, error)
+	// SelectLastIngested returns the last job that ingested the same source, filter and mode.
+	SelectLastIngested(job IngestJob) (IngestJob, error)
+	// Claim marks a queued job running, leased to the caller for lease.
+	Claim(id uuid.UUID, lease time.Duration) (IngestJob, error)
+	// RenewLease extends the lease of a running job.
+	RenewLease(id uuid.UUID, lease time.Duration) error
+	// Fail marks a queued or running job failed with message.
+	Fail(id uuid.UUID, message string) error
+	// FailExpired fails the running jobs whose lease expired and returns their count.
+	FailExpired(message string) (int64, error)
+}
+
+type ingestJobStorage struct {
+	querier storage.DBQuerier
+	builder sq.StatementBuilderType
+}
+
+func NewIngestJobStorage(querier storage.DBQuerier) IngestJobStorage {
+	return &ingestJobStorage{
+		querier: querier,
+		builder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+}
+
+var ingestJobColumns = []string{
+	"id",
+	"created_at",
+	"updated_at",
+	"archived_at",
+	"state",
+	"zip_url",
+	"csv_file_name",
+	"csv_files",
+	"dialect",
+	"filter",
+	"mode",
+	"expected_sha256",
+	"force",
+	"started_at",
+	"finished_at",
+	"downloaded_bytes",
+	"download_total_bytes",
+	"rows_read",
+	"rows_filtered",
+	"rows_rejected",
+	"units_created",
+	"units_relocated",
+	"surveys_created",
+	"surveys_revised",
+	"surveys_unchanged",
+	"source_sha256",
+	"unchanged_since",
+	"detected_dialect",
+	"files",
+	"error",
+	"dedup_key",
+}
+
+func (s *ingestJobStorage) InsertOrUpdate(job IngestJob) error {
+	sqlView, err := IngestJobToSqlView(job)
+	if err != nil {
+		return fmt.Errorf("failed to convert IngestJob to SQL view: %w", err)
+	}
+
+	builder := s.builder.Insert("ingest_jobs").
+		Columns(ingestJobColumns...).
+		Values(
+			sqlView.ID,
+			sqlView.CreatedAt,
+			sqlView.UpdatedAt,
+			sqlView.ArchivedAt,
+			sqlView.State,
+			sqlView.ZipURL,
+			sqlView.CSVFileName,
+			sqlView.CSVFiles,
+			sqlView.Dialect,
+			sqlView.Filter,
+			sqlView.Mode,
+			sqlView.ExpectedSHA256,
+			sqlView.Force,
+			sqlView.StartedAt,
+			sqlView.FinishedAt,
+			sqlView.DownloadedBytes,
+			sqlView.DownloadTotalBytes,
+			sqlView.RowsRead,
+			sqlView.RowsFiltered,
+			sqlView.RowsRejected,
+			sqlView.UnitsCreated,
+			sqlView.UnitsRelocated,
+			sqlView.SurveysCreated,
+			sqlView.SurveysRevised,
+			sqlView.SurveysUnchanged,
+			sqlView.SourceSHA256,
+			sqlView.UnchangedSince,
+			sqlView.DetectedDialect,
+			sqlView.Files,
+			sqlView.Error,
+			sqlView.DedupKey,
+		).
+		Suffix(`
+			ON CONFLICT (id) DO UPDATE SET
+				updated_at = EXCLUDED.updated_at,
+				archived_at = EXCLUDED.archived_at,
+				state = EXCLUDED.state,
+				started_at = EXCLUDED.started_at,
+				finished_at = EXCLUDED.finished_at,
+				downloaded_bytes = EXCLUDED.downloaded_bytes,
+				download_total_bytes = EXCLUDED.download_total_bytes,
+				rows_read = EXCLUDED.rows_read,
+				rows_filtered = EXCLUDED.rows_filtered,
+				rows_rejected = EXCLUDED.rows_rejected,
+				units_created = EXCLUDED.units_created,
+				units_relocated = EXCLUDED.units_relocated,
+				surveys_created = EXCLUDED.surveys_created,
+				surveys_revised = EXCLUDED.surveys_revised,
+				surveys_unchanged = EXCLUDED.surveys_unchanged,
+				source_sha256 = EXCLUDED.source_sha256,
+				unchanged_since = EXCLUDED.unchanged_since,
+				detected_dialect = EXCLUDED.detected_dialect,
+				files = EXCLUDED.files,
+				error = EXCLUDED.error
+		`)
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build InsertOrUpdate SQL for IngestJob: %w", err)
+	}
+
+	_, err = s.querier.Exec(query, args...)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == activeDedupKeyIndex {
+		return fmt.Errorf("%w: %s", ErrActiveIngestJobExists, job.DedupKey())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to execute InsertOrUpdate for IngestJob: %w", err)
+	}
+
+	return nil
+}
+
+func (s *ingestJobStorage) SelectByID(id uuid.UUID) (IngestJob, error) {
+	jobs, err := s.selectJobs(s.builder.Select(ingestJobColumns...).
+		From("ingest_jobs").
+		Where(sq.Eq{"id": id.String()}))
+	if err != nil {
+		return IngestJob{}, err
+	}
+	if len(jobs) == 0 {
+		return IngestJob{}, fmt.Errorf("%w: %s", ErrIngestJobNotFound, id)
+	}
+	return jobs[0], nil
+}
+
+func (s *ingestJobStorage) SelectRecent(limit uint64) ([]IngestJob, error) {
+	return s.selectJobs(s.builder.Select(ingestJobColumns...).
+		From("ingest_jobs").
+		OrderBy("created_at DESC").
+		Limit(limit))
+}
+
+func (s *ingestJobStorage) SelectActive() ([]IngestJob, error) {
+	return s.selectJobs(s.builder.Select(ingestJobColumns...).
+		From("ingest_jobs").
+		Where(sq.Eq{"state": []string{string(IngestJobQueued), string(IngestJobRunning)}}).
+		OrderBy("created_at ASC"))
+}
+
+func (s *ingestJobStorage) SelectLastIngested(job IngestJob) (IngestJob, error) {
+	csvFiles, err := marshalCSVFiles(job.CSVFiles)
+	if err != nil {
+		return IngestJob{}, fmt.Errorf("failed to marshal CSV files to JSON: %w", err)
+	}
+	dialect, err := json.Marshal(job.Dialect)
+	if err != nil {
+		return IngestJob{}, fmt.Errorf("failed to marshal dialect to JSON: %w", err)
+	}
+	jobs, err := s.selectJobs(s.builder.Select(ingestJobColumns...).
+		From("ingest_jobs").
+		Where(sq.Eq{
+			"state":           string(IngestJobSucceeded),
+			"zip_url":         job.ZipURL,
+			"csv_file_name":   job.CSVFileName,
+			"csv_files":       csvFiles,
+			"dialect":         dialect,
+			"filter":          job.Filter,
+			"mode":            job.Mode,
+			"unchanged_since": nil,
+		}).
+		Where(sq.NotEq{"source_sha256": ""}).
+		OrderBy("finished_at DESC").
+		Limit(1))
+	if err != nil {
+		return IngestJob{}, err
+	}
+	if len(jobs) == 0 {
+		return IngestJob{}, fmt.Errorf("%w: no ingested run of %s", ErrIngestJobNotFound, job.ZipURL)
+	}
+	return jobs[0], nil
+}
+
+func (s *ingestJobStorage) Claim(id uuid.UUID, lease time.Duration) (IngestJob, error) {
+	jobs, err := s.selectJobs(s.builder.Update("ingest_jobs").
+		Set("state", string(IngestJobRunning)).
+		Set("started_at", sq.Expr("NOW()")).
+		Set("updated_at", sq.Expr("NOW()")).
+		Set("lease_expires_at", leaseExpiry(lease)).
+		Where(sq.Eq{"id": id.String(), "state": string(IngestJobQueued)}).
+		Suffix("RETURNING " + strings.Join(ingestJobColumns, ", ")))
+	if err != nil {
+		return IngestJob{}, err
+	}
+	if len(jobs) == 0 {
+		return IngestJob{}, fmt.Errorf("%w: %s", ErrIngestJobNotClaimed, id)
+	}
+	return jobs[0], nil
+}
+
+func (s *ingestJobStorage) RenewLease(id uuid.UUID, lease time.Duration) error {
+	return s.exec(s.builder.Update("ingest_jobs").
+		Set("lease_expires_at", leaseExpiry(lease)).
+		Where(sq.Eq{"id": id.String(), "state": string(IngestJobRunning)}))
+}
+
+func (s *ingestJobStorage) Fail(id uuid.UUID, message string) error {
+	return s.exec(s.failJobs(message).
+		Where(sq.Eq{"id": id.String(), "state": []string{string(IngestJobQueued), string(IngestJobRunning)}}))
+}
+
+func (s *ingestJobStorage) FailExpired(message string) (int64, error) {
+	query, args, err := s.failJobs(message).
+		Where(sq.Eq{"state": string(IngestJobRunning)}).
+		Where("(lease_expires_at IS NULL OR lease_expires_at < NOW())").
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build SQL query with squirrel: %w", err)
+	}
+	result, err := s.querier.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail expired ingest jobs: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func (s *ingestJobStorage) failJobs(message string) sq.UpdateBuilder {
+	return s.builder.Update("ingest_jobs").
+		Set("state", string(IngestJobFailed)).
+		Set("error", message).
+		Set("finished_at", sq.Expr("NOW()")).
+		Set("updated_at", sq.Expr("NOW()"))
+}
+
+func leaseExpiry(lease time.Duration) sq.Sqlizer {
+	return sq.Expr("NOW() + ? * INTERVAL '1 millisecond'", lease.Milliseconds())
+}
+
+func (s *ingestJobStorage) exec(builder sq.UpdateBuilder) error {
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query with squirrel: %w", err)
+	}
+	if _, err := s.querier.Exec(query, args...); err != nil {
+		return fmt.Errorf("failed to update ingest job: %w", err)
+	}
+	return nil
+}
+
+func (s *ingestJobStorage) selectJobs(queryBuilder sq.Sqlizer) ([]IngestJob, error) {
+	sqlQuery, args, err := queryBuilder.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build SQL query with squirrel: %w", err)
+	}
+
+	rows, err := s.querier.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute ingest job query: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []IngestJob
+	for rows.Next() {
+		var sqlView IngestJobSqlView
+		err := rows.Scan(
+			&sqlView.ID,
+			&sqlView.CreatedAt,
+			&sqlView.UpdatedAt,
+			&sqlView.ArchivedAt,
+			&sqlView.State,
+			&sqlView.ZipURL,
+			&sqlView.CSVFileName,
+			&sqlView.CSVFiles,
+			&sqlView.Dialect,
+			&sqlView.Filter,
+			&sqlView.Mode,
+			&sqlView.ExpectedSHA256,
+			&sqlView.Force,
+			&sqlView.StartedAt,
+			&sqlView.FinishedAt,
+			&sqlView.DownloadedBytes,
+			&sqlView.DownloadTotalBytes,
+			&sqlView.RowsRead,
+			&sqlView.RowsFiltered,
+			&sqlView.RowsRejected,
+			&sqlView.UnitsCreated,
+			&sqlView.UnitsRelocated,
+			&sqlView.SurveysCreated,
+			&sqlView.SurveysRevised,
+			&sqlView.SurveysUnchanged,
+			&sqlView.SourceSHA256,
+			&sqlView.UnchangedSince,
+			&sqlView.DetectedDialect,
+			&sqlView.Files,
+			&sqlView.Error,
+			&sqlView.DedupKey,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan ingest job row: %w", err)
+		}
+
+		job, err := IngestJobFromSqlView(sqlView)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert SQL view to domain model: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during row iteration: %w", err)
+	}
+
+	return jobs, nil
+}
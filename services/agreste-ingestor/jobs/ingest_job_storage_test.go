@@ -0,0 +1,304 @@
+package jobs
+
+import (
+	"agreste-ingestor/agri_units"
+	"agreste-ingestor/misc"
+	"common/testutils"
+	"database/sql"
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	go_sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var ingestJobTestColumns = []string{"id", "created_at", "updated_at", "archived_at", "state", "zip_url", "csv_file_name", "csv_files", "dialect", "filter", "mode", "expected_sha256", "force", "started_at", "finished_at", "downloaded_bytes", "download_total_bytes", "rows_read", "rows_filtered", "rows_rejected", "units_created", "units_relocated", "surveys_created", "surveys_revised", "surveys_unchanged", "source_sha256", "unchanged_since", "detected_dialect", "files", "error", "dedup_key"}
+
+func TestIngestJobSqlViewRoundTrip(t *testing.T) {
+	now := time.Now().Truncate(time.Millisecond)
+	job := IngestJob{
+		ID:              uuid.New(),
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		State:           IngestJobFailed,
+		ZipURL:          "https://example.org/rica.zip",
+		CSVFiles:        []string{"2022/*.csv", "2023/rica.csv"},
+		Dialect:         misc.CSVDialect{Encoding: misc.EncodingWindows1252},
+		StartedAt:       &now,
+		FinishedAt:      &now,
+		RowsRead:        4,
+		UnitsCreated:    1,
+		SurveysCreated:  2,
+		DetectedDialect: &misc.CSVDialect{Encoding: misc.EncodingWindows1252, Delimiter: ";", DecimalSeparator: ","},
+		Files: []agri_units.IngestFileSummary{
+			{File: "2022/rica.csv", IngestSummary: agri_units.IngestSummary{RowsRead: 4, UnitsCreated: 1, SurveysCreated: 2}},
+			{File: "2023/rica.csv", Error: "boom"},
+		},
+		Error: "boom",
+	}
+
+	sqlView, err := IngestJobToSqlView(job)
+	if err != nil {
+		t.Fatalf("IngestJobToSqlView returned an unexpected error: %v", err)
+	}
+	if !sqlView.Error.Valid || sqlView.Error.String != "boom" {
+		t.Errorf("Error should be a valid NullString, got %+v", sqlView.Error)
+	}
+	if !sqlView.StartedAt.Valid || sqlView.ArchivedAt.Valid {
+		t.Errorf("unexpected NullTime validity: started %t, archived %t", sqlView.StartedAt.Valid, sqlView.ArchivedAt.Valid)
+	}
+
+	converted, err := IngestJobFromSqlView(sqlView)
+	if err != nil {
+		t.Fatalf("IngestJobFromSqlView returned an unexpected error: %v", err)
+	}
+	if converted.ID != job.ID || converted.State != job.State || converted.Error != job.Error {
+		t.Errorf("round trip mismatch. Expected %+v, got %+v", job, converted)
+	}
+	if converted.Dialect != job.Dialect || converted.DetectedDialect == nil || *converted.DetectedDialect != *job.DetectedDialect {
+		t.Errorf("dialect mismatch. Expected %+v and %+v, got %+v and %+v", job.Dialect, job.DetectedDialect, converted.Dialect, converted.DetectedDialect)
+	}
+	if !reflect.DeepEqual(converted.CSVFiles, job.CSVFiles) || !reflect.DeepEqual(converted.Files, job.Files) {
+		t.Errorf("CSV files mismatch. Expected %v and %+v, got %v and %+v", job.CSVFiles, job.Files, converted.CSVFiles, converted.Files)
+	}
+	if converted.StartedAt == nil || !converted.StartedAt.Equal(now) {
+		t.Errorf("StartedAt mismatch. Expected %v, got %v", now, converted.StartedAt)
+	}
+
+	sqlView.ID = "invalid-uuid-string"
+	if _, err := IngestJobFromSqlView(sqlView); err == nil {
+		t.Errorf("IngestJobFromSqlView expected an error for invalid UUID, but got none.")
+	}
+}
+
+func TestIngestJobStorage_SelectByID(t *testing.T) {
+	mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+	if err != nil {
+		t.Fatalf("failed to create mock querier: %v", err)
+	}
+	defer mockQuerierInstance.Db.Close()
+
+	storage := NewIngestJobStorage(mockQuerierInstance)
+	jobID := uuid.New()
+	now := time.Now().Truncate(time.Millisecond)
+
+	expectedSQL := "SELECT id, created_at, updated_at, archived_at, state, zip_url, csv_file_name, csv_files, dialect, filter, mode, expected_sha256, force, started_at, finished_at, downloaded_bytes, download_total_bytes, rows_read, rows_filtered, rows_rejected, units_created, units_relocated, surveys_created, surveys_revised, surveys_unchanged, source_sha256, unchanged_since, detected_dialect, files, error, dedup_key FROM ingest_jobs WHERE id = $1"
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(expectedSQL)).
+		WithArgs(jobID.String()).
+		WillReturnRows(sqlMock.NewRows(ingestJobTestColumns).
+			AddRow(jobID.String(), now, now, sql.NullTime{}, "running", "https://example.org/rica.zip", "rica.csv", []byte("[]"), []byte("{}"), "OTEFDD in (1500)", "insert", "", false, now, sql.NullTime{}, int64(0), int64(0), 12, 3, 1, 0, 0, 0, 0, 0, "", sql.NullString{}, nil, nil, sql.NullString{}, sql.NullString{}))
+
+	job, err := storage.SelectByID(jobID)
+	if err != nil {
+		t.Fatalf("SelectByID() returned an unexpected error: %v", err)
+	}
+	if job.ID != jobID || job.State != IngestJobRunning || job.RowsRead != 12 || job.RowsFiltered != 3 || job.RowsRejected != 1 {
+		t.Errorf("unexpected job: %+v", job)
+	}
+	if job.Filter != "OTEFDD in (1500)" {
+		t.Errorf("Filter mismatch, got '%s'", job.Filter)
+	}
+	if job.FinishedAt != nil {
+		t.Errorf("FinishedAt should be nil, got %v", job.FinishedAt)
+	}
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(expectedSQL)).
+		WithArgs(jobID.String()).
+		WillReturnRows(sqlMock.NewRows(ingestJobTestColumns))
+
+	_, err = storage.SelectByID(jobID)
+	if !errors.Is(err, ErrIngestJobNotFound) {
+		t.Errorf("expected ErrIngestJobNotFound, got %v", err)
+	}
+
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestIngestJobStorage_SelectActive(t *testing.T) {
+	mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+	if err != nil {
+		t.Fatalf("failed to create mock querier: %v", err)
+	}
+	defer mockQuerierInstance.Db.Close()
+
+	storage := NewIngestJobStorage(mockQuerierInstance)
+
+	expectedSQL := "SELECT id, created_at, updated_at, archived_at, state, zip_url, csv_file_name, csv_files, dialect, filter, mode, expected_sha256, force, started_at, finished_at, downloaded_bytes, download_total_bytes, rows_read, rows_filtered, rows_rejected, units_created, units_relocated, surveys_created, surveys_revised, surveys_unchanged, source_sha256, unchanged_since, detected_dialect, files, error, dedup_key FROM ingest_jobs WHERE state IN ($1,$2) ORDER BY created_at ASC"
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(expectedSQL)).
+		WithArgs("queued", "running").
+		WillReturnRows(sqlMock.NewRows(ingestJobTestColumns))
+
+	activeJobs, err := storage.SelectActive()
+	if err != nil {
+		t.Fatalf("SelectActive() returned an unexpected error: %v", err)
+	}
+	if len(activeJobs) != 0 {
+		t.Errorf("expected no active jobs, got %d", len(activeJobs))
+	}
+
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestIngestJobStorage_InsertOrUpdate(t *testing.T) {
+	mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+	if err != nil {
+		t.Fatalf("failed to create mock querier: %v", err)
+	}
+	defer mockQuerierInstance.Db.Close()
+
+	storage := NewIngestJobStorage(mockQuerierInstance)
+	job := CreateIngestJob(IngestJobValue{ZipURL: "https://example.org/rica.zip", CSVFileName: "rica.csv", Filter: "OTEFDD = 4500"})
+
+	expectedSQL := "INSERT INTO ingest_jobs (id,created_at,updated_at,archived_at,state,zip_url,csv_file_name,csv_files,dialect,filter,mode,expected_sha256,force,started_at,finished_at,downloaded_bytes,download_total_bytes,rows_read,rows_filtered,rows_rejected,units_created,units_relocated,surveys_created,surveys_revised,surveys_unchanged,source_sha256,unchanged_since,detected_dialect,files,error,dedup_key) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24,$25,$26,$27,$28,$29,$30,$31) ON CONFLICT (id) DO UPDATE SET"
+
+	sqlMock.ExpectExec(regexp.QuoteMeta(expectedSQL)).
+		WithArgs(job.ID.String(), job.CreatedAt, job.UpdatedAt, sql.NullTime{}, "queued", job.ZipURL, job.CSVFileName, []byte("[]"), []byte("{}"), job.Filter, job.Mode, "", false, sql.NullTime{}, sql.NullTime{}, int64(0), int64(0), 0, 0, 0, 0, 0, 0, 0, 0, "", sql.NullString{}, []byte(nil), []byte(nil), sql.NullString{}, sql.NullString{String: job.DedupKey(), Valid: true}).
+		WillReturnResult(go_sqlmock.NewResult(1, 1))
+
+	if err := storage.InsertOrUpdate(job); err != nil {
+		t.Fatalf("InsertOrUpdate() returned an unexpected error: %v", err)
+	}
+
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestIngestJobStorage_InsertOrUpdate_ActiveDuplicate(t *testing.T) {
+	mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+	if err != nil {
+		t.Fatalf("failed to create mock querier: %v", err)
+	}
+	defer mockQuerierInstance.Db.Close()
+
+	storage := NewIngestJobStorage(mockQuerierInstance)
+	job := CreateIngestJob(IngestJobValue{ZipURL: "https://example.org/rica.zip", CSVFileName: "rica.csv"})
+
+	sqlMock.ExpectExec(regexp.QuoteMeta("INSERT INTO ingest_jobs")).
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "ingest_jobs_active_dedup_key_idx"})
+
+	if err := storage.InsertOrUpdate(job); !errors.Is(err, ErrActiveIngestJobExists) {
+		t.Errorf("expected ErrActiveIngestJobExists, got %v", err)
+	}
+
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestIngestJobStorage_SelectLastIngested(t *testing.T) {
+	mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+	if err != nil {
+		t.Fatalf("failed to create mock querier: %v", err)
+	}
+	defer mockQuerierInstance.Db.Close()
+
+	storage := NewIngestJobStorage(mockQuerierInstance)
+	job := CreateIngestJob(IngestJobValue{ZipURL: "https://example.org/rica.zip", CSVFileName: "rica.csv", Filter: "OTEFDD = 4500", Mode: "insert"})
+	previousID := uuid.New()
+	now := time.Now().Truncate(time.Millisecond)
+
+	expectedSQL := "SELECT id, created_at, updated_at, archived_at, state, zip_url, csv_file_name, csv_files, dialect, filter, mode, expected_sha256, force, started_at, finished_at, downloaded_bytes, download_total_bytes, rows_read, rows_filtered, rows_rejected, units_created, units_relocated, surveys_created, surveys_revised, surveys_unchanged, source_sha256, unchanged_since, detected_dialect, files, error, dedup_key FROM ingest_jobs WHERE csv_file_name = $1 AND csv_files = $2 AND dialect = $3 AND filter = $4 AND mode = $5 AND state = $6 AND unchanged_since IS NULL AND zip_url = $7 AND source_sha256 <> $8 ORDER BY finished_at DESC LIMIT 1"
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(expectedSQL)).
+		WithArgs("rica.csv", []byte("[]"), []byte("{}"), "OTEFDD = 4500", "insert", "succeeded", "https://example.org/rica.zip", "").
+		WillReturnRows(sqlMock.NewRows(ingestJobTestColumns).
+			AddRow(previousID.String(), now, now, sql.NullTime{}, "succeeded", "https://example.org/rica.zip", "rica.csv", []byte("[]"), []byte("{}"), "OTEFDD = 4500", "insert", "", false, now, now, int64(2048), int64(2048), 12, 3, 1, 0, 0, 0, 0, 0, "ab12", sql.NullString{}, nil, nil, sql.NullString{}, sql.NullString{}))
+
+	previous, err := storage.SelectLastIngested(job)
+	if err != nil {
+		t.Fatalf("SelectLastIngested() returned an unexpected error: %v", err)
+	}
+	if previous.ID != previousID || previous.SourceSHA256 != "ab12" || previous.UnchangedSince != nil {
+		t.Errorf("unexpected job: %+v", previous)
+	}
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(expectedSQL)).
+		WithArgs("rica.csv", []byte("[]"), []byte("{}"), "OTEFDD = 4500", "insert", "succeeded", "https://example.org/rica.zip", "").
+		WillReturnRows(sqlMock.NewRows(ingestJobTestColumns))
+
+	if _, err := storage.SelectLastIngested(job); !errors.Is(err, ErrIngestJobNotFound) {
+		t.Errorf("expected ErrIngestJobNotFound, got %v", err)
+	}
+
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestIngestJobStorage_Claim(t *testing.T) {
+	mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+	if err != nil {
+		t.Fatalf("failed to create mock querier: %v", err)
+	}
+	defer mockQuerierInstance.Db.Close()
+
+	storage := NewIngestJobStorage(mockQuerierInstance)
+	jobID := uuid.New()
+	now := time.Now().Truncate(time.Millisecond)
+
+	expectedSQL := "UPDATE ingest_jobs SET state = $1, started_at = NOW(), updated_at = NOW(), lease_expires_at = NOW() + $2 * INTERVAL '1 millisecond' WHERE id = $3 AND state = $4 RETURNING id, created_at"
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(expectedSQL)).
+		WithArgs("running", int64(60000), jobID.String(), "queued").
+		WillReturnRows(sqlMock.NewRows(ingestJobTestColumns).
+			AddRow(jobID.String(), now, now, sql.NullTime{}, "running", "https://example.org/rica.zip", "rica.csv", []byte("[]"), []byte("{}"), "", "insert", "", false, now, sql.NullTime{}, int64(0), int64(0), 0, 0, 0, 0, 0, 0, 0, 0, "", sql.NullString{}, nil, nil, sql.NullString{}, sql.NullString{}))
+
+	job, err := storage.Claim(jobID, time.Minute)
+	if err != nil {
+		t.Fatalf("Claim() returned an unexpected error: %v", err)
+	}
+	if job.ID != jobID || job.State != IngestJobRunning || job.StartedAt == nil {
+		t.Errorf("unexpected claimed job: %+v", job)
+	}
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(expectedSQL)).
+		WithArgs("running", int64(60000), jobID.String(), "queued").
+		WillReturnRows(sqlMock.NewRows(ingestJobTestColumns))
+
+	if _, err := storage.Claim(jobID, time.Minute); !errors.Is(err, ErrIngestJobNotClaimed) {
+		t.Errorf("expected ErrIngestJobNotClaimed, got %v", err)
+	}
+
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestIngestJobStorage_FailExpired(t *testing.T) {
+	mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+	if err != nil {
+		t.Fatalf("failed to create mock querier: %v", err)
+	}
+	defer mockQuerierInstance.Db.Close()
+
+	storage := NewIngestJobStorage(mockQuerierInstance)
+
+	expectedSQL := "UPDATE ingest_jobs SET state = $1, error = $2, finished_at = NOW(), updated_at = NOW() WHERE state = $3 AND (lease_expires_at IS NULL OR lease_expires_at < NOW())"
+
+	sqlMock.ExpectExec(regexp.QuoteMeta(expectedSQL)).
+		WithArgs("failed", "lease expired", "running").
+		WillReturnResult(go_sqlmock.NewResult(0, 2))
+
+	expired, err := storage.FailExpired("lease expired")
+	if err != nil {
+		t.Fatalf("FailExpired() returned an unexpected error: %v", err)
+	}
+	if expired != 2 {
+		t.Errorf("expected 2 expired jobs, got %d", expired)
+	}
+
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,74 @@
+package jobs
+
+import (
+	"agreste-ingestor/agri_units"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCreateIngestJob(t *testing.T) {
+	job := CreateIngestJob(IngestJobValue{ZipURL: "https://example.org/rica.zip", CSVFileName: "rica.csv"})
+
+	if job.ID == uuid.Nil {
+		t.Errorf("ID should not be nil")
+	}
+	if job.State != IngestJobQueued {
+		t.Errorf("State should be %s upon creation, got %s", IngestJobQueued, job.State)
+	}
+	if !job.CreatedAt.Equal(job.UpdatedAt) {
+		t.Errorf("CreatedAt and UpdatedAt should be equal upon creation. Got CreatedAt: %v, UpdatedAt: %v", job.CreatedAt, job.UpdatedAt)
+	}
+	if job.StartedAt != nil || job.FinishedAt != nil {
+		t.Errorf("StartedAt and FinishedAt should be nil upon creation")
+	}
+}
+
+func TestIngestJobTransitions(t *testing.T) {
+	summary := agri_units.IngestSummary{RowsRead: 10, RowsFiltered: 4, RowsRejected: 1, UnitsCreated: 2, SurveysCreated: 3, SurveysUnchanged: 2, SourceSHA256: "ab12"}
+
+	t.Run("Succeeded", func(t *testing.T) {
+		job := CreateIngestJob(IngestJobValue{ZipURL: "u", CSVFileName: "f"})
+		job.MarkRunning()
+		if job.State != IngestJobRunning || job.StartedAt == nil {
+			t.Fatalf("expected running job with StartedAt, got %s / %v", job.State, job.StartedAt)
+		}
+
+		job.MarkSucceeded(summary)
+		if job.State != IngestJobSucceeded || job.FinishedAt == nil {
+			t.Fatalf("expected succeeded job with FinishedAt, got %s / %v", job.State, job.FinishedAt)
+		}
+		if job.RowsRead != 10 || job.RowsFiltered != 4 || job.RowsRejected != 1 || job.UnitsCreated != 2 || job.SurveysCreated != 3 || job.SurveysUnchanged != 2 || job.SourceSHA256 != "ab12" {
+			t.Errorf("summary not applied, got %+v", job)
+		}
+		if job.State.IsActive() {
+			t.Errorf("succeeded job should not be active")
+		}
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		job := CreateIngestJob(IngestJobValue{ZipURL: "u", CSVFileName: "f"})
+		job.MarkRunning()
+		job.MarkFailed(summary, errors.New("boom"))
+		if job.State != IngestJobFailed || job.Error != "boom" {
+			t.Errorf("expected failed job with error 'boom', got %s / '%s'", job.State, job.Error)
+		}
+	})
+}
+
+func TestIngestJob_MemberPatterns(t *testing.T) {
+	single := CreateIngestJob(IngestJobValue{ZipURL: "u", CSVFileName: "rica.csv"})
+	several := CreateIngestJob(IngestJobValue{ZipURL: "u", CSVFiles: []string{"rica.csv"}})
+	all := CreateIngestJob(IngestJobValue{ZipURL: "u", CSVFiles: []string{"*.csv"}})
+
+	if patterns := single.MemberPatterns(); len(patterns) != 1 || patterns[0] != "rica.csv" {
+		t.Errorf("expected the CSV file name as only pattern, got %q", patterns)
+	}
+	if patterns := CreateIngestJob(IngestJobValue{ZipURL: "u"}).MemberPatterns(); patterns != nil {
+		t.Errorf("expected no pattern, got %q", patterns)
+	}
+	if single.DedupKey() != several.DedupKey() || single.DedupKey() == all.DedupKey() {
+		t.Errorf("expected jobs to be deduplicated by the files they select, got %s, %s and %s", single.DedupKey(), several.DedupKey(), all.DedupKey())
+	}
+}
@@ -2,22 +2,127 @@ package main
 
 import (
 	"agreste-ingestor/agri_units"
+	"agreste-ingestor/anonymization"
+	"agreste-ingestor/filters"
+	"agreste-ingestor/jobs"
+	"agreste-ingestor/misc"
+	"agreste-ingestor/schema"
+	"agreste-ingestor/sources"
+	"common/agri"
+	"common/migrations"
+	"common/storage"
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
 )
 
+// IngestionRequest names a survey file by SourceURL, or ZipURL, its former name.
 type IngestionRequest struct {
-	ZipURL      string `json:"zipUrl"`
-	CSVFileName string `json:"csvFileName"`
+	SourceURL      string          `json:"sourceUrl"`
+	ZipURL         string          `json:"zipUrl"`
+	CSVFileName    string          `json:"csvFileName"`
+	CSVFiles       []string        `json:"csvFiles,omitempty"`
+	Dialect        misc.CSVDialect `json:"dialect,omitempty"`
+	Filter         *string         `json:"filter,omitempty"`
+	Mode           string          `json:"mode,omitempty"`
+	ExpectedSHA256 string          `json:"expectedSha256,omitempty"`
+	Force          bool            `json:"force,omitempty"`
 }
 
 type App struct {
-	AgriUnitStorage       agri_units.AgriUnitStorage
+	Transactor            storage.Transactor
+	AgriUnitStorage       agri.AgriUnitStorage
 	AgriUnitSurveyStorage agri_units.AgriculturalUnitSurveyStorage
+	SurveyRevisionStorage agri_units.AgriculturalUnitSurveyRevisionStorage
+	RejectionStorage      agri_units.IngestRejectionStorage
+	IngestJobStorage      jobs.IngestJobStorage
+	IngestJobRunner       *jobs.IngestJobRunner
+	DefaultFilter         string
+	Locator               agri_units.UnitLocator
+	RelocateUnits         bool
+	Dictionaries          *schema.Registry
+	Opener                *sources.Opener
+	// ArchiveAbsentYears archives the units absent from that many latest survey years.
+	ArchiveAbsentYears int
+}
+
+const defaultJobsListLimit = 50
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("Error encoding JSON response: %v\n", err)
+	}
+}
+
+func parseFilter(expression string) (*filters.Filter, error) {
+	if strings.TrimSpace(expression) == "" {
+		return nil, nil
+	}
+	return filters.Parse(expression)
+}
+
+func (a *App) runIngestJob(job jobs.IngestJob, progress sources.ProgressFunc) (agri_units.IngestSummary, error) {
+	filter, err := parseFilter(job.Filter)
+	if err != nil {
+		return agri_units.IngestSummary{}, err
+	}
+
+	var previousRun *agri_units.SourceRun
+	if !job.Force {
+		previous, err := a.IngestJobStorage.SelectLastIngested(job)
+		if err != nil && !errors.Is(err, jobs.ErrIngestJobNotFound) {
+			return agri_units.IngestSummary{}, err
+		}
+		if err == nil {
+			previousRun = &agri_units.SourceRun{RunID: previous.ID, SHA256: previous.SourceSHA256}
+		}
+	}
+
+	summary, err := agri_units.HandleAgriUnitSurveyIngest(
+		job.ZipURL,
+		job.MemberPatterns(),
+		agri_units.IngestOptions{
+			Filter:         filter,
+			Locator:        a.Locator,
+			RelocateUnits:  a.RelocateUnits,
+			Dictionaries:   a.Dictionaries,
+			Opener:         a.Opener,
+			Progress:       progress,
+			Dialect:        job.Dialect,
+			Mode:           agri_units.IngestMode(job.Mode),
+			RunID:          job.ID,
+			ExpectedSHA256: job.ExpectedSHA256,
+			PreviousRun:    previousRun,
+		},
+		a.Transactor,
+		a.AgriUnitStorage,
+		a.AgriUnitSurveyStorage,
+		a.SurveyRevisionStorage,
+		a.RejectionStorage,
+	)
+	if err != nil {
+		return summary, err
+	}
+
+	if a.ArchiveAbsentYears > 0 {
+		a.archiveAbsentUnits(a.ArchiveAbsentYears)
+	}
+	return summary, nil
 }
 
 func (a *App) IngestionHandler(w http.ResponseWriter, r *http.Request) {
@@ -34,29 +139,162 @@ func (a *App) IngestionHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
-	if req.ZipURL == "" || req.CSVFileName == "" {
-		http.Error(w, "Fields 'zipUrl' and 'csvFileName' are required.", http.StatusBadRequest)
+	if req.SourceURL == "" {
+		req.SourceURL = req.ZipURL
+	}
+	if req.SourceURL == "" {
+		http.Error(w, "Field 'sourceUrl' is required.", http.StatusBadRequest)
+		return
+	}
+	if err := a.Opener.Validate(req.SourceURL); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid 'sourceUrl': %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if req.CSVFileName != "" && len(req.CSVFiles) > 0 {
+		http.Error(w, "Fields 'csvFileName' and 'csvFiles' cannot be used together.", http.StatusBadRequest)
 		return
 	}
+	for _, pattern := range req.CSVFiles {
+		if strings.TrimSpace(pattern) == "" {
+			http.Error(w, "Field 'csvFiles' cannot hold an empty name.", http.StatusBadRequest)
+			return
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid 'csvFiles' pattern '%s': %v", pattern, err), http.StatusBadRequest)
+			return
+		}
+	}
 
-	log.Printf("Ingestion request received: ZipURL='%s', CSVFileName='%s'\n", req.ZipURL, req.CSVFileName)
+	req.Dialect.Encoding = strings.ToLower(req.Dialect.Encoding)
+	req.Dialect.LineEnding = strings.ToLower(req.Dialect.LineEnding)
+	if err := req.Dialect.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid 'dialect': %v", err), http.StatusBadRequest)
+		return
+	}
 
-	err = agri_units.HandleAgriUnitSurveyIngest(
-		req.ZipURL,
-		req.CSVFileName,
-		a.AgriUnitStorage,
-		a.AgriUnitSurveyStorage,
-	)
+	if req.ExpectedSHA256 != "" && !isSHA256(req.ExpectedSHA256) {
+		http.Error(w, fmt.Sprintf("Invalid 'expectedSha256': '%s' is not a hex-encoded SHA-256", req.ExpectedSHA256), http.StatusBadRequest)
+		return
+	}
+
+	filterExpression := a.DefaultFilter
+	if req.Filter != nil {
+		filterExpression = *req.Filter
+	}
+	filter, err := parseFilter(filterExpression)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid 'filter': %v", err), http.StatusBadRequest)
+		return
+	}
+
+	mode, err := agri_units.ParseIngestMode(req.Mode)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid 'mode': %v", err), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Ingestion request received: SourceURL='%s', CSVFileName='%s', CSVFiles=%q, Filter='%s', Mode='%s'\n", req.SourceURL, req.CSVFileName, req.CSVFiles, filter.String(), mode)
+
+	job, created, err := a.IngestJobRunner.Submit(jobs.IngestJobValue{
+		ZipURL:         req.SourceURL,
+		CSVFileName:    req.CSVFileName,
+		CSVFiles:       req.CSVFiles,
+		Dialect:        req.Dialect,
+		Filter:         filter.String(),
+		Mode:           string(mode),
+		ExpectedSHA256: strings.ToLower(req.ExpectedSHA256),
+		Force:          req.Force,
+	})
+	if err != nil {
+		log.Printf("Error queuing ingestion: %v\n", err)
+		http.Error(w, fmt.Sprintf("Error queuing ingestion: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if created {
+		log.Printf("Ingestion job %s queued.\n", job.ID)
+	} else {
+		log.Printf("Ingestion already in progress as job %s.\n", job.ID)
+	}
+
+	w.Header().Set("Location", "/jobs/"+job.ID.String())
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func isSHA256(value string) bool {
+	decoded, err := hex.DecodeString(value)
+	return err == nil && len(decoded) == sha256.Size
+}
 
+func (a *App) JobsHandler(w http.ResponseWriter, r *http.Request) {
+	limit := uint64(defaultJobsListLimit)
+	if rawLimit := r.URL.Query().Get("limit"); rawLimit != "" {
+		parsedLimit, err := strconv.ParseUint(rawLimit, 10, 64)
+		if err != nil || parsedLimit == 0 {
+			http.Error(w, fmt.Sprintf("Invalid 'limit' query parameter: '%s'", rawLimit), http.StatusBadRequest)
+			return
+		}
+		limit = parsedLimit
+	}
+
+	recentJobs, err := a.IngestJobStorage.SelectRecent(limit)
+	if err != nil {
+		log.Printf("Error listing ingestion jobs: %v\n", err)
+		http.Error(w, fmt.Sprintf("Error listing ingestion jobs: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if recentJobs == nil {
+		recentJobs = []jobs.IngestJob{}
+	}
+
+	writeJSON(w, http.StatusOK, recentJobs)
+}
+
+func (a *App) JobHandler(w http.ResponseWriter, r *http.Request) {
+	jobID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid job ID '%s'", r.PathValue("id")), http.StatusBadRequest)
+		return
+	}
+
+	job, err := a.IngestJobStorage.SelectByID(jobID)
+	if errors.Is(err, jobs.ErrIngestJobNotFound) {
+		http.Error(w, fmt.Sprintf("Ingestion job '%s' not found", jobID), http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		log.Printf("Error during ingestion: %v\n", err)
-		http.Error(w, fmt.Sprintf("Error processing ingestion: %v", err), http.StatusInternalServerError)
+		log.Printf("Error loading ingestion job %s: %v\n", jobID, err)
+		http.Error(w, fmt.Sprintf("Error loading ingestion job: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Data ingestion started successfully.\n"))
-	log.Println("Ingestion completed successfully.")
+	writeJSON(w, http.StatusOK, job)
+}
+
+func loadHTTPConfig() (sources.HTTPConfig, error) {
+	var config sources.HTTPConfig
+	var err error
+
+	if caBundle := os.Getenv("INGEST_CA_BUNDLE"); caBundle != "" {
+		if config.RootCAs, err = sources.LoadCABundle(caBundle); err != nil {
+			return config, fmt.Errorf("INGEST_CA_BUNDLE: %w", err)
+		}
+	}
+	if config.Pins, err = sources.ParsePins(os.Getenv("INGEST_TLS_PINS")); err != nil {
+		return config, fmt.Errorf("INGEST_TLS_PINS: %w", err)
+	}
+	if rawTimeout := os.Getenv("INGEST_HTTP_TIMEOUT"); rawTimeout != "" {
+		if config.Timeout, err = time.ParseDuration(rawTimeout); err != nil || config.Timeout <= 0 {
+			return config, fmt.Errorf("INGEST_HTTP_TIMEOUT: invalid duration '%s'", rawTimeout)
+		}
+	}
+	if rawMaxSize := os.Getenv("INGEST_MAX_SOURCE_BYTES"); rawMaxSize != "" {
+		if config.MaxSize, err = strconv.ParseInt(rawMaxSize, 10, 64); err != nil || config.MaxSize <= 0 {
+			return config, fmt.Errorf("INGEST_MAX_SOURCE_BYTES: invalid size '%s'", rawMaxSize)
+		}
+	}
+	return config, nil
 }
 
 func main() {
@@ -85,15 +323,160 @@ func main() {
 	}
 	log.Println("PostgreSQL database connection established successfully.")
 
-	realAgriUnitStorage := agri_units.NewAgriUnitStorage(db)
-	realAgriUnitSurveyStorage := agri_units.NewAgriculturalUnitSurveyStorage(db)
+	schemaMigrations, err := migrations.All()
+	if err != nil {
+		log.Fatalf("Failed to load database migrations: %v", err)
+	}
+	migrator := migrations.NewMigrator(db, schemaMigrations)
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrations.RunCommand(context.Background(), migrator, os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "dedup" {
+		report, err := agri_units.MergeDuplicates(storage.NewRealDBQuerier(db))
+		if err != nil {
+			log.Fatalf("Failed to merge duplicate agricultural units and surveys: %v", err)
+		}
+		log.Printf("Dedup done: %d units removed, %d weather rows moved, %d surveys removed, %d surveys merged.\n",
+			report.UnitsRemoved, report.WeatherRowsMoved, report.SurveysRemoved, report.SurveysMerged)
+		return
+	}
+
+	applied, err := migrator.Up(context.Background())
+	for _, migration := range applied {
+		log.Printf("Applied database migration %04d_%s.\n", migration.Version, migration.Name)
+	}
+	if err != nil {
+		log.Fatalf("Failed to migrate the database schema: %v", err)
+	}
+
+	defaultFilter, hasDefaultFilter := os.LookupEnv("INGEST_DEFAULT_FILTER")
+	if !hasDefaultFilter {
+		defaultFilter = agri_units.DefaultFilterExpression
+	}
+	if _, err := parseFilter(defaultFilter); err != nil {
+		log.Fatalf("Invalid INGEST_DEFAULT_FILTER: %v", err)
+	}
+
+	minJitterKm := anonymization.DefaultMinJitterKm
+	if rawMinJitterKm := os.Getenv("ANONYMIZATION_MIN_JITTER_KM"); rawMinJitterKm != "" {
+		minJitterKm, err = strconv.ParseFloat(rawMinJitterKm, 64)
+		if err != nil {
+			log.Fatalf("Invalid ANONYMIZATION_MIN_JITTER_KM '%s': %v", rawMinJitterKm, err)
+		}
+	}
+	locator, err := anonymization.NewLocator(anonymization.LocatorConfig{
+		Salt:             os.Getenv("ANONYMIZATION_SALT"),
+		MinJitterKm:      minJitterKm,
+		RegionColumn:     os.Getenv("ANONYMIZATION_REGION_COLUMN"),
+		DepartmentColumn: os.Getenv("ANONYMIZATION_DEPARTMENT_COLUMN"),
+	})
+	if err != nil {
+		log.Fatalf("Invalid anonymization configuration (check ANONYMIZATION_SALT and ANONYMIZATION_MIN_JITTER_KM): %v", err)
+	}
+	relocateUnits := false
+	if rawRelocateUnits := os.Getenv("ANONYMIZATION_RELOCATE_UNITS"); rawRelocateUnits != "" {
+		relocateUnits, err = strconv.ParseBool(rawRelocateUnits)
+		if err != nil {
+			log.Fatalf("Invalid ANONYMIZATION_RELOCATE_UNITS '%s': %v", rawRelocateUnits, err)
+		}
+	}
+
+	httpConfig, err := loadHTTPConfig()
+	if err != nil {
+		log.Fatalf("Invalid source download configuration: %v", err)
+	}
+	retryPolicy := sources.DefaultRetryPolicy
+	if rawAttempts := os.Getenv("INGEST_DOWNLOAD_ATTEMPTS"); rawAttempts != "" {
+		retryPolicy.Attempts, err = strconv.Atoi(rawAttempts)
+		if err != nil || retryPolicy.Attempts <= 0 {
+			log.Fatalf("Invalid INGEST_DOWNLOAD_ATTEMPTS '%s'", rawAttempts)
+		}
+	}
+	opener := sources.NewOpener(sources.Config{
+		FileRoot: os.Getenv("INGEST_FILE_ROOT"),
+		CacheDir: os.Getenv("INGEST_CACHE_DIR"),
+		S3: sources.S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("AWS_REGION"),
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		},
+		HTTP:  httpConfig,
+		Retry: retryPolicy,
+	})
+
+	database := storage.NewRealDBQuerier(db)
+
+	dictionaries, err := schema.LoadRegistry(os.Getenv("RICA_DICTIONARY_DIR"))
+	if err != nil {
+		log.Fatalf("Failed to load RICA dictionaries (check RICA_DICTIONARY_DIR): %v", err)
+	}
+	dictionaryStorage := schema.NewDictionaryStorage(database)
+	for _, dictionary := range dictionaries.All() {
+		if err := dictionaryStorage.InsertOrUpdate(dictionary); err != nil {
+			log.Fatalf("Failed to store RICA dictionary %s: %v", dictionary.Version, err)
+		}
+	}
+	log.Printf("Loaded %d RICA dictionaries.\n", len(dictionaries.All()))
+
+	archiveAbsentYears := 0
+	if rawYears := os.Getenv("ARCHIVE_ABSENT_UNITS_YEARS"); rawYears != "" {
+		archiveAbsentYears, err = strconv.Atoi(rawYears)
+		if err != nil || archiveAbsentYears < 0 {
+			log.Fatalf("Invalid ARCHIVE_ABSENT_UNITS_YEARS '%s', expected a number of survey years", rawYears)
+		}
+	}
+
+	realAgriUnitStorage := agri.NewAgriUnitStorage(database)
+	realAgriUnitSurveyStorage := agri_units.NewAgriculturalUnitSurveyStorage(database)
+	realSurveyRevisionStorage := agri_units.NewAgriculturalUnitSurveyRevisionStorage(database)
+	realRejectionStorage := agri_units.NewIngestRejectionStorage(database)
+	realIngestJobStorage := jobs.NewIngestJobStorage(database)
 
 	app := &App{
+		Transactor:            database,
 		AgriUnitStorage:       realAgriUnitStorage,
 		AgriUnitSurveyStorage: realAgriUnitSurveyStorage,
+		SurveyRevisionStorage: realSurveyRevisionStorage,
+		RejectionStorage:      realRejectionStorage,
+		IngestJobStorage:      realIngestJobStorage,
+		DefaultFilter:         defaultFilter,
+		Locator:               locator,
+		RelocateUnits:         relocateUnits,
+		Dictionaries:          dictionaries,
+		Opener:                opener,
+		ArchiveAbsentYears:    archiveAbsentYears,
+	}
+	app.IngestJobRunner = jobs.NewIngestJobRunner(realIngestJobStorage, app.runIngestJob)
+
+	if err := app.IngestJobRunner.Recover(); err != nil {
+		log.Fatalf("Failed to recover pending ingestion jobs: %v", err)
 	}
+	go app.IngestJobRunner.Run(context.Background())
 
 	http.HandleFunc("/ingest", app.IngestionHandler)
+	http.HandleFunc("GET /jobs", app.JobsHandler)
+	http.HandleFunc("GET /jobs/{id}", app.JobHandler)
+	http.HandleFunc("GET /jobs/{id}/rejections", app.JobRejectionsHandler)
+	http.HandleFunc("GET /units", app.UnitsHandler)
+	http.HandleFunc("GET /units/{id}", app.UnitHandler)
+	http.HandleFunc("GET /units/{id}/surveys", app.UnitSurveysHandler)
+	http.HandleFunc("POST /units/{id}/archive", app.ArchiveUnitHandler)
+	http.HandleFunc("POST /units/{id}/unarchive", app.UnarchiveUnitHandler)
+	http.HandleFunc("POST /units/archive-absent", app.ArchiveAbsentUnitsHandler)
+	http.HandleFunc("GET /surveys", app.SurveysHandler)
+	http.HandleFunc("GET /surveys/{id}", app.SurveyHandler)
+	http.HandleFunc("GET /surveys/{id}/revisions", app.SurveyRevisionsHandler)
+	http.HandleFunc("GET /surveys/{id}/revisions/diff", app.SurveyRevisionDiffHandler)
+	http.HandleFunc("GET /schemas", app.SchemasHandler)
+	http.HandleFunc("GET /schemas/{version}", app.SchemaHandler)
+	http.HandleFunc("GET /sources/members", app.SourceMembersHandler)
 
 	port := ":8080"
 	log.Printf("Server started on port %s\n", port)
@@ -0,0 +1,322 @@
+package misc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	EncodingUTF8        = "utf-8"
+	EncodingWindows1252 = "windows-1252"
+	EncodingISO88591    = "iso-8859-1"
+)
+
+const (
+	LineEndingLF   = "lf"
+	LineEndingCRLF = "crlf"
+	// LineEndingCR ends lines with a lone carriage return, as old Mac spreadsheets did.
+	LineEndingCR = "cr"
+)
+
+const dialectSampleSize = 64 << 10
+
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+var delimiterCandidates = []rune{';', ',', '\t', '|'}
+
+// CSVDialect describes how a CSV file is written.
+type CSVDialect struct {
+	// Encoding is EncodingUTF8, EncodingWindows1252 or EncodingISO88591.
+	Encoding string `json:"encoding,omitempty"`
+	// BOM is set when the file starts with a UTF-8 byte order mark.
+	BOM        bool   `json:"bom,omitempty"`
+	LineEnding string `json:"lineEnding,omitempty"`
+	Delimiter  string `json:"delimiter,omitempty"`
+	// DecimalSeparator is "," or "." in decimal numbers.
+	DecimalSeparator string `json:"decimalSeparator,omitempty"`
+}
+
+func (d CSVDialect) IsZero() bool {
+	return d == CSVDialect{}
+}
+
+// Validate checks the fields of a dialect given as an override.
+func (d CSVDialect) Validate() error {
+	switch d.Encoding {
+	case "", EncodingUTF8, EncodingWindows1252, EncodingISO88591:
+	default:
+		return fmt.Errorf("unsupported encoding '%s', expected '%s', '%s' or '%s'", d.Encoding, EncodingUTF8, EncodingWindows1252, EncodingISO88591)
+	}
+	switch d.LineEnding {
+	case "", LineEndingLF, LineEndingCRLF, LineEndingCR:
+	default:
+		return fmt.Errorf("invalid line ending '%s', expected '%s', '%s' or '%s'", d.LineEnding, LineEndingLF, LineEndingCRLF, LineEndingCR)
+	}
+	if d.Delimiter != "" {
+		delimiter, size := utf8.DecodeRuneInString(d.Delimiter)
+		if size != len(d.Delimiter) || delimiter == '"' || delimiter == '\r' || delimiter == '\n' || delimiter == utf8.RuneError {
+			return fmt.Errorf("invalid delimiter '%s', expected a single character", d.Delimiter)
+		}
+	}
+	switch d.DecimalSeparator {
+	case "", ",", ".":
+	default:
+		return fmt.Errorf("invalid decimal separator '%s', expected ',' or '.'", d.DecimalSeparator)
+	}
+	if d.Delimiter != "" && d.Delimiter == d.DecimalSeparator {
+		return fmt.Errorf("the delimiter and the decimal separator cannot both be '%s'", d.Delimiter)
+	}
+	return nil
+}
+
+// Comma returns the delimiter as expected by encoding/csv.
+func (d CSVDialect) Comma() rune {
+	delimiter, _ := utf8.DecodeRuneInString(d.Delimiter)
+	return delimiter
+}
+
+// DetectCSVDialect guesses the dialect of a CSV from its first bytes.
+func DetectCSVDialect(sample []byte) CSVDialect {
+	var dialect CSVDialect
+	if bytes.HasPrefix(sample, utf8BOM) {
+		dialect.BOM = true
+		sample = sample[len(utf8BOM):]
+	}
+
+	dialect.Encoding = EncodingUTF8
+	if !validUTF8Prefix(sample) {
+		dialect.Encoding = EncodingWindows1252
+	}
+
+	dialect.LineEnding = detectLineEnding(sample)
+	lines := sampleLines(sample)
+	dialect.Delimiter = string(detectDelimiter(lines))
+	dialect.DecimalSeparator = detectDecimalSeparator(lines, dialect.Comma())
+	return dialect
+}
+
+func validUTF8Prefix(sample []byte) bool {
+	for i := 0; i < utf8.UTFMax && i < len(sample); i++ {
+		if utf8.Valid(sample[:len(sample)-i]) {
+			return true
+		}
+	}
+	return utf8.Valid(sample)
+}
+
+func detectLineEnding(sample []byte) string {
+	switch {
+	case bytes.Contains(sample, []byte("\r\n")):
+		return LineEndingCRLF
+	case bytes.IndexByte(sample, '\r') >= 0 && bytes.IndexByte(sample, '\n') < 0:
+		return LineEndingCR
+	}
+	return LineEndingLF
+}
+
+func sampleLines(sample []byte) []string {
+	text := string(sample)
+	complete := len(sample) < dialectSampleSize
+	lines := strings.FieldsFunc(text, func(r rune) bool { return r == '\n' || r == '\r' })
+	if !complete && len(lines) > 1 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func splitFields(line string, delimiter rune) []string {
+	var fields []string
+	var field strings.Builder
+	quoted := false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == delimiter && !quoted:
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteRune(r)
+		}
+	}
+	return append(fields, field.String())
+}
+
+func detectDelimiter(lines []string) rune {
+	if len(lines) > 20 {
+		lines = lines[:20]
+	}
+
+	best, bestFields := delimiterCandidates[0], 1
+	for _, candidate := range delimiterCandidates {
+		fieldCount := -1
+		consistent := true
+		for _, line := range lines {
+			count := len(splitFields(line, candidate))
+			if fieldCount == -1 {
+				fieldCount = count
+			} else if count != fieldCount {
+				consistent = false
+				break
+			}
+		}
+		if consistent && fieldCount > bestFields {
+			best, bestFields = candidate, fieldCount
+		}
+	}
+	return best
+}
+
+func detectDecimalSeparator(lines []string, delimiter rune) string {
+	if delimiter == ',' {
+		return "."
+	}
+	commas, points := 0, 0
+	for i, line := range lines {
+		if i == 0 {
+			continue
+		}
+		for _, field := range splitFields(line, delimiter) {
+			switch decimalSeparatorOf(strings.TrimSpace(field)) {
+			case ',':
+				commas++
+			case '.':
+				points++
+			}
+		}
+	}
+	if points > commas {
+		return "."
+	}
+	return ","
+}
+
+func decimalSeparatorOf(value string) rune {
+	value = strings.TrimPrefix(value, "-")
+	separator := strings.IndexAny(value, ",.")
+	if separator <= 0 || separator == len(value)-1 {
+		return 0
+	}
+	for i, r := range value {
+		if i != separator && (r < '0' || r > '9') {
+			return 0
+		}
+	}
+	return rune(value[separator])
+}
+
+// OpenCSVStream reads the CSV rows of r in their detected dialect, transcoded to UTF-8.
+func OpenCSVStream(r io.Reader, override CSVDialect) (*CSVStream, error) {
+	if err := override.Validate(); err != nil {
+		return nil, err
+	}
+
+	buffered := bufio.NewReaderSize(r, dialectSampleSize)
+	sample, err := buffered.Peek(dialectSampleSize)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, fmt.Errorf("failed to read the start of the CSV: %w", err)
+	}
+	if bytes.HasPrefix(sample, []byte{0xff, 0xfe}) || bytes.HasPrefix(sample, []byte{0xfe, 0xff}) {
+		return nil, errors.New("UTF-16 CSV files are not supported, export the file as UTF-8 or Windows-1252")
+	}
+
+	dialect := DetectCSVDialect(sample)
+	if override.Encoding != "" {
+		dialect.Encoding = override.Encoding
+	}
+	if override.Delimiter != "" {
+		dialect.Delimiter = override.Delimiter
+		if override.DecimalSeparator == "" {
+			dialect.DecimalSeparator = detectDecimalSeparator(sampleLines(sample), dialect.Comma())
+		}
+	}
+	if override.DecimalSeparator != "" {
+		dialect.DecimalSeparator = override.DecimalSeparator
+	}
+	if override.LineEnding != "" {
+		dialect.LineEnding = override.LineEnding
+	}
+
+	var reader io.Reader = buffered
+	if dialect.BOM {
+		if _, err := buffered.Discard(len(utf8BOM)); err != nil {
+			return nil, fmt.Errorf("failed to skip the byte order mark: %w", err)
+		}
+	}
+	if dialect.Encoding != EncodingUTF8 {
+		reader = &singleByteDecoder{source: reader, table: decodingTable(dialect.Encoding)}
+	}
+	if dialect.LineEnding == LineEndingCR {
+		reader = &carriageReturnReader{source: reader}
+	}
+
+	stream, err := NewCSVStream(reader, dialect.Comma())
+	if err != nil {
+		return nil, err
+	}
+	stream.dialect = dialect
+	return stream, nil
+}
+
+// windows1252 maps the bytes 0x80 to 0x9F; every other byte is its own code point.
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8d, 'Ž', 0x8f,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9d, 'ž', 'Ÿ',
+}
+
+func decodingTable(encoding string) *[256]rune {
+	var table [256]rune
+	for i := range table {
+		table[i] = rune(i)
+	}
+	if encoding == EncodingWindows1252 {
+		copy(table[0x80:0xa0], windows1252[:])
+	}
+	return &table
+}
+
+type singleByteDecoder struct {
+	source  io.Reader
+	table   *[256]rune
+	pending []byte
+	buffer  []byte
+}
+
+func (d *singleByteDecoder) Read(p []byte) (int, error) {
+	for len(d.pending) == 0 {
+		if cap(d.buffer) == 0 {
+			d.buffer = make([]byte, 4096)
+		}
+		n, err := d.source.Read(d.buffer[:cap(d.buffer)])
+		encoded := make([]byte, 0, n*2)
+		for _, b := range d.buffer[:n] {
+			encoded = utf8.AppendRune(encoded, d.table[b])
+		}
+		d.pending = encoded
+		if err != nil && len(d.pending) == 0 {
+			return 0, err
+		}
+	}
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+type carriageReturnReader struct {
+	source io.Reader
+}
+
+func (r *carriageReturnReader) Read(p []byte) (int, error) {
+	n, err := r.source.Read(p)
+	for i, b := range p[:n] {
+		if b == '\r' {
+			p[i] = '\n'
+		}
+	}
+	return n, err
+}
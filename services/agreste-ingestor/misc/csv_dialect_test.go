@@ -0,0 +1,115 @@
+package misc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectCSVDialect(t *testing.T) {
+	cases := map[string]struct {
+		sample   string
+		expected CSVDialect
+	}{
+		"UTF-8 with BOM": {
+			"\xef\xbb\xbfIDNUM;MILEX;SAU\n101;2023;12,5\n",
+			CSVDialect{Encoding: EncodingUTF8, BOM: true, LineEnding: LineEndingLF, Delimiter: ";", DecimalSeparator: ","},
+		},
+		"Windows-1252 with CRLF": {
+			"IDNUM;MILEX;LIBDEP\r\n101;2023;C\xf4te-d'Or\r\n",
+			CSVDialect{Encoding: EncodingWindows1252, LineEnding: LineEndingCRLF, Delimiter: ";", DecimalSeparator: ","},
+		},
+		"comma and decimal point": {
+			"IDNUM,MILEX,SAU\n101,2023,12.5\n102,2023,\"1,5\"\n",
+			CSVDialect{Encoding: EncodingUTF8, LineEnding: LineEndingLF, Delimiter: ",", DecimalSeparator: "."},
+		},
+		"tabs and decimal point": {
+			"IDNUM\tMILEX\tSAU\tUTA\n101\t2023\t12.5\t1.25\n",
+			CSVDialect{Encoding: EncodingUTF8, LineEnding: LineEndingLF, Delimiter: "\t", DecimalSeparator: "."},
+		},
+		"lone carriage returns": {
+			"IDNUM;MILEX\r101;2023\r",
+			CSVDialect{Encoding: EncodingUTF8, LineEnding: LineEndingCR, Delimiter: ";", DecimalSeparator: ","},
+		},
+	}
+
+	for name, tc := range cases {
+		if dialect := DetectCSVDialect([]byte(tc.sample)); dialect != tc.expected {
+			t.Errorf("%s: expected %+v, got %+v", name, tc.expected, dialect)
+		}
+	}
+}
+
+func TestOpenCSVStream(t *testing.T) {
+	t.Run("TranscodesWindows1252", func(t *testing.T) {
+		stream, err := OpenCSVStream(strings.NewReader(" IDNUM ;MILEX;LIBDEP\r\n101;2023;C\xf4te-d'Or \x80\r\n"), CSVDialect{})
+		if err != nil {
+			t.Fatalf("OpenCSVStream returned an unexpected error: %v", err)
+		}
+		if !stream.HasColumn("IDNUM") {
+			t.Errorf("expected the IDNUM column to be found, got header %q", stream.Header())
+		}
+		record, err := stream.Next()
+		if err != nil {
+			t.Fatalf("Next() returned an unexpected error: %v", err)
+		}
+		if value, _ := record.Get("LIBDEP"); value != "Côte-d'Or €" {
+			t.Errorf("expected the value to be transcoded to UTF-8, got %q", value)
+		}
+		if stream.Dialect().Encoding != EncodingWindows1252 {
+			t.Errorf("expected the Windows-1252 encoding to be recorded, got %+v", stream.Dialect())
+		}
+	})
+
+	t.Run("StripsBOM", func(t *testing.T) {
+		stream, err := OpenCSVStream(strings.NewReader("\xef\xbb\xbfIDNUM;MILEX\n101;2023\n"), CSVDialect{})
+		if err != nil {
+			t.Fatalf("OpenCSVStream returned an unexpected error: %v", err)
+		}
+		if !stream.HasColumn("IDNUM") || !stream.Dialect().BOM {
+			t.Errorf("expected the BOM to be detected and skipped, got header %q and %+v", stream.Header(), stream.Dialect())
+		}
+	})
+
+	t.Run("ReadsLoneCarriageReturns", func(t *testing.T) {
+		stream, err := OpenCSVStream(strings.NewReader("IDNUM;MILEX\r101;2023\r102;2023\r"), CSVDialect{})
+		if err != nil {
+			t.Fatalf("OpenCSVStream returned an unexpected error: %v", err)
+		}
+		rows := 0
+		for {
+			if _, err := stream.Next(); err != nil {
+				break
+			}
+			rows++
+		}
+		if rows != 2 {
+			t.Errorf("expected 2 rows, got %d", rows)
+		}
+	})
+
+	t.Run("AppliesOverrides", func(t *testing.T) {
+		override := CSVDialect{Encoding: EncodingISO88591, Delimiter: "|", DecimalSeparator: "."}
+		stream, err := OpenCSVStream(strings.NewReader("IDNUM|LIB\n101|\x80\n"), override)
+		if err != nil {
+			t.Fatalf("OpenCSVStream returned an unexpected error: %v", err)
+		}
+		dialect := stream.Dialect()
+		if dialect.Encoding != EncodingISO88591 || dialect.Delimiter != "|" || dialect.DecimalSeparator != "." {
+			t.Errorf("expected the overrides to be applied, got %+v", dialect)
+		}
+		if record, _ := stream.Next(); record.Values[1] != "\u0080" {
+			t.Errorf("expected ISO-8859-1 to keep 0x80 a control character, got %q", record.Values[1])
+		}
+	})
+
+	t.Run("RefusesUTF16AndInvalidOverrides", func(t *testing.T) {
+		if _, err := OpenCSVStream(strings.NewReader("\xff\xfeI\x00D\x00"), CSVDialect{}); err == nil {
+			t.Errorf("expected UTF-16 input to be refused")
+		}
+		for _, override := range []CSVDialect{{Encoding: "utf-16"}, {Delimiter: ";;"}, {DecimalSeparator: ";"}, {Delimiter: ",", DecimalSeparator: ","}} {
+			if _, err := OpenCSVStream(strings.NewReader("A;B\n"), override); err == nil {
+				t.Errorf("expected %+v to be refused", override)
+			}
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package misc
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type CSVRecord struct {
+	Row    int
+	Values []string
+	index  map[string]int
+}
+
+func (r CSVRecord) Get(column string) (string, bool) {
+	idx, exists := r.index[column]
+	if !exists || idx >= len(r.Values) {
+		return "", false
+	}
+	return r.Values[idx], true
+}
+
+type CSVStream struct {
+	reader  *csv.Reader
+	header  []string
+	index   map[string]int
+	row     int
+	dialect CSVDialect
+	closers []func() error
+}
+
+// NewCSVStream reads UTF-8 rows separated by comma, trimming column names.
+func NewCSVStream(r io.Reader, comma rune) (*CSVStream, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = comma
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("CSV input is empty, a header row was expected")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], string(utf8BOM))
+	}
+	index := make(map[string]int, len(header))
+	for idx, colName := range header {
+		header[idx] = strings.TrimSpace(colName)
+		index[header[idx]] = idx
+	}
+
+	return &CSVStream{
+		reader:  reader,
+		header:  header,
+		index:   index,
+		dialect: CSVDialect{Encoding: EncodingUTF8, Delimiter: string(comma), DecimalSeparator: ","},
+	}, nil
+}
+
+// Dialect returns how the rows are written.
+func (s *CSVStream) Dialect() CSVDialect {
+	return s.dialect
+}
+
+func (s *CSVStream) Header() []string {
+	return s.header
+}
+
+func (s *CSVStream) HasColumn(column string) bool {
+	_, exists := s.index[column]
+	return exists
+}
+
+// Next returns the following data row, numbered from 1, or io.EOF at the end.
+func (s *CSVStream) Next() (CSVRecord, error) {
+	values, err := s.reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return CSVRecord{}, io.EOF
+		}
+		return CSVRecord{}, fmt.Errorf("failed to read CSV row %d: %w", s.row+1, err)
+	}
+	s.row++
+
+	return CSVRecord{Row: s.row, Values: values, index: s.index}, nil
+}
+
+// OnClose registers a function run, in reverse order, when the stream is closed.
+func (s *CSVStream) OnClose(closer func() error) {
+	s.closers = append(s.closers, closer)
+}
+
+func (s *CSVStream) Close() error {
+	var errs []error
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		if err := s.closers[i](); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	s.closers = nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,86 @@
+package misc
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCSVStream(t *testing.T) {
+
+	t.Run("ReadsRowsOneByOne", func(t *testing.T) {
+		input := "IDNUM;MILEX;OTEFDD\n101;2023;1500\n102;2023;4500\n"
+		stream, err := NewCSVStream(strings.NewReader(input), ';')
+		if err != nil {
+			t.Fatalf("NewCSVStream returned an unexpected error: %v", err)
+		}
+		defer stream.Close()
+
+		if got := strings.Join(stream.Header(), ","); got != "IDNUM,MILEX,OTEFDD" {
+			t.Errorf("Header mismatch. Expected IDNUM,MILEX,OTEFDD, got %s", got)
+		}
+		if !stream.HasColumn("MILEX") {
+			t.Errorf("HasColumn(MILEX) should be true")
+		}
+		if stream.HasColumn("REGION") {
+			t.Errorf("HasColumn(REGION) should be false")
+		}
+
+		expected := []string{"101", "102"}
+		for i, idNum := range expected {
+			record, err := stream.Next()
+			if err != nil {
+				t.Fatalf("Next() returned an unexpected error on row %d: %v", i+1, err)
+			}
+			if record.Row != i+1 {
+				t.Errorf("Row number mismatch. Expected %d, got %d", i+1, record.Row)
+			}
+			if got, ok := record.Get("IDNUM"); !ok || got != idNum {
+				t.Errorf("IDNUM mismatch. Expected %s, got %s (found: %t)", idNum, got, ok)
+			}
+			if _, ok := record.Get("UNKNOWN"); ok {
+				t.Errorf("Get(UNKNOWN) should not find a value")
+			}
+		}
+
+		if _, err := stream.Next(); !errors.Is(err, io.EOF) {
+			t.Errorf("expected io.EOF after the last row, got %v", err)
+		}
+	})
+
+	t.Run("EmptyInput", func(t *testing.T) {
+		_, err := NewCSVStream(strings.NewReader(""), ';')
+		if err == nil {
+			t.Errorf("NewCSVStream expected an error for an empty input, but got none")
+		}
+	})
+
+	t.Run("MalformedRow", func(t *testing.T) {
+		stream, err := NewCSVStream(strings.NewReader("A;B\n1;2;3\n"), ';')
+		if err != nil {
+			t.Fatalf("NewCSVStream returned an unexpected error: %v", err)
+		}
+		if _, err := stream.Next(); err == nil || errors.Is(err, io.EOF) {
+			t.Errorf("expected a parse error for a row with too many fields, got %v", err)
+		}
+	})
+
+	t.Run("CloseRunsClosersInReverseOrder", func(t *testing.T) {
+		stream, err := NewCSVStream(strings.NewReader("A\n1\n"), ';')
+		if err != nil {
+			t.Fatalf("NewCSVStream returned an unexpected error: %v", err)
+		}
+
+		var order []string
+		stream.OnClose(func() error { order = append(order, "first"); return nil })
+		stream.OnClose(func() error { order = append(order, "second"); return errors.New("boom") })
+
+		if err := stream.Close(); err == nil {
+			t.Errorf("Close expected to report the closer error, but got none")
+		}
+		if strings.Join(order, ",") != "second,first" {
+			t.Errorf("closers ran in unexpected order: %v", order)
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"agreste-ingestor/agri_units"
+	"agreste-ingestor/jobs"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// JobRejectionsHandler serves the rows a job did not write, as JSON or with ?format=csv as CSV.
+func (a *App) JobRejectionsHandler(w http.ResponseWriter, r *http.Request) {
+	jobID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid job ID '%s'", r.PathValue("id")), http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		http.Error(w, fmt.Sprintf("Invalid 'format' query parameter: '%s', expected 'json' or 'csv'", format), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := a.IngestJobStorage.SelectByID(jobID); errors.Is(err, jobs.ErrIngestJobNotFound) {
+		http.Error(w, fmt.Sprintf("Ingestion job '%s' not found", jobID), http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error loading ingestion job %s: %v\n", jobID, err)
+		http.Error(w, fmt.Sprintf("Error loading ingestion job: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	rejections, err := a.RejectionStorage.SelectByRunID(jobID)
+	if err != nil {
+		log.Printf("Error loading rejections of ingestion job %s: %v\n", jobID, err)
+		http.Error(w, fmt.Sprintf("Error loading rejections: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if rejections == nil {
+		rejections = []agri_units.IngestRejection{}
+	}
+
+	if format != "csv" {
+		writeJSON(w, http.StatusOK, rejections)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="rejections-%s.csv"`, jobID))
+	if err := agri_units.WriteIngestRejectionsCSV(w, rejections); err != nil {
+		log.Printf("Error writing rejections of ingestion job %s: %v\n", jobID, err)
+	}
+}
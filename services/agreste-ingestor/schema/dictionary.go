@@ -0,0 +1,272 @@
+package schema
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type ColumnType string
+
+const (
+	// TypeInteger is a whole number, written without decimals.
+	TypeInteger ColumnType = "integer"
+	// TypeDecimal accepts both "12.5" and the French "12,5".
+	TypeDecimal ColumnType = "decimal"
+	// TypeBoolean accepts 1/0, true/false, oui/non and o/n.
+	TypeBoolean ColumnType = "boolean"
+	// TypeCode is an identifier kept verbatim, leading zeros included.
+	TypeCode ColumnType = "code"
+	TypeText ColumnType = "text"
+)
+
+type Column struct {
+	Name     string     `json:"name"`
+	Type     ColumnType `json:"type"`
+	Unit     string     `json:"unit,omitempty"`
+	Label    string     `json:"label"`
+	Nullable bool       `json:"nullable"`
+}
+
+// Dictionary describes the RICA columns from FromYear to ToYear, zero leaving an end open.
+type Dictionary struct {
+	Version  string   `json:"version"`
+	FromYear int      `json:"fromYear"`
+	ToYear   int      `json:"toYear"`
+	Columns  []Column `json:"columns"`
+
+	index map[string]Column
+}
+
+func (d *Dictionary) Covers(year int) bool {
+	return (d.FromYear == 0 || year >= d.FromYear) && (d.ToYear == 0 || year <= d.ToYear)
+}
+
+func (d *Dictionary) Column(name string) (Column, bool) {
+	column, exists := d.index[name]
+	return column, exists
+}
+
+func (d *Dictionary) validate() error {
+	if d.Version == "" {
+		return fmt.Errorf("dictionary has no version")
+	}
+	if d.ToYear != 0 && d.ToYear < d.FromYear {
+		return fmt.Errorf("dictionary %s ends (%d) before it starts (%d)", d.Version, d.ToYear, d.FromYear)
+	}
+
+	d.index = make(map[string]Column, len(d.Columns))
+	for _, column := range d.Columns {
+		switch column.Type {
+		case TypeInteger, TypeDecimal, TypeBoolean, TypeCode, TypeText:
+		default:
+			return fmt.Errorf("dictionary %s: column %s has unknown type '%s'", d.Version, column.Name, column.Type)
+		}
+		if _, duplicate := d.index[column.Name]; duplicate {
+			return fmt.Errorf("dictionary %s: column %s is defined twice", d.Version, column.Name)
+		}
+		d.index[column.Name] = column
+	}
+	return nil
+}
+
+func ParseDictionary(content []byte) (*Dictionary, error) {
+	var dictionary Dictionary
+	if err := json.Unmarshal(content, &dictionary); err != nil {
+		return nil, fmt.Errorf("failed to decode RICA dictionary: %w", err)
+	}
+	if err := dictionary.validate(); err != nil {
+		return nil, err
+	}
+	return &dictionary, nil
+}
+
+// Registry holds every known dictionary version.
+type Registry struct {
+	dictionaries []*Dictionary
+}
+
+//go:embed dictionaries/*.json
+var embeddedDictionaries embed.FS
+
+// LoadRegistry reads the embedded dictionaries, then those of extraDir, which replace them by version.
+func LoadRegistry(extraDir string) (*Registry, error) {
+	byVersion := make(map[string]*Dictionary)
+
+	entries, err := embeddedDictionaries.ReadDir("dictionaries")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list embedded RICA dictionaries: %w", err)
+	}
+	for _, entry := range entries {
+		content, err := embeddedDictionaries.ReadFile("dictionaries/" + entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read embedded RICA dictionary %s: %w", entry.Name(), err)
+		}
+		dictionary, err := ParseDictionary(content)
+		if err != nil {
+			return nil, fmt.Errorf("embedded RICA dictionary %s: %w", entry.Name(), err)
+		}
+		byVersion[dictionary.Version] = dictionary
+	}
+
+	if extraDir != "" {
+		paths, err := filepath.Glob(filepath.Join(extraDir, "*.json"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list RICA dictionaries in %s: %w", extraDir, err)
+		}
+		for _, path := range paths {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read RICA dictionary %s: %w", path, err)
+			}
+			dictionary, err := ParseDictionary(content)
+			if err != nil {
+				return nil, fmt.Errorf("RICA dictionary %s: %w", path, err)
+			}
+			byVersion[dictionary.Version] = dictionary
+		}
+	}
+
+	registry := &Registry{}
+	for _, dictionary := range byVersion {
+		registry.dictionaries = append(registry.dictionaries, dictionary)
+	}
+	sort.Slice(registry.dictionaries, func(i, j int) bool {
+		return registry.dictionaries[i].Version < registry.dictionaries[j].Version
+	})
+	return registry, nil
+}
+
+func NewRegistry(dictionaries ...*Dictionary) *Registry {
+	return &Registry{dictionaries: dictionaries}
+}
+
+// All returns the dictionaries ordered by version.
+func (r *Registry) All() []*Dictionary {
+	return r.dictionaries
+}
+
+func (r *Registry) Version(version string) (*Dictionary, bool) {
+	for _, dictionary := range r.dictionaries {
+		if dictionary.Version == version {
+			return dictionary, true
+		}
+	}
+	return nil, false
+}
+
+// ForYear returns the dictionary covering year with the latest FromYear.
+func (r *Registry) ForYear(year int) (*Dictionary, error) {
+	var best *Dictionary
+	for _, dictionary := range r.dictionaries {
+		if !dictionary.Covers(year) {
+			continue
+		}
+		if best == nil || dictionary.FromYear > best.FromYear {
+			best = dictionary
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no RICA dictionary covers survey year %d", year)
+	}
+	return best, nil
+}
+
+// FieldIssue explains why a cell could not be parsed with its column type.
+type FieldIssue struct {
+	Column string `json:"column"`
+	Value  string `json:"value"`
+	Reason string `json:"reason"`
+}
+
+func (i FieldIssue) Error() string {
+	return fmt.Sprintf("column %s: %s (value '%s')", i.Column, i.Reason, i.Value)
+}
+
+// ParseRow converts a CSV row to a survey payload, reporting the cells it cannot parse.
+func (d *Dictionary) ParseRow(header []string, values []string, decimalSeparator string) (map[string]interface{}, []FieldIssue) {
+	payload := make(map[string]interface{}, len(header))
+	var issues []FieldIssue
+
+	for colIdx, colName := range header {
+		if colIdx >= len(values) {
+			break
+		}
+		raw := values[colIdx]
+
+		column, described := d.index[colName]
+		if !described {
+			payload[colName] = inferValue(raw, decimalSeparator)
+			continue
+		}
+
+		value, err := column.Parse(raw, decimalSeparator)
+		if err != nil {
+			issues = append(issues, FieldIssue{Column: colName, Value: raw, Reason: err.Error()})
+			continue
+		}
+		payload[colName] = value
+	}
+
+	return payload, issues
+}
+
+func inferValue(raw string, decimalSeparator string) interface{} {
+	value := raw
+	if decimalSeparator == "," {
+		value = strings.Replace(value, ",", ".", 1)
+	}
+	if number, err := strconv.ParseFloat(value, 64); err == nil {
+		return number
+	}
+	if boolean, err := strconv.ParseBool(raw); err == nil {
+		return boolean
+	}
+	return raw
+}
+
+// Parse converts one cell, nil for an empty nullable cell.
+func (c Column) Parse(raw string, decimalSeparator string) (interface{}, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		if c.Nullable {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("value is required")
+	}
+
+	switch c.Type {
+	case TypeInteger:
+		number, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("not an integer")
+		}
+		return number, nil
+	case TypeDecimal:
+		if decimalSeparator != "." {
+			value = strings.Replace(value, ",", ".", 1)
+		}
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("not a decimal number")
+		}
+		return number, nil
+	case TypeBoolean:
+		switch strings.ToLower(value) {
+		case "1", "true", "oui", "o":
+			return true, nil
+		case "0", "false", "non", "n":
+			return false, nil
+		}
+		return nil, fmt.Errorf("not a boolean")
+	case TypeCode:
+		return value, nil
+	default:
+		return raw, nil
+	}
+}
@@ -0,0 +1,90 @@
+package schema
+
+import (
+	"common/storage"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	_ "github.com/lib/pq"
+)
+
+// DictionarySqlView is a row of survey_schemas.
+type DictionarySqlView struct {
+	Version   string    `db:"version"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+	FromYear  int       `db:"from_year"`
+	ToYear    int       `db:"to_year"`
+	Columns   []byte    `db:"columns"`
+}
+
+func DictionaryToSqlView(dictionary *Dictionary, now time.Time) (DictionarySqlView, error) {
+	columns, err := json.Marshal(dictionary.Columns)
+	if err != nil {
+		return DictionarySqlView{}, fmt.Errorf("failed to marshal columns of RICA dictionary %s: %w", dictionary.Version, err)
+	}
+	return DictionarySqlView{
+		Version:   dictionary.Version,
+		CreatedAt: now,
+		UpdatedAt: now,
+		FromYear:  dictionary.FromYear,
+		ToYear:    dictionary.ToYear,
+		Columns:   columns,
+	}, nil
+}
+
+type DictionaryStorage interface {
+	InsertOrUpdate(dictionary *Dictionary) error
+	WithQuerier(querier storage.DBQuerier) DictionaryStorage
+}
+
+type dictionaryStorage struct {
+	querier storage.DBQuerier
+	builder sq.StatementBuilderType
+}
+
+func NewDictionaryStorage(querier storage.DBQuerier) DictionaryStorage {
+	return &dictionaryStorage{
+		querier: querier,
+		builder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+}
+
+func (s *dictionaryStorage) WithQuerier(querier storage.DBQuerier) DictionaryStorage {
+	return &dictionaryStorage{
+		querier: querier,
+		builder: s.builder,
+	}
+}
+
+func (s *dictionaryStorage) InsertOrUpdate(dictionary *Dictionary) error {
+	sqlView, err := DictionaryToSqlView(dictionary, time.Now())
+	if err != nil {
+		return err
+	}
+
+	query, args, err := s.builder.Insert("survey_schemas").
+		Columns("version", "created_at", "updated_at", "from_year", "to_year", "columns").
+		Values(sqlView.Version, sqlView.CreatedAt, sqlView.UpdatedAt, sqlView.FromYear, sqlView.ToYear, sqlView.Columns).
+		Suffix(`
+			ON CONFLICT (version) DO UPDATE SET
+				updated_at = EXCLUDED.updated_at,
+				from_year = EXCLUDED.from_year,
+				to_year = EXCLUDED.to_year,
+				columns = EXCLUDED.columns
+		`).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build InsertOrUpdate SQL for RICA dictionary: %w", err)
+	}
+
+	_, err = s.querier.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to execute InsertOrUpdate for RICA dictionary %s: %w", dictionary.Version, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package schema
+
+import (
+	"common/testutils"
+	"regexp"
+	"testing"
+
+	go_sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDictionaryStorage_InsertOrUpdate(t *testing.T) {
+	mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+	if err != nil {
+		t.Fatalf("Failed to create mock querier: %v", err)
+	}
+	defer mockQuerierInstance.Db.Close()
+
+	dictionary := &Dictionary{
+		Version:  "rica-2020.1",
+		FromYear: 2020,
+		Columns:  []Column{{Name: "SAU", Type: TypeDecimal, Unit: "ha", Label: "Surface agricole utilisée", Nullable: true}},
+	}
+
+	sqlMock.ExpectExec(regexp.QuoteMeta(`INSERT INTO survey_schemas (version,created_at,updated_at,from_year,to_year,columns) VALUES ($1,$2,$3,$4,$5,$6) ON CONFLICT (version) DO UPDATE SET`)).
+		WithArgs("rica-2020.1", go_sqlmock.AnyArg(), go_sqlmock.AnyArg(), 2020, 0,
+			[]byte(`[{"name":"SAU","type":"decimal","unit":"ha","label":"Surface agricole utilisée","nullable":true}]`)).
+		WillReturnResult(go_sqlmock.NewResult(0, 1))
+
+	if err := NewDictionaryStorage(mockQuerierInstance).InsertOrUpdate(dictionary); err != nil {
+		t.Fatalf("InsertOrUpdate returned an unexpected error: %v", err)
+	}
+
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("There were unfulfilled expectations: %s", err)
+	}
+}
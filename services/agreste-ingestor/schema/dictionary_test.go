@@ -0,0 +1,129 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestColumnParse(t *testing.T) {
+	cases := []struct {
+		column   Column
+		raw      string
+		decimal  string
+		expected interface{}
+		invalid  bool
+	}{
+		{Column{Name: "IDNUM", Type: TypeInteger}, "101", ",", int64(101), false},
+		{Column{Name: "IDNUM", Type: TypeInteger}, "10.5", ",", nil, true},
+		{Column{Name: "IDNUM", Type: TypeInteger}, "", ",", nil, true},
+		{Column{Name: "SAU", Type: TypeDecimal, Nullable: true}, "12,5", ",", 12.5, false},
+		{Column{Name: "SAU", Type: TypeDecimal, Nullable: true}, " 80.25 ", ",", 80.25, false},
+		{Column{Name: "SAU", Type: TypeDecimal, Nullable: true}, "", ",", nil, false},
+		{Column{Name: "SAU", Type: TypeDecimal, Nullable: true}, "n/a", ",", nil, true},
+		{Column{Name: "SAU", Type: TypeDecimal, Nullable: true}, "80.25", ".", 80.25, false},
+		{Column{Name: "SAU", Type: TypeDecimal, Nullable: true}, "1,250", ".", nil, true},
+		{Column{Name: "AGBIO", Type: TypeBoolean, Nullable: true}, "1", ",", true, false},
+		{Column{Name: "AGBIO", Type: TypeBoolean, Nullable: true}, "Non", ",", false, false},
+		{Column{Name: "AGBIO", Type: TypeBoolean, Nullable: true}, "2", ",", nil, true},
+		{Column{Name: "DEP", Type: TypeCode, Nullable: true}, "01", ",", "01", false},
+		{Column{Name: "LIB", Type: TypeText, Nullable: true}, " Ferme ", ",", " Ferme ", false},
+	}
+
+	for _, tc := range cases {
+		value, err := tc.column.Parse(tc.raw, tc.decimal)
+		if tc.invalid {
+			if err == nil {
+				t.Errorf("%s %q: expected an error, got %v", tc.column.Type, tc.raw, value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %q: unexpected error %v", tc.column.Type, tc.raw, err)
+			continue
+		}
+		if !reflect.DeepEqual(value, tc.expected) {
+			t.Errorf("%s %q: expected %#v, got %#v", tc.column.Type, tc.raw, tc.expected, value)
+		}
+	}
+}
+
+func TestDictionaryParseRow(t *testing.T) {
+	dictionary, err := ParseDictionary([]byte(`{
+		"version": "test.1", "fromYear": 2020,
+		"columns": [
+			{"name": "IDNUM", "type": "integer"},
+			{"name": "SAU", "type": "decimal", "unit": "ha", "nullable": true}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseDictionary returned an unexpected error: %v", err)
+	}
+
+	header := []string{"IDNUM", "SAU", "PBV3COLZ", "OTHER", "FLAG", "NOTE"}
+	payload, issues := dictionary.ParseRow(header, []string{"101", "12,5", "1234,5", "007", "true", "n/a"}, ",")
+	if len(issues) != 0 {
+		t.Fatalf("expected no issues, got %v", issues)
+	}
+	// Undescribed columns are read as numbers or booleans when they can be.
+	expected := map[string]interface{}{"IDNUM": int64(101), "SAU": 12.5, "PBV3COLZ": 1234.5, "OTHER": 7.0, "FLAG": true, "NOTE": "n/a"}
+	if !reflect.DeepEqual(payload, expected) {
+		t.Errorf("payload mismatch. Expected %v, got %v", expected, payload)
+	}
+
+	payload, issues = dictionary.ParseRow([]string{"IDNUM", "SAU"}, []string{"", "abc"}, ",")
+	if len(issues) != 2 || issues[0].Column != "IDNUM" || issues[1].Column != "SAU" || issues[1].Value != "abc" {
+		t.Errorf("expected issues on IDNUM and SAU, got %v", issues)
+	}
+	if len(payload) != 0 {
+		t.Errorf("invalid cells should be left out of the payload, got %v", payload)
+	}
+}
+
+func TestParseDictionary_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"no version":     `{"columns": []}`,
+		"unknown type":   `{"version": "x", "columns": [{"name": "A", "type": "date"}]}`,
+		"duplicate":      `{"version": "x", "columns": [{"name": "A", "type": "text"}, {"name": "A", "type": "code"}]}`,
+		"inverted years": `{"version": "x", "fromYear": 2024, "toYear": 2020, "columns": []}`,
+		"not json":       `version: x`,
+	}
+	for name, content := range cases {
+		if _, err := ParseDictionary([]byte(content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLoadRegistry(t *testing.T) {
+	extraDir := t.TempDir()
+	extra := `{"version": "rica-2024.1", "fromYear": 2024, "toYear": 2024, "columns": [{"name": "IDNUM", "type": "integer"}]}`
+	if err := os.WriteFile(filepath.Join(extraDir, "rica-2024.json"), []byte(extra), 0o644); err != nil {
+		t.Fatalf("failed to write extra dictionary: %v", err)
+	}
+
+	registry, err := LoadRegistry(extraDir)
+	if err != nil {
+		t.Fatalf("LoadRegistry returned an unexpected error: %v", err)
+	}
+
+	for year, expected := range map[int]string{2008: "rica-base.1", 2019: "rica-base.1", 2021: "rica-2020.1", 2024: "rica-2024.1", 2025: "rica-2020.1"} {
+		dictionary, err := registry.ForYear(year)
+		if err != nil {
+			t.Errorf("ForYear(%d) returned an unexpected error: %v", year, err)
+			continue
+		}
+		if dictionary.Version != expected {
+			t.Errorf("ForYear(%d): expected %s, got %s", year, expected, dictionary.Version)
+		}
+	}
+
+	if _, err := NewRegistry().ForYear(2019); err == nil || !strings.Contains(err.Error(), "2019") {
+		t.Errorf("expected no dictionary for 2019 in an empty registry, got %v", err)
+	}
+	if _, found := registry.Version("rica-2024.1"); !found {
+		t.Errorf("expected rica-2024.1 to be listed")
+	}
+}
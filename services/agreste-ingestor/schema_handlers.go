@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+func (a *App) SchemasHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.Dictionaries.All())
+}
+
+func (a *App) SchemaHandler(w http.ResponseWriter, r *http.Request) {
+	dictionary, found := a.Dictionaries.Version(r.PathValue("version"))
+	if !found {
+		http.Error(w, fmt.Sprintf("RICA dictionary '%s' not found", r.PathValue("version")), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, dictionary)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"agreste-ingestor/sources"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// SourceMembersResponse lists the files of the archive at URL.
+type SourceMembersResponse struct {
+	URL       string            `json:"url"`
+	SHA256    string            `json:"sha256"`
+	Container sources.Container `json:"container"`
+	Members   []sources.Member  `json:"members"`
+}
+
+// SourceMembersHandler lists the files of the source given by the url query parameter.
+func (a *App) SourceMembersHandler(w http.ResponseWriter, r *http.Request) {
+	sourceURL := r.URL.Query().Get("url")
+	if sourceURL == "" {
+		http.Error(w, "Query parameter 'url' is required.", http.StatusBadRequest)
+		return
+	}
+	if err := a.Opener.Validate(sourceURL); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid 'url': %v", err), http.StatusBadRequest)
+		return
+	}
+
+	artifact, err := a.Opener.Fetch(sourceURL, nil)
+	if err != nil {
+		writeSourceError(w, sourceURL, fmt.Errorf("failed to fetch source: %w", err))
+		return
+	}
+	container, members, err := a.Opener.Members(artifact)
+	if err != nil {
+		writeSourceError(w, sourceURL, err)
+		return
+	}
+	if members == nil {
+		members = []sources.Member{}
+	}
+
+	writeJSON(w, http.StatusOK, SourceMembersResponse{
+		URL:       sourceURL,
+		SHA256:    artifact.SHA256,
+		Container: container,
+		Members:   members,
+	})
+}
+
+func writeSourceError(w http.ResponseWriter, sourceURL string, err error) {
+	log.Printf("Error listing the members of %s: %v\n", sourceURL, err)
+	status := http.StatusBadGateway
+	if sources.IsPermanent(err) {
+		status = http.StatusUnprocessableEntity
+	}
+	http.Error(w, fmt.Sprintf("Error listing source members: %v", err), status)
+}
@@ -0,0 +1,244 @@
+package sources
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Validators are the HTTP cache validators of a downloaded object.
+type Validators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+func (v Validators) IsZero() bool {
+	return v.ETag == "" && v.LastModified == ""
+}
+
+// CacheEntry describes the last download of a source URI.
+type CacheEntry struct {
+	URI        string     `json:"uri"`
+	SHA256     string     `json:"sha256"`
+	Size       int64      `json:"size"`
+	Validators Validators `json:"validators"`
+	FetchedAt  time.Time  `json:"fetchedAt"`
+}
+
+// Cache keeps downloaded sources on disk, stored once by their SHA-256.
+type Cache struct {
+	dir string
+}
+
+func NewCache(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+func (c *Cache) entryPath(uri string) string {
+	sum := sha256.Sum256([]byte(uri))
+	return filepath.Join(c.dir, "entries", hex.EncodeToString(sum[:])+".json")
+}
+
+// ObjectPath is where the content of an entry is stored.
+func (c *Cache) ObjectPath(entry CacheEntry) string {
+	return filepath.Join(c.dir, "objects", entry.SHA256)
+}
+
+// Lookup returns the entry of uri, absent when its content has gone missing.
+func (c *Cache) Lookup(uri string) (CacheEntry, bool, error) {
+	content, err := os.ReadFile(c.entryPath(uri))
+	if errors.Is(err, os.ErrNotExist) {
+		return CacheEntry{}, false, nil
+	}
+	if err != nil {
+		return CacheEntry{}, false, fmt.Errorf("failed to read cache entry of '%s': %w", uri, err)
+	}
+
+	var entry CacheEntry
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return CacheEntry{}, false, fmt.Errorf("failed to decode cache entry of '%s': %w", uri, err)
+	}
+	if _, err := os.Stat(c.ObjectPath(entry)); err != nil {
+		return CacheEntry{}, false, nil
+	}
+	return entry, true, nil
+}
+
+// Download is a source being written to the cache.
+type Download struct {
+	file *os.File
+	size int64
+}
+
+// NewDownload starts an empty download in the cache directory.
+func (c *Cache) NewDownload() (*Download, error) {
+	objectsDir := filepath.Join(c.dir, "objects")
+	if err := os.MkdirAll(objectsDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create source cache directory: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Join(c.dir, "entries"), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create source cache directory: %w", err)
+	}
+
+	file, err := os.CreateTemp(objectsDir, ".download-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary source file: %w", err)
+	}
+	return &Download{file: file}, nil
+}
+
+func (d *Download) Size() int64 {
+	return d.size
+}
+
+// Reset drops what was written, for a server that does not resume downloads.
+func (d *Download) Reset() error {
+	if err := d.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate partial download: %w", err)
+	}
+	if _, err := d.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind partial download: %w", err)
+	}
+	d.size = 0
+	return nil
+}
+
+// Append copies content at the end of the download, keeping it on a read error.
+func (d *Download) Append(content io.Reader, written func(size int64)) error {
+	buffer := make([]byte, 64*1024)
+	for {
+		n, readErr := content.Read(buffer)
+		if n > 0 {
+			if _, err := d.file.Write(buffer[:n]); err != nil {
+				return fmt.Errorf("failed to write source content to cache: %w", err)
+			}
+			d.size += int64(n)
+			written(d.size)
+		}
+		if errors.Is(readErr, io.EOF) {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+// Discard removes an uncommitted download; it does nothing once committed.
+func (d *Download) Discard() {
+	d.file.Close()
+	os.Remove(d.file.Name())
+}
+
+// Commit moves a complete download under its SHA-256 and records it as the content of uri.
+func (c *Cache) Commit(uri string, download *Download, validators Validators) (CacheEntry, error) {
+	previous, hadPrevious, err := c.Lookup(uri)
+	if err != nil {
+		return CacheEntry{}, err
+	}
+
+	if err := download.file.Sync(); err != nil {
+		return CacheEntry{}, fmt.Errorf("failed to sync cached source: %w", err)
+	}
+	if err := download.file.Close(); err != nil {
+		return CacheEntry{}, fmt.Errorf("failed to close cached source: %w", err)
+	}
+	sum, size, err := hashFile(download.file.Name())
+	if err != nil {
+		return CacheEntry{}, err
+	}
+
+	entry := CacheEntry{
+		URI:        uri,
+		SHA256:     sum,
+		Size:       size,
+		Validators: validators,
+		FetchedAt:  time.Now(),
+	}
+	if err := os.Rename(download.file.Name(), c.ObjectPath(entry)); err != nil {
+		return CacheEntry{}, fmt.Errorf("failed to move source into cache: %w", err)
+	}
+	if err := c.writeEntry(entry); err != nil {
+		return CacheEntry{}, err
+	}
+
+	if hadPrevious && previous.SHA256 != entry.SHA256 {
+		if err := c.removeUnreferenced(previous.SHA256); err != nil {
+			return CacheEntry{}, err
+		}
+	}
+	return entry, nil
+}
+
+// Touch records that the URI of entry still serves it.
+func (c *Cache) Touch(entry CacheEntry, validators Validators) (CacheEntry, error) {
+	if !validators.IsZero() {
+		entry.Validators = validators
+	}
+	entry.FetchedAt = time.Now()
+	return entry, c.writeEntry(entry)
+}
+
+func (c *Cache) writeEntry(entry CacheEntry) error {
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry of '%s': %w", entry.URI, err)
+	}
+
+	path := c.entryPath(entry.URI)
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, content, 0o644); err != nil {
+		return fmt.Errorf("failed to write cache entry of '%s': %w", entry.URI, err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		return fmt.Errorf("failed to write cache entry of '%s': %w", entry.URI, err)
+	}
+	return nil
+}
+
+func (c *Cache) removeUnreferenced(sum string) error {
+	entryFiles, err := os.ReadDir(filepath.Join(c.dir, "entries"))
+	if err != nil {
+		return fmt.Errorf("failed to list cache entries: %w", err)
+	}
+	for _, entryFile := range entryFiles {
+		if !strings.HasSuffix(entryFile.Name(), ".json") {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(c.dir, "entries", entryFile.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read cache entry %s: %w", entryFile.Name(), err)
+		}
+		var entry CacheEntry
+		if json.Unmarshal(content, &entry) == nil && entry.SHA256 == sum {
+			return nil
+		}
+	}
+
+	if err := os.Remove(filepath.Join(c.dir, "objects", sum)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove stale cached source %s: %w", sum, err)
+	}
+	return nil
+}
+
+func hashFile(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
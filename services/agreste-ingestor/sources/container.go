@@ -0,0 +1,153 @@
+package sources
+
+import (
+	"agreste-ingestor/misc"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type Container string
+
+const (
+	ContainerZip  Container = "zip"
+	ContainerGzip Container = "gzip"
+	ContainerCSV  Container = "csv"
+)
+
+var (
+	zipMagic  = []byte("PK\x03\x04")
+	gzipMagic = []byte{0x1f, 0x8b}
+)
+
+// DetectContainer tells archives from plain CSV by their first bytes.
+func DetectContainer(head []byte) Container {
+	switch {
+	case bytes.HasPrefix(head, zipMagic):
+		return ContainerZip
+	case bytes.HasPrefix(head, gzipMagic):
+		return ContainerGzip
+	}
+	return ContainerCSV
+}
+
+func detectFileContainer(path string) (Container, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	head := make([]byte, len(zipMagic))
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read the start of %s: %w", path, err)
+	}
+	return DetectContainer(head[:n]), nil
+}
+
+func openCSVFile(path string, member string, dialect misc.CSVDialect) (stream *misc.CSVStream, err error) {
+	container, err := detectFileContainer(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var closers []func() error
+	defer func() {
+		if err != nil {
+			for i := len(closers) - 1; i >= 0; i-- {
+				closers[i]()
+			}
+		}
+	}()
+
+	var reader io.Reader
+	switch container {
+	case ContainerZip:
+		zipReader, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open ZIP archive: %w", err)
+		}
+		closers = append(closers, zipReader.Close)
+
+		csvFile, err := findZipMember(zipReader.File, member)
+		if err != nil {
+			return nil, err
+		}
+		rc, err := csvFile.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open CSV file '%s' from ZIP: %w", csvFile.Name, err)
+		}
+		closers = append(closers, rc.Close)
+		reader = rc
+	case ContainerGzip:
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip file: %w", err)
+		}
+		closers = append(closers, file.Close)
+
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip header: %w", err)
+		}
+		closers = append(closers, gzipReader.Close)
+		reader = gzipReader
+	default:
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open CSV file: %w", err)
+		}
+		closers = append(closers, file.Close)
+		reader = file
+	}
+
+	stream, err = misc.OpenCSVStream(reader, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s CSV records: %w", container, err)
+	}
+	for _, closer := range closers {
+		stream.OnClose(closer)
+	}
+	return stream, nil
+}
+
+func findZipMember(files []*zip.File, member string) (*zip.File, error) {
+	if member != "" {
+		for _, file := range files {
+			if strings.EqualFold(file.Name, member) {
+				return file, nil
+			}
+		}
+		var matches []*zip.File
+		for _, file := range files {
+			if strings.EqualFold(filepath.Base(file.Name), member) {
+				matches = append(matches, file)
+			}
+		}
+		switch len(matches) {
+		case 0:
+			return nil, permanent("CSV file '%s' not found in the ZIP archive", member)
+		case 1:
+			return matches[0], nil
+		}
+		return nil, permanent("%d files of the ZIP archive are named '%s', give the path of the one to ingest", len(matches), member)
+	}
+
+	var csvFiles []*zip.File
+	for _, file := range files {
+		if isCSVName(file.Name) {
+			csvFiles = append(csvFiles, file)
+		}
+	}
+	if len(csvFiles) != 1 {
+		return nil, permanent("the ZIP archive holds %d CSV files, name the one to ingest", len(csvFiles))
+	}
+	return csvFiles[0], nil
+}
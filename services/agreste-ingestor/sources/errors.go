@@ -0,0 +1,66 @@
+package sources
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+var ErrPinMismatch = errors.New("no certificate matches the pinned public keys")
+
+// RetryableError is a failure that may not happen again, such as a 5xx response.
+type RetryableError struct {
+	Err error
+}
+
+func (e *RetryableError) Error() string { return e.Err.Error() }
+func (e *RetryableError) Unwrap() error { return e.Err }
+
+// PermanentError is a failure retrying cannot fix, such as a 404 response.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+func IsRetryable(err error) bool {
+	var retryable *RetryableError
+	return errors.As(err, &retryable)
+}
+
+func IsPermanent(err error) bool {
+	var permanent *PermanentError
+	return errors.As(err, &permanent)
+}
+
+func permanent(format string, args ...interface{}) error {
+	return &PermanentError{Err: fmt.Errorf(format, args...)}
+}
+
+func isRetryableStatus(status int) bool {
+	return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+}
+
+func classifyTransferError(err error) error {
+	var (
+		verification *tls.CertificateVerificationError
+		unknownCA    x509.UnknownAuthorityError
+		hostname     x509.HostnameError
+		invalid      x509.CertificateInvalidError
+		dns          *net.DNSError
+	)
+	switch {
+	case IsRetryable(err) || IsPermanent(err):
+		return err
+	case errors.Is(err, ErrSourceTooLarge), errors.Is(err, ErrPinMismatch),
+		errors.As(err, &verification), errors.As(err, &unknownCA), errors.As(err, &hostname), errors.As(err, &invalid):
+		return &PermanentError{Err: err}
+	case errors.As(err, &dns) && dns.IsNotFound:
+		return &PermanentError{Err: err}
+	}
+	return &RetryableError{Err: err}
+}
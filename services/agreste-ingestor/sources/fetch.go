@@ -0,0 +1,153 @@
+package sources
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errRangeIgnored = errors.New("the server did not resume the download")
+
+// FetchRequest asks for an object, conditionally on Cached and from Offset on.
+type FetchRequest struct {
+	Cached  Validators
+	Offset  int64
+	IfRange string
+}
+
+// FetchResponse holds the content of an object from Offset on.
+type FetchResponse struct {
+	Body       io.ReadCloser
+	Validators Validators
+	// Offset is where Body starts in the object.
+	Offset int64
+	// Size is the size of the whole object, -1 when unknown.
+	Size int64
+}
+
+// ProgressFunc is told the bytes received of a download and its total, -1 when unknown.
+type ProgressFunc func(received, total int64)
+
+// RetryPolicy bounds the attempts of a download and the backoff between them.
+type RetryPolicy struct {
+	Attempts       int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{Attempts: 5, InitialBackoff: time.Second, MaxBackoff: time.Minute}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.Attempts <= 0 {
+		p.Attempts = DefaultRetryPolicy.Attempts
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = DefaultRetryPolicy.InitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = DefaultRetryPolicy.MaxBackoff
+	}
+	return p
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	wait := p.InitialBackoff
+	for i := 1; i < attempt && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, p.MaxBackoff)
+}
+
+func (v Validators) ifRange() string {
+	if v.ETag != "" && !strings.HasPrefix(v.ETag, "W/") {
+		return v.ETag
+	}
+	return v.LastModified
+}
+
+func prepareRequest(req *http.Request, request FetchRequest) {
+	if request.Offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", request.Offset))
+		req.Header.Set("If-Range", request.IfRange)
+		return
+	}
+	if request.Cached.ETag != "" {
+		req.Header.Set("If-None-Match", request.Cached.ETag)
+	}
+	if request.Cached.LastModified != "" {
+		req.Header.Set("If-Modified-Since", request.Cached.LastModified)
+	}
+}
+
+func readResponse(resp *http.Response, request FetchRequest, what string) (FetchResponse, error) {
+	validators := Validators{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return FetchResponse{Body: resp.Body, Validators: validators, Size: resp.ContentLength}, nil
+	case http.StatusPartialContent:
+		start, size, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil || start != request.Offset {
+			resp.Body.Close()
+			return FetchResponse{}, &RetryableError{Err: fmt.Errorf("%w: unexpected range '%s' for %s", errRangeIgnored, resp.Header.Get("Content-Range"), what)}
+		}
+		return FetchResponse{Body: resp.Body, Validators: validators, Offset: start, Size: size}, nil
+	case http.StatusNotModified:
+		resp.Body.Close()
+		return FetchResponse{Validators: validators}, ErrNotModified
+	case http.StatusRequestedRangeNotSatisfiable:
+		resp.Body.Close()
+		return FetchResponse{}, &RetryableError{Err: fmt.Errorf("%w: range refused for %s", errRangeIgnored, what)}
+	}
+
+	defer resp.Body.Close()
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err := fmt.Errorf("failed to download %s, status: %s", what, resp.Status)
+	if trimmed := strings.TrimSpace(string(detail)); trimmed != "" {
+		err = fmt.Errorf("%w: %s", err, trimmed)
+	}
+	if isRetryableStatus(resp.StatusCode) {
+		return FetchResponse{}, &RetryableError{Err: err}
+	}
+	return FetchResponse{}, &PermanentError{Err: err}
+}
+
+func parseContentRange(value string) (start, size int64, err error) {
+	spec, found := strings.CutPrefix(value, "bytes ")
+	byteRange, rawSize, hasSize := strings.Cut(spec, "/")
+	rawStart, _, hasEnd := strings.Cut(byteRange, "-")
+	if !found || !hasSize || !hasEnd {
+		return 0, 0, fmt.Errorf("invalid Content-Range '%s'", value)
+	}
+	if start, err = strconv.ParseInt(rawStart, 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid Content-Range '%s': %w", value, err)
+	}
+	if rawSize == "*" {
+		return start, -1, nil
+	}
+	if size, err = strconv.ParseInt(rawSize, 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid Content-Range '%s': %w", value, err)
+	}
+	return start, size, nil
+}
+
+type progressReporter struct {
+	report   ProgressFunc
+	interval time.Duration
+	total    int64
+	last     time.Time
+}
+
+func (p *progressReporter) received(size int64) {
+	if p.report == nil {
+		return
+	}
+	if now := time.Now(); now.Sub(p.last) >= p.interval || size == p.total {
+		p.last = now
+		p.report(size, p.total)
+	}
+}
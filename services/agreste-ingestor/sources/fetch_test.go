@@ -0,0 +1,156 @@
+package sources
+
+import (
+	"agreste-ingestor/misc"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testRetryPolicy = RetryPolicy{Attempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond}
+
+func TestOpener_Fetch_ResumesInterruptedDownloads(t *testing.T) {
+	content := strings.Repeat(testCSV, 100)
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range")+" "+r.Header.Get("If-Range"))
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("Range") != "" {
+			http.ServeContent(w, r, "rica.csv", time.Time{}, strings.NewReader(content))
+			return
+		}
+		// The connection drops halfway through the first transfer.
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Write([]byte(content[:len(content)/2]))
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}))
+	defer server.Close()
+
+	var received, total int64
+	opener := NewOpener(Config{HTTPClient: server.Client(), CacheDir: t.TempDir(), Retry: testRetryPolicy})
+	artifact, err := opener.Fetch(server.URL+"/rica.csv", func(r, t int64) { received, total = r, t })
+	if err != nil {
+		t.Fatalf("Fetch returned an unexpected error: %v", err)
+	}
+
+	cached, _ := os.ReadFile(artifact.Path)
+	if string(cached) != content {
+		t.Errorf("resumed download differs from the source: %d of %d bytes", len(cached), len(content))
+	}
+	expectedRanges := []string{" ", "bytes=" + strconv.Itoa(len(content)/2) + `- "v1"`}
+	if strings.Join(ranges, "|") != strings.Join(expectedRanges, "|") {
+		t.Errorf("expected requests %q, got %q", expectedRanges, ranges)
+	}
+	if received != int64(len(content)) || total != int64(len(content)) {
+		t.Errorf("expected the final progress to be %d/%d, got %d/%d", len(content), len(content), received, total)
+	}
+}
+
+func TestOpener_Fetch_RestartsWhenRangeIsIgnored(t *testing.T) {
+	content := strings.Repeat(testCSV, 100)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		if requests == 1 {
+			w.Write([]byte(content[:100]))
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	opener := NewOpener(Config{HTTPClient: server.Client(), CacheDir: t.TempDir(), Retry: testRetryPolicy})
+	artifact, err := opener.Fetch(server.URL+"/rica.csv", nil)
+	if err != nil {
+		t.Fatalf("Fetch returned an unexpected error: %v", err)
+	}
+	if cached, _ := os.ReadFile(artifact.Path); string(cached) != content {
+		t.Errorf("expected the download to start over, got %d of %d bytes", len(cached), len(content))
+	}
+}
+
+func TestOpener_Fetch_RetryableAndPermanentErrors(t *testing.T) {
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		switch {
+		case r.URL.Path == "/flaky.csv" && requests[r.URL.Path] < 3:
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+		case r.URL.Path == "/flaky.csv":
+			w.Write([]byte(testCSV))
+		case r.URL.Path == "/down.csv":
+			http.Error(w, "down", http.StatusBadGateway)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	opener := NewOpener(Config{HTTPClient: server.Client(), CacheDir: t.TempDir(), Retry: testRetryPolicy})
+
+	if _, err := opener.Fetch(server.URL+"/flaky.csv", nil); err != nil || requests["/flaky.csv"] != 3 {
+		t.Errorf("expected the download to succeed on the third attempt, got %v after %d requests", err, requests["/flaky.csv"])
+	}
+
+	_, err := opener.Fetch(server.URL+"/down.csv", nil)
+	if !IsRetryable(err) || requests["/down.csv"] != 3 || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Errorf("expected a retryable error after 3 attempts, got %v after %d requests", err, requests["/down.csv"])
+	}
+
+	_, err = opener.Fetch(server.URL+"/missing.csv", nil)
+	if !IsPermanent(err) || requests["/missing.csv"] != 1 {
+		t.Errorf("expected a permanent error without retry, got %v after %d requests", err, requests["/missing.csv"])
+	}
+}
+
+func TestOpener_OpenArtifact_MissingMemberIsPermanent(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "rica.zip")
+	if err := os.WriteFile(path, zipOf(t, map[string]string{"rica.csv": testCSV}), 0o644); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+
+	opener := NewOpener(Config{FileRoot: root})
+	artifact, err := opener.Fetch("file://"+path, nil)
+	if err != nil {
+		t.Fatalf("Fetch returned an unexpected error: %v", err)
+	}
+	if _, err := opener.OpenArtifact(artifact, "other.csv", misc.CSVDialect{}); !IsPermanent(err) {
+		t.Errorf("expected a permanent error, got %v", err)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}.withDefaults()
+	for attempt, expected := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		if wait := policy.backoff(attempt); wait != expected {
+			t.Errorf("attempt %d: expected %s, got %s", attempt, expected, wait)
+		}
+	}
+	if policy.Attempts != DefaultRetryPolicy.Attempts {
+		t.Errorf("expected the default number of attempts, got %d", policy.Attempts)
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	for value, expected := range map[string][2]int64{"bytes 100-199/200": {100, 200}, "bytes 0-9/*": {0, -1}} {
+		start, size, err := parseContentRange(value)
+		if err != nil || start != expected[0] || size != expected[1] {
+			t.Errorf("%s: expected %v, got %d, %d, %v", value, expected, start, size, err)
+		}
+	}
+	for _, invalid := range []string{"", "bytes */200", "items 0-9/10", "bytes 0-9"} {
+		if _, _, err := parseContentRange(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
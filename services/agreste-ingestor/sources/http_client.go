@@ -0,0 +1,170 @@
+package sources
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultHTTPTimeout   = 10 * time.Minute
+	DefaultMaxSourceSize = 2 << 30
+)
+
+var ErrSourceTooLarge = errors.New("source exceeds the maximum download size")
+
+// HTTPConfig configures the client of http://, https:// and s3:// sources.
+type HTTPConfig struct {
+	// RootCAs are the certificate authorities servers are verified against, the system ones when nil.
+	RootCAs *x509.CertPool
+	// Pins maps a host to the public keys its chain must hold one of.
+	Pins map[string][]string
+	// Proxy defaults to http.ProxyFromEnvironment.
+	Proxy func(*http.Request) (*url.URL, error)
+	// Timeout bounds a whole download, DefaultHTTPTimeout by default.
+	Timeout time.Duration
+	// MaxSize bounds the size of a download in bytes, DefaultMaxSourceSize by default.
+	MaxSize int64
+}
+
+func NewHTTPClient(config HTTPConfig) *http.Client {
+	if config.Proxy == nil {
+		config.Proxy = http.ProxyFromEnvironment
+	}
+	if config.Timeout == 0 {
+		config.Timeout = DefaultHTTPTimeout
+	}
+	if config.MaxSize == 0 {
+		config.MaxSize = DefaultMaxSourceSize
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = config.Proxy
+	transport.TLSClientConfig = &tls.Config{
+		RootCAs:    config.RootCAs,
+		MinVersion: tls.VersionTLS12,
+	}
+	if len(config.Pins) > 0 {
+		transport.TLSClientConfig.VerifyConnection = verifyPins(config.Pins)
+	}
+
+	return &http.Client{
+		Timeout:   config.Timeout,
+		Transport: &limitedTransport{base: transport, maxSize: config.MaxSize},
+	}
+}
+
+// LoadCABundle returns the system certificate authorities plus the PEM certificates at path.
+func LoadCABundle(path string) (*x509.CertPool, error) {
+	bundle, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle %s: %w", path, err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("CA bundle %s holds no PEM certificate", path)
+	}
+	return pool, nil
+}
+
+// ParsePins reads comma-separated host=sha256//<base64> pins.
+func ParsePins(value string) (map[string][]string, error) {
+	pins := make(map[string][]string)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host, pin, found := strings.Cut(entry, "=")
+		pin, isSHA256 := strings.CutPrefix(strings.TrimSpace(pin), "sha256//")
+		if !found || !isSHA256 || strings.TrimSpace(host) == "" {
+			return nil, fmt.Errorf("invalid pin '%s', expected host=sha256//<base64>", entry)
+		}
+		if decoded, err := base64.StdEncoding.DecodeString(pin); err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("invalid pin '%s': not a base64 SHA-256", entry)
+		}
+		host = strings.ToLower(strings.TrimSpace(host))
+		pins[host] = append(pins[host], pin)
+	}
+	return pins, nil
+}
+
+// PublicKeyPin returns the base64 SHA-256 of the public key of a certificate.
+func PublicKeyPin(certificate *x509.Certificate) string {
+	sum := sha256.Sum256(certificate.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func verifyPins(pins map[string][]string) func(tls.ConnectionState) error {
+	return func(state tls.ConnectionState) error {
+		hostPins, pinned := pins[strings.ToLower(state.ServerName)]
+		if !pinned {
+			return nil
+		}
+		for _, chain := range state.VerifiedChains {
+			for _, certificate := range chain {
+				pin := PublicKeyPin(certificate)
+				for _, hostPin := range hostPins {
+					if pin == hostPin {
+						return nil
+					}
+				}
+			}
+		}
+		return fmt.Errorf("%w of %s", ErrPinMismatch, state.ServerName)
+	}
+}
+
+type limitedTransport struct {
+	base    http.RoundTripper
+	maxSize int64
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ContentLength > t.maxSize {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s is %d bytes, the limit is %d", ErrSourceTooLarge, req.URL.Redacted(), resp.ContentLength, t.maxSize)
+	}
+	if _, size, err := parseContentRange(resp.Header.Get("Content-Range")); err == nil && size > t.maxSize {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s is %d bytes, the limit is %d", ErrSourceTooLarge, req.URL.Redacted(), size, t.maxSize)
+	}
+	resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: t.maxSize}
+	return resp, nil
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, ErrSourceTooLarge
+	}
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), ErrSourceTooLarge
+	}
+	return n, err
+}
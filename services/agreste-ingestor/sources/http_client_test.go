@@ -0,0 +1,195 @@
+package sources
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTLSServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// serverCABundle writes the certificate of the test server to a PEM file.
+func serverCABundle(t *testing.T, server *httptest.Server) string {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	content := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatalf("failed to write CA bundle: %v", err)
+	}
+	return path
+}
+
+// dialServerAs sends every connection of the client to the test server.
+func dialServerAs(client *http.Client, server *httptest.Server) {
+	transport := client.Transport.(*limitedTransport).base.(*http.Transport)
+	address := server.Listener.Addr().String()
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, address)
+	}
+}
+
+func get(client *http.Client, rawURL string) (string, error) {
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestNewHTTPClient_VerifiesCertificates(t *testing.T) {
+	server := newTLSServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testCSV))
+	})
+
+	if _, err := get(NewHTTPClient(HTTPConfig{}), server.URL); err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Errorf("expected an unknown authority to be refused, got %v", err)
+	}
+
+	roots, err := LoadCABundle(serverCABundle(t, server))
+	if err != nil {
+		t.Fatalf("LoadCABundle returned an unexpected error: %v", err)
+	}
+	body, err := get(NewHTTPClient(HTTPConfig{RootCAs: roots}), server.URL)
+	if err != nil || body != testCSV {
+		t.Errorf("expected the CA bundle to be trusted, got %q, %v", body, err)
+	}
+
+	if _, err := LoadCABundle(filepath.Join(t.TempDir(), "missing.pem")); err == nil {
+		t.Errorf("expected an error for a missing CA bundle")
+	}
+	notPEM := filepath.Join(t.TempDir(), "bundle.pem")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0o644)
+	if _, err := LoadCABundle(notPEM); err == nil {
+		t.Errorf("expected an error for a bundle without certificates")
+	}
+}
+
+func TestNewHTTPClient_Pins(t *testing.T) {
+	server := newTLSServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testCSV))
+	})
+	roots, err := LoadCABundle(serverCABundle(t, server))
+	if err != nil {
+		t.Fatalf("LoadCABundle returned an unexpected error: %v", err)
+	}
+	serverPin := PublicKeyPin(server.Certificate())
+	otherKey := sha256.Sum256([]byte("another public key"))
+	otherPin := base64.StdEncoding.EncodeToString(otherKey[:])
+
+	for name, test := range map[string]struct {
+		pins      map[string][]string
+		expectErr bool
+	}{
+		"matching pin":      {pins: map[string][]string{"example.com": {otherPin, serverPin}}},
+		"other pin":         {pins: map[string][]string{"example.com": {otherPin}}, expectErr: true},
+		"other host pinned": {pins: map[string][]string{"agreste.agriculture.gouv.fr": {otherPin}}},
+	} {
+		client := NewHTTPClient(HTTPConfig{RootCAs: roots, Pins: test.pins})
+		dialServerAs(client, server)
+
+		_, err := get(client, "https://example.com/rica.csv")
+		if test.expectErr && (err == nil || !strings.Contains(err.Error(), "pinned")) {
+			t.Errorf("%s: expected a pinning error, got %v", name, err)
+		}
+		if !test.expectErr && err != nil {
+			t.Errorf("%s: unexpected error %v", name, err)
+		}
+	}
+}
+
+func TestParsePins(t *testing.T) {
+	pin := "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+	pins, err := ParsePins(" Agreste.Agriculture.gouv.fr=sha256//" + pin + ",agreste.agriculture.gouv.fr=sha256//" + pin + ",")
+	if err != nil {
+		t.Fatalf("ParsePins returned an unexpected error: %v", err)
+	}
+	if got := pins["agreste.agriculture.gouv.fr"]; len(got) != 2 || got[0] != pin {
+		t.Errorf("unexpected pins %v", pins)
+	}
+
+	for _, invalid := range []string{"agreste.agriculture.gouv.fr", "agreste.agriculture.gouv.fr=" + pin, "=sha256//" + pin, "host=sha256//bm90IGEgaGFzaA=="} {
+		if _, err := ParsePins(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestNewHTTPClient_Proxy(t *testing.T) {
+	var proxiedURL string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedURL = r.URL.String()
+		w.Write([]byte(testCSV))
+	}))
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	client := NewHTTPClient(HTTPConfig{Proxy: http.ProxyURL(proxyURL)})
+	if body, err := get(client, "http://agreste.example.org/rica.csv"); err != nil || body != testCSV {
+		t.Fatalf("expected the download to go through the proxy, got %q, %v", body, err)
+	}
+	if proxiedURL != "http://agreste.example.org/rica.csv" {
+		t.Errorf("expected the proxy to receive the absolute URL, got %s", proxiedURL)
+	}
+}
+
+func TestNewHTTPClient_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := newTLSServer(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-time.After(5 * time.Second):
+		}
+	})
+	defer close(release)
+
+	roots, _ := LoadCABundle(serverCABundle(t, server))
+	_, err := get(NewHTTPClient(HTTPConfig{RootCAs: roots, Timeout: 50 * time.Millisecond}), server.URL)
+	if err == nil || !strings.Contains(err.Error(), "Timeout") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+}
+
+func TestNewHTTPClient_MaxSize(t *testing.T) {
+	payload := strings.Repeat("x", 1000)
+	server := newTLSServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunked" {
+			w.Write([]byte(payload[:500]))
+			w.(http.Flusher).Flush()
+			w.Write([]byte(payload[500:]))
+			return
+		}
+		w.Write([]byte(payload))
+	})
+	roots, _ := LoadCABundle(serverCABundle(t, server))
+
+	small := NewHTTPClient(HTTPConfig{RootCAs: roots, MaxSize: 100})
+	for _, path := range []string{"/", "/chunked"} {
+		if _, err := get(small, server.URL+path); !errors.Is(err, ErrSourceTooLarge) {
+			t.Errorf("%s: expected ErrSourceTooLarge, got %v", path, err)
+		}
+	}
+
+	exact := NewHTTPClient(HTTPConfig{RootCAs: roots, MaxSize: 1000})
+	for _, path := range []string{"/", "/chunked"} {
+		if body, err := get(exact, server.URL+path); err != nil || body != payload {
+			t.Errorf("%s: expected a body of exactly the limit to be read, got %d bytes, %v", path, len(body), err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package sources
+
+import (
+	"archive/zip"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// Member is a file of a ZIP archive.
+type Member struct {
+	Name     string    `json:"name"`
+	Size     uint64    `json:"size"`
+	Modified time.Time `json:"modified"`
+	CSV      bool      `json:"csv"`
+}
+
+func isCSVName(name string) bool {
+	return strings.EqualFold(path.Ext(name), ".csv")
+}
+
+// Members lists the files of a fetched ZIP archive.
+func (o *Opener) Members(artifact Artifact) (Container, []Member, error) {
+	container, err := detectFileContainer(artifact.Path)
+	if err != nil {
+		return "", nil, err
+	}
+	if container != ContainerZip {
+		return container, nil, nil
+	}
+
+	zipReader, err := zip.OpenReader(artifact.Path)
+	if err != nil {
+		return "", nil, &PermanentError{Err: fmt.Errorf("failed to open ZIP archive: %w", err)}
+	}
+	defer zipReader.Close()
+
+	members := make([]Member, 0, len(zipReader.File))
+	for _, file := range zipReader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		members = append(members, Member{
+			Name:     file.Name,
+			Size:     file.UncompressedSize64,
+			Modified: file.Modified,
+			CSV:      isCSVName(file.Name),
+		})
+	}
+	return container, members, nil
+}
+
+// MatchMembers resolves the members to ingest from names and path.Match patterns.
+func (o *Opener) MatchMembers(artifact Artifact, patterns []string) ([]string, error) {
+	container, members, err := o.Members(artifact)
+	if err != nil {
+		return nil, err
+	}
+	if container != ContainerZip {
+		return []string{""}, nil
+	}
+
+	if len(patterns) == 0 {
+		var csvNames []string
+		for _, member := range members {
+			if member.CSV {
+				csvNames = append(csvNames, member.Name)
+			}
+		}
+		if len(csvNames) != 1 {
+			return nil, permanent("the ZIP archive holds %d CSV files, name the ones to ingest", len(csvNames))
+		}
+		return csvNames, nil
+	}
+
+	selected := make([]bool, len(members))
+	for _, pattern := range patterns {
+		isGlob := strings.ContainsAny(pattern, "*?[")
+		lowerPattern := strings.ToLower(pattern)
+		if _, err := path.Match(lowerPattern, ""); err != nil {
+			return nil, permanent("invalid CSV file pattern '%s': %w", pattern, err)
+		}
+
+		matched := false
+		for i, member := range members {
+			if isGlob && !member.CSV {
+				continue
+			}
+			name := member.Name
+			if !strings.Contains(pattern, "/") {
+				name = path.Base(name)
+			}
+			if isMatch, _ := path.Match(lowerPattern, strings.ToLower(name)); isMatch {
+				selected[i] = true
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, permanent("no file of the ZIP archive matches '%s'", pattern)
+		}
+	}
+
+	var names []string
+	for i, member := range members {
+		if selected[i] {
+			names = append(names, member.Name)
+		}
+	}
+	return names, nil
+}
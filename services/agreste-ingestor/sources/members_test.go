@@ -0,0 +1,81 @@
+package sources
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOpener_MatchMembers(t *testing.T) {
+	root := t.TempDir()
+	archivePath := filepath.Join(root, "rica.zip")
+	archive := zipOf(t, map[string]string{
+		"2022/Rica_France_2022.csv": testCSV,
+		"2023/Rica_France_2023.csv": testCSV,
+		"2023/Rica_Corse_2023.CSV":  testCSV,
+		"2023/readme.txt":           "RICA",
+	})
+	if err := os.WriteFile(archivePath, archive, 0o644); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+
+	opener := NewOpener(Config{FileRoot: root})
+	artifact, err := opener.Fetch("file://"+archivePath, nil)
+	if err != nil {
+		t.Fatalf("Fetch returned an unexpected error: %v", err)
+	}
+
+	container, members, err := opener.Members(artifact)
+	if err != nil || container != ContainerZip || len(members) != 4 {
+		t.Fatalf("expected 4 ZIP members, got %s %v %v", container, members, err)
+	}
+	for _, member := range members {
+		if member.CSV != strings.HasSuffix(strings.ToLower(member.Name), ".csv") || member.Size == 0 {
+			t.Errorf("unexpected member %+v", member)
+		}
+	}
+
+	for name, test := range map[string]struct {
+		patterns []string
+		expected string
+	}{
+		"all CSV":       {patterns: []string{"*"}, expected: "2022/Rica_France_2022.csv|2023/Rica_Corse_2023.CSV|2023/Rica_France_2023.csv"},
+		"glob":          {patterns: []string{"rica_france_*.csv"}, expected: "2022/Rica_France_2022.csv|2023/Rica_France_2023.csv"},
+		"path glob":     {patterns: []string{"2023/*"}, expected: "2023/Rica_Corse_2023.CSV|2023/Rica_France_2023.csv"},
+		"list":          {patterns: []string{"Rica_Corse_2023.csv", "2022/*", "rica_corse_2023.csv"}, expected: "2022/Rica_France_2022.csv|2023/Rica_Corse_2023.CSV"},
+		"non-CSV named": {patterns: []string{"readme.txt"}, expected: "2023/readme.txt"},
+	} {
+		// zipOf writes members in map order, so only the selection is checked.
+		names, err := opener.MatchMembers(artifact, test.patterns)
+		if err != nil || !sameSet(names, strings.Split(test.expected, "|")) {
+			t.Errorf("%s: expected %s, got %v, %v", name, test.expected, names, err)
+		}
+	}
+
+	for _, patterns := range [][]string{nil, {"2021/*"}, {"[a-"}} {
+		if _, err := opener.MatchMembers(artifact, patterns); !IsPermanent(err) {
+			t.Errorf("%v: expected a permanent error, got %v", patterns, err)
+		}
+	}
+
+	if rows := readAllRows(t, opener, "file://"+archivePath, "2023/Rica_France_2023.csv"); len(rows) != 2 {
+		t.Errorf("expected a member to be opened by its whole name, got %v", rows)
+	}
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]bool, len(a))
+	for _, value := range a {
+		seen[value] = true
+	}
+	for _, value := range b {
+		if !seen[value] {
+			return false
+		}
+	}
+	return true
+}
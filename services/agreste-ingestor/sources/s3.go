@@ -0,0 +1,164 @@
+package sources
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+type S3Config struct {
+	// Endpoint is the base URL of the S3-compatible service, AWS S3 in Region by default.
+	Endpoint        string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// S3Fetcher reads s3://bucket/key URIs with path-style requests.
+type S3Fetcher struct {
+	config S3Config
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewS3Fetcher(config S3Config, client *http.Client) *S3Fetcher {
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", config.Region)
+	}
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+	return &S3Fetcher{config: config, client: client, now: time.Now}
+}
+
+func (f *S3Fetcher) Fetch(uri *url.URL, request FetchRequest) (FetchResponse, error) {
+	bucket := uri.Host
+	key := strings.TrimPrefix(uri.Path, "/")
+	if bucket == "" || key == "" {
+		return FetchResponse{}, &PermanentError{Err: fmt.Errorf("S3 URI '%s' must name a bucket and a key", uri)}
+	}
+
+	objectURL := f.config.Endpoint + "/" + uriEncode(bucket, false) + "/" + uriEncode(key, true)
+	req, err := http.NewRequest(http.MethodGet, objectURL, nil)
+	if err != nil {
+		return FetchResponse{}, &PermanentError{Err: fmt.Errorf("failed to build S3 request for '%s': %w", uri, err)}
+	}
+	prepareRequest(req, request)
+	if f.config.AccessKeyID != "" {
+		SignV4(req, f.config, "s3", f.now())
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return FetchResponse{}, classifyTransferError(fmt.Errorf("failed to download S3 object '%s': %w", uri, err))
+	}
+	return readResponse(resp, request, fmt.Sprintf("S3 object '%s'", uri))
+}
+
+// SignV4 adds the AWS Signature Version 4 headers to a request without body.
+func SignV4(req *http.Request, config S3Config, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", emptyPayloadHash)
+	if config.SessionToken != "" {
+		req.Header.Set("x-amz-security-token", config.SessionToken)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		lowerName := strings.ToLower(name)
+		if lowerName == "range" || strings.HasPrefix(lowerName, "x-amz-") {
+			headers[lowerName] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+
+	scope := date + "/" + config.Region + "/" + service + "/aws4_request"
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalRequestHash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+config.SecretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, config.Region)
+	signingKey = hmacSHA256(signingKey, service)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, uriEncode(key, false)+"="+uriEncode(value, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func uriEncode(value string, keepSlash bool) string {
+	var encoded strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			encoded.WriteByte(c)
+		case c == '/' && keepSlash:
+			encoded.WriteByte(c)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", c)
+		}
+	}
+	return encoded.String()
+}
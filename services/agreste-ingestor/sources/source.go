@@ -0,0 +1,314 @@
+package sources
+
+import (
+	"agreste-ingestor/misc"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrNotModified is returned when the object still matches the cached copy.
+var ErrNotModified = errors.New("source not modified")
+
+// Fetcher downloads the object a source URI points at.
+type Fetcher interface {
+	Fetch(uri *url.URL, request FetchRequest) (FetchResponse, error)
+}
+
+type localFetcher interface {
+	LocalPath(uri *url.URL) (string, error)
+}
+
+type Config struct {
+	// FileRoot is the directory file:// URIs must point into; empty refuses them.
+	FileRoot string
+	// CacheDir keeps downloaded sources between runs, under the temporary directory by default.
+	CacheDir   string
+	S3         S3Config
+	HTTP       HTTPConfig
+	HTTPClient *http.Client
+	// Retry bounds the attempts of remote downloads, DefaultRetryPolicy by default.
+	Retry RetryPolicy
+}
+
+// Artifact is a source file available on disk, ready to be opened.
+type Artifact struct {
+	URI    string `json:"uri"`
+	Path   string `json:"-"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+	// Cached is set when the cached copy of a remote source was still current.
+	Cached    bool      `json:"cached"`
+	FetchedAt time.Time `json:"fetchedAt"`
+}
+
+// Opener reads surveys from file://, s3://, http:// and https:// URIs.
+type Opener struct {
+	fetchers         map[string]Fetcher
+	cache            *Cache
+	retry            RetryPolicy
+	progressInterval time.Duration
+}
+
+func NewOpener(config Config) *Opener {
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = NewHTTPClient(config.HTTP)
+	}
+
+	cacheDir := config.CacheDir
+	if cacheDir == "" {
+		cacheDir = filepath.Join(os.TempDir(), "agreste-ingestor-sources")
+	}
+
+	httpFetcher := &HTTPFetcher{Client: httpClient}
+	return &Opener{
+		cache:            NewCache(cacheDir),
+		retry:            config.Retry.withDefaults(),
+		progressInterval: time.Second,
+		fetchers: map[string]Fetcher{
+			"file":  &FileFetcher{Root: config.FileRoot},
+			"s3":    NewS3Fetcher(config.S3, httpClient),
+			"http":  httpFetcher,
+			"https": httpFetcher,
+		},
+	}
+}
+
+func (o *Opener) fetcher(uri string) (*url.URL, Fetcher, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid source URI '%s': %w", uri, err)
+	}
+	fetcher, supported := o.fetchers[strings.ToLower(parsed.Scheme)]
+	if !supported {
+		return nil, nil, fmt.Errorf("unsupported source URI scheme '%s', expected file, s3, http or https", parsed.Scheme)
+	}
+	return parsed, fetcher, nil
+}
+
+// Validate reports the URIs Open would refuse, without fetching anything.
+func (o *Opener) Validate(uri string) error {
+	parsed, fetcher, err := o.fetcher(uri)
+	if err != nil {
+		return err
+	}
+	if local, isLocal := fetcher.(localFetcher); isLocal {
+		_, err = local.LocalPath(parsed)
+	}
+	return err
+}
+
+// Fetch makes the object at uri available on disk, downloading it only when it changed.
+func (o *Opener) Fetch(uri string, progress ProgressFunc) (Artifact, error) {
+	parsed, fetcher, err := o.fetcher(uri)
+	if err != nil {
+		return Artifact{}, &PermanentError{Err: err}
+	}
+
+	if local, isLocal := fetcher.(localFetcher); isLocal {
+		path, err := local.LocalPath(parsed)
+		if err != nil {
+			return Artifact{}, &PermanentError{Err: err}
+		}
+		sum, size, err := hashFile(path)
+		if err != nil {
+			return Artifact{}, err
+		}
+		if progress != nil {
+			progress(size, size)
+		}
+		return Artifact{URI: uri, Path: path, SHA256: sum, Size: size, FetchedAt: time.Now()}, nil
+	}
+
+	cached, isCached, err := o.cache.Lookup(uri)
+	if err != nil {
+		return Artifact{}, err
+	}
+	var cachedValidators Validators
+	if isCached {
+		cachedValidators = cached.Validators
+	}
+
+	download, err := o.cache.NewDownload()
+	if err != nil {
+		return Artifact{}, err
+	}
+	defer download.Discard()
+
+	reporter := &progressReporter{report: progress, interval: o.progressInterval, total: -1}
+	var validators Validators
+	for attempt := 1; ; attempt++ {
+		request := FetchRequest{Cached: cachedValidators}
+		if download.Size() > 0 {
+			request = FetchRequest{Offset: download.Size(), IfRange: validators.ifRange()}
+		}
+
+		err = o.transfer(fetcher, parsed, request, download, reporter, &validators)
+		if err == nil {
+			break
+		}
+		if errors.Is(err, ErrNotModified) && isCached {
+			entry, err := o.cache.Touch(cached, validators)
+			if err != nil {
+				return Artifact{}, err
+			}
+			return o.artifact(entry, true), nil
+		}
+		if !IsRetryable(err) {
+			return Artifact{}, err
+		}
+		if attempt >= o.retry.Attempts {
+			return Artifact{}, fmt.Errorf("giving up on %s after %d attempts: %w", uri, attempt, err)
+		}
+
+		wait := o.retry.backoff(attempt)
+		fmt.Printf("Download of %s failed (attempt %d of %d), retrying in %s from byte %d: %v\n", uri, attempt, o.retry.Attempts, wait, download.Size(), err)
+		time.Sleep(wait)
+	}
+
+	entry, err := o.cache.Commit(uri, download, validators)
+	if err != nil {
+		return Artifact{}, err
+	}
+	return o.artifact(entry, isCached && entry.SHA256 == cached.SHA256), nil
+}
+
+func (o *Opener) transfer(fetcher Fetcher, uri *url.URL, request FetchRequest, download *Download, reporter *progressReporter, validators *Validators) error {
+	if request.Offset > 0 && request.IfRange == "" {
+		if err := download.Reset(); err != nil {
+			return err
+		}
+		request = FetchRequest{}
+	}
+
+	response, err := fetcher.Fetch(uri, request)
+	if errors.Is(err, errRangeIgnored) {
+		if resetErr := download.Reset(); resetErr != nil {
+			return resetErr
+		}
+	}
+	if errors.Is(err, ErrNotModified) {
+		*validators = response.Validators
+	}
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.Offset != download.Size() {
+		if err := download.Reset(); err != nil {
+			return err
+		}
+	}
+	if request.Offset == 0 || !response.Validators.IsZero() {
+		*validators = response.Validators
+	}
+	if response.Size >= 0 {
+		reporter.total = response.Size
+	}
+
+	if err := download.Append(response.Body, reporter.received); err != nil {
+		return classifyTransferError(fmt.Errorf("download of %s interrupted after %d bytes: %w", uri.Redacted(), download.Size(), err))
+	}
+	if reporter.total >= 0 && download.Size() != reporter.total {
+		return &RetryableError{Err: fmt.Errorf("download of %s ended after %d of %d bytes", uri.Redacted(), download.Size(), reporter.total)}
+	}
+	return nil
+}
+
+func (o *Opener) artifact(entry CacheEntry, cached bool) Artifact {
+	return Artifact{
+		URI:       entry.URI,
+		Path:      o.cache.ObjectPath(entry),
+		SHA256:    entry.SHA256,
+		Size:      entry.Size,
+		Cached:    cached,
+		FetchedAt: entry.FetchedAt,
+	}
+}
+
+// OpenArtifact returns a stream over the CSV rows of a fetched CSV, gzip or ZIP file.
+func (o *Opener) OpenArtifact(artifact Artifact, member string, dialect misc.CSVDialect) (*misc.CSVStream, error) {
+	return openCSVFile(artifact.Path, member, dialect)
+}
+
+// Open fetches the object at uri and returns a stream over its CSV rows.
+func (o *Opener) Open(uri string, member string) (*misc.CSVStream, error) {
+	artifact, err := o.Fetch(uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	return o.OpenArtifact(artifact, member, misc.CSVDialect{})
+}
+
+type HTTPFetcher struct {
+	Client *http.Client
+}
+
+func (f *HTTPFetcher) Fetch(uri *url.URL, request FetchRequest) (FetchResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, uri.String(), nil)
+	if err != nil {
+		return FetchResponse{}, &PermanentError{Err: fmt.Errorf("failed to build request for '%s': %w", uri, err)}
+	}
+	prepareRequest(req, request)
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return FetchResponse{}, classifyTransferError(fmt.Errorf("failed to download source: %w", err))
+	}
+	return readResponse(resp, request, fmt.Sprintf("source '%s'", uri.Redacted()))
+}
+
+// FileFetcher reads files under Root.
+type FileFetcher struct {
+	Root string
+}
+
+func (f *FileFetcher) LocalPath(uri *url.URL) (string, error) {
+	if f.Root == "" {
+		return "", fmt.Errorf("file sources are disabled, set INGEST_FILE_ROOT to allow them")
+	}
+	if uri.Host != "" && uri.Host != "localhost" {
+		return "", fmt.Errorf("file URI '%s' must not name a remote host", uri)
+	}
+
+	root, err := filepath.EvalSymlinks(f.Root)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve file source root %s: %w", f.Root, err)
+	}
+	path, err := filepath.EvalSymlinks(filepath.Clean(uri.Path))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve file source %s: %w", uri.Path, err)
+	}
+	if relative, err := filepath.Rel(root, path); err != nil || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file source %s is outside %s", uri.Path, f.Root)
+	}
+	return path, nil
+}
+
+func (f *FileFetcher) Fetch(uri *url.URL, request FetchRequest) (FetchResponse, error) {
+	path, err := f.LocalPath(uri)
+	if err != nil {
+		return FetchResponse{}, &PermanentError{Err: err}
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return FetchResponse{}, &PermanentError{Err: err}
+	}
+	if _, err := file.Seek(request.Offset, io.SeekStart); err != nil {
+		file.Close()
+		return FetchResponse{}, &PermanentError{Err: err}
+	}
+	size := int64(-1)
+	if info, err := file.Stat(); err == nil {
+		size = info.Size()
+	}
+	return FetchResponse{Body: file, Offset: request.Offset, Size: size}, nil
+}
@@ -0,0 +1,247 @@
+package sources
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testCSV = "IDNUM;MILEX;OTEFDD\n101;2023;1500\n102;2023;4500\n"
+
+func zipOf(t *testing.T, members map[string]string) []byte {
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for name, content := range members {
+		member, err := writer.Create(name)
+		if err != nil {
+			t.Fatalf("failed to add %s to ZIP: %v", name, err)
+		}
+		member.Write([]byte(content))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close ZIP: %v", err)
+	}
+	return buffer.Bytes()
+}
+
+func gzipOf(t *testing.T, content string) []byte {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	writer.Write([]byte(content))
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close gzip: %v", err)
+	}
+	return buffer.Bytes()
+}
+
+func readAllRows(t *testing.T, opener *Opener, uri, member string) []string {
+	stream, err := opener.Open(uri, member)
+	if err != nil {
+		t.Fatalf("Open(%s, %q) returned an unexpected error: %v", uri, member, err)
+	}
+	defer stream.Close()
+
+	var rows []string
+	for {
+		record, err := stream.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", uri, err)
+		}
+		rows = append(rows, strings.Join(record.Values, ";"))
+	}
+	return rows
+}
+
+func TestOpener_Open_DetectsContainers(t *testing.T) {
+	root := t.TempDir()
+	files := map[string][]byte{
+		"rica.csv":      []byte(testCSV),
+		"rica.csv.gz":   gzipOf(t, testCSV),
+		"rica.zip":      zipOf(t, map[string]string{"data/Rica_2023.csv": testCSV, "readme.txt": "RICA"}),
+		"rica-misnamed": gzipOf(t, testCSV),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), content, 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	opener := NewOpener(Config{FileRoot: root})
+	for name, member := range map[string]string{"rica.csv": "", "rica.csv.gz": "", "rica.zip": "", "rica-misnamed": ""} {
+		rows := readAllRows(t, opener, "file://"+filepath.Join(root, name), member)
+		if strings.Join(rows, "|") != "101;2023;1500|102;2023;4500" {
+			t.Errorf("%s: unexpected rows %v", name, rows)
+		}
+	}
+
+	if rows := readAllRows(t, opener, "file://"+filepath.Join(root, "rica.zip"), "rica_2023.CSV"); len(rows) != 2 {
+		t.Errorf("expected the named ZIP member to be read, got %v", rows)
+	}
+	if _, err := opener.Open("file://"+filepath.Join(root, "rica.zip"), "other.csv"); err == nil {
+		t.Errorf("expected an error for a missing ZIP member")
+	}
+}
+
+func TestOpener_Open_ZipWithSeveralCSVNeedsMember(t *testing.T) {
+	root := t.TempDir()
+	archive := zipOf(t, map[string]string{"a.csv": testCSV, "b.csv": testCSV})
+	if err := os.WriteFile(filepath.Join(root, "rica.zip"), archive, 0o644); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+
+	_, err := NewOpener(Config{FileRoot: root}).Open("file://"+filepath.Join(root, "rica.zip"), "")
+	if err == nil || !strings.Contains(err.Error(), "2 CSV files") {
+		t.Errorf("expected an error asking for the member, got %v", err)
+	}
+}
+
+func TestOpener_Open_FileRoot(t *testing.T) {
+	root := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "secret.csv")
+	if err := os.WriteFile(outside, []byte(testCSV), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	if _, err := NewOpener(Config{FileRoot: root}).Open("file://"+outside, ""); err == nil || !strings.Contains(err.Error(), "outside") {
+		t.Errorf("expected a file outside the root to be refused, got %v", err)
+	}
+	if _, err := NewOpener(Config{}).Open("file://"+outside, ""); err == nil || !strings.Contains(err.Error(), "disabled") {
+		t.Errorf("expected file sources to be disabled without a root, got %v", err)
+	}
+}
+
+func TestOpener_Open_HTTP(t *testing.T) {
+	archive := zipOf(t, map[string]string{"Rica_France_micro_Donnees_ex2023.csv": testCSV})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rica.zip" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(archive)
+	}))
+	defer server.Close()
+
+	opener := NewOpener(Config{HTTPClient: server.Client(), CacheDir: t.TempDir()})
+	if rows := readAllRows(t, opener, server.URL+"/rica.zip", "Rica_France_micro_Donnees_ex2023.csv"); len(rows) != 2 {
+		t.Errorf("expected 2 rows, got %v", rows)
+	}
+	if _, err := opener.Open(server.URL+"/missing.zip", ""); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected a 404 error, got %v", err)
+	}
+	if _, err := opener.Open("ftp://example.org/rica.zip", ""); err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Errorf("expected an unsupported scheme error, got %v", err)
+	}
+}
+
+func TestOpener_Fetch_ConditionalRequests(t *testing.T) {
+	content := testCSV
+	etag := `"v1"`
+	transfers := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		transfers++
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	cacheDir := t.TempDir()
+	opener := NewOpener(Config{HTTPClient: server.Client(), CacheDir: cacheDir})
+
+	first, err := opener.Fetch(server.URL+"/rica.csv", nil)
+	if err != nil {
+		t.Fatalf("Fetch returned an unexpected error: %v", err)
+	}
+	if first.Cached || len(first.SHA256) != 64 || first.Size != int64(len(testCSV)) {
+		t.Errorf("unexpected first artifact %+v", first)
+	}
+
+	second, err := opener.Fetch(server.URL+"/rica.csv", nil)
+	if err != nil {
+		t.Fatalf("Fetch returned an unexpected error: %v", err)
+	}
+	if !second.Cached || second.SHA256 != first.SHA256 || second.Path != first.Path {
+		t.Errorf("expected the cached copy to be reused, got %+v", second)
+	}
+	if transfers != 1 {
+		t.Errorf("expected a single transfer, got %d", transfers)
+	}
+
+	content, etag = "IDNUM;MILEX;OTEFDD\n103;2024;1500\n", `"v2"`
+	third, err := opener.Fetch(server.URL+"/rica.csv", nil)
+	if err != nil {
+		t.Fatalf("Fetch returned an unexpected error: %v", err)
+	}
+	if third.Cached || third.SHA256 == first.SHA256 {
+		t.Errorf("expected the changed source to be downloaded again, got %+v", third)
+	}
+	if _, err := os.Stat(first.Path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the stale cached copy to be removed, got %v", err)
+	}
+	if rows := readAllRows(t, opener, server.URL+"/rica.csv", ""); strings.Join(rows, "|") != "103;2024;1500" {
+		t.Errorf("unexpected rows %v", rows)
+	}
+}
+
+func TestOpener_Fetch_LocalFileChecksum(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "rica.csv")
+	if err := os.WriteFile(path, []byte("RICA"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	artifact, err := NewOpener(Config{FileRoot: root}).Fetch("file://"+path, nil)
+	if err != nil {
+		t.Fatalf("Fetch returned an unexpected error: %v", err)
+	}
+	if artifact.SHA256 != "0a53276e88bf3f63fd26bfaa0f212d3ced86cbfeef382d4eaa5a093f341c4aad" || artifact.Size != 4 || artifact.Path != path {
+		t.Errorf("unexpected artifact %+v", artifact)
+	}
+}
+
+func TestOpener_Open_AgresteData(t *testing.T) {
+	agresteZipURL := "https://agreste.agriculture.gouv.fr/agreste-web/download/service/SV-Accès micro données RICA/RicaMicrodonnées2023_v2.zip"
+	agresteCsvFileName := "Rica_France_micro_Donnees_ex2023.csv"
+
+	stream, err := NewOpener(Config{}).Open(agresteZipURL, agresteCsvFileName)
+	if err != nil {
+		t.Fatalf("Open a retourné une erreur inattendue : %v", err)
+	}
+	defer stream.Close()
+
+	firstRecord, err := stream.Next()
+	if errors.Is(err, io.EOF) {
+		t.Fatal("Open n'a retourné aucune donnée (0 lignes), des enregistrements étaient attendus.")
+	}
+	if err != nil {
+		t.Fatalf("Lecture de la première ligne impossible : %v", err)
+	}
+
+	rowCount := 1
+	for {
+		_, err := stream.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Lecture de la ligne %d impossible : %v", rowCount+1, err)
+		}
+		rowCount++
+	}
+
+	t.Logf("Lecture réussie de %d lignes depuis '%s'. En-tête : %v, première ligne : %v", rowCount, agresteCsvFileName, stream.Header(), firstRecord.Values)
+}
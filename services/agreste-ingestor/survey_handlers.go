@@ -0,0 +1,109 @@
+package main
+
+import (
+	"agreste-ingestor/agri_units"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+type SurveyRevisionDiff struct {
+	SurveyID uuid.UUID                      `json:"surveyId"`
+	From     agri_units.SurveyRevisionRef   `json:"from"`
+	To       agri_units.SurveyRevisionRef   `json:"to"`
+	Changes  []agri_units.SurveyFieldChange `json:"changes"`
+}
+
+func (a *App) loadSurveyHistory(w http.ResponseWriter, r *http.Request) ([]agri_units.AgriculturalUnitSurveyRevision, bool) {
+	surveyID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid survey ID '%s'", r.PathValue("id")), http.StatusBadRequest)
+		return nil, false
+	}
+
+	survey, err := a.AgriUnitSurveyStorage.SelectByID(surveyID)
+	if errors.Is(err, agri_units.ErrAgriculturalUnitSurveyNotFound) {
+		http.Error(w, fmt.Sprintf("Survey '%s' not found", surveyID), http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Error loading survey %s: %v\n", surveyID, err)
+		http.Error(w, fmt.Sprintf("Error loading survey: %v", err), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	history, err := agri_units.SurveyHistory(survey, a.SurveyRevisionStorage)
+	if err != nil {
+		log.Printf("Error loading revisions of survey %s: %v\n", surveyID, err)
+		http.Error(w, fmt.Sprintf("Error loading survey revisions: %v", err), http.StatusInternalServerError)
+		return nil, false
+	}
+	return history, true
+}
+
+func (a *App) SurveyRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	history, ok := a.loadSurveyHistory(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, history)
+}
+
+// SurveyRevisionDiffHandler compares two revisions of a survey, by default the latest two.
+func (a *App) SurveyRevisionDiffHandler(w http.ResponseWriter, r *http.Request) {
+	history, ok := a.loadSurveyHistory(w, r)
+	if !ok {
+		return
+	}
+
+	latest := history[len(history)-1].Revision
+	from, err := revisionParameter(r, "from", max(latest-1, 1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := revisionParameter(r, "to", latest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fromRevision, fromFound := findRevision(history, from)
+	toRevision, toFound := findRevision(history, to)
+	if !fromFound || !toFound {
+		http.Error(w, fmt.Sprintf("Survey has no revisions %d and %d to compare (latest is %d)", from, to, latest), http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, SurveyRevisionDiff{
+		SurveyID: toRevision.SurveyID,
+		From:     fromRevision.Ref(),
+		To:       toRevision.Ref(),
+		Changes:  agri_units.DiffSurveyData(fromRevision.Data, toRevision.Data),
+	})
+}
+
+func revisionParameter(r *http.Request, name string, defaultValue int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return defaultValue, nil
+	}
+	revision, err := strconv.Atoi(raw)
+	if err != nil || revision < 1 {
+		return 0, fmt.Errorf("Invalid '%s' query parameter: '%s'", name, raw)
+	}
+	return revision, nil
+}
+
+func findRevision(history []agri_units.AgriculturalUnitSurveyRevision, revision int) (agri_units.AgriculturalUnitSurveyRevision, bool) {
+	for _, candidate := range history {
+		if candidate.Revision == revision {
+			return candidate, true
+		}
+	}
+	return agri_units.AgriculturalUnitSurveyRevision{}, false
+}
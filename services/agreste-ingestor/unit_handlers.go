@@ -0,0 +1,322 @@
+package main
+
+import (
+	"agreste-ingestor/agri_units"
+	"common/agri"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+type listParameters struct {
+	IDNums   []int
+	IDNumMin *int
+	IDNumMax *int
+	BBox     *agri.BoundingBox
+	Archived agri.ArchivedFilter
+	Limit    uint64
+	Offset   uint64
+}
+
+func parseListParameters(query url.Values) (listParameters, error) {
+	parameters := listParameters{Limit: defaultPageLimit}
+
+	var err error
+	if parameters.IDNums, err = intListParameter(query, "idNums"); err != nil {
+		return parameters, err
+	}
+	if parameters.IDNumMin, err = optionalIntParameter(query, "idNumMin"); err != nil {
+		return parameters, err
+	}
+	if parameters.IDNumMax, err = optionalIntParameter(query, "idNumMax"); err != nil {
+		return parameters, err
+	}
+
+	if raw := query.Get("bbox"); raw != "" {
+		bbox, err := parseBoundingBox(raw)
+		if err != nil {
+			return parameters, fmt.Errorf("Invalid 'bbox' query parameter: %v", err)
+		}
+		parameters.BBox = &bbox
+	}
+
+	if parameters.Archived, err = parseArchivedParameter(query); err != nil {
+		return parameters, err
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil || limit == 0 || limit > maxPageLimit {
+			return parameters, fmt.Errorf("Invalid 'limit' query parameter: '%s', expected 1 to %d", raw, maxPageLimit)
+		}
+		parameters.Limit = limit
+	}
+	if raw := query.Get("offset"); raw != "" {
+		offset, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return parameters, fmt.Errorf("Invalid 'offset' query parameter: '%s'", raw)
+		}
+		parameters.Offset = offset
+	}
+	return parameters, nil
+}
+
+func parseArchivedParameter(query url.Values) (agri.ArchivedFilter, error) {
+	switch raw := query.Get("archived"); raw {
+	case "", "false":
+		return agri.ArchivedExcluded, nil
+	case "true":
+		return agri.ArchivedOnly, nil
+	case "all":
+		return agri.ArchivedIncluded, nil
+	default:
+		return "", fmt.Errorf("Invalid 'archived' query parameter: '%s', expected 'false', 'true' or 'all'", raw)
+	}
+}
+
+func optionalIntParameter(query url.Values, name string) (*int, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid '%s' query parameter: '%s'", name, raw)
+	}
+	return &value, nil
+}
+
+func intListParameter(query url.Values, name string) ([]int, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	var values []int
+	for _, part := range strings.Split(raw, ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid '%s' query parameter: '%s' is not an integer", name, part)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func parseBoundingBox(raw string) (agri.BoundingBox, error) {
+	parts := strings.Split(raw, ",")
+	if len(parts) != 4 {
+		return agri.BoundingBox{}, fmt.Errorf("'%s' is not minLon,minLat,maxLon,maxLat", raw)
+	}
+	var coordinates [4]float64
+	for i, part := range parts {
+		coordinate, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return agri.BoundingBox{}, fmt.Errorf("'%s' is not a coordinate", part)
+		}
+		coordinates[i] = coordinate
+	}
+	bbox := agri.BoundingBox{
+		MinLongitude: coordinates[0],
+		MinLatitude:  coordinates[1],
+		MaxLongitude: coordinates[2],
+		MaxLatitude:  coordinates[3],
+	}
+	return bbox, bbox.Validate()
+}
+
+func parseFields(query url.Values) []string {
+	raw := query.Get("fields")
+	if raw == "" {
+		return nil
+	}
+	var fields []string
+	for _, field := range strings.Split(raw, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+func projectSurveys(surveys []agri_units.AgriculturalUnitSurvey, fields []string) []agri_units.AgriculturalUnitSurvey {
+	if surveys == nil {
+		return []agri_units.AgriculturalUnitSurvey{}
+	}
+	if fields == nil {
+		return surveys
+	}
+	projected := make([]agri_units.AgriculturalUnitSurvey, len(surveys))
+	for i, survey := range surveys {
+		projected[i] = survey.Project(fields)
+	}
+	return projected
+}
+
+// UnitsHandler lists agricultural units by IDNUM, active ones by default.
+func (a *App) UnitsHandler(w http.ResponseWriter, r *http.Request) {
+	parameters, err := parseListParameters(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := agri.AgriUnitQuery{
+		IDNums:   parameters.IDNums,
+		IDNumMin: parameters.IDNumMin,
+		IDNumMax: parameters.IDNumMax,
+		BBox:     parameters.BBox,
+		Archived: parameters.Archived,
+		Limit:    parameters.Limit,
+		Offset:   parameters.Offset,
+	}
+	if query.AfterIDNum, err = optionalIntParameter(r.URL.Query(), "afterIdNum"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	units, err := a.AgriUnitStorage.Select(query)
+	if err != nil {
+		log.Printf("Error listing agricultural units: %v\n", err)
+		http.Error(w, fmt.Sprintf("Error listing agricultural units: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if units == nil {
+		units = []agri.AgriculturalUnit{}
+	}
+
+	writeJSON(w, http.StatusOK, units)
+}
+
+func (a *App) loadUnit(w http.ResponseWriter, r *http.Request) (agri.AgriculturalUnit, bool) {
+	rawID := r.PathValue("id")
+
+	unit, err := agri.SelectByIDOrIDNum(a.AgriUnitStorage, rawID)
+	if errors.Is(err, agri.ErrInvalidAgriculturalUnitID) {
+		http.Error(w, fmt.Sprintf("Invalid unit ID '%s', expected a UUID or an IDNUM", rawID), http.StatusBadRequest)
+		return unit, false
+	}
+	if errors.Is(err, agri.ErrAgriculturalUnitNotFound) {
+		http.Error(w, fmt.Sprintf("Agricultural unit '%s' not found", rawID), http.StatusNotFound)
+		return unit, false
+	}
+	if err != nil {
+		log.Printf("Error loading agricultural unit %s: %v\n", rawID, err)
+		http.Error(w, fmt.Sprintf("Error loading agricultural unit: %v", err), http.StatusInternalServerError)
+		return unit, false
+	}
+	return unit, true
+}
+
+func (a *App) UnitHandler(w http.ResponseWriter, r *http.Request) {
+	unit, ok := a.loadUnit(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, unit)
+}
+
+// UnitSurveysHandler lists the surveys of a unit by year, active ones by default.
+func (a *App) UnitSurveysHandler(w http.ResponseWriter, r *http.Request) {
+	archived, err := parseArchivedParameter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	unit, ok := a.loadUnit(w, r)
+	if !ok {
+		return
+	}
+
+	surveys, err := a.AgriUnitSurveyStorage.Select(agri_units.SurveyQuery{IDNums: []int{unit.IDNum}, Archived: archived})
+	if err != nil {
+		log.Printf("Error loading surveys of agricultural unit %d: %v\n", unit.IDNum, err)
+		http.Error(w, fmt.Sprintf("Error loading surveys: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, projectSurveys(surveys, parseFields(r.URL.Query())))
+}
+
+// SurveysHandler lists surveys by IDNUM and year, active ones by default.
+func (a *App) SurveysHandler(w http.ResponseWriter, r *http.Request) {
+	parameters, err := parseListParameters(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := agri_units.SurveyQuery{
+		IDNums:   parameters.IDNums,
+		IDNumMin: parameters.IDNumMin,
+		IDNumMax: parameters.IDNumMax,
+		BBox:     parameters.BBox,
+		Archived: parameters.Archived,
+		Limit:    parameters.Limit,
+		Offset:   parameters.Offset,
+	}
+	if query.Years, err = intListParameter(r.URL.Query(), "year"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	afterIDNum, err := optionalIntParameter(r.URL.Query(), "afterIdNum")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	afterYear, err := optionalIntParameter(r.URL.Query(), "afterYear")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if (afterIDNum == nil) != (afterYear == nil) {
+		http.Error(w, "Query parameters 'afterIdNum' and 'afterYear' must be given together", http.StatusBadRequest)
+		return
+	}
+	if afterIDNum != nil {
+		query.After = &agri_units.SurveyKey{IDNum: *afterIDNum, Year: *afterYear}
+	}
+
+	surveys, err := a.AgriUnitSurveyStorage.Select(query)
+	if err != nil {
+		log.Printf("Error listing surveys: %v\n", err)
+		http.Error(w, fmt.Sprintf("Error listing surveys: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, projectSurveys(surveys, parseFields(r.URL.Query())))
+}
+
+func (a *App) SurveyHandler(w http.ResponseWriter, r *http.Request) {
+	surveyID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid survey ID '%s'", r.PathValue("id")), http.StatusBadRequest)
+		return
+	}
+
+	survey, err := a.AgriUnitSurveyStorage.SelectByID(surveyID)
+	if errors.Is(err, agri_units.ErrAgriculturalUnitSurveyNotFound) {
+		http.Error(w, fmt.Sprintf("Survey '%s' not found", surveyID), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading survey %s: %v\n", surveyID, err)
+		http.Error(w, fmt.Sprintf("Error loading survey: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if fields := parseFields(r.URL.Query()); fields != nil {
+		survey = survey.Project(fields)
+	}
+	writeJSON(w, http.StatusOK, survey)
+}
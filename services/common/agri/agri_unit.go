@@ -1,4 +1,4 @@
-package agri_units
+package agri
 
 import (
 	"time"
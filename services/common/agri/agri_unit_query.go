@@ -0,0 +1,121 @@
+package agri
+
+import (
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+// ArchivedFilter selects rows by their archived_at, only active ones by default.
+type ArchivedFilter string
+
+const (
+	ArchivedExcluded ArchivedFilter = ""
+	ArchivedOnly     ArchivedFilter = "only"
+	ArchivedIncluded ArchivedFilter = "include"
+)
+
+// Condition returns the archived_at condition of the filter, nil when every row matches.
+func (f ArchivedFilter) Condition() (sq.Sqlizer, error) {
+	switch f {
+	case ArchivedExcluded:
+		return sq.Eq{"archived_at": nil}, nil
+	case ArchivedOnly:
+		return sq.NotEq{"archived_at": nil}, nil
+	case ArchivedIncluded:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown archived filter '%s'", f)
+}
+
+// BoundingBox is a rectangle of WGS84 coordinates, bounds included.
+type BoundingBox struct {
+	MinLatitude  float64
+	MinLongitude float64
+	MaxLatitude  float64
+	MaxLongitude float64
+}
+
+func (b BoundingBox) Validate() error {
+	if b.MinLatitude < -90 || b.MaxLatitude > 90 || b.MinLatitude > b.MaxLatitude {
+		return fmt.Errorf("invalid latitudes %g to %g, expected -90 <= min <= max <= 90", b.MinLatitude, b.MaxLatitude)
+	}
+	if b.MinLongitude < -180 || b.MaxLongitude > 180 || b.MinLongitude > b.MaxLongitude {
+		return fmt.Errorf("invalid longitudes %g to %g, expected -180 <= min <= max <= 180", b.MinLongitude, b.MaxLongitude)
+	}
+	return nil
+}
+
+// Condition matches the latitude and longitude columns inside the box.
+func (b BoundingBox) Condition() sq.And {
+	return sq.And{
+		sq.GtOrEq{"latitude": b.MinLatitude},
+		sq.LtOrEq{"latitude": b.MaxLatitude},
+		sq.GtOrEq{"longitude": b.MinLongitude},
+		sq.LtOrEq{"longitude": b.MaxLongitude},
+	}
+}
+
+// AgriUnitQuery filters and pages agricultural units, ordered by IDNUM.
+type AgriUnitQuery struct {
+	IDs        []uuid.UUID
+	IDNums     []int
+	IDNumMin   *int
+	IDNumMax   *int
+	BBox       *BoundingBox
+	Archived   ArchivedFilter
+	AfterIDNum *int
+	Limit      uint64
+	Offset     uint64
+}
+
+// IDConditions matches the id and id_num columns shared by the unit tables.
+func IDConditions(ids []uuid.UUID, idNums []int, idNumMin, idNumMax *int) sq.And {
+	var conditions sq.And
+	if ids != nil {
+		values := make([]string, len(ids))
+		for i, id := range ids {
+			values[i] = id.String()
+		}
+		conditions = append(conditions, sq.Eq{"id": values})
+	}
+	if idNums != nil {
+		conditions = append(conditions, sq.Eq{"id_num": idNums})
+	}
+	if idNumMin != nil {
+		conditions = append(conditions, sq.GtOrEq{"id_num": *idNumMin})
+	}
+	if idNumMax != nil {
+		conditions = append(conditions, sq.LtOrEq{"id_num": *idNumMax})
+	}
+	return conditions
+}
+
+func (q AgriUnitQuery) apply(builder sq.SelectBuilder) (sq.SelectBuilder, error) {
+	archived, err := q.Archived.Condition()
+	if err != nil {
+		return builder, err
+	}
+	if archived != nil {
+		builder = builder.Where(archived)
+	}
+	for _, condition := range IDConditions(q.IDs, q.IDNums, q.IDNumMin, q.IDNumMax) {
+		builder = builder.Where(condition)
+	}
+	if q.BBox != nil {
+		builder = builder.Where(q.BBox.Condition())
+	}
+	if q.AfterIDNum != nil {
+		builder = builder.Where(sq.Gt{"id_num": *q.AfterIDNum})
+	}
+
+	builder = builder.OrderBy("id_num")
+	if q.Limit > 0 {
+		builder = builder.Limit(q.Limit)
+	}
+	if q.Offset > 0 {
+		builder = builder.Offset(q.Offset)
+	}
+	return builder, nil
+}
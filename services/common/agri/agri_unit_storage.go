@@ -0,0 +1,302 @@
+package agri
+
+import (
+	"common/storage"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+
+	_ "github.com/lib/pq"
+)
+
+type AgriculturalUnitSqlView struct {
+	ID         string       `db:"id"`
+	CreatedAt  time.Time    `db:"created_at"`
+	UpdatedAt  time.Time    `db:"updated_at"`
+	ArchivedAt sql.NullTime `db:"archived_at"`
+
+	IDNum     int     `db:"id_num"`
+	Latitude  float64 `db:"latitude"`
+	Longitude float64 `db:"longitude"`
+}
+
+func AgriculturalUnitToSqlView(unit AgriculturalUnit) AgriculturalUnitSqlView {
+	sqlView := AgriculturalUnitSqlView{
+		ID:        unit.ID.String(),
+		CreatedAt: unit.CreatedAt,
+		UpdatedAt: unit.UpdatedAt,
+		IDNum:     unit.IDNum,
+		Latitude:  unit.Latitude,
+		Longitude: unit.Longitude,
+	}
+
+	if unit.ArchivedAt != nil {
+		sqlView.ArchivedAt = sql.NullTime{Time: *unit.ArchivedAt, Valid: true}
+	} else {
+		sqlView.ArchivedAt = sql.NullTime{Valid: false}
+	}
+
+	return sqlView
+}
+
+func AgriculturalUnitFromSqlView(sqlView AgriculturalUnitSqlView) (AgriculturalUnit, error) {
+	parsedID, err := uuid.Parse(sqlView.ID)
+	if err != nil {
+		return AgriculturalUnit{}, fmt.Errorf("failed to parse UUID from SQL view '%s': %w", sqlView.ID, err)
+	}
+
+	unit := AgriculturalUnit{
+		ID:        parsedID,
+		CreatedAt: sqlView.CreatedAt,
+		UpdatedAt: sqlView.UpdatedAt,
+		IDNum:     sqlView.IDNum,
+		Latitude:  sqlView.Latitude,
+		Longitude: sqlView.Longitude,
+	}
+
+	if sqlView.ArchivedAt.Valid {
+		unit.ArchivedAt = &sqlView.ArchivedAt.Time
+	} else {
+		unit.ArchivedAt = nil
+	}
+
+	return unit, nil
+}
+
+var (
+	ErrAgriculturalUnitNotFound  = errors.New("agricultural unit not found")
+	ErrInvalidAgriculturalUnitID = errors.New("invalid agricultural unit ID")
+)
+
+type AgriUnitStorage interface {
+	SelectAll() ([]AgriculturalUnit, error)
+	Select(query AgriUnitQuery) ([]AgriculturalUnit, error)
+	// Each calls visit with every unit matching query, stopping at its first error.
+	Each(query AgriUnitQuery, visit func(AgriculturalUnit) error) error
+	SelectByID(id uuid.UUID) (AgriculturalUnit, error)
+	SelectByIDNum(idNum int) (AgriculturalUnit, error)
+	InsertOrUpdate(unit AgriculturalUnit) error
+	InsertOrUpdateBatch(units []AgriculturalUnit) error
+	WithQuerier(querier storage.DBQuerier) AgriUnitStorage
+}
+
+const maxUnitsPerStatement = 1000
+
+const agriUnitUpsertSuffix = `
+			ON CONFLICT (id_num) DO UPDATE SET
+				updated_at = EXCLUDED.updated_at,
+				archived_at = EXCLUDED.archived_at,
+				latitude = EXCLUDED.latitude,
+				longitude = EXCLUDED.longitude
+		`
+
+var agriUnitColumns = []string{
+	"id",
+	"created_at",
+	"updated_at",
+	"archived_at",
+	"id_num",
+	"latitude",
+	"longitude",
+}
+
+type agriUnitStorage struct {
+	querier storage.DBQuerier
+	builder sq.StatementBuilderType
+}
+
+func NewAgriUnitStorage(querier storage.DBQuerier) AgriUnitStorage {
+	return &agriUnitStorage{
+		querier: querier,
+		builder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+}
+
+func (s *agriUnitStorage) WithQuerier(querier storage.DBQuerier) AgriUnitStorage {
+	return &agriUnitStorage{
+		querier: querier,
+		builder: s.builder,
+	}
+}
+
+func (s *agriUnitStorage) insertBuilder() sq.InsertBuilder {
+	return s.builder.Insert("agricultural_units").
+		Columns(agriUnitColumns...)
+}
+
+func (s *agriUnitStorage) selectBuilder() sq.SelectBuilder {
+	return s.builder.Select(agriUnitColumns...).From("agricultural_units")
+}
+
+func (s *agriUnitStorage) SelectAll() ([]AgriculturalUnit, error) {
+	return s.Select(AgriUnitQuery{})
+}
+
+func (s *agriUnitStorage) Select(query AgriUnitQuery) ([]AgriculturalUnit, error) {
+	queryBuilder, err := query.apply(s.selectBuilder())
+	if err != nil {
+		return nil, err
+	}
+	return s.selectUnits(queryBuilder)
+}
+
+func (s *agriUnitStorage) Each(query AgriUnitQuery, visit func(AgriculturalUnit) error) error {
+	queryBuilder, err := query.apply(s.selectBuilder())
+	if err != nil {
+		return err
+	}
+	return s.eachUnit(queryBuilder, visit)
+}
+
+func (s *agriUnitStorage) SelectByID(id uuid.UUID) (AgriculturalUnit, error) {
+	units, err := s.selectUnits(s.selectBuilder().Where(sq.Eq{"id": id.String()}))
+	if err != nil {
+		return AgriculturalUnit{}, err
+	}
+	if len(units) == 0 {
+		return AgriculturalUnit{}, fmt.Errorf("%w: %s", ErrAgriculturalUnitNotFound, id)
+	}
+	return units[0], nil
+}
+
+func (s *agriUnitStorage) SelectByIDNum(idNum int) (AgriculturalUnit, error) {
+	units, err := s.selectUnits(s.selectBuilder().Where(sq.Eq{"id_num": idNum}))
+	if err != nil {
+		return AgriculturalUnit{}, err
+	}
+	if len(units) == 0 {
+		return AgriculturalUnit{}, fmt.Errorf("%w: IDNUM %d", ErrAgriculturalUnitNotFound, idNum)
+	}
+	return units[0], nil
+}
+
+// SelectByIDOrIDNum selects the unit named by rawID, a UUID or an IDNUM.
+func SelectByIDOrIDNum(unitStorage AgriUnitStorage, rawID string) (AgriculturalUnit, error) {
+	if id, err := uuid.Parse(rawID); err == nil {
+		return unitStorage.SelectByID(id)
+	}
+	if idNum, err := strconv.Atoi(rawID); err == nil {
+		return unitStorage.SelectByIDNum(idNum)
+	}
+	return AgriculturalUnit{}, fmt.Errorf("%w: '%s', expected a UUID or an IDNUM", ErrInvalidAgriculturalUnitID, rawID)
+}
+
+func (s *agriUnitStorage) selectUnits(queryBuilder sq.SelectBuilder) ([]AgriculturalUnit, error) {
+	var units []AgriculturalUnit
+	err := s.eachUnit(queryBuilder, func(unit AgriculturalUnit) error {
+		units = append(units, unit)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return units, nil
+}
+
+func (s *agriUnitStorage) eachUnit(queryBuilder sq.SelectBuilder, visit func(AgriculturalUnit) error) error {
+	sqlQuery, args, err := queryBuilder.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query with squirrel: %w", err)
+	}
+
+	rows, err := s.querier.Query(sqlQuery, args...)
+	if err != nil {
+		return fmt.Errorf("failed to execute agricultural unit query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sqlView AgriculturalUnitSqlView
+		err := rows.Scan(
+			&sqlView.ID,
+			&sqlView.CreatedAt,
+			&sqlView.UpdatedAt,
+			&sqlView.ArchivedAt,
+			&sqlView.IDNum,
+			&sqlView.Latitude,
+			&sqlView.Longitude,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan agricultural unit row: %w", err)
+		}
+
+		domainUnit, err := AgriculturalUnitFromSqlView(sqlView)
+		if err != nil {
+			return fmt.Errorf("failed to convert SQL view to domain model: %w", err)
+		}
+		if err := visit(domainUnit); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error during row iteration: %w", err)
+	}
+
+	return nil
+}
+
+func (s *agriUnitStorage) InsertOrUpdate(unit AgriculturalUnit) error {
+	sqlView := AgriculturalUnitToSqlView(unit)
+
+	builder := s.insertBuilder().
+		Values(
+			sqlView.ID,
+			sqlView.CreatedAt,
+			sqlView.UpdatedAt,
+			sqlView.ArchivedAt,
+			sqlView.IDNum,
+			sqlView.Latitude,
+			sqlView.Longitude,
+		).
+		Suffix(agriUnitUpsertSuffix)
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build InsertOrUpdate SQL: %w", err)
+	}
+
+	_, err = s.querier.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to execute InsertOrUpdate: %w", err)
+	}
+
+	return nil
+}
+
+func (s *agriUnitStorage) InsertOrUpdateBatch(units []AgriculturalUnit) error {
+	for start := 0; start < len(units); start += maxUnitsPerStatement {
+		end := min(start+maxUnitsPerStatement, len(units))
+
+		builder := s.insertBuilder()
+		for _, unit := range units[start:end] {
+			sqlView := AgriculturalUnitToSqlView(unit)
+			builder = builder.Values(
+				sqlView.ID,
+				sqlView.CreatedAt,
+				sqlView.UpdatedAt,
+				sqlView.ArchivedAt,
+				sqlView.IDNum,
+				sqlView.Latitude,
+				sqlView.Longitude,
+			)
+		}
+
+		query, args, err := builder.Suffix(agriUnitUpsertSuffix).ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build InsertOrUpdateBatch SQL: %w", err)
+		}
+
+		_, err = s.querier.Exec(query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to execute InsertOrUpdateBatch for %d agricultural units: %w", end-start, err)
+		}
+	}
+
+	return nil
+}
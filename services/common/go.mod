@@ -0,0 +1,15 @@
+module common
+
+go 1.23.1
+
+require (
+	github.com/Masterminds/squirrel v1.5.4
+	github.com/google/uuid v1.6.0
+	github.com/lib/pq v1.10.9
+)
+
+require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
+	github.com/lann/builder v0.0.0-20180802200727-47ae307949d0 // indirect
+	github.com/lann/ps v0.0.0-20150810152359-62de8c46ede0 // indirect
+)
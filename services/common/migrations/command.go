@@ -0,0 +1,80 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+const Usage = "migrate up | migrate down [steps] | migrate status"
+
+// RunCommand runs the migrate subcommand of a service, reporting what it did to w.
+func RunCommand(ctx context.Context, migrator *Migrator, args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing migrate command, expected %s", Usage)
+	}
+
+	switch args[0] {
+	case "up":
+		if len(args) > 1 {
+			return fmt.Errorf("unexpected arguments %q, expected %s", args[1:], Usage)
+		}
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Fprintf(w, "Applied migration %04d_%s.\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(w, "Schema is up to date.")
+		}
+		return nil
+
+	case "down":
+		steps := 1
+		if len(args) > 2 {
+			return fmt.Errorf("unexpected arguments %q, expected %s", args[2:], Usage)
+		}
+		if len(args) == 2 {
+			var err error
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps <= 0 {
+				return fmt.Errorf("invalid number of steps '%s', expected a positive integer", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, migration := range reverted {
+			fmt.Fprintf(w, "Reverted migration %04d_%s.\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(reverted) == 0 {
+			fmt.Fprintln(w, "No migration to revert.")
+		}
+		return nil
+
+	case "status":
+		if len(args) > 1 {
+			return fmt.Errorf("unexpected arguments %q, expected %s", args[1:], Usage)
+		}
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			if status.Unknown {
+				state += " (unknown to this build)"
+			}
+			fmt.Fprintf(w, "%04d_%-24s %s\n", status.Version, status.Name, state)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown migrate command '%s', expected %s", args[0], Usage)
+}
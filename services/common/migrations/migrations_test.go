@@ -0,0 +1,359 @@
+package migrations
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	go_sqlmock "github.com/DATA-DOG/go-sqlmock"
+	_ "github.com/lib/pq"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_add_column.up.sql":   {Data: []byte("ALTER TABLE t ADD COLUMN c INT;")},
+		"0002_add_column.down.sql": {Data: []byte("ALTER TABLE t DROP COLUMN c;")},
+		"0001_create.up.sql":       {Data: []byte("CREATE TABLE t (id INT);")},
+		"0001_create.down.sql":     {Data: []byte("DROP TABLE t;")},
+		"README.md":                {Data: []byte("ignored")},
+	}
+
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load returned an unexpected error: %v", err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("expected 2 migrations, got %d", len(migrations))
+	}
+	if migrations[0].Version != 1 || migrations[0].Name != "create" || migrations[0].Down != "DROP TABLE t;" {
+		t.Errorf("unexpected first migration %+v", migrations[0])
+	}
+	if migrations[1].Version != 2 || migrations[1].Name != "add_column" || migrations[1].Up != "ALTER TABLE t ADD COLUMN c INT;" {
+		t.Errorf("unexpected second migration %+v", migrations[1])
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	testCases := map[string]fstest.MapFS{
+		"MissingDown": {
+			"0001_create.up.sql": {Data: []byte("CREATE TABLE t (id INT);")},
+		},
+		"BadName": {
+			"create.up.sql":   {Data: []byte("CREATE TABLE t (id INT);")},
+			"create.down.sql": {Data: []byte("DROP TABLE t;")},
+		},
+		"NameMismatch": {
+			"0001_create.up.sql":   {Data: []byte("CREATE TABLE t (id INT);")},
+			"0001_remove.down.sql": {Data: []byte("DROP TABLE t;")},
+		},
+		"ZeroVersion": {
+			"0000_create.up.sql":   {Data: []byte("CREATE TABLE t (id INT);")},
+			"0000_create.down.sql": {Data: []byte("DROP TABLE t;")},
+		},
+	}
+
+	for name, fsys := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(fsys); err == nil {
+				t.Error("Load expected an error, but got none")
+			}
+		})
+	}
+}
+
+func TestAll(t *testing.T) {
+	migrations, err := All()
+	if err != nil {
+		t.Fatalf("All returned an unexpected error: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("expected embedded migrations")
+	}
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Errorf("expected migration %d to have version %d, got %04d_%s", i, i+1, migration.Version, migration.Name)
+		}
+	}
+}
+
+func TestMigrator_Up_Mock(t *testing.T) {
+	db, sqlMock, err := go_sqlmock.New(go_sqlmock.QueryMatcherOption(go_sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	migrations := []Migration{
+		{Version: 1, Name: "create", Up: "CREATE TABLE t (id INT)", Down: "DROP TABLE t"},
+		{Version: 2, Name: "add_column", Up: "ALTER TABLE t ADD COLUMN c INT", Down: "ALTER TABLE t DROP COLUMN c"},
+	}
+	now := time.Now()
+
+	sqlMock.ExpectExec("SELECT pg_advisory_lock($1)").WithArgs(lockKey).WillReturnResult(go_sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec(createSchemaMigrationsSQL).WillReturnResult(go_sqlmock.NewResult(0, 0))
+	sqlMock.ExpectQuery("SELECT version, name, applied_at FROM schema_migrations").
+		WillReturnRows(sqlMock.NewRows([]string{"version", "name", "applied_at"}).AddRow(1, "create", now))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("ALTER TABLE t ADD COLUMN c INT").WillReturnResult(go_sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec("INSERT INTO schema_migrations (version, name) VALUES ($1, $2)").
+		WithArgs(2, "add_column").
+		WillReturnResult(go_sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	sqlMock.ExpectExec("SELECT pg_advisory_unlock($1)").WithArgs(lockKey).WillReturnResult(go_sqlmock.NewResult(0, 0))
+
+	applied, err := NewMigrator(db, migrations).Up(context.Background())
+	if err != nil {
+		t.Fatalf("Up returned an unexpected error: %v", err)
+	}
+	if len(applied) != 1 || applied[0].Version != 2 {
+		t.Errorf("expected only migration 2 to be applied, got %+v", applied)
+	}
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestMigrator_Up_RollsBackFailedMigration(t *testing.T) {
+	db, sqlMock, err := go_sqlmock.New(go_sqlmock.QueryMatcherOption(go_sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	migrations := []Migration{{Version: 1, Name: "create", Up: "CREATE TABLE t (id INT)", Down: "DROP TABLE t"}}
+
+	sqlMock.ExpectExec("SELECT pg_advisory_lock($1)").WithArgs(lockKey).WillReturnResult(go_sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec(createSchemaMigrationsSQL).WillReturnResult(go_sqlmock.NewResult(0, 0))
+	sqlMock.ExpectQuery("SELECT version, name, applied_at FROM schema_migrations").
+		WillReturnRows(sqlMock.NewRows([]string{"version", "name", "applied_at"}))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("CREATE TABLE t (id INT)").WillReturnError(fmt.Errorf("simulated syntax error"))
+	sqlMock.ExpectRollback()
+	sqlMock.ExpectExec("SELECT pg_advisory_unlock($1)").WithArgs(lockKey).WillReturnResult(go_sqlmock.NewResult(0, 0))
+
+	applied, err := NewMigrator(db, migrations).Up(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "0001_create") {
+		t.Errorf("expected an error naming migration 0001_create, got %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("expected no migration to be applied, got %+v", applied)
+	}
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// openTestDatabase creates a throwaway database on the server of MIGRATIONS_TEST_DATABASE_URL.
+func openTestDatabase(t *testing.T) *sql.DB {
+	t.Helper()
+	serverURL := os.Getenv("MIGRATIONS_TEST_DATABASE_URL")
+	if serverURL == "" {
+		t.Skip("MIGRATIONS_TEST_DATABASE_URL is not set")
+	}
+
+	server, err := sql.Open("postgres", serverURL)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", serverURL, err)
+	}
+	name := fmt.Sprintf("migrations_test_%d", time.Now().UnixNano())
+	if _, err := server.Exec("CREATE DATABASE " + name); err != nil {
+		server.Close()
+		t.Fatalf("failed to create test database: %v", err)
+	}
+
+	databaseURL, err := withDatabase(serverURL, name)
+	if err != nil {
+		t.Fatalf("failed to build test database URL: %v", err)
+	}
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+		if _, err := server.Exec("DROP DATABASE IF EXISTS " + name); err != nil {
+			t.Errorf("failed to drop test database %s: %v", name, err)
+		}
+		server.Close()
+	})
+	return db
+}
+
+func withDatabase(serverURL, name string) (string, error) {
+	parsed, err := url.Parse(serverURL)
+	if err != nil {
+		return "", err
+	}
+	parsed.Path = "/" + name
+	return parsed.String(), nil
+}
+
+func tableNames(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	rows, err := db.Query(`SELECT table_name FROM information_schema.tables WHERE table_schema = 'public' AND table_name <> 'schema_migrations' ORDER BY table_name`)
+	if err != nil {
+		t.Fatalf("failed to list tables: %v", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("failed to scan table name: %v", err)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestMigrator_Postgres(t *testing.T) {
+	db := openTestDatabase(t)
+	ctx := context.Background()
+
+	migrations, err := All()
+	if err != nil {
+		t.Fatalf("All returned an unexpected error: %v", err)
+	}
+	migrator := NewMigrator(db, migrations)
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up returned an unexpected error: %v", err)
+	}
+	if len(applied) != len(migrations) {
+		t.Errorf("expected %d migrations applied, got %d", len(migrations), len(applied))
+	}
+	expectedTables := []string{
+		"agricultural_unit_survey_revisions",
+		"agricultural_unit_surveys",
+		"agricultural_units",
+		"ingest_jobs",
+		"ingest_rejections",
+		"survey_schemas",
+		"weather",
+	}
+	if tables := tableNames(t, db); strings.Join(tables, ",") != strings.Join(expectedTables, ",") {
+		t.Errorf("expected tables %v, got %v", expectedTables, tables)
+	}
+
+	if applied, err := migrator.Up(ctx); err != nil || len(applied) != 0 {
+		t.Errorf("a second Up should apply nothing, got %+v, %v", applied, err)
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status returned an unexpected error: %v", err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("migration %04d_%s should be applied", status.Version, status.Name)
+		}
+	}
+
+	// Every down migration must undo its up migration, down to an empty schema.
+	for i := len(migrations) - 1; i >= 0; i-- {
+		reverted, err := migrator.Down(ctx, 1)
+		if err != nil {
+			t.Fatalf("Down returned an unexpected error: %v", err)
+		}
+		if len(reverted) != 1 || reverted[0].Version != migrations[i].Version {
+			t.Fatalf("expected migration %d to be reverted, got %+v", migrations[i].Version, reverted)
+		}
+	}
+	if tables := tableNames(t, db); len(tables) != 0 {
+		t.Errorf("expected no table left after reverting every migration, got %v", tables)
+	}
+	if reverted, err := migrator.Down(ctx, 1); err != nil || len(reverted) != 0 {
+		t.Errorf("Down on an empty schema should revert nothing, got %+v, %v", reverted, err)
+	}
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Up after reverting everything returned an unexpected error: %v", err)
+	}
+	if tables := tableNames(t, db); strings.Join(tables, ",") != strings.Join(expectedTables, ",") {
+		t.Errorf("expected tables %v after migrating again, got %v", expectedTables, tables)
+	}
+}
+
+func TestMigrator_Postgres_Concurrent(t *testing.T) {
+	db := openTestDatabase(t)
+
+	migrations, err := All()
+	if err != nil {
+		t.Fatalf("All returned an unexpected error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	total := 0
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			applied, err := NewMigrator(db, migrations).Up(context.Background())
+			if err != nil {
+				t.Errorf("Up returned an unexpected error: %v", err)
+			}
+			mu.Lock()
+			total += len(applied)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if total != len(migrations) {
+		t.Errorf("expected each of the %d migrations to be applied once, got %d applications", len(migrations), total)
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	db := openTestDatabase(t)
+	migrations, err := All()
+	if err != nil {
+		t.Fatalf("All returned an unexpected error: %v", err)
+	}
+	migrator := NewMigrator(db, migrations)
+	ctx := context.Background()
+
+	var output bytes.Buffer
+	if err := RunCommand(ctx, migrator, []string{"up"}, &output); err != nil {
+		t.Fatalf("migrate up returned an unexpected error: %v", err)
+	}
+	if !strings.Contains(output.String(), "Applied migration 0001_initial.") {
+		t.Errorf("expected migrate up to report 0001_initial, got %q", output.String())
+	}
+
+	output.Reset()
+	if err := RunCommand(ctx, migrator, []string{"down", "2"}, &output); err != nil {
+		t.Fatalf("migrate down returned an unexpected error: %v", err)
+	}
+	if strings.Count(output.String(), "Reverted migration") != 2 {
+		t.Errorf("expected migrate down 2 to revert 2 migrations, got %q", output.String())
+	}
+
+	output.Reset()
+	if err := RunCommand(ctx, migrator, []string{"status"}, &output); err != nil {
+		t.Fatalf("migrate status returned an unexpected error: %v", err)
+	}
+	if strings.Count(output.String(), "pending") != 2 {
+		t.Errorf("expected 2 pending migrations, got %q", output.String())
+	}
+}
+
+func TestRunCommand_InvalidArguments(t *testing.T) {
+	for _, args := range [][]string{nil, {"sideways"}, {"down", "zero"}, {"down", "0"}, {"up", "now"}} {
+		if err := RunCommand(context.Background(), nil, args, &bytes.Buffer{}); err == nil {
+			t.Errorf("expected an error for arguments %q", args)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS weather;
+DROP TABLE IF EXISTS agricultural_unit_surveys;
+DROP TABLE IF EXISTS agricultural_units;
@@ -0,0 +1,2 @@
+ALTER TABLE agricultural_unit_surveys DROP CONSTRAINT IF EXISTS agricultural_unit_surveys_id_num_year_key;
+ALTER TABLE agricultural_units DROP CONSTRAINT IF EXISTS agricultural_units_id_num_key;
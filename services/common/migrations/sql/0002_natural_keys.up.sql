@@ -0,0 +1,12 @@
+-- Fails on databases holding duplicate farms or surveys: merge them first with
+-- the agreste-ingestor dedup command, which adds the same constraints.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'agricultural_units_id_num_key') THEN
+        ALTER TABLE agricultural_units ADD CONSTRAINT agricultural_units_id_num_key UNIQUE (id_num);
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'agricultural_unit_surveys_id_num_year_key') THEN
+        ALTER TABLE agricultural_unit_surveys ADD CONSTRAINT agricultural_unit_surveys_id_num_year_key UNIQUE (id_num, year);
+    END IF;
+END
+$$;
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS agricultural_unit_survey_revisions;
+
+ALTER TABLE agricultural_unit_surveys
+    DROP COLUMN IF EXISTS source_version,
+    DROP COLUMN IF EXISTS source_file,
+    DROP COLUMN IF EXISTS source_url,
+    DROP COLUMN IF EXISTS revision;
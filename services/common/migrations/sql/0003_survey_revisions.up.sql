@@ -0,0 +1,23 @@
+ALTER TABLE agricultural_unit_surveys
+    ADD COLUMN IF NOT EXISTS revision INT NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS source_url TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS source_file TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS source_version TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS agricultural_unit_survey_revisions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    archived_at TIMESTAMPTZ NULL,
+
+    survey_id UUID NOT NULL,
+    id_num INT NOT NULL,
+    year INT NOT NULL,
+    revision INT NOT NULL,
+    data JSONB NOT NULL,
+    source_url TEXT NOT NULL DEFAULT '',
+    source_file TEXT NOT NULL DEFAULT '',
+    source_version TEXT NOT NULL DEFAULT '',
+
+    CONSTRAINT agricultural_unit_survey_revisions_survey_revision_key UNIQUE (survey_id, revision)
+);
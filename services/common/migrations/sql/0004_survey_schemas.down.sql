@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS survey_schemas;
+
+ALTER TABLE agricultural_unit_survey_revisions DROP COLUMN IF EXISTS schema_version;
+ALTER TABLE agricultural_unit_surveys DROP COLUMN IF EXISTS schema_version;
@@ -0,0 +1,12 @@
+ALTER TABLE agricultural_unit_surveys ADD COLUMN IF NOT EXISTS schema_version TEXT NOT NULL DEFAULT '';
+ALTER TABLE agricultural_unit_survey_revisions ADD COLUMN IF NOT EXISTS schema_version TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS survey_schemas (
+    version TEXT PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    from_year INT NOT NULL,
+    to_year INT NOT NULL,
+    columns JSONB NOT NULL
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS ingest_rejections;
+DROP TABLE IF EXISTS ingest_jobs;
@@ -0,0 +1,54 @@
+CREATE TABLE IF NOT EXISTS ingest_jobs (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    archived_at TIMESTAMPTZ NULL,
+
+    state TEXT NOT NULL,
+    zip_url TEXT NOT NULL,
+    csv_file_name TEXT NOT NULL,
+    csv_files JSONB NOT NULL DEFAULT '[]',
+    dialect JSONB NOT NULL DEFAULT '{}',
+    filter TEXT NOT NULL DEFAULT '',
+    mode TEXT NOT NULL DEFAULT 'insert',
+    expected_sha256 TEXT NOT NULL DEFAULT '',
+    force BOOLEAN NOT NULL DEFAULT FALSE,
+    started_at TIMESTAMPTZ NULL,
+    finished_at TIMESTAMPTZ NULL,
+    downloaded_bytes BIGINT NOT NULL DEFAULT 0,
+    download_total_bytes BIGINT NOT NULL DEFAULT 0,
+    rows_read INT NOT NULL DEFAULT 0,
+    rows_filtered INT NOT NULL DEFAULT 0,
+    rows_rejected INT NOT NULL DEFAULT 0,
+    units_created INT NOT NULL DEFAULT 0,
+    units_relocated INT NOT NULL DEFAULT 0,
+    surveys_created INT NOT NULL DEFAULT 0,
+    surveys_revised INT NOT NULL DEFAULT 0,
+    surveys_unchanged INT NOT NULL DEFAULT 0,
+    source_sha256 TEXT NOT NULL DEFAULT '',
+    unchanged_since UUID NULL,
+    detected_dialect JSONB NULL,
+    files JSONB NULL,
+    error TEXT NULL
+);
+
+CREATE INDEX IF NOT EXISTS ingest_jobs_state_idx ON ingest_jobs (state);
+CREATE INDEX IF NOT EXISTS ingest_jobs_source_idx ON ingest_jobs (zip_url, csv_file_name, finished_at DESC);
+
+CREATE TABLE IF NOT EXISTS ingest_rejections (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    archived_at TIMESTAMPTZ NULL,
+
+    run_id UUID NOT NULL,
+    file_name TEXT NOT NULL DEFAULT '',
+    row_number INT NOT NULL,
+    column_name TEXT NOT NULL DEFAULT '',
+    value TEXT NOT NULL DEFAULT '',
+    reason TEXT NOT NULL,
+    message TEXT NOT NULL DEFAULT '',
+    raw_values JSONB NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS ingest_rejections_run_id_idx ON ingest_rejections (run_id, file_name, row_number);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS agricultural_unit_surveys_year_idx;
+DROP INDEX IF EXISTS agricultural_units_location_idx;
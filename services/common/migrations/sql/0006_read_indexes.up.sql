@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS agricultural_units_location_idx ON agricultural_units (latitude, longitude);
+CREATE INDEX IF NOT EXISTS agricultural_unit_surveys_year_idx ON agricultural_unit_surveys (year, id_num);
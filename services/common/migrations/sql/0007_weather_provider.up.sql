@@ -0,0 +1,3 @@
+-- Rows stored before the provider was recorded all come from OpenWeather.
+ALTER TABLE weather ADD COLUMN IF NOT EXISTS provider TEXT NOT NULL DEFAULT 'openweather';
+ALTER TABLE weather ALTER COLUMN provider DROP DEFAULT;
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS daily_weather (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    archived_at TIMESTAMPTZ NULL,
+
+    agricultural_unit_id UUID NOT NULL,
+    date DATE NOT NULL,
+    provider TEXT NOT NULL,
+
+    latitude DOUBLE PRECISION NOT NULL,
+    longitude DOUBLE PRECISION NOT NULL,
+    temperature_min DOUBLE PRECISION NULL,
+    temperature_max DOUBLE PRECISION NULL,
+    temperature_mean DOUBLE PRECISION NULL,
+    precipitation DOUBLE PRECISION NULL,
+    radiation DOUBLE PRECISION NULL,
+    wind_speed_max DOUBLE PRECISION NULL,
+
+    CONSTRAINT daily_weather_agricultural_unit_id_date_key UNIQUE (agricultural_unit_id, date)
+);
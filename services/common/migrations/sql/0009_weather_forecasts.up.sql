@@ -0,0 +1,26 @@
+-- Each fetch of a unit's forecast is an issue: its steps share issued_at, so
+-- superseded issues can be compared with the weather later observed.
+CREATE TABLE IF NOT EXISTS weather_forecasts (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    archived_at TIMESTAMPTZ NULL,
+
+    agricultural_unit_id UUID NOT NULL,
+    issued_at TIMESTAMPTZ NOT NULL,
+    forecast_time TIMESTAMPTZ NOT NULL,
+    provider TEXT NOT NULL,
+
+    latitude DOUBLE PRECISION NOT NULL,
+    longitude DOUBLE PRECISION NOT NULL,
+    temperature DOUBLE PRECISION NOT NULL,
+    humidity INT NOT NULL,
+    wind_speed DOUBLE PRECISION NOT NULL,
+    clouds INT NOT NULL,
+    weather_main TEXT NOT NULL,
+    weather_desc TEXT NOT NULL,
+
+    CONSTRAINT weather_forecasts_unit_issue_time_key UNIQUE (agricultural_unit_id, issued_at, forecast_time)
+);
+
+CREATE INDEX IF NOT EXISTS weather_forecasts_issued_at_idx ON weather_forecasts (issued_at);
@@ -0,0 +1,3 @@
+-- The grid cell whose weather was fetched for the unit, NULL when it was
+-- fetched at the unit's own coordinates.
+ALTER TABLE weather ADD COLUMN IF NOT EXISTS cell_id TEXT;
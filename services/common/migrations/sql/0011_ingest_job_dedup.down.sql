@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS ingest_jobs_active_dedup_key_idx;
+ALTER TABLE ingest_jobs DROP COLUMN IF EXISTS dedup_key;
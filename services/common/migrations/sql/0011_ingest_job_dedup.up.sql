@@ -0,0 +1,7 @@
+-- The dedup key of the job, see IngestJob.DedupKey, NULL for jobs stored
+-- before it was recorded. At most one queued or running job holds a key, so
+-- two processes cannot both queue the same work.
+ALTER TABLE ingest_jobs ADD COLUMN IF NOT EXISTS dedup_key TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS ingest_jobs_active_dedup_key_idx ON ingest_jobs (dedup_key)
+    WHERE state IN ('queued', 'running');
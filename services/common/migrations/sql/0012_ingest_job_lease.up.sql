@@ -0,0 +1,4 @@
+-- Until when the process running the job holds it. The runner renews the
+-- lease while the job runs; a running job whose lease expired has lost its
+-- worker and is failed by the next process recovering jobs.
+ALTER TABLE ingest_jobs ADD COLUMN IF NOT EXISTS lease_expires_at TIMESTAMPTZ NULL;
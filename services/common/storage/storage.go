@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+type DBQuerier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// Transactor runs fn in a transaction, committed only when fn returns nil.
+type Transactor interface {
+	InTransaction(fn func(tx DBQuerier) error) error
+}
+
+type Database interface {
+	DBQuerier
+	Transactor
+}
+
+type realDBQuerier struct {
+	db *sql.DB
+}
+
+func NewRealDBQuerier(db *sql.DB) Database {
+	return &realDBQuerier{db: db}
+}
+
+func (r *realDBQuerier) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return r.db.Query(query, args...)
+}
+
+func (r *realDBQuerier) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return r.db.Exec(query, args...)
+}
+
+func (r *realDBQuerier) InTransaction(fn func(tx DBQuerier) error) error {
+	return RunInTransaction(r.db, fn)
+}
+
+type TxBeginner interface {
+	Begin() (*sql.Tx, error)
+}
+
+func RunInTransaction(db TxBeginner, fn func(tx DBQuerier) error) (err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return errors.Join(err, fmt.Errorf("failed to rollback transaction: %w", rollbackErr))
+		}
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
-package misc
+package testutils
 
 import (
+	"common/storage"
 	"database/sql"
 	"fmt"
 	"testing"
@@ -29,3 +30,7 @@ func (m *MockQuerier) Query(query string, args ...interface{}) (*sql.Rows, error
 func (m *MockQuerier) Exec(query string, args ...interface{}) (sql.Result, error) {
 	return m.Db.Exec(query, args...)
 }
+
+func (m *MockQuerier) InTransaction(fn func(tx storage.DBQuerier) error) error {
+	return storage.RunInTransaction(m.Db, fn)
+}
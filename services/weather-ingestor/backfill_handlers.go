@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"weather-ingestor/weather"
+)
+
+type BackfillRequestBody struct {
+	IDNums []int  `json:"idNums"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+func parseBackfillRequest(idNums []int, from, to string) (weather.BackfillRequest, error) {
+	request := weather.BackfillRequest{IDNums: idNums}
+	for _, bound := range []struct {
+		name  string
+		value string
+		date  *time.Time
+	}{
+		{"from", from, &request.From},
+		{"to", to, &request.To},
+	} {
+		date, err := time.Parse("2006-01-02", bound.value)
+		if err != nil {
+			return weather.BackfillRequest{}, fmt.Errorf("invalid '%s' date '%s', expected YYYY-MM-DD", bound.name, bound.value)
+		}
+		*bound.date = date
+	}
+	if err := request.Validate(time.Now().UTC()); err != nil {
+		return weather.BackfillRequest{}, err
+	}
+	return request, nil
+}
+
+func (a *App) newBackfiller() *weather.WeatherBackfiller {
+	return weather.NewWeatherBackfiller(a.ArchiveProvider, a.DailyWeatherStorage, a.AgriUnitStorage, a.ArchiveFetcherConfig)
+}
+
+// BackfillHandler starts a backfill of daily weather in the background, or answers 409 while one runs.
+func (a *App) BackfillHandler(w http.ResponseWriter, r *http.Request) {
+	var body BackfillRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, fmt.Sprintf("Error reading JSON request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	request, err := parseBackfillRequest(body.IDNums, body.From, body.To)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid backfill request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	backfiller := a.newBackfiller()
+	run, started := a.backfillRuns.start(func(ctx context.Context) (interface{}, error) {
+		return backfiller.Backfill(ctx, request)
+	})
+	if !started {
+		writeJSON(w, http.StatusConflict, run)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, run)
+}
+
+// BackfillStatusHandler returns the latest backfill, running or finished.
+func (a *App) BackfillStatusHandler(w http.ResponseWriter, r *http.Request) {
+	run, exists := a.backfillRuns.status()
+	if !exists {
+		http.Error(w, "No weather backfill has run yet.", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, run)
+}
+
+// CancelBackfillHandler cancels the running backfill.
+func (a *App) CancelBackfillHandler(w http.ResponseWriter, r *http.Request) {
+	run, running := a.backfillRuns.stop()
+	if !running {
+		http.Error(w, "No weather backfill is running.", http.StatusConflict)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, run)
+}
+
+func (a *App) runBackfillCommand(ctx context.Context, args []string) (weather.BackfillSummary, error) {
+	if len(args) < 2 {
+		return weather.BackfillSummary{}, fmt.Errorf("usage: backfill FROM TO [IDNUM...], with YYYY-MM-DD dates")
+	}
+
+	var idNums []int
+	for _, arg := range args[2:] {
+		idNum, err := strconv.Atoi(arg)
+		if err != nil {
+			return weather.BackfillSummary{}, fmt.Errorf("invalid IDNUM '%s'", arg)
+		}
+		idNums = append(idNums, idNum)
+	}
+
+	request, err := parseBackfillRequest(idNums, args[0], args[1])
+	if err != nil {
+		return weather.BackfillSummary{}, err
+	}
+	return a.newBackfiller().Backfill(ctx, request)
+}
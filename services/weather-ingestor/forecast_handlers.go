@@ -0,0 +1,92 @@
+package main
+
+import (
+	"common/agri"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+	"weather-ingestor/weather"
+
+	"github.com/google/uuid"
+)
+
+type ForecastStep struct {
+	Time        time.Time `json:"time"`
+	Temperature float64   `json:"temperature"`
+	Humidity    int       `json:"humidity"`
+	WindSpeed   float64   `json:"wind_speed"`
+	Clouds      int       `json:"clouds"`
+	WeatherMain string    `json:"weather_main"`
+	WeatherDesc string    `json:"weather_desc"`
+}
+
+type ForecastResponse struct {
+	UnitID   uuid.UUID      `json:"unitId"`
+	IssuedAt time.Time      `json:"issuedAt"`
+	Provider string         `json:"provider"`
+	Steps    []ForecastStep `json:"steps"`
+}
+
+func (a *App) loadUnit(w http.ResponseWriter, r *http.Request) (agri.AgriculturalUnit, bool) {
+	rawID := r.PathValue("id")
+
+	unit, err := agri.SelectByIDOrIDNum(a.AgriUnitStorage, rawID)
+	if errors.Is(err, agri.ErrInvalidAgriculturalUnitID) {
+		http.Error(w, fmt.Sprintf("Invalid unit ID '%s', expected a UUID or an IDNUM", rawID), http.StatusBadRequest)
+		return unit, false
+	}
+	if errors.Is(err, agri.ErrAgriculturalUnitNotFound) {
+		http.Error(w, fmt.Sprintf("Agricultural unit '%s' not found", rawID), http.StatusNotFound)
+		return unit, false
+	}
+	if err != nil {
+		log.Printf("Error loading agricultural unit %s: %v\n", rawID, err)
+		http.Error(w, fmt.Sprintf("Error loading agricultural unit: %v", err), http.StatusInternalServerError)
+		return unit, false
+	}
+	return unit, true
+}
+
+// ForecastHandler returns the latest forecast issued for a unit, in time order.
+func (a *App) ForecastHandler(w http.ResponseWriter, r *http.Request) {
+	unit, ok := a.loadUnit(w, r)
+	if !ok {
+		return
+	}
+
+	steps, err := a.ForecastStorage.SelectLatest(unit.ID)
+	if err != nil {
+		log.Printf("Error loading the forecast of unit %s: %v\n", unit.ID, err)
+		http.Error(w, fmt.Sprintf("Error loading the forecast: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if len(steps) == 0 {
+		http.Error(w, fmt.Sprintf("No forecast for agricultural unit '%s' yet", r.PathValue("id")), http.StatusNotFound)
+		return
+	}
+
+	response := ForecastResponse{
+		UnitID:   unit.ID,
+		IssuedAt: steps[0].IssuedAt,
+		Provider: steps[0].Provider,
+		Steps:    make([]ForecastStep, len(steps)),
+	}
+	for i, step := range steps {
+		response.Steps[i] = forecastStep(step)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func forecastStep(f weather.WeatherForecast) ForecastStep {
+	return ForecastStep{
+		Time:        f.ForecastTime,
+		Temperature: f.Temperature,
+		Humidity:    f.Humidity,
+		WindSpeed:   f.WindSpeed,
+		Clouds:      f.Clouds,
+		WeatherMain: f.WeatherMain,
+		WeatherDesc: f.WeatherDesc,
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+	"weather-ingestor/weather"
+)
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("Error encoding JSON response: %v\n", err)
+	}
+}
+
+// IngestionHandler starts a weather ingestion in the background, or answers 409 while one runs.
+func (a *App) IngestionHandler(w http.ResponseWriter, r *http.Request) {
+	fetcher := weather.NewWeatherFetcher(a.Provider, a.WeatherStorage, a.ForecastStorage, a.AgriUnitStorage, a.FetcherConfig)
+	run, started := a.ingestRuns.start(func(ctx context.Context) (interface{}, error) {
+		summary, err := fetcher.HandleWeatherIngest(ctx)
+		a.pruneForecasts()
+		return summary, err
+	})
+	if !started {
+		writeJSON(w, http.StatusConflict, run)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, run)
+}
+
+// IngestionStatusHandler returns the latest run, running or finished.
+func (a *App) IngestionStatusHandler(w http.ResponseWriter, r *http.Request) {
+	run, exists := a.ingestRuns.status()
+	if !exists {
+		http.Error(w, "No weather ingestion has run yet.", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, run)
+}
+
+// CancelIngestionHandler cancels the running ingestion.
+func (a *App) CancelIngestionHandler(w http.ResponseWriter, r *http.Request) {
+	run, running := a.ingestRuns.stop()
+	if !running {
+		http.Error(w, "No weather ingestion is running.", http.StatusConflict)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, run)
+}
+
+func (a *App) pruneForecasts() {
+	if a.ForecastRetention <= 0 {
+		return
+	}
+	deleted, err := a.ForecastStorage.DeleteSuperseded(time.Now().Add(-a.ForecastRetention))
+	if err != nil {
+		log.Printf("Error deleting superseded forecasts: %v\n", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("Deleted %d superseded forecast steps.\n", deleted)
+	}
+}
@@ -1,42 +1,116 @@
 package main
 
 import (
+	"common/agri"
+	"common/migrations"
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 	"weather-ingestor/weather"
 
 	_ "github.com/lib/pq"
 )
 
 type App struct {
-	AgriUnitStorage weather.AgriUnitStorage
-	WeatherStorage  weather.WeatherStorage
-	apiURL          string
-	apiKey          string
+	AgriUnitStorage     agri.AgriUnitStorage
+	WeatherStorage      weather.WeatherStorage
+	DailyWeatherStorage weather.DailyWeatherStorage
+	ForecastStorage     weather.WeatherForecastStorage
+	FetcherConfig       weather.FetcherConfig
+	Provider            weather.WeatherProvider
+	// ForecastRetention is how long a superseded forecast is kept, forever when zero.
+	ForecastRetention time.Duration
+	// ArchiveProvider serves the backfills, within ArchiveFetcherConfig.
+	ArchiveProvider      weather.WeatherProvider
+	ArchiveFetcherConfig weather.FetcherConfig
+
+	ingestRuns   runTracker
+	backfillRuns runTracker
 }
 
-func (a *App) IngestionHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed. Only POST method is supported.", http.StatusMethodNotAllowed)
-		return
+func loadFetcherConfig() (weather.FetcherConfig, error) {
+	config := weather.FetcherConfig{Concurrency: weather.DefaultConcurrency}
+	limit := weather.RateLimit{PerMinute: defaultCallsPerMinute}
+
+	for _, setting := range []struct {
+		name  string
+		value *int
+	}{
+		{"WEATHER_CONCURRENCY", &config.Concurrency},
+		{"WEATHER_FORECAST_DAYS", &config.ForecastDays},
+		{"WEATHER_GRID_PRECISION", &config.GridPrecision},
+		{"WEATHER_CALLS_PER_MINUTE", &limit.PerMinute},
+		{"WEATHER_CALLS_PER_DAY", &limit.PerDay},
+	} {
+		raw := os.Getenv(setting.name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 0 {
+			return weather.FetcherConfig{}, fmt.Errorf("invalid %s '%s', expected a non-negative number", setting.name, raw)
+		}
+		*setting.value = value
+	}
+	if config.GridPrecision > weather.MaxGridPrecision {
+		return weather.FetcherConfig{}, fmt.Errorf("invalid WEATHER_GRID_PRECISION %d, expected at most %d", config.GridPrecision, weather.MaxGridPrecision)
 	}
 
-	fetcher := weather.NewWeatherFetcher(a.apiURL, a.apiKey, a.WeatherStorage, a.AgriUnitStorage)
+	config.Limiter = weather.NewRateLimiter(limit)
+	return config, nil
+}
 
-	err := fetcher.HandleWeatherIngest()
+func loadForecastRetention() (time.Duration, error) {
+	raw := os.Getenv("FORECAST_RETENTION_DAYS")
+	if raw == "" {
+		return defaultForecastRetentionDays * 24 * time.Hour, nil
+	}
+	days, err := strconv.Atoi(raw)
+	if err != nil || days < 0 {
+		return 0, fmt.Errorf("invalid FORECAST_RETENTION_DAYS '%s', expected a non-negative number", raw)
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
 
-	if err != nil {
-		log.Printf("Error during ingestion: %v\n", err)
-		http.Error(w, fmt.Sprintf("Error processing ingestion: %v", err), http.StatusInternalServerError)
-		return
+func loadProvider() (weather.WeatherProvider, error) {
+	switch name := os.Getenv("WEATHER_PROVIDER"); name {
+	case "", weather.ProviderOpenWeather:
+		return weather.NewOpenWeatherProvider(os.Getenv("API_URL"), os.Getenv("API_KEY")), nil
+	case weather.ProviderOpenMeteo:
+		return loadOpenMeteoProvider(), nil
+	default:
+		return nil, fmt.Errorf("unknown WEATHER_PROVIDER '%s', expected '%s' or '%s'", name, weather.ProviderOpenWeather, weather.ProviderOpenMeteo)
 	}
+}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Data weather ingestion started successfully.\n"))
-	log.Println("Ingestion completed successfully.")
+func loadOpenMeteoProvider() weather.WeatherProvider {
+	return weather.NewOpenMeteoProvider(weather.OpenMeteoConfig{
+		URL:        os.Getenv("OPEN_METEO_URL"),
+		ArchiveURL: os.Getenv("OPEN_METEO_ARCHIVE_URL"),
+		APIKey:     os.Getenv("OPEN_METEO_API_KEY"),
+	})
+}
+
+// defaultCallsPerMinute is the quota of OpenWeather's free plan.
+const defaultCallsPerMinute = 60
+
+const defaultForecastRetentionDays = 30
+
+var openMeteoFreeLimit = weather.RateLimit{PerMinute: 60, PerDay: 10_000}
+
+func loadArchiveProvider(provider weather.WeatherProvider, config weather.FetcherConfig) (weather.WeatherProvider, weather.FetcherConfig) {
+	if provider.Name() == weather.ProviderOpenMeteo {
+		return provider, config
+	}
+	config.Limiter = weather.NewRateLimiter(openMeteoFreeLimit)
+	return loadOpenMeteoProvider(), config
 }
 
 func main() {
@@ -64,20 +138,79 @@ func main() {
 	}
 	log.Println("PostgreSQL database connection established successfully.")
 
-	realAgriUnitStorage := weather.NewAgriUnitStorage(db)
+	schemaMigrations, err := migrations.All()
+	if err != nil {
+		log.Fatalf("Failed to load database migrations: %v", err)
+	}
+	migrator := migrations.NewMigrator(db, schemaMigrations)
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrations.RunCommand(context.Background(), migrator, os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	applied, err := migrator.Up(context.Background())
+	for _, migration := range applied {
+		log.Printf("Applied database migration %04d_%s.\n", migration.Version, migration.Name)
+	}
+	if err != nil {
+		log.Fatalf("Failed to migrate the database schema: %v", err)
+	}
+
+	realAgriUnitStorage := agri.NewAgriUnitStorage(db)
 	realWeatherStorage := weather.NewWeatherStorage(db)
+	realDailyWeatherStorage := weather.NewDailyWeatherStorage(db)
+	realForecastStorage := weather.NewWeatherForecastStorage(db)
 
-	apiUrl := os.Getenv("API_URL")
-	apiKey := os.Getenv("API_KEY")
+	provider, err := loadProvider()
+	if err != nil {
+		log.Fatalf("Invalid weather provider configuration: %v", err)
+	}
+	fetcherConfig, err := loadFetcherConfig()
+	if err != nil {
+		log.Fatalf("Invalid weather fetching configuration: %v", err)
+	}
+	forecastRetention, err := loadForecastRetention()
+	if err != nil {
+		log.Fatalf("Invalid forecast retention: %v", err)
+	}
+
+	archiveProvider, archiveFetcherConfig := loadArchiveProvider(provider, fetcherConfig)
 
 	app := &App{
-		AgriUnitStorage: realAgriUnitStorage,
-		WeatherStorage:  realWeatherStorage,
-		apiURL:          apiUrl,
-		apiKey:          apiKey,
+		AgriUnitStorage:      realAgriUnitStorage,
+		WeatherStorage:       realWeatherStorage,
+		DailyWeatherStorage:  realDailyWeatherStorage,
+		ForecastStorage:      realForecastStorage,
+		FetcherConfig:        fetcherConfig,
+		ForecastRetention:    forecastRetention,
+		Provider:             provider,
+		ArchiveProvider:      archiveProvider,
+		ArchiveFetcherConfig: archiveFetcherConfig,
+		ingestRuns:           runTracker{name: "Weather ingestion"},
+		backfillRuns:         runTracker{name: "Weather backfill"},
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		summary, err := app.runBackfillCommand(ctx, os.Args[2:])
+		log.Printf("Backfill summary: %+v\n", summary)
+		if err != nil {
+			log.Fatalf("Backfill failed: %v", err)
+		}
+		return
 	}
 
-	http.HandleFunc("/ingest", app.IngestionHandler)
+	http.HandleFunc("POST /ingest", app.IngestionHandler)
+	http.HandleFunc("GET /ingest", app.IngestionStatusHandler)
+	http.HandleFunc("DELETE /ingest", app.CancelIngestionHandler)
+	http.HandleFunc("GET /units/{id}/forecast", app.ForecastHandler)
+	http.HandleFunc("POST /backfill", app.BackfillHandler)
+	http.HandleFunc("GET /backfill", app.BackfillStatusHandler)
+	http.HandleFunc("DELETE /backfill", app.CancelBackfillHandler)
 
 	port := ":8080"
 	log.Printf("Server started on port %s\n", port)
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Run is the state of a background weather ingestion or backfill.
+type Run struct {
+	Running    bool        `json:"running"`
+	StartedAt  time.Time   `json:"startedAt"`
+	FinishedAt *time.Time  `json:"finishedAt,omitempty"`
+	Summary    interface{} `json:"summary,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+type runTracker struct {
+	name string
+
+	mu     sync.Mutex
+	latest *Run
+	cancel context.CancelFunc
+}
+
+func (t *runTracker) start(work func(ctx context.Context) (interface{}, error)) (run Run, started bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.latest != nil && t.latest.Running {
+		return *t.latest, false
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	latest := &Run{Running: true, StartedAt: time.Now()}
+	t.latest = latest
+	t.cancel = cancel
+
+	go func() {
+		defer cancel()
+		summary, err := work(ctx)
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		finishedAt := time.Now()
+		latest.Running = false
+		latest.FinishedAt = &finishedAt
+		latest.Summary = summary
+		if err != nil {
+			latest.Error = err.Error()
+			log.Printf("%s stopped: %v (%+v)\n", t.name, err, summary)
+			return
+		}
+		log.Printf("%s completed: %+v\n", t.name, summary)
+	}()
+
+	return *latest, true
+}
+
+func (t *runTracker) status() (Run, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.latest == nil {
+		return Run{}, false
+	}
+	return *t.latest, true
+}
+
+func (t *runTracker) stop() (Run, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.latest == nil || !t.latest.Running {
+		return Run{}, false
+	}
+	t.cancel()
+	return *t.latest, true
+}
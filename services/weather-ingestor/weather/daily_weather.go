@@ -0,0 +1,55 @@
+package weather
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DailyWeather is the observed weather of a unit on a UTC day.
+type DailyWeather struct {
+	ID                 uuid.UUID  `json:"id"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	UpdatedAt          time.Time  `json:"updatedAt"`
+	ArchivedAt         *time.Time `json:"archivedAt,omitempty"`
+	AgriculturalUnitId uuid.UUID  `json:"agricultural_unit_id"`
+	Date               time.Time  `json:"date"`
+	Provider           string     `json:"provider"`
+	Latitude           float64    `json:"latitude"`
+	Longitude          float64    `json:"longitude"`
+	TemperatureMin     *float64   `json:"temperature_min"`
+	TemperatureMax     *float64   `json:"temperature_max"`
+	TemperatureMean    *float64   `json:"temperature_mean"`
+	Precipitation      *float64   `json:"precipitation"`
+	Radiation          *float64   `json:"radiation"`
+	WindSpeedMax       *float64   `json:"wind_speed_max"`
+}
+
+type DailyWeatherValue struct {
+	AgriculturalUnitId uuid.UUID
+	Provider           string
+	Latitude           float64
+	Longitude          float64
+	Conditions         DailyConditions
+}
+
+func CreateDailyWeather(value DailyWeatherValue) DailyWeather {
+	now := time.Now()
+	conditions := value.Conditions
+	return DailyWeather{
+		ID:                 uuid.New(),
+		CreatedAt:          now,
+		UpdatedAt:          now,
+		AgriculturalUnitId: value.AgriculturalUnitId,
+		Date:               conditions.Date,
+		Provider:           value.Provider,
+		Latitude:           value.Latitude,
+		Longitude:          value.Longitude,
+		TemperatureMin:     conditions.TemperatureMin,
+		TemperatureMax:     conditions.TemperatureMax,
+		TemperatureMean:    conditions.TemperatureMean,
+		Precipitation:      conditions.Precipitation,
+		Radiation:          conditions.Radiation,
+		WindSpeedMax:       conditions.WindSpeed,
+	}
+}
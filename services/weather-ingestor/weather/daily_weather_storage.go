@@ -0,0 +1,218 @@
+package weather
+
+import (
+	"common/storage"
+	"database/sql"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+type DailyWeatherSqlView struct {
+	ID                 uuid.UUID       `db:"id"`
+	CreatedAt          time.Time       `db:"created_at"`
+	UpdatedAt          time.Time       `db:"updated_at"`
+	ArchivedAt         sql.NullTime    `db:"archived_at"`
+	AgriculturalUnitId uuid.UUID       `db:"agricultural_unit_id"`
+	Date               time.Time       `db:"date"`
+	Provider           string          `db:"provider"`
+	Latitude           float64         `db:"latitude"`
+	Longitude          float64         `db:"longitude"`
+	TemperatureMin     sql.NullFloat64 `db:"temperature_min"`
+	TemperatureMax     sql.NullFloat64 `db:"temperature_max"`
+	TemperatureMean    sql.NullFloat64 `db:"temperature_mean"`
+	Precipitation      sql.NullFloat64 `db:"precipitation"`
+	Radiation          sql.NullFloat64 `db:"radiation"`
+	WindSpeedMax       sql.NullFloat64 `db:"wind_speed_max"`
+}
+
+func toNullFloat64(value *float64) sql.NullFloat64 {
+	if value == nil {
+		return sql.NullFloat64{Valid: false}
+	}
+	return sql.NullFloat64{Float64: *value, Valid: true}
+}
+
+func fromNullFloat64(value sql.NullFloat64) *float64 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Float64
+}
+
+func DailyWeatherToSqlView(d DailyWeather) DailyWeatherSqlView {
+	var archived sql.NullTime
+	if d.ArchivedAt != nil {
+		archived = sql.NullTime{Time: *d.ArchivedAt, Valid: true}
+	} else {
+		archived = sql.NullTime{Valid: false}
+	}
+
+	return DailyWeatherSqlView{
+		ID:                 d.ID,
+		CreatedAt:          d.CreatedAt,
+		UpdatedAt:          d.UpdatedAt,
+		ArchivedAt:         archived,
+		AgriculturalUnitId: d.AgriculturalUnitId,
+		Date:               d.Date,
+		Provider:           d.Provider,
+		Latitude:           d.Latitude,
+		Longitude:          d.Longitude,
+		TemperatureMin:     toNullFloat64(d.TemperatureMin),
+		TemperatureMax:     toNullFloat64(d.TemperatureMax),
+		TemperatureMean:    toNullFloat64(d.TemperatureMean),
+		Precipitation:      toNullFloat64(d.Precipitation),
+		Radiation:          toNullFloat64(d.Radiation),
+		WindSpeedMax:       toNullFloat64(d.WindSpeedMax),
+	}
+}
+
+func DailyWeatherFromSqlView(sqlView DailyWeatherSqlView) DailyWeather {
+	var archivedAt *time.Time
+	if sqlView.ArchivedAt.Valid {
+		archivedAt = &sqlView.ArchivedAt.Time
+	}
+
+	return DailyWeather{
+		ID:                 sqlView.ID,
+		CreatedAt:          sqlView.CreatedAt,
+		UpdatedAt:          sqlView.UpdatedAt,
+		ArchivedAt:         archivedAt,
+		AgriculturalUnitId: sqlView.AgriculturalUnitId,
+		Date:               sqlView.Date,
+		Provider:           sqlView.Provider,
+		Latitude:           sqlView.Latitude,
+		Longitude:          sqlView.Longitude,
+		TemperatureMin:     fromNullFloat64(sqlView.TemperatureMin),
+		TemperatureMax:     fromNullFloat64(sqlView.TemperatureMax),
+		TemperatureMean:    fromNullFloat64(sqlView.TemperatureMean),
+		Precipitation:      fromNullFloat64(sqlView.Precipitation),
+		Radiation:          fromNullFloat64(sqlView.Radiation),
+		WindSpeedMax:       fromNullFloat64(sqlView.WindSpeedMax),
+	}
+}
+
+type DailyWeatherStorage interface {
+	// InsertOrUpdateBatch upserts days on their unit and date.
+	InsertOrUpdateBatch(days []DailyWeather) error
+	// SelectDates returns the dates stored for a unit from from to to included, in order.
+	SelectDates(unitID uuid.UUID, from, to time.Time) ([]time.Time, error)
+}
+
+const maxDaysPerStatement = 1000
+
+const dailyWeatherUpsertSuffix = `
+			ON CONFLICT (agricultural_unit_id, date) DO UPDATE SET
+				updated_at = EXCLUDED.updated_at,
+				archived_at = EXCLUDED.archived_at,
+				provider = EXCLUDED.provider,
+				latitude = EXCLUDED.latitude,
+				longitude = EXCLUDED.longitude,
+				temperature_min = EXCLUDED.temperature_min,
+				temperature_max = EXCLUDED.temperature_max,
+				temperature_mean = EXCLUDED.temperature_mean,
+				precipitation = EXCLUDED.precipitation,
+				radiation = EXCLUDED.radiation,
+				wind_speed_max = EXCLUDED.wind_speed_max
+		`
+
+type dailyWeatherStorage struct {
+	querier storage.DBQuerier
+	builder sq.StatementBuilderType
+}
+
+func NewDailyWeatherStorage(querier storage.DBQuerier) DailyWeatherStorage {
+	return &dailyWeatherStorage{
+		querier: querier,
+		builder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+}
+
+func (s *dailyWeatherStorage) InsertOrUpdateBatch(days []DailyWeather) error {
+	for start := 0; start < len(days); start += maxDaysPerStatement {
+		end := min(start+maxDaysPerStatement, len(days))
+
+		builder := s.builder.Insert("daily_weather").
+			Columns(
+				"id",
+				"created_at",
+				"updated_at",
+				"archived_at",
+				"agricultural_unit_id",
+				"date",
+				"provider",
+				"latitude",
+				"longitude",
+				"temperature_min",
+				"temperature_max",
+				"temperature_mean",
+				"precipitation",
+				"radiation",
+				"wind_speed_max",
+			)
+		for _, day := range days[start:end] {
+			sqlView := DailyWeatherToSqlView(day)
+			builder = builder.Values(
+				sqlView.ID,
+				sqlView.CreatedAt,
+				sqlView.UpdatedAt,
+				sqlView.ArchivedAt,
+				sqlView.AgriculturalUnitId,
+				sqlView.Date,
+				sqlView.Provider,
+				sqlView.Latitude,
+				sqlView.Longitude,
+				sqlView.TemperatureMin,
+				sqlView.TemperatureMax,
+				sqlView.TemperatureMean,
+				sqlView.Precipitation,
+				sqlView.Radiation,
+				sqlView.WindSpeedMax,
+			)
+		}
+
+		query, args, err := builder.Suffix(dailyWeatherUpsertSuffix).ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build InsertOrUpdateBatch SQL for DailyWeather: %w", err)
+		}
+
+		if _, err := s.querier.Exec(query, args...); err != nil {
+			return fmt.Errorf("failed to execute InsertOrUpdateBatch for DailyWeather: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *dailyWeatherStorage) SelectDates(unitID uuid.UUID, from, to time.Time) ([]time.Time, error) {
+	query, args, err := s.builder.Select("date").
+		From("daily_weather").
+		Where(sq.Eq{"agricultural_unit_id": unitID}).
+		Where(sq.GtOrEq{"date": from}).
+		Where(sq.LtOrEq{"date": to}).
+		OrderBy("date").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build SelectDates SQL for DailyWeather: %w", err)
+	}
+
+	rows, err := s.querier.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute SelectDates for DailyWeather: %w", err)
+	}
+	defer rows.Close()
+
+	var dates []time.Time
+	for rows.Next() {
+		var date time.Time
+		if err := rows.Scan(&date); err != nil {
+			return nil, fmt.Errorf("failed to scan daily weather date: %w", err)
+		}
+		dates = append(dates, date.UTC())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over daily weather dates: %w", err)
+	}
+	return dates, nil
+}
@@ -0,0 +1,148 @@
+package weather
+
+import (
+	"common/testutils"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+func TestDailyWeatherToSqlViewAndBack(t *testing.T) {
+	now := time.Now().Truncate(time.Millisecond)
+	mean := 17.8
+	precipitation := 0.0
+
+	day := DailyWeather{
+		ID:                 uuid.New(),
+		CreatedAt:          now,
+		UpdatedAt:          now,
+		AgriculturalUnitId: uuid.New(),
+		Date:               time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC),
+		Provider:           ProviderOpenMeteo,
+		Latitude:           45.76,
+		Longitude:          4.85,
+		TemperatureMean:    &mean,
+		Precipitation:      &precipitation,
+	}
+
+	sqlView := DailyWeatherToSqlView(day)
+	if !sqlView.TemperatureMean.Valid || sqlView.TemperatureMean.Float64 != mean {
+		t.Errorf("TemperatureMean mismatch: got %v want %v", sqlView.TemperatureMean, mean)
+	}
+	if !sqlView.Precipitation.Valid {
+		t.Error("a zero Precipitation should be valid")
+	}
+	if sqlView.Radiation.Valid {
+		t.Error("a missing Radiation should be null")
+	}
+	if sqlView.ArchivedAt.Valid {
+		t.Error("ArchivedAt.Valid should be false when ArchivedAt is nil")
+	}
+
+	converted := DailyWeatherFromSqlView(sqlView)
+	if converted.ID != day.ID || !converted.Date.Equal(day.Date) || converted.Provider != day.Provider || converted.AgriculturalUnitId != day.AgriculturalUnitId {
+		t.Errorf("converted day mismatch: got %+v want %+v", converted, day)
+	}
+	if converted.TemperatureMean == nil || *converted.TemperatureMean != mean {
+		t.Errorf("converted TemperatureMean mismatch: got %v want %v", converted.TemperatureMean, mean)
+	}
+	if converted.Radiation != nil || converted.TemperatureMin != nil {
+		t.Errorf("expected missing measures to stay nil, got %+v", converted)
+	}
+}
+
+func TestDailyWeatherStorage_InsertOrUpdateBatch(t *testing.T) {
+	mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+	if err != nil {
+		t.Fatalf("failed to create mock querier: %v", err)
+	}
+	defer mockQuerierInstance.Db.Close()
+
+	now := time.Now().Truncate(time.Millisecond)
+	unitID := uuid.New()
+	maxTemperature := 24.5
+	day := DailyWeather{
+		ID:                 uuid.New(),
+		CreatedAt:          now,
+		UpdatedAt:          now,
+		AgriculturalUnitId: unitID,
+		Date:               time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC),
+		Provider:           ProviderOpenMeteo,
+		Latitude:           45.76,
+		Longitude:          4.85,
+		TemperatureMax:     &maxTemperature,
+	}
+
+	expectedSQL := "INSERT INTO daily_weather (id,created_at,updated_at,archived_at,agricultural_unit_id,date,provider,latitude,longitude," +
+		"temperature_min,temperature_max,temperature_mean,precipitation,radiation,wind_speed_max) " +
+		"VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15) " +
+		"ON CONFLICT (agricultural_unit_id, date) DO UPDATE SET updated_at = EXCLUDED.updated_at"
+	sqlMock.ExpectExec(regexp.QuoteMeta(expectedSQL)).
+		WithArgs(
+			day.ID,
+			day.CreatedAt,
+			day.UpdatedAt,
+			sql.NullTime{Valid: false},
+			unitID,
+			day.Date,
+			ProviderOpenMeteo,
+			45.76,
+			4.85,
+			sql.NullFloat64{Valid: false},
+			sql.NullFloat64{Float64: maxTemperature, Valid: true},
+			sql.NullFloat64{Valid: false},
+			sql.NullFloat64{Valid: false},
+			sql.NullFloat64{Valid: false},
+			sql.NullFloat64{Valid: false},
+		).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := NewDailyWeatherStorage(mockQuerierInstance).InsertOrUpdateBatch([]DailyWeather{day}); err != nil {
+		t.Fatalf("InsertOrUpdateBatch returned an unexpected error: %v", err)
+	}
+
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDailyWeatherStorage_InsertOrUpdateBatch_Empty(t *testing.T) {
+	if err := NewDailyWeatherStorage(nil).InsertOrUpdateBatch(nil); err != nil {
+		t.Errorf("InsertOrUpdateBatch of no days returned an unexpected error: %v", err)
+	}
+}
+
+func TestDailyWeatherStorage_SelectDates(t *testing.T) {
+	mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+	if err != nil {
+		t.Fatalf("failed to create mock querier: %v", err)
+	}
+	defer mockQuerierInstance.Db.Close()
+
+	unitID := uuid.New()
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	expectedSQL := "SELECT date FROM daily_weather WHERE agricultural_unit_id = $1 AND date >= $2 AND date <= $3 ORDER BY date"
+	sqlMock.ExpectQuery(regexp.QuoteMeta(expectedSQL)).
+		WithArgs(unitID, from, to).
+		WillReturnRows(sqlMock.NewRows([]string{"date"}).
+			AddRow(time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)).
+			AddRow(time.Date(2023, 3, 2, 0, 0, 0, 0, time.UTC)))
+
+	dates, err := NewDailyWeatherStorage(mockQuerierInstance).SelectDates(unitID, from, to)
+	if err != nil {
+		t.Fatalf("SelectDates returned an unexpected error: %v", err)
+	}
+	if len(dates) != 2 || !dates[1].Equal(time.Date(2023, 3, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected 2023-03-01 and 2023-03-02, got %v", dates)
+	}
+
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
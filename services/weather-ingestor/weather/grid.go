@@ -0,0 +1,35 @@
+package weather
+
+// MaxGridPrecision is the longest geohash, cells of a few centimetres.
+const MaxGridPrecision = 12
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// geohashCell returns the geohash of the cell holding a point, and its center.
+func geohashCell(lat, lon float64, precision int) (string, float64, float64) {
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+
+	hash := make([]byte, precision)
+	// Bits alternate between longitude and latitude, longitude first.
+	bit := 0
+	for i := range hash {
+		var index byte
+		for range 5 {
+			bounds, value := &lonRange, lon
+			if bit%2 == 1 {
+				bounds, value = &latRange, lat
+			}
+			index <<= 1
+			if mid := (bounds[0] + bounds[1]) / 2; value >= mid {
+				index |= 1
+				bounds[0] = mid
+			} else {
+				bounds[1] = mid
+			}
+			bit++
+		}
+		hash[i] = geohashAlphabet[index]
+	}
+	return string(hash), (latRange[0] + latRange[1]) / 2, (lonRange[0] + lonRange[1]) / 2
+}
@@ -0,0 +1,46 @@
+package weather
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGeohashCell(t *testing.T) {
+	tests := []struct {
+		name      string
+		lat, lon  float64
+		precision int
+		want      string
+	}{
+		{"reference point", 57.64911, 10.40744, 11, "u4pruydqqvj"},
+		{"Lyon", 45.7640, 4.8357, 6, "u05kq5"},
+		{"south west corner", -90, -180, 4, "0000"},
+		{"north east corner", 90, 180, 4, "zzzz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cell, lat, lon := geohashCell(tt.lat, tt.lon, tt.precision)
+			if cell != tt.want {
+				t.Errorf("expected cell %s, got %s", tt.want, cell)
+			}
+			// The center is in the cell, so it hashes to the same one.
+			if centerCell, _, _ := geohashCell(lat, lon, tt.precision); centerCell != cell {
+				t.Errorf("expected the center (%v, %v) in cell %s, got %s", lat, lon, cell, centerCell)
+			}
+		})
+	}
+}
+
+func TestGeohashCell_Neighbours(t *testing.T) {
+	// Two farms 200 m apart share a cell, a third 5 km away does not.
+	cell, lat, lon := geohashCell(45.7640, 4.8357, 6)
+	if near, _, _ := geohashCell(45.7650, 4.8370, 6); near != cell {
+		t.Errorf("expected a neighbour in cell %s, got %s", cell, near)
+	}
+	if far, _, _ := geohashCell(45.8090, 4.8357, 6); far == cell {
+		t.Errorf("expected a farm 5 km away outside cell %s", cell)
+	}
+	if math.Abs(lat-45.7640) > 0.003 || math.Abs(lon-4.8357) > 0.006 {
+		t.Errorf("expected the center of the cell near the farm, got (%v, %v)", lat, lon)
+	}
+}
@@ -0,0 +1,263 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Default endpoints of the free Open-Meteo API, which needs no key.
+const (
+	DefaultOpenMeteoURL        = "https://api.open-meteo.com/v1/forecast"
+	DefaultOpenMeteoArchiveURL = "https://archive-api.open-meteo.com/v1/archive"
+)
+
+const (
+	openMeteoMaxForecastDays = 16
+	openMeteoTimeLayout      = "2006-01-02T15:04"
+	openMeteoDateLayout      = "2006-01-02"
+	openMeteoConditions      = "temperature_2m,relative_humidity_2m,wind_speed_10m,cloud_cover,weather_code"
+	openMeteoDailyConditions = "temperature_2m_min,temperature_2m_max,temperature_2m_mean,precipitation_sum,shortwave_radiation_sum,wind_speed_10m_max"
+)
+
+type OpenMeteoConfig struct {
+	URL        string
+	ArchiveURL string
+	// APIKey is only needed by the commercial endpoints.
+	APIKey string
+}
+
+type openMeteoProvider struct {
+	config OpenMeteoConfig
+	client *http.Client
+}
+
+func NewOpenMeteoProvider(config OpenMeteoConfig) WeatherProvider {
+	if config.URL == "" {
+		config.URL = DefaultOpenMeteoURL
+	}
+	if config.ArchiveURL == "" {
+		config.ArchiveURL = DefaultOpenMeteoArchiveURL
+	}
+	return &openMeteoProvider{
+		config: config,
+		client: &http.Client{Timeout: requestTimeout},
+	}
+}
+
+func (p *openMeteoProvider) Name() string {
+	return ProviderOpenMeteo
+}
+
+func (p *openMeteoProvider) query(lat, lon float64) url.Values {
+	query := url.Values{
+		"latitude":        {formatCoordinate(lat)},
+		"longitude":       {formatCoordinate(lon)},
+		"wind_speed_unit": {"ms"},
+		"timezone":        {"UTC"},
+	}
+	if p.config.APIKey != "" {
+		query.Set("apikey", p.config.APIKey)
+	}
+	return query
+}
+
+func (p *openMeteoProvider) Current(ctx context.Context, lat, lon float64) (Conditions, error) {
+	query := p.query(lat, lon)
+	query.Set("current", openMeteoConditions)
+
+	var data struct {
+		Current struct {
+			Time        string  `json:"time"`
+			Temperature float64 `json:"temperature_2m"`
+			Humidity    float64 `json:"relative_humidity_2m"`
+			WindSpeed   float64 `json:"wind_speed_10m"`
+			CloudCover  float64 `json:"cloud_cover"`
+			WeatherCode int     `json:"weather_code"`
+		} `json:"current"`
+	}
+	if err := getJSON(ctx, p.client, p.config.URL, query, &data); err != nil {
+		return Conditions{}, err
+	}
+
+	observedAt, err := time.Parse(openMeteoTimeLayout, data.Current.Time)
+	if err != nil {
+		return Conditions{}, fmt.Errorf("invalid time '%s' in response: %w", data.Current.Time, err)
+	}
+	main, desc := wmoWeather(data.Current.WeatherCode)
+	return Conditions{
+		Time:        observedAt,
+		Temperature: data.Current.Temperature,
+		Humidity:    int(math.Round(data.Current.Humidity)),
+		WindSpeed:   data.Current.WindSpeed,
+		Clouds:      int(math.Round(data.Current.CloudCover)),
+		WeatherMain: main,
+		WeatherDesc: desc,
+	}, nil
+}
+
+// Forecast leaves out the steps missing a temperature, humidity, wind speed or cloud cover.
+func (p *openMeteoProvider) Forecast(ctx context.Context, lat, lon float64, days int) ([]Conditions, error) {
+	if days <= 0 || days > openMeteoMaxForecastDays {
+		return nil, fmt.Errorf("invalid forecast length of %d days, expected 1 to %d", days, openMeteoMaxForecastDays)
+	}
+
+	query := p.query(lat, lon)
+	query.Set("hourly", openMeteoConditions)
+	query.Set("forecast_days", fmt.Sprint(days))
+
+	var data struct {
+		Hourly struct {
+			Time        []string   `json:"time"`
+			Temperature []*float64 `json:"temperature_2m"`
+			Humidity    []*float64 `json:"relative_humidity_2m"`
+			WindSpeed   []*float64 `json:"wind_speed_10m"`
+			CloudCover  []*float64 `json:"cloud_cover"`
+			WeatherCode []*int     `json:"weather_code"`
+		} `json:"hourly"`
+	}
+	if err := getJSON(ctx, p.client, p.config.URL, query, &data); err != nil {
+		return nil, err
+	}
+
+	hourly := data.Hourly
+	forecast := make([]Conditions, 0, len(hourly.Time))
+	for i, rawTime := range hourly.Time {
+		stepTime, err := time.Parse(openMeteoTimeLayout, rawTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time '%s' in response: %w", rawTime, err)
+		}
+		temperature, humidity := at(hourly.Temperature, i), at(hourly.Humidity, i)
+		windSpeed, cloudCover := at(hourly.WindSpeed, i), at(hourly.CloudCover, i)
+		if temperature == nil || humidity == nil || windSpeed == nil || cloudCover == nil {
+			continue
+		}
+		code := -1
+		if at(hourly.WeatherCode, i) != nil {
+			code = *hourly.WeatherCode[i]
+		}
+		main, desc := wmoWeather(code)
+		forecast = append(forecast, Conditions{
+			Time:        stepTime,
+			Temperature: *temperature,
+			Humidity:    int(math.Round(*humidity)),
+			WindSpeed:   *windSpeed,
+			Clouds:      int(math.Round(*cloudCover)),
+			WeatherMain: main,
+			WeatherDesc: desc,
+		})
+	}
+	return forecast, nil
+}
+
+func (p *openMeteoProvider) Historical(ctx context.Context, lat, lon float64, from, to time.Time) ([]DailyConditions, error) {
+	if to.Before(from) {
+		return nil, fmt.Errorf("invalid date range %s to %s", from.Format(openMeteoDateLayout), to.Format(openMeteoDateLayout))
+	}
+
+	query := p.query(lat, lon)
+	query.Set("daily", openMeteoDailyConditions)
+	query.Set("start_date", from.Format(openMeteoDateLayout))
+	query.Set("end_date", to.Format(openMeteoDateLayout))
+
+	var data struct {
+		Daily struct {
+			Time            []string   `json:"time"`
+			TemperatureMin  []*float64 `json:"temperature_2m_min"`
+			TemperatureMax  []*float64 `json:"temperature_2m_max"`
+			TemperatureMean []*float64 `json:"temperature_2m_mean"`
+			Precipitation   []*float64 `json:"precipitation_sum"`
+			Radiation       []*float64 `json:"shortwave_radiation_sum"`
+			WindSpeed       []*float64 `json:"wind_speed_10m_max"`
+		} `json:"daily"`
+	}
+	if err := getJSON(ctx, p.client, p.config.ArchiveURL, query, &data); err != nil {
+		return nil, err
+	}
+
+	daily := data.Daily
+	days := make([]DailyConditions, len(daily.Time))
+	for i, rawDate := range daily.Time {
+		date, err := time.Parse(openMeteoDateLayout, rawDate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date '%s' in response: %w", rawDate, err)
+		}
+		days[i] = DailyConditions{
+			Date:            date,
+			TemperatureMin:  at(daily.TemperatureMin, i),
+			TemperatureMax:  at(daily.TemperatureMax, i),
+			TemperatureMean: at(daily.TemperatureMean, i),
+			Precipitation:   at(daily.Precipitation, i),
+			Radiation:       at(daily.Radiation, i),
+			WindSpeed:       at(daily.WindSpeed, i),
+		}
+	}
+	return days, nil
+}
+
+func at[T any](values []*T, i int) *T {
+	if i < len(values) {
+		return values[i]
+	}
+	return nil
+}
+
+// wmoWeather maps a WMO weather code to OpenWeather's group and a description.
+func wmoWeather(code int) (main, desc string) {
+	switch code {
+	case 0:
+		return "Clear", "clear sky"
+	case 1:
+		return "Clouds", "mainly clear"
+	case 2:
+		return "Clouds", "partly cloudy"
+	case 3:
+		return "Clouds", "overcast"
+	case 45:
+		return "Fog", "fog"
+	case 48:
+		return "Fog", "depositing rime fog"
+	case 51:
+		return "Drizzle", "light drizzle"
+	case 53:
+		return "Drizzle", "moderate drizzle"
+	case 55:
+		return "Drizzle", "dense drizzle"
+	case 56, 57:
+		return "Drizzle", "freezing drizzle"
+	case 61:
+		return "Rain", "slight rain"
+	case 63:
+		return "Rain", "moderate rain"
+	case 65:
+		return "Rain", "heavy rain"
+	case 66, 67:
+		return "Rain", "freezing rain"
+	case 71:
+		return "Snow", "slight snow fall"
+	case 73:
+		return "Snow", "moderate snow fall"
+	case 75:
+		return "Snow", "heavy snow fall"
+	case 77:
+		return "Snow", "snow grains"
+	case 80:
+		return "Rain", "slight rain showers"
+	case 81:
+		return "Rain", "moderate rain showers"
+	case 82:
+		return "Rain", "violent rain showers"
+	case 85:
+		return "Snow", "slight snow showers"
+	case 86:
+		return "Snow", "heavy snow showers"
+	case 95:
+		return "Thunderstorm", "thunderstorm"
+	case 96, 99:
+		return "Thunderstorm", "thunderstorm with hail"
+	}
+	return "", ""
+}
@@ -0,0 +1,107 @@
+package weather_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"weather-ingestor/weather"
+)
+
+func TestOpenMeteoProvider_Current(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("latitude") != "45.76" || query.Get("longitude") != "4.85" || query.Get("wind_speed_unit") != "ms" || query.Get("current") == "" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"current": {"time": "2024-06-01T12:00", "temperature_2m": 22.3, "relative_humidity_2m": 55,
+			"wind_speed_10m": 4.5, "cloud_cover": 30, "weather_code": 61}}`))
+	}))
+	defer server.Close()
+
+	provider := weather.NewOpenMeteoProvider(weather.OpenMeteoConfig{URL: server.URL})
+	got, err := provider.Current(context.Background(), 45.76, 4.85)
+	if err != nil {
+		t.Fatalf("Current returned an unexpected error: %v", err)
+	}
+
+	expected := weather.Conditions{
+		Time:        time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+		Temperature: 22.3,
+		Humidity:    55,
+		WindSpeed:   4.5,
+		Clouds:      30,
+		WeatherMain: "Rain",
+		WeatherDesc: "slight rain",
+	}
+	if got != expected {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+	if provider.Name() != weather.ProviderOpenMeteo {
+		t.Errorf("expected name '%s', got '%s'", weather.ProviderOpenMeteo, provider.Name())
+	}
+}
+
+func TestOpenMeteoProvider_Forecast(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("forecast_days") != "7" || r.URL.Query().Get("hourly") == "" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"hourly": {"time": ["2024-06-01T00:00", "2024-06-01T01:00", "2024-06-01T02:00", "2024-06-01T03:00"],
+			"temperature_2m": [14.2, null, 13.5, 13.1], "relative_humidity_2m": [80, 82, 84, null], "wind_speed_10m": [1.5, 1.8, 2.1, 2.4],
+			"cloud_cover": [100, 90, 80, 70], "weather_code": [3, 45, null, 1]}}`))
+	}))
+	defer server.Close()
+
+	forecast, err := weather.NewOpenMeteoProvider(weather.OpenMeteoConfig{URL: server.URL}).Forecast(context.Background(), 45.76, 4.85, 7)
+	if err != nil {
+		t.Fatalf("Forecast returned an unexpected error: %v", err)
+	}
+	// The steps of 01:00 and 03:00 lack a temperature and a humidity.
+	if len(forecast) != 2 {
+		t.Fatalf("expected 2 steps, got %d: %+v", len(forecast), forecast)
+	}
+	if forecast[0].Temperature != 14.2 || forecast[0].WeatherMain != "Clouds" || forecast[0].WeatherDesc != "overcast" {
+		t.Errorf("unexpected first step %+v", forecast[0])
+	}
+	if !forecast[1].Time.Equal(time.Date(2024, 6, 1, 2, 0, 0, 0, time.UTC)) || forecast[1].Temperature != 13.5 || forecast[1].Humidity != 84 {
+		t.Errorf("unexpected second step %+v", forecast[1])
+	}
+}
+
+func TestOpenMeteoProvider_Historical(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.URL.Path != "/archive" || query.Get("start_date") != "2023-06-01" || query.Get("end_date") != "2023-06-02" || query.Get("daily") == "" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"daily": {"time": ["2023-06-01", "2023-06-02"], "temperature_2m_min": [11.2, 12], "temperature_2m_max": [24.5, 26.1],
+			"temperature_2m_mean": [17.8, 19], "precipitation_sum": [0, 3.4], "shortwave_radiation_sum": [25.3, null], "wind_speed_10m_max": [4.2, 6]}}`))
+	}))
+	defer server.Close()
+
+	provider := weather.NewOpenMeteoProvider(weather.OpenMeteoConfig{URL: server.URL + "/forecast", ArchiveURL: server.URL + "/archive"})
+	days, err := provider.Historical(context.Background(), 45.76, 4.85,
+		time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 6, 2, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Historical returned an unexpected error: %v", err)
+	}
+	if len(days) != 2 {
+		t.Fatalf("expected 2 days, got %d", len(days))
+	}
+
+	second := days[1]
+	if !second.Date.Equal(time.Date(2023, 6, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected 2023-06-02, got %v", second.Date)
+	}
+	if second.TemperatureMin == nil || *second.TemperatureMin != 12 || second.TemperatureMax == nil || *second.TemperatureMax != 26.1 {
+		t.Errorf("unexpected temperatures %+v", second)
+	}
+	if second.Precipitation == nil || *second.Precipitation != 3.4 {
+		t.Errorf("expected 3.4 mm of precipitation, got %v", second.Precipitation)
+	}
+	if second.Radiation != nil {
+		t.Errorf("expected a missing radiation for a null value, got %v", *second.Radiation)
+	}
+}
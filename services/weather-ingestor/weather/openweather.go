@@ -0,0 +1,118 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultOpenWeatherURL is the base URL of OpenWeather's free API.
+const DefaultOpenWeatherURL = "https://api.openweathermap.org/data/2.5"
+
+const (
+	openWeatherForecastSteps   = 8
+	openWeatherMaxForecastDays = 5
+)
+
+type openWeatherProvider struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+// NewOpenWeatherProvider returns the provider of OpenWeather's current weather and 5-day forecast.
+func NewOpenWeatherProvider(baseURL, apiKey string) WeatherProvider {
+	if baseURL == "" {
+		baseURL = DefaultOpenWeatherURL
+	}
+	return &openWeatherProvider{
+		baseURL: strings.TrimSuffix(strings.TrimSuffix(baseURL, "/"), "/weather"),
+		apiKey:  apiKey,
+		client:  &http.Client{Timeout: requestTimeout},
+	}
+}
+
+func (p *openWeatherProvider) Name() string {
+	return ProviderOpenWeather
+}
+
+type openWeatherConditions struct {
+	Dt   int64 `json:"dt"`
+	Main struct {
+		Temp     float64 `json:"temp"`
+		Humidity int     `json:"humidity"`
+	} `json:"main"`
+	Wind struct {
+		Speed float64 `json:"speed"`
+	} `json:"wind"`
+	Clouds struct {
+		All int `json:"all"`
+	} `json:"clouds"`
+	Weather []struct {
+		Main        string `json:"main"`
+		Description string `json:"description"`
+	} `json:"weather"`
+}
+
+func (c openWeatherConditions) conditions() Conditions {
+	conditions := Conditions{
+		Temperature: c.Main.Temp,
+		Humidity:    c.Main.Humidity,
+		WindSpeed:   c.Wind.Speed,
+		Clouds:      c.Clouds.All,
+	}
+	if c.Dt > 0 {
+		conditions.Time = time.Unix(c.Dt, 0).UTC()
+	}
+	if len(c.Weather) > 0 {
+		conditions.WeatherMain = c.Weather[0].Main
+		conditions.WeatherDesc = c.Weather[0].Description
+	}
+	return conditions
+}
+
+func (p *openWeatherProvider) query(lat, lon float64) url.Values {
+	return url.Values{
+		"lat":   {formatCoordinate(lat)},
+		"lon":   {formatCoordinate(lon)},
+		"appid": {p.apiKey},
+		"units": {"metric"},
+	}
+}
+
+func (p *openWeatherProvider) Current(ctx context.Context, lat, lon float64) (Conditions, error) {
+	var data openWeatherConditions
+	if err := getJSON(ctx, p.client, p.baseURL+"/weather", p.query(lat, lon), &data); err != nil {
+		return Conditions{}, err
+	}
+	return data.conditions(), nil
+}
+
+func (p *openWeatherProvider) Forecast(ctx context.Context, lat, lon float64, days int) ([]Conditions, error) {
+	if days <= 0 || days > openWeatherMaxForecastDays {
+		return nil, fmt.Errorf("invalid forecast length of %d days, expected 1 to %d", days, openWeatherMaxForecastDays)
+	}
+
+	query := p.query(lat, lon)
+	query.Set("cnt", fmt.Sprint(days*openWeatherForecastSteps))
+
+	var data struct {
+		List []openWeatherConditions `json:"list"`
+	}
+	if err := getJSON(ctx, p.client, p.baseURL+"/forecast", query, &data); err != nil {
+		return nil, err
+	}
+
+	forecast := make([]Conditions, len(data.List))
+	for i, step := range data.List {
+		forecast[i] = step.conditions()
+	}
+	return forecast, nil
+}
+
+func (p *openWeatherProvider) Historical(ctx context.Context, lat, lon float64, from, to time.Time) ([]DailyConditions, error) {
+	return nil, fmt.Errorf("historical weather: %w", ErrUnsupported)
+}
@@ -0,0 +1,93 @@
+package weather_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"weather-ingestor/weather"
+)
+
+func TestOpenWeatherProvider_Current(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.URL.Path != "/weather" || query.Get("lat") != "45.76" || query.Get("lon") != "4.85" || query.Get("appid") != "key" || query.Get("units") != "metric" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"dt": 1717243200, "main": {"temp": 22.3, "humidity": 55}, "wind": {"speed": 4.5}, "clouds": {"all": 30},
+			"weather": [{"main": "Clouds", "description": "scattered clouds"}]}`))
+	}))
+	defer server.Close()
+
+	// The URL of the current weather endpoint still works.
+	provider := weather.NewOpenWeatherProvider(server.URL+"/weather", "key")
+	got, err := provider.Current(context.Background(), 45.76, 4.85)
+	if err != nil {
+		t.Fatalf("Current returned an unexpected error: %v", err)
+	}
+
+	expected := weather.Conditions{
+		Time:        time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+		Temperature: 22.3,
+		Humidity:    55,
+		WindSpeed:   4.5,
+		Clouds:      30,
+		WeatherMain: "Clouds",
+		WeatherDesc: "scattered clouds",
+	}
+	if got != expected {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+	if provider.Name() != weather.ProviderOpenWeather {
+		t.Errorf("expected name '%s', got '%s'", weather.ProviderOpenWeather, provider.Name())
+	}
+}
+
+func TestOpenWeatherProvider_Forecast(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/forecast" || r.URL.Query().Get("cnt") != "16" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"list": [
+			{"dt": 1717243200, "main": {"temp": 20, "humidity": 50}, "weather": [{"main": "Clear", "description": "clear sky"}]},
+			{"dt": 1717254000, "main": {"temp": 17.5, "humidity": 70}, "weather": [{"main": "Rain", "description": "light rain"}]}
+		]}`))
+	}))
+	defer server.Close()
+
+	forecast, err := weather.NewOpenWeatherProvider(server.URL, "key").Forecast(context.Background(), 45.76, 4.85, 2)
+	if err != nil {
+		t.Fatalf("Forecast returned an unexpected error: %v", err)
+	}
+	if len(forecast) != 2 {
+		t.Fatalf("expected 2 steps, got %d", len(forecast))
+	}
+	if !forecast[1].Time.Equal(time.Date(2024, 6, 1, 15, 0, 0, 0, time.UTC)) || forecast[1].Temperature != 17.5 || forecast[1].WeatherMain != "Rain" {
+		t.Errorf("unexpected second step %+v", forecast[1])
+	}
+
+	if _, err := weather.NewOpenWeatherProvider(server.URL, "key").Forecast(context.Background(), 45.76, 4.85, 6); err == nil {
+		t.Error("expected an error beyond the 5 days of the forecast API")
+	}
+}
+
+func TestOpenWeatherProvider_Historical(t *testing.T) {
+	day := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	_, err := weather.NewOpenWeatherProvider("", "key").Historical(context.Background(), 45.76, 4.85, day, day)
+	if !errors.Is(err, weather.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestOpenWeatherProvider_Current_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"cod": 401, "message": "Invalid API key"}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	if _, err := weather.NewOpenWeatherProvider(server.URL, "wrong").Current(context.Background(), 45.76, 4.85); err == nil {
+		t.Error("expected an error for an unauthorized request")
+	}
+}
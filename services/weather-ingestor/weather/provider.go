@@ -0,0 +1,91 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Names of the providers, stored on the weather rows they fetched.
+const (
+	ProviderOpenWeather = "openweather"
+	ProviderOpenMeteo   = "open-meteo"
+)
+
+// ErrUnsupported is returned by a provider for data its API does not offer.
+var ErrUnsupported = errors.New("not supported by the weather provider")
+
+const requestTimeout = 30 * time.Second
+
+// Conditions is the weather at a place and time, in metric units.
+type Conditions struct {
+	Time        time.Time
+	Temperature float64
+	Humidity    int
+	WindSpeed   float64
+	Clouds      int
+	WeatherMain string
+	WeatherDesc string
+}
+
+// DailyConditions is the weather of a UTC day, nil values not being reported.
+type DailyConditions struct {
+	Date            time.Time
+	TemperatureMin  *float64
+	TemperatureMax  *float64
+	TemperatureMean *float64
+	Precipitation   *float64
+	Radiation       *float64
+	WindSpeed       *float64
+}
+
+// Reported tells whether the provider reported any value for the day.
+func (c DailyConditions) Reported() bool {
+	for _, value := range []*float64{c.TemperatureMin, c.TemperatureMax, c.TemperatureMean, c.Precipitation, c.Radiation, c.WindSpeed} {
+		if value != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// WeatherProvider is a weather API, mapping its responses to Conditions.
+type WeatherProvider interface {
+	Name() string
+	Current(ctx context.Context, lat, lon float64) (Conditions, error)
+	// Forecast returns the forecast steps of the coming days, in time order.
+	Forecast(ctx context.Context, lat, lon float64, days int) ([]Conditions, error)
+	// Historical returns the observations of the days from from to to included.
+	Historical(ctx context.Context, lat, lon float64, from, to time.Time) ([]DailyConditions, error)
+}
+
+func formatCoordinate(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func getJSON(ctx context.Context, client *http.Client, endpoint string, query url.Values, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to build api request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("api request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return fmt.Errorf("decoding failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrDailyQuotaExhausted = errors.New("daily weather API quota exhausted")
+
+// RateLimit is the quota of the weather provider, a zero field leaving its window unlimited.
+type RateLimit struct {
+	PerMinute int
+	PerDay    int
+}
+
+type tokenBucket struct {
+	capacity float64
+	period   time.Duration
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(capacity int, period time.Duration, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		capacity: float64(capacity),
+		period:   period,
+		tokens:   float64(capacity),
+		last:     now,
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(b.capacity, b.tokens+b.capacity*float64(elapsed)/float64(b.period))
+		b.last = now
+	}
+}
+
+func (b *tokenBucket) delay() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) * float64(b.period) / b.capacity)
+}
+
+// RateLimiter spaces the calls of every run of the process to stay within a RateLimit.
+type RateLimiter struct {
+	mu     sync.Mutex
+	minute *tokenBucket
+	day    *tokenBucket
+	now    func() time.Time
+}
+
+func NewRateLimiter(limit RateLimit) *RateLimiter {
+	return newRateLimiter(limit, time.Now)
+}
+
+func newRateLimiter(limit RateLimit, now func() time.Time) *RateLimiter {
+	l := &RateLimiter{now: now}
+	if limit.PerMinute > 0 {
+		l.minute = newTokenBucket(limit.PerMinute, time.Minute, now())
+	}
+	if limit.PerDay > 0 {
+		l.day = newTokenBucket(limit.PerDay, 24*time.Hour, now())
+	}
+	return l
+}
+
+// Wait blocks until a call is allowed, or fails once the daily quota is used up.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		delay, err := l.reserve()
+		if err != nil || delay == 0 {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (l *RateLimiter) reserve() (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if l.day != nil {
+		l.day.refill(now)
+		if l.day.delay() > 0 {
+			return 0, ErrDailyQuotaExhausted
+		}
+	}
+	if l.minute != nil {
+		l.minute.refill(now)
+		if delay := l.minute.delay(); delay > 0 {
+			return delay, nil
+		}
+		l.minute.tokens--
+	}
+	if l.day != nil {
+		l.day.tokens--
+	}
+	return 0, nil
+}
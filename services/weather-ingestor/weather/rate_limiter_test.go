@@ -0,0 +1,75 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock is a time source the tests move by hand.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func TestRateLimiter_PerMinute(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	limiter := newRateLimiter(RateLimit{PerMinute: 2}, clock.Now)
+
+	for i := 0; i < 2; i++ {
+		if delay, err := limiter.reserve(); delay != 0 || err != nil {
+			t.Fatalf("call %d: expected a token at once, got delay %v and error %v", i+1, delay, err)
+		}
+	}
+	if delay, err := limiter.reserve(); delay != 30*time.Second || err != nil {
+		t.Errorf("expected to wait 30s for the third call, got delay %v and error %v", delay, err)
+	}
+
+	clock.now = clock.now.Add(30 * time.Second)
+	if delay, err := limiter.reserve(); delay != 0 || err != nil {
+		t.Errorf("expected a token refilled after 30s, got delay %v and error %v", delay, err)
+	}
+}
+
+func TestRateLimiter_PerDay(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	limiter := newRateLimiter(RateLimit{PerMinute: 60, PerDay: 1}, clock.Now)
+
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait returned an unexpected error: %v", err)
+	}
+	if err := limiter.Wait(context.Background()); !errors.Is(err, ErrDailyQuotaExhausted) {
+		t.Errorf("expected ErrDailyQuotaExhausted, got %v", err)
+	}
+
+	clock.now = clock.now.Add(24 * time.Hour)
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Errorf("expected the daily quota refilled after a day, got %v", err)
+	}
+}
+
+func TestRateLimiter_Unlimited(t *testing.T) {
+	limiter := NewRateLimiter(RateLimit{})
+	for i := 0; i < 1000; i++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait returned an unexpected error: %v", err)
+		}
+	}
+}
+
+func TestRateLimiter_Wait_Cancelled(t *testing.T) {
+	limiter := NewRateLimiter(RateLimit{PerMinute: 1})
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait returned an unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the wait for the next minute to be cut short, got %v", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/google/uuid"
 )
 
+// Weather is the current weather of a unit, at the coordinates it was fetched at.
 type Weather struct {
 	ID                 uuid.UUID  `json:"id"`
 	CreatedAt          time.Time  `json:"createdAt"`
@@ -19,6 +20,8 @@ type Weather struct {
 	Clouds             int        `json:"clouds"`
 	WeatherMain        string     `json:"weather_main"`
 	WeatherDesc        string     `json:"weather_desc"`
+	Provider           string     `json:"provider"`
+	CellID             string     `json:"cell_id,omitempty"`
 	AgriculturalUnitId uuid.UUID  `json:"agricultural_unit_id"`
 }
 
@@ -31,6 +34,8 @@ type WeatherValue struct {
 	Clouds             int
 	WeatherMain        string
 	WeatherDesc        string
+	Provider           string
+	CellID             string
 	AgriculturalUnitId uuid.UUID
 }
 
@@ -48,6 +53,8 @@ func CreateWeather(value WeatherValue) Weather {
 		Clouds:             value.Clouds,
 		WeatherMain:        value.WeatherMain,
 		WeatherDesc:        value.WeatherDesc,
+		Provider:           value.Provider,
+		CellID:             value.CellID,
 		AgriculturalUnitId: value.AgriculturalUnitId,
 	}
 }
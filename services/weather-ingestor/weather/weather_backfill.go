@@ -0,0 +1,153 @@
+package weather
+
+import (
+	"common/agri"
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+const maxBackfillSpanDays = 366
+
+// BackfillRequest selects the units and the UTC days to backfill, every active unit when IDNums is nil.
+type BackfillRequest struct {
+	IDNums []int
+	From   time.Time
+	To     time.Time
+}
+
+func (r BackfillRequest) Validate(today time.Time) error {
+	if r.From.IsZero() || r.To.IsZero() {
+		return fmt.Errorf("a date range is required")
+	}
+	if r.To.Before(r.From) {
+		return fmt.Errorf("invalid date range %s to %s", r.From.Format(dateLayout), r.To.Format(dateLayout))
+	}
+	if r.To.After(today) {
+		return fmt.Errorf("cannot backfill after today, got %s", r.To.Format(dateLayout))
+	}
+	return nil
+}
+
+// BackfillSummary counts the units of a backfill and the days it stored.
+type BackfillSummary struct {
+	IngestSummary
+	Days int `json:"days"`
+}
+
+type WeatherBackfiller struct {
+	unitRunner
+	provider            WeatherProvider
+	dailyWeatherStorage DailyWeatherStorage
+}
+
+// NewWeatherBackfiller returns a backfiller of the daily observations of provider.
+func NewWeatherBackfiller(provider WeatherProvider, dws DailyWeatherStorage, aus agri.AgriUnitStorage, config FetcherConfig) *WeatherBackfiller {
+	return &WeatherBackfiller{
+		unitRunner:          newUnitRunner(aus, config),
+		provider:            provider,
+		dailyWeatherStorage: dws,
+	}
+}
+
+// Backfill fetches and stores the days not stored yet of the requested units.
+func (b *WeatherBackfiller) Backfill(ctx context.Context, request BackfillRequest) (BackfillSummary, error) {
+	request.From, request.To = truncateToDay(request.From), truncateToDay(request.To)
+	if err := request.Validate(truncateToDay(time.Now())); err != nil {
+		return BackfillSummary{}, err
+	}
+	from, to := request.From, request.To
+
+	query := agri.AgriUnitQuery{}
+	if request.IDNums != nil {
+		query = agri.AgriUnitQuery{IDNums: request.IDNums, Archived: agri.ArchivedIncluded}
+	}
+
+	var days atomic.Int64
+	summary, err := b.run(ctx, query, func(ctx context.Context, unit agri.AgriculturalUnit) error {
+		stored, err := b.backfillUnit(ctx, unit, from, to)
+		days.Add(int64(stored))
+		return err
+	})
+	return BackfillSummary{IngestSummary: summary, Days: int(days.Load())}, err
+}
+
+func (b *WeatherBackfiller) backfillUnit(ctx context.Context, unit agri.AgriculturalUnit, from, to time.Time) (int, error) {
+	storedDates, err := b.dailyWeatherStorage.SelectDates(unit.ID, from, to)
+	if err != nil {
+		return 0, fmt.Errorf("failed to select stored days: %w", err)
+	}
+
+	spans := missingSpans(from, to, storedDates, maxBackfillSpanDays)
+	if len(spans) == 0 {
+		return 0, errUpToDate
+	}
+
+	stored := 0
+	for _, span := range spans {
+		if err := b.limiter.Wait(ctx); err != nil {
+			return stored, err
+		}
+
+		observations, err := b.provider.Historical(ctx, unit.Latitude, unit.Longitude, span.from, span.to)
+		if err != nil {
+			return stored, fmt.Errorf("failed to fetch daily weather from %s to %s: %w", span.from.Format(dateLayout), span.to.Format(dateLayout), err)
+		}
+
+		// Days the archive has not caught up with are left for a later backfill.
+		days := make([]DailyWeather, 0, len(observations))
+		for _, observation := range observations {
+			if !observation.Reported() {
+				continue
+			}
+			days = append(days, CreateDailyWeather(DailyWeatherValue{
+				AgriculturalUnitId: unit.ID,
+				Provider:           b.provider.Name(),
+				Latitude:           unit.Latitude,
+				Longitude:          unit.Longitude,
+				Conditions:         observation,
+			}))
+		}
+		if len(days) == 0 {
+			continue
+		}
+		if err := b.dailyWeatherStorage.InsertOrUpdateBatch(days); err != nil {
+			return stored, fmt.Errorf("failed to save daily weather: %w", err)
+		}
+		stored += len(days)
+	}
+	return stored, nil
+}
+
+type dateSpan struct {
+	from time.Time
+	to   time.Time
+}
+
+func missingSpans(from, to time.Time, stored []time.Time, maxDays int) []dateSpan {
+	storedDays := make(map[string]bool, len(stored))
+	for _, date := range stored {
+		storedDays[date.Format(dateLayout)] = true
+	}
+
+	var spans []dateSpan
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if storedDays[day.Format(dateLayout)] {
+			continue
+		}
+		if last := len(spans) - 1; last >= 0 && day.Before(spans[last].from.AddDate(0, 0, maxDays)) {
+			spans[last].to = day
+			continue
+		}
+		spans = append(spans, dateSpan{from: day, to: day})
+	}
+	return spans
+}
+
+func truncateToDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,210 @@
+package weather_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"weather-ingestor/weather"
+
+	"github.com/google/uuid"
+)
+
+type MockDailyWeatherStorage struct {
+	mu     sync.Mutex
+	Stored map[uuid.UUID]map[string]weather.DailyWeather
+}
+
+func (m *MockDailyWeatherStorage) InsertOrUpdateBatch(days []weather.DailyWeather) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Stored == nil {
+		m.Stored = make(map[uuid.UUID]map[string]weather.DailyWeather)
+	}
+	for _, day := range days {
+		if m.Stored[day.AgriculturalUnitId] == nil {
+			m.Stored[day.AgriculturalUnitId] = make(map[string]weather.DailyWeather)
+		}
+		m.Stored[day.AgriculturalUnitId][day.Date.Format("2006-01-02")] = day
+	}
+	return nil
+}
+
+func (m *MockDailyWeatherStorage) SelectDates(unitID uuid.UUID, from, to time.Time) ([]time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var dates []time.Time
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if _, stored := m.Stored[unitID][day.Format("2006-01-02")]; stored {
+			dates = append(dates, day)
+		}
+	}
+	return dates, nil
+}
+
+// newFakeArchive serves the Open-Meteo archive, failing the requests of the unit at failLatitude.
+func newFakeArchive(failLatitude string) (*httptest.Server, *[]string) {
+	var (
+		mu     sync.Mutex
+		ranges []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		mu.Lock()
+		ranges = append(ranges, query.Get("latitude")+":"+query.Get("start_date")+".."+query.Get("end_date"))
+		mu.Unlock()
+
+		if query.Get("latitude") == failLatitude {
+			http.Error(w, "archive unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		from, _ := time.Parse("2006-01-02", query.Get("start_date"))
+		to, _ := time.Parse("2006-01-02", query.Get("end_date"))
+		var dates, temperatures []string
+		for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+			dates = append(dates, `"`+day.Format("2006-01-02")+`"`)
+			temperatures = append(temperatures, fmt.Sprint(day.Day()))
+		}
+		fmt.Fprintf(w, `{"daily": {"time": [%s], "temperature_2m_mean": [%s]}}`, strings.Join(dates, ","), strings.Join(temperatures, ","))
+	}))
+	return server, &ranges
+}
+
+func TestWeatherBackfiller_Backfill(t *testing.T) {
+	server, ranges := newFakeArchive("41")
+	defer server.Close()
+
+	units := newUnits(2)
+	dailyStorage := &MockDailyWeatherStorage{}
+	provider := weather.NewOpenMeteoProvider(weather.OpenMeteoConfig{ArchiveURL: server.URL})
+	backfiller := weather.NewWeatherBackfiller(provider, dailyStorage, &MockAgriUnitStorage{Units: units}, weather.FetcherConfig{Concurrency: 2})
+
+	request := weather.BackfillRequest{From: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2023, 6, 10, 0, 0, 0, 0, time.UTC)}
+	summary, err := backfiller.Backfill(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Backfill returned an unexpected error: %v", err)
+	}
+
+	expected := weather.BackfillSummary{IngestSummary: weather.IngestSummary{Units: 2, Fetched: 1, Failed: 1}, Days: 10}
+	if summary != expected {
+		t.Errorf("expected %+v, got %+v", expected, summary)
+	}
+	day := dailyStorage.Stored[units[0].ID]["2023-06-05"]
+	if day.Provider != weather.ProviderOpenMeteo || day.TemperatureMean == nil || *day.TemperatureMean != 5 {
+		t.Errorf("unexpected stored day %+v", day)
+	}
+	if len(*ranges) != 2 {
+		t.Errorf("expected a call per unit, got %v", *ranges)
+	}
+}
+
+func TestWeatherBackfiller_Backfill_Resumes(t *testing.T) {
+	server, ranges := newFakeArchive("")
+	defer server.Close()
+
+	units := newUnits(2)
+	dailyStorage := &MockDailyWeatherStorage{}
+	provider := weather.NewOpenMeteoProvider(weather.OpenMeteoConfig{ArchiveURL: server.URL})
+	backfiller := weather.NewWeatherBackfiller(provider, dailyStorage, &MockAgriUnitStorage{Units: units}, weather.FetcherConfig{Concurrency: 1})
+
+	// The second unit is missing the first and last days of the range.
+	var stored []weather.DailyWeather
+	for day := 1; day <= 10; day++ {
+		date := time.Date(2023, 6, day, 0, 0, 0, 0, time.UTC)
+		stored = append(stored, weather.DailyWeather{AgriculturalUnitId: units[0].ID, Date: date})
+		if day > 2 && day < 9 {
+			stored = append(stored, weather.DailyWeather{AgriculturalUnitId: units[1].ID, Date: date})
+		}
+	}
+	dailyStorage.InsertOrUpdateBatch(stored)
+
+	request := weather.BackfillRequest{From: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2023, 6, 10, 0, 0, 0, 0, time.UTC)}
+	summary, err := backfiller.Backfill(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Backfill returned an unexpected error: %v", err)
+	}
+
+	expected := weather.BackfillSummary{IngestSummary: weather.IngestSummary{Units: 2, Fetched: 1, UpToDate: 1}, Days: 10}
+	if summary != expected {
+		t.Errorf("expected %+v, got %+v", expected, summary)
+	}
+	// The gap between the missing days costs less than a second call.
+	if len(*ranges) != 1 || (*ranges)[0] != "41:2023-06-01..2023-06-10" {
+		t.Errorf("expected a single call for the second unit, got %v", *ranges)
+	}
+}
+
+func TestWeatherBackfiller_Backfill_SkipsUnreportedDays(t *testing.T) {
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		ranges = append(ranges, query.Get("start_date")+".."+query.Get("end_date"))
+		// The archive has no value yet for the last two days.
+		fmt.Fprint(w, `{"daily": {"time": ["2023-06-01", "2023-06-02", "2023-06-03"], "temperature_2m_mean": [12.5, null, null], "precipitation_sum": [0, null, null]}}`)
+	}))
+	defer server.Close()
+
+	units := newUnits(1)
+	dailyStorage := &MockDailyWeatherStorage{}
+	provider := weather.NewOpenMeteoProvider(weather.OpenMeteoConfig{ArchiveURL: server.URL})
+	backfiller := weather.NewWeatherBackfiller(provider, dailyStorage, &MockAgriUnitStorage{Units: units}, weather.FetcherConfig{})
+
+	request := weather.BackfillRequest{From: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2023, 6, 3, 0, 0, 0, 0, time.UTC)}
+	for range 2 {
+		summary, err := backfiller.Backfill(context.Background(), request)
+		if err != nil {
+			t.Fatalf("Backfill returned an unexpected error: %v", err)
+		}
+		if summary.Days != 1 {
+			t.Errorf("expected only the reported day to be stored, got %+v", summary)
+		}
+	}
+	if len(dailyStorage.Stored[units[0].ID]) != 1 {
+		t.Errorf("expected a single stored day, got %+v", dailyStorage.Stored[units[0].ID])
+	}
+	// The unreported days are asked for again by the next backfill.
+	expected := []string{"2023-06-01..2023-06-03", "2023-06-02..2023-06-03"}
+	if fmt.Sprint(ranges) != fmt.Sprint(expected) {
+		t.Errorf("expected calls %v, got %v", expected, ranges)
+	}
+}
+
+func TestWeatherBackfiller_Backfill_SplitsLongRanges(t *testing.T) {
+	server, ranges := newFakeArchive("")
+	defer server.Close()
+
+	provider := weather.NewOpenMeteoProvider(weather.OpenMeteoConfig{ArchiveURL: server.URL})
+	backfiller := weather.NewWeatherBackfiller(provider, &MockDailyWeatherStorage{}, &MockAgriUnitStorage{Units: newUnits(1)}, weather.FetcherConfig{})
+
+	request := weather.BackfillRequest{From: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2021, 12, 31, 0, 0, 0, 0, time.UTC)}
+	summary, err := backfiller.Backfill(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Backfill returned an unexpected error: %v", err)
+	}
+	if summary.Days != 731 {
+		t.Errorf("expected 731 days stored, got %d", summary.Days)
+	}
+	expected := []string{"40:2020-01-01..2020-12-31", "40:2021-01-01..2021-12-31"}
+	if fmt.Sprint(*ranges) != fmt.Sprint(expected) {
+		t.Errorf("expected calls %v, got %v", expected, *ranges)
+	}
+}
+
+func TestWeatherBackfiller_Backfill_InvalidRange(t *testing.T) {
+	backfiller := weather.NewWeatherBackfiller(weather.NewOpenMeteoProvider(weather.OpenMeteoConfig{}), &MockDailyWeatherStorage{}, &MockAgriUnitStorage{}, weather.FetcherConfig{})
+
+	for name, request := range map[string]weather.BackfillRequest{
+		"Reversed": {From: time.Date(2023, 6, 10, 0, 0, 0, 0, time.UTC), To: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)},
+		"Future":   {From: time.Now().AddDate(0, 0, -1), To: time.Now().AddDate(0, 0, 2)},
+		"Missing":  {},
+	} {
+		if _, err := backfiller.Backfill(context.Background(), request); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package weather
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WeatherForecast is the weather expected for a unit at ForecastTime, as forecast at IssuedAt.
+type WeatherForecast struct {
+	ID                 uuid.UUID  `json:"id"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	UpdatedAt          time.Time  `json:"updatedAt"`
+	ArchivedAt         *time.Time `json:"archivedAt,omitempty"`
+	AgriculturalUnitId uuid.UUID  `json:"agricultural_unit_id"`
+	IssuedAt           time.Time  `json:"issued_at"`
+	ForecastTime       time.Time  `json:"forecast_time"`
+	Provider           string     `json:"provider"`
+	Latitude           float64    `json:"latitude"`
+	Longitude          float64    `json:"longitude"`
+	Temperature        float64    `json:"temperature"`
+	Humidity           int        `json:"humidity"`
+	WindSpeed          float64    `json:"wind_speed"`
+	Clouds             int        `json:"clouds"`
+	WeatherMain        string     `json:"weather_main"`
+	WeatherDesc        string     `json:"weather_desc"`
+}
+
+type WeatherForecastValue struct {
+	AgriculturalUnitId uuid.UUID
+	IssuedAt           time.Time
+	Provider           string
+	Latitude           float64
+	Longitude          float64
+	Conditions         Conditions
+}
+
+func CreateWeatherForecast(value WeatherForecastValue) WeatherForecast {
+	now := time.Now()
+	conditions := value.Conditions
+	return WeatherForecast{
+		ID:                 uuid.New(),
+		CreatedAt:          now,
+		UpdatedAt:          now,
+		AgriculturalUnitId: value.AgriculturalUnitId,
+		IssuedAt:           value.IssuedAt,
+		ForecastTime:       conditions.Time,
+		Provider:           value.Provider,
+		Latitude:           value.Latitude,
+		Longitude:          value.Longitude,
+		Temperature:        conditions.Temperature,
+		Humidity:           conditions.Humidity,
+		WindSpeed:          conditions.WindSpeed,
+		Clouds:             conditions.Clouds,
+		WeatherMain:        conditions.WeatherMain,
+		WeatherDesc:        conditions.WeatherDesc,
+	}
+}
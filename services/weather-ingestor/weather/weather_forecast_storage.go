@@ -0,0 +1,234 @@
+package weather
+
+import (
+	"common/storage"
+	"database/sql"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+type WeatherForecastSqlView struct {
+	ID                 uuid.UUID    `db:"id"`
+	CreatedAt          time.Time    `db:"created_at"`
+	UpdatedAt          time.Time    `db:"updated_at"`
+	ArchivedAt         sql.NullTime `db:"archived_at"`
+	AgriculturalUnitId uuid.UUID    `db:"agricultural_unit_id"`
+	IssuedAt           time.Time    `db:"issued_at"`
+	ForecastTime       time.Time    `db:"forecast_time"`
+	Provider           string       `db:"provider"`
+	Latitude           float64      `db:"latitude"`
+	Longitude          float64      `db:"longitude"`
+	Temperature        float64      `db:"temperature"`
+	Humidity           int          `db:"humidity"`
+	WindSpeed          float64      `db:"wind_speed"`
+	Clouds             int          `db:"clouds"`
+	WeatherMain        string       `db:"weather_main"`
+	WeatherDesc        string       `db:"weather_desc"`
+}
+
+func WeatherForecastToSqlView(f WeatherForecast) WeatherForecastSqlView {
+	var archived sql.NullTime
+	if f.ArchivedAt != nil {
+		archived = sql.NullTime{Time: *f.ArchivedAt, Valid: true}
+	} else {
+		archived = sql.NullTime{Valid: false}
+	}
+
+	return WeatherForecastSqlView{
+		ID:                 f.ID,
+		CreatedAt:          f.CreatedAt,
+		UpdatedAt:          f.UpdatedAt,
+		ArchivedAt:         archived,
+		AgriculturalUnitId: f.AgriculturalUnitId,
+		IssuedAt:           f.IssuedAt,
+		ForecastTime:       f.ForecastTime,
+		Provider:           f.Provider,
+		Latitude:           f.Latitude,
+		Longitude:          f.Longitude,
+		Temperature:        f.Temperature,
+		Humidity:           f.Humidity,
+		WindSpeed:          f.WindSpeed,
+		Clouds:             f.Clouds,
+		WeatherMain:        f.WeatherMain,
+		WeatherDesc:        f.WeatherDesc,
+	}
+}
+
+func WeatherForecastFromSqlView(sqlView WeatherForecastSqlView) WeatherForecast {
+	var archivedAt *time.Time
+	if sqlView.ArchivedAt.Valid {
+		archivedAt = &sqlView.ArchivedAt.Time
+	}
+
+	return WeatherForecast{
+		ID:                 sqlView.ID,
+		CreatedAt:          sqlView.CreatedAt,
+		UpdatedAt:          sqlView.UpdatedAt,
+		ArchivedAt:         archivedAt,
+		AgriculturalUnitId: sqlView.AgriculturalUnitId,
+		IssuedAt:           sqlView.IssuedAt,
+		ForecastTime:       sqlView.ForecastTime,
+		Provider:           sqlView.Provider,
+		Latitude:           sqlView.Latitude,
+		Longitude:          sqlView.Longitude,
+		Temperature:        sqlView.Temperature,
+		Humidity:           sqlView.Humidity,
+		WindSpeed:          sqlView.WindSpeed,
+		Clouds:             sqlView.Clouds,
+		WeatherMain:        sqlView.WeatherMain,
+		WeatherDesc:        sqlView.WeatherDesc,
+	}
+}
+
+type WeatherForecastStorage interface {
+	// InsertBatch stores the steps of forecasts.
+	InsertBatch(steps []WeatherForecast) error
+	// SelectLatest returns the steps of the latest forecast issued for a unit, in time order.
+	SelectLatest(unitID uuid.UUID) ([]WeatherForecast, error)
+	// DeleteSuperseded deletes the forecasts issued before cutoff that a newer one replaced.
+	DeleteSuperseded(cutoff time.Time) (int64, error)
+}
+
+const maxForecastStepsPerStatement = 1000
+
+var weatherForecastColumns = []string{
+	"id",
+	"created_at",
+	"updated_at",
+	"archived_at",
+	"agricultural_unit_id",
+	"issued_at",
+	"forecast_time",
+	"provider",
+	"latitude",
+	"longitude",
+	"temperature",
+	"humidity",
+	"wind_speed",
+	"clouds",
+	"weather_main",
+	"weather_desc",
+}
+
+const deleteSupersededForecastsSQL = `
+	DELETE FROM weather_forecasts f
+	WHERE f.issued_at < $1
+	AND EXISTS (
+		SELECT 1 FROM weather_forecasts newer
+		WHERE newer.agricultural_unit_id = f.agricultural_unit_id AND newer.issued_at > f.issued_at
+	)`
+
+type weatherForecastStorage struct {
+	querier storage.DBQuerier
+	builder sq.StatementBuilderType
+}
+
+func NewWeatherForecastStorage(querier storage.DBQuerier) WeatherForecastStorage {
+	return &weatherForecastStorage{
+		querier: querier,
+		builder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+}
+
+func (s *weatherForecastStorage) InsertBatch(steps []WeatherForecast) error {
+	for start := 0; start < len(steps); start += maxForecastStepsPerStatement {
+		end := min(start+maxForecastStepsPerStatement, len(steps))
+
+		builder := s.builder.Insert("weather_forecasts").Columns(weatherForecastColumns...)
+		for _, step := range steps[start:end] {
+			sqlView := WeatherForecastToSqlView(step)
+			builder = builder.Values(
+				sqlView.ID,
+				sqlView.CreatedAt,
+				sqlView.UpdatedAt,
+				sqlView.ArchivedAt,
+				sqlView.AgriculturalUnitId,
+				sqlView.IssuedAt,
+				sqlView.ForecastTime,
+				sqlView.Provider,
+				sqlView.Latitude,
+				sqlView.Longitude,
+				sqlView.Temperature,
+				sqlView.Humidity,
+				sqlView.WindSpeed,
+				sqlView.Clouds,
+				sqlView.WeatherMain,
+				sqlView.WeatherDesc,
+			)
+		}
+
+		query, args, err := builder.ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build InsertBatch SQL for WeatherForecast: %w", err)
+		}
+
+		if _, err := s.querier.Exec(query, args...); err != nil {
+			return fmt.Errorf("failed to execute InsertBatch for WeatherForecast: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *weatherForecastStorage) SelectLatest(unitID uuid.UUID) ([]WeatherForecast, error) {
+	latestIssue := sq.Expr("issued_at = (SELECT MAX(issued_at) FROM weather_forecasts WHERE agricultural_unit_id = ?)", unitID)
+	query, args, err := s.builder.Select(weatherForecastColumns...).
+		From("weather_forecasts").
+		Where(sq.Eq{"agricultural_unit_id": unitID}).
+		Where(latestIssue).
+		OrderBy("forecast_time").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build SelectLatest SQL for WeatherForecast: %w", err)
+	}
+
+	rows, err := s.querier.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute SelectLatest for WeatherForecast: %w", err)
+	}
+	defer rows.Close()
+
+	var steps []WeatherForecast
+	for rows.Next() {
+		var sqlView WeatherForecastSqlView
+		if err := rows.Scan(
+			&sqlView.ID,
+			&sqlView.CreatedAt,
+			&sqlView.UpdatedAt,
+			&sqlView.ArchivedAt,
+			&sqlView.AgriculturalUnitId,
+			&sqlView.IssuedAt,
+			&sqlView.ForecastTime,
+			&sqlView.Provider,
+			&sqlView.Latitude,
+			&sqlView.Longitude,
+			&sqlView.Temperature,
+			&sqlView.Humidity,
+			&sqlView.WindSpeed,
+			&sqlView.Clouds,
+			&sqlView.WeatherMain,
+			&sqlView.WeatherDesc,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan weather forecast row: %w", err)
+		}
+		steps = append(steps, WeatherForecastFromSqlView(sqlView))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over weather forecast rows: %w", err)
+	}
+	return steps, nil
+}
+
+func (s *weatherForecastStorage) DeleteSuperseded(cutoff time.Time) (int64, error) {
+	result, err := s.querier.Exec(deleteSupersededForecastsSQL, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete superseded weather forecasts: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count deleted weather forecasts: %w", err)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,126 @@
+package weather
+
+import (
+	"common/testutils"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+const weatherForecastColumnList = "id,created_at,updated_at,archived_at,agricultural_unit_id,issued_at,forecast_time,provider,latitude,longitude," +
+	"temperature,humidity,wind_speed,clouds,weather_main,weather_desc"
+
+func TestWeatherForecastStorage_InsertBatch(t *testing.T) {
+	mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+	if err != nil {
+		t.Fatalf("failed to create mock querier: %v", err)
+	}
+	defer mockQuerierInstance.Db.Close()
+
+	issuedAt := time.Date(2024, 6, 1, 6, 0, 0, 0, time.UTC)
+	step := CreateWeatherForecast(WeatherForecastValue{
+		AgriculturalUnitId: uuid.New(),
+		IssuedAt:           issuedAt,
+		Provider:           ProviderOpenWeather,
+		Latitude:           45.76,
+		Longitude:          4.85,
+		Conditions:         Conditions{Time: issuedAt.Add(3 * time.Hour), Temperature: 18, Humidity: 70, WeatherMain: "Rain", WeatherDesc: "light rain"},
+	})
+
+	expectedSQL := "INSERT INTO weather_forecasts (" + weatherForecastColumnList + ") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16)"
+	sqlMock.ExpectExec(regexp.QuoteMeta(expectedSQL)).
+		WithArgs(
+			step.ID,
+			step.CreatedAt,
+			step.UpdatedAt,
+			sql.NullTime{Valid: false},
+			step.AgriculturalUnitId,
+			issuedAt,
+			issuedAt.Add(3*time.Hour),
+			ProviderOpenWeather,
+			45.76,
+			4.85,
+			18.0,
+			70,
+			0.0,
+			0,
+			"Rain",
+			"light rain",
+		).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := NewWeatherForecastStorage(mockQuerierInstance).InsertBatch([]WeatherForecast{step}); err != nil {
+		t.Fatalf("InsertBatch returned an unexpected error: %v", err)
+	}
+
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestWeatherForecastStorage_SelectLatest(t *testing.T) {
+	mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+	if err != nil {
+		t.Fatalf("failed to create mock querier: %v", err)
+	}
+	defer mockQuerierInstance.Db.Close()
+
+	unitID := uuid.New()
+	now := time.Now().Truncate(time.Millisecond)
+	issuedAt := time.Date(2024, 6, 1, 6, 0, 0, 0, time.UTC)
+
+	expectedSQL := "SELECT " + regexp.MustCompile(",").ReplaceAllString(weatherForecastColumnList, ", ") + " FROM weather_forecasts " +
+		"WHERE agricultural_unit_id = $1 AND issued_at = (SELECT MAX(issued_at) FROM weather_forecasts WHERE agricultural_unit_id = $2) " +
+		"ORDER BY forecast_time"
+	rows := sqlMock.NewRows([]string{"id", "created_at", "updated_at", "archived_at", "agricultural_unit_id", "issued_at", "forecast_time", "provider",
+		"latitude", "longitude", "temperature", "humidity", "wind_speed", "clouds", "weather_main", "weather_desc"})
+	for step := 1; step <= 2; step++ {
+		rows.AddRow(uuid.New(), now, now, nil, unitID, issuedAt, issuedAt.Add(time.Duration(step)*time.Hour), ProviderOpenMeteo,
+			45.76, 4.85, 15.0+float64(step), 80, 2.5, 100, "Clouds", "overcast")
+	}
+	sqlMock.ExpectQuery(regexp.QuoteMeta(expectedSQL)).WithArgs(unitID, unitID).WillReturnRows(rows)
+
+	steps, err := NewWeatherForecastStorage(mockQuerierInstance).SelectLatest(unitID)
+	if err != nil {
+		t.Fatalf("SelectLatest returned an unexpected error: %v", err)
+	}
+	if len(steps) != 2 {
+		t.Fatalf("expected 2 steps, got %d", len(steps))
+	}
+	if !steps[1].IssuedAt.Equal(issuedAt) || steps[1].Temperature != 17 || steps[1].Provider != ProviderOpenMeteo {
+		t.Errorf("unexpected second step %+v", steps[1])
+	}
+
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestWeatherForecastStorage_DeleteSuperseded(t *testing.T) {
+	mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
+	if err != nil {
+		t.Fatalf("failed to create mock querier: %v", err)
+	}
+	defer mockQuerierInstance.Db.Close()
+
+	cutoff := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	sqlMock.ExpectExec(regexp.QuoteMeta("DELETE FROM weather_forecasts f")).
+		WithArgs(cutoff).
+		WillReturnResult(sqlmock.NewResult(0, 48))
+
+	deleted, err := NewWeatherForecastStorage(mockQuerierInstance).DeleteSuperseded(cutoff)
+	if err != nil {
+		t.Fatalf("DeleteSuperseded returned an unexpected error: %v", err)
+	}
+	if deleted != 48 {
+		t.Errorf("expected 48 steps deleted, got %d", deleted)
+	}
+
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
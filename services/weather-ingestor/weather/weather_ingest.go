@@ -1,102 +1,307 @@
 package weather
 
 import (
-	"encoding/json"
+	"common/agri"
+	"context"
+	"errors"
 	"fmt"
-	"net/http"
-
-	"github.com/google/uuid"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// DefaultConcurrency is how many units a run fetches at the same time by default.
+const DefaultConcurrency = 8
+
+type FetcherConfig struct {
+	Concurrency int
+	// Limiter spaces the calls to the API, a nil Limiter leaving them unlimited.
+	Limiter *RateLimiter
+	// ForecastDays is the length of the forecast fetched with the current weather, none when zero.
+	ForecastDays int
+	// GridPrecision is the geohash length of the cells whose units share a fetch, none when zero.
+	GridPrecision int
+}
+
+type unitRunner struct {
+	agriUnitStorage agri.AgriUnitStorage
+	concurrency     int
+	limiter         *RateLimiter
+}
+
+func newUnitRunner(aus agri.AgriUnitStorage, config FetcherConfig) unitRunner {
+	concurrency := config.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	limiter := config.Limiter
+	if limiter == nil {
+		limiter = NewRateLimiter(RateLimit{})
+	}
+	return unitRunner{
+		agriUnitStorage: aus,
+		concurrency:     concurrency,
+		limiter:         limiter,
+	}
+}
+
 type WeatherFetcher struct {
-	apiURL          string
-	apiKey          string
+	unitRunner
+	provider        WeatherProvider
 	weatherStorage  WeatherStorage
-	agriUnitStorage AgriUnitStorage
+	forecastStorage WeatherForecastStorage
+	forecastDays    int
+	gridPrecision   int
 }
 
-func NewWeatherFetcher(apiURL, apiKey string, ws WeatherStorage, aus AgriUnitStorage) *WeatherFetcher {
+// NewWeatherFetcher returns a fetcher of the current weather and forecast of the units.
+func NewWeatherFetcher(provider WeatherProvider, ws WeatherStorage, fs WeatherForecastStorage, aus agri.AgriUnitStorage, config FetcherConfig) *WeatherFetcher {
 	return &WeatherFetcher{
-		apiURL:          apiURL,
-		apiKey:          apiKey,
+		unitRunner:      newUnitRunner(aus, config),
+		provider:        provider,
 		weatherStorage:  ws,
-		agriUnitStorage: aus,
+		forecastStorage: fs,
+		forecastDays:    config.ForecastDays,
+		gridPrecision:   min(config.GridPrecision, MaxGridPrecision),
 	}
 }
 
-func (wf *WeatherFetcher) HandleWeatherIngest() error {
-	units, err := wf.agriUnitStorage.SelectAll()
-	if err != nil {
-		return fmt.Errorf("failed to fetch agri units: %w", err)
-	}
+// IngestSummary counts what a run did with each unit.
+type IngestSummary struct {
+	Units      int `json:"units"`
+	Fetched    int `json:"fetched"`
+	Failed     int `json:"failed"`
+	Skipped    int `json:"skipped"`
+	UpToDate   int `json:"upToDate,omitempty"`
+	Forecasts  int `json:"forecasts,omitempty"`
+	CallsSaved int `json:"callsSaved,omitempty"`
+}
 
-	for _, unit := range units {
-		weather, err := wf.fetchWeather(unit.Latitude, unit.Longitude, unit.ID)
-		if err != nil {
-			fmt.Printf("failed to fetch weather for unit %v: %v\n", unit.ID, err)
-			continue
+var errUpToDate = errors.New("nothing to fetch")
+
+var errCellNotFetched = errors.New("weather of the grid cell not fetched")
+
+const unitPageSize = 500
+
+// HandleWeatherIngest fetches and stores the current weather and forecast of every active unit.
+func (wf *WeatherFetcher) HandleWeatherIngest(ctx context.Context) (IngestSummary, error) {
+	cells := cellFetches{fetches: make(map[string]*cellFetch)}
+	var forecasts, saved atomic.Int64
+	summary, err := wf.run(ctx, agri.AgriUnitQuery{}, func(ctx context.Context, unit agri.AgriculturalUnit) error {
+		cell, lat, lon := wf.cellOf(unit)
+		fetch, shared := cells.get(cell)
+		fetch.once.Do(func() { wf.fetchCell(ctx, fetch, lat, lon) })
+
+		if fetch.currentErr != nil {
+			return sharedFetchErr(fetch.currentErr, shared)
+		}
+		if err := wf.saveWeather(unit, cell, lat, lon, fetch.current); err != nil {
+			return err
+		}
+		if shared {
+			saved.Add(1)
 		}
 
-		if err := wf.weatherStorage.InsertOrUpdate(weather); err != nil {
-			fmt.Printf("failed to save weather for unit %v: %v\n", unit.ID, err)
+		if wf.forecastDays == 0 {
+			return nil
+		}
+		if fetch.forecastErr != nil {
+			return sharedFetchErr(fetch.forecastErr, shared)
 		}
+		if err := wf.saveForecast(unit, lat, lon, fetch.issuedAt, fetch.forecast); err != nil {
+			return err
+		}
+		if shared {
+			saved.Add(1)
+		}
+		forecasts.Add(1)
+		return nil
+	})
+	summary.Forecasts = int(forecasts.Load())
+	summary.CallsSaved = int(saved.Load())
+	return summary, err
+}
+
+func sharedFetchErr(err error, shared bool) error {
+	if shared {
+		return fmt.Errorf("%w: %w", errCellNotFetched, err)
 	}
+	return err
+}
 
-	return nil
+type cellFetch struct {
+	once        sync.Once
+	current     Conditions
+	currentErr  error
+	issuedAt    time.Time
+	forecast    []Conditions
+	forecastErr error
 }
 
-func (wf *WeatherFetcher) fetchWeather(lat, lon float64, agriUnitId uuid.UUID) (Weather, error) {
-	url := fmt.Sprintf("%s?lat=%f&lon=%f&appid=%s&units=metric", wf.apiURL, lat, lon, wf.apiKey)
+type cellFetches struct {
+	mu      sync.Mutex
+	fetches map[string]*cellFetch
+}
 
-	resp, err := http.Get(url)
+func (c *cellFetches) get(cell string) (*cellFetch, bool) {
+	if cell == "" {
+		return &cellFetch{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if fetch, exists := c.fetches[cell]; exists {
+		return fetch, true
+	}
+	fetch := &cellFetch{}
+	c.fetches[cell] = fetch
+	return fetch, false
+}
+
+func (wf *WeatherFetcher) cellOf(unit agri.AgriculturalUnit) (string, float64, float64) {
+	if wf.gridPrecision <= 0 {
+		return "", unit.Latitude, unit.Longitude
+	}
+	return geohashCell(unit.Latitude, unit.Longitude, wf.gridPrecision)
+}
+
+func (wf *WeatherFetcher) fetchCell(ctx context.Context, fetch *cellFetch, lat, lon float64) {
+	if err := wf.limiter.Wait(ctx); err != nil {
+		fetch.currentErr = err
+		return
+	}
+	conditions, err := wf.provider.Current(ctx, lat, lon)
 	if err != nil {
-		return Weather{}, fmt.Errorf("api request failed: %w", err)
+		fetch.currentErr = fmt.Errorf("failed to fetch weather: %w", err)
+		return
 	}
-	defer resp.Body.Close()
+	fetch.current = conditions
 
-	if resp.StatusCode != http.StatusOK {
-		return Weather{}, fmt.Errorf("unexpected status: %s", resp.Status)
+	if wf.forecastDays == 0 {
+		return
 	}
+	if err := wf.limiter.Wait(ctx); err != nil {
+		fetch.forecastErr = err
+		return
+	}
+	// The steps of a forecast are all issued at the time of the fetch.
+	fetch.issuedAt = time.Now().UTC().Truncate(time.Second)
+	forecast, err := wf.provider.Forecast(ctx, lat, lon, wf.forecastDays)
+	if err != nil {
+		fetch.forecastErr = fmt.Errorf("failed to fetch forecast: %w", err)
+		return
+	}
+	fetch.forecast = forecast
+}
 
-	var data struct {
-		Main struct {
-			Temp     float64 `json:"temp"`
-			Humidity int     `json:"humidity"`
-		} `json:"main"`
-		Wind struct {
-			Speed float64 `json:"speed"`
-		} `json:"wind"`
-		Clouds struct {
-			All int `json:"all"`
-		} `json:"clouds"`
-		Weather []struct {
-			Main        string `json:"main"`
-			Description string `json:"description"`
-		} `json:"weather"`
+func (r unitRunner) run(ctx context.Context, query agri.AgriUnitQuery, work func(context.Context, agri.AgriculturalUnit) error) (IngestSummary, error) {
+	var (
+		mu      sync.Mutex
+		summary IngestSummary
+	)
+	count := func(counter *int) {
+		mu.Lock()
+		*counter++
+		mu.Unlock()
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return Weather{}, fmt.Errorf("decoding failed: %w", err)
+	units := make(chan agri.AgriculturalUnit)
+	var workers sync.WaitGroup
+	for range r.concurrency {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for unit := range units {
+				switch err := work(ctx, unit); {
+				case err == nil:
+					count(&summary.Fetched)
+				case errors.Is(err, errUpToDate):
+					count(&summary.UpToDate)
+				case errors.Is(err, ErrDailyQuotaExhausted), errors.Is(err, errCellNotFetched), ctx.Err() != nil:
+					count(&summary.Skipped)
+				default:
+					log.Printf("Failed to fetch weather for unit %v: %v\n", unit.ID, err)
+					count(&summary.Failed)
+				}
+			}
+		}()
 	}
 
-	main := ""
-	desc := ""
-	if len(data.Weather) > 0 {
-		main = data.Weather[0].Main
-		desc = data.Weather[0].Description
+	err := r.eachUnit(ctx, query, func(unit agri.AgriculturalUnit) error {
+		count(&summary.Units)
+		select {
+		case units <- unit:
+			return nil
+		case <-ctx.Done():
+			count(&summary.Skipped)
+			return ctx.Err()
+		}
+	})
+	close(units)
+	workers.Wait()
+
+	return summary, err
+}
+
+func (r unitRunner) eachUnit(ctx context.Context, query agri.AgriUnitQuery, visit func(agri.AgriculturalUnit) error) error {
+	query.Limit = unitPageSize
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		units, err := r.agriUnitStorage.Select(query)
+		if err != nil {
+			return fmt.Errorf("failed to fetch agri units: %w", err)
+		}
+
+		for _, unit := range units {
+			if err := visit(unit); err != nil {
+				return err
+			}
+		}
+
+		if len(units) < unitPageSize {
+			return nil
+		}
+		lastIDNum := units[len(units)-1].IDNum
+		query.AfterIDNum = &lastIDNum
 	}
+}
 
+func (wf *WeatherFetcher) saveWeather(unit agri.AgriculturalUnit, cell string, lat, lon float64, conditions Conditions) error {
 	weather := CreateWeather(WeatherValue{
 		Latitude:           lat,
 		Longitude:          lon,
-		Temperature:        data.Main.Temp,
-		Humidity:           data.Main.Humidity,
-		WindSpeed:          data.Wind.Speed,
-		Clouds:             data.Clouds.All,
-		WeatherMain:        main,
-		WeatherDesc:        desc,
-		AgriculturalUnitId: agriUnitId,
+		Temperature:        conditions.Temperature,
+		Humidity:           conditions.Humidity,
+		WindSpeed:          conditions.WindSpeed,
+		Clouds:             conditions.Clouds,
+		WeatherMain:        conditions.WeatherMain,
+		WeatherDesc:        conditions.WeatherDesc,
+		Provider:           wf.provider.Name(),
+		CellID:             cell,
+		AgriculturalUnitId: unit.ID,
 	})
+	if err := wf.weatherStorage.InsertOrUpdate(weather); err != nil {
+		return fmt.Errorf("failed to save weather: %w", err)
+	}
+	return nil
+}
 
-	return weather, nil
+func (wf *WeatherFetcher) saveForecast(unit agri.AgriculturalUnit, lat, lon float64, issuedAt time.Time, forecast []Conditions) error {
+	steps := make([]WeatherForecast, len(forecast))
+	for i, conditions := range forecast {
+		steps[i] = CreateWeatherForecast(WeatherForecastValue{
+			AgriculturalUnitId: unit.ID,
+			IssuedAt:           issuedAt,
+			Provider:           wf.provider.Name(),
+			Latitude:           lat,
+			Longitude:          lon,
+			Conditions:         conditions,
+		})
+	}
+	if err := wf.forecastStorage.InsertBatch(steps); err != nil {
+		return fmt.Errorf("failed to save forecast: %w", err)
+	}
+	return nil
 }
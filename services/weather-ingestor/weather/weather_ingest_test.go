@@ -1,19 +1,35 @@
 package weather_test
 
 import (
+	"common/agri"
+	"common/storage"
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 	"weather-ingestor/weather"
 
 	"github.com/google/uuid"
 )
 
-type MockAgriUnitStorage struct{}
+type MockAgriUnitStorage struct {
+	Units   []agri.AgriculturalUnit
+	Queries []agri.AgriUnitQuery
+}
 
-func (m *MockAgriUnitStorage) SelectAll() ([]weather.AgriculturalUnit, error) {
-	return []weather.AgriculturalUnit{
+func (m *MockAgriUnitStorage) SelectAll() ([]agri.AgriculturalUnit, error) {
+	if m.Units != nil {
+		return m.Units, nil
+	}
+	return []agri.AgriculturalUnit{
 		{
 			ID:        uuid.New(),
 			Latitude:  45.76,
@@ -22,17 +38,109 @@ func (m *MockAgriUnitStorage) SelectAll() ([]weather.AgriculturalUnit, error) {
 	}, nil
 }
 
+func (m *MockAgriUnitStorage) Select(query agri.AgriUnitQuery) ([]agri.AgriculturalUnit, error) {
+	m.Queries = append(m.Queries, query)
+	if query.AfterIDNum != nil {
+		return nil, nil
+	}
+	return m.SelectAll()
+}
+
+func (m *MockAgriUnitStorage) Each(query agri.AgriUnitQuery, visit func(agri.AgriculturalUnit) error) error {
+	units, err := m.Select(query)
+	if err != nil {
+		return err
+	}
+	for _, unit := range units {
+		if err := visit(unit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MockAgriUnitStorage) SelectByID(id uuid.UUID) (agri.AgriculturalUnit, error) {
+	return agri.AgriculturalUnit{}, sql.ErrNoRows
+}
+
+func (m *MockAgriUnitStorage) SelectByIDNum(idNum int) (agri.AgriculturalUnit, error) {
+	return agri.AgriculturalUnit{}, sql.ErrNoRows
+}
+
+func (m *MockAgriUnitStorage) InsertOrUpdate(unit agri.AgriculturalUnit) error {
+	return nil
+}
+
+func (m *MockAgriUnitStorage) InsertOrUpdateBatch(units []agri.AgriculturalUnit) error {
+	return nil
+}
+
+func (m *MockAgriUnitStorage) WithQuerier(querier storage.DBQuerier) agri.AgriUnitStorage {
+	return m
+}
+
 type MockWeatherStorage struct {
+	mu     sync.Mutex
 	Called bool
 	Last   weather.Weather
+	Saved  int
+	Stored []weather.Weather
 }
 
 func (m *MockWeatherStorage) InsertOrUpdate(w weather.Weather) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.Called = true
 	m.Last = w
+	m.Saved++
+	m.Stored = append(m.Stored, w)
 	return nil
 }
 
+type MockWeatherForecastStorage struct {
+	mu    sync.Mutex
+	Steps []weather.WeatherForecast
+}
+
+func (m *MockWeatherForecastStorage) InsertBatch(steps []weather.WeatherForecast) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Steps = append(m.Steps, steps...)
+	return nil
+}
+
+func (m *MockWeatherForecastStorage) SelectLatest(unitID uuid.UUID) ([]weather.WeatherForecast, error) {
+	return nil, nil
+}
+
+func (m *MockWeatherForecastStorage) DeleteSuperseded(cutoff time.Time) (int64, error) {
+	return 0, nil
+}
+
+// newUnits returns count units, the i-th at latitude 40 + i.
+func newUnits(count int) []agri.AgriculturalUnit {
+	units := make([]agri.AgriculturalUnit, count)
+	for i := range units {
+		units[i] = agri.AgriculturalUnit{ID: uuid.New(), IDNum: i + 1, Latitude: 40 + float64(i), Longitude: 2}
+	}
+	return units
+}
+
+// newFakeProvider serves the OpenWeather current-weather response unless handle does.
+func newFakeProvider(handle func(w http.ResponseWriter, r *http.Request) bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handle != nil && handle(w, r) {
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"main":    map[string]interface{}{"temp": 18.5, "humidity": 60},
+			"wind":    map[string]interface{}{"speed": 3.1},
+			"clouds":  map[string]interface{}{"all": 10},
+			"weather": []map[string]interface{}{{"main": "Clear", "description": "clear sky"}},
+		})
+	}))
+}
+
 func TestWeatherFetcher_Run_Success(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		resp := map[string]interface{}{
@@ -57,12 +165,15 @@ func TestWeatherFetcher_Run_Success(t *testing.T) {
 	mockWeatherStorage := &MockWeatherStorage{}
 	mockAgriUnitStorage := &MockAgriUnitStorage{}
 
-	fetcher := weather.NewWeatherFetcher(server.URL, "dummy", mockWeatherStorage, mockAgriUnitStorage)
+	fetcher := weather.NewWeatherFetcher(weather.NewOpenWeatherProvider(server.URL, "dummy"), mockWeatherStorage, nil, mockAgriUnitStorage, weather.FetcherConfig{})
 
-	err := fetcher.HandleWeatherIngest()
+	summary, err := fetcher.HandleWeatherIngest(context.Background())
 	if err != nil {
 		t.Fatalf("Run returned error: %v", err)
 	}
+	if summary != (weather.IngestSummary{Units: 1, Fetched: 1}) {
+		t.Errorf("expected 1 unit fetched, got %+v", summary)
+	}
 
 	if !mockWeatherStorage.Called {
 		t.Errorf("InsertOrUpdate was not called")
@@ -81,4 +192,274 @@ func TestWeatherFetcher_Run_Success(t *testing.T) {
 	if got.Humidity != 55 {
 		t.Errorf("expected Humidity 55, got %d", got.Humidity)
 	}
+	if got.Provider != weather.ProviderOpenWeather {
+		t.Errorf("expected Provider '%s', got '%s'", weather.ProviderOpenWeather, got.Provider)
+	}
+
+	for _, query := range mockAgriUnitStorage.Queries {
+		if query.Archived != agri.ArchivedExcluded {
+			t.Errorf("expected archived units to be skipped, got archived filter '%s'", query.Archived)
+		}
+	}
+}
+
+func TestWeatherFetcher_Run_Concurrency(t *testing.T) {
+	const concurrency = 4
+	var inFlight, maxInFlight atomic.Int32
+	server := newFakeProvider(func(w http.ResponseWriter, r *http.Request) bool {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			seen := maxInFlight.Load()
+			if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		return false
+	})
+	defer server.Close()
+
+	mockWeatherStorage := &MockWeatherStorage{}
+	fetcher := weather.NewWeatherFetcher(weather.NewOpenWeatherProvider(server.URL, "dummy"), mockWeatherStorage, nil, &MockAgriUnitStorage{Units: newUnits(20)},
+		weather.FetcherConfig{Concurrency: concurrency})
+
+	summary, err := fetcher.HandleWeatherIngest(context.Background())
+	if err != nil {
+		t.Fatalf("HandleWeatherIngest returned an unexpected error: %v", err)
+	}
+	if summary != (weather.IngestSummary{Units: 20, Fetched: 20}) {
+		t.Errorf("expected 20 units fetched, got %+v", summary)
+	}
+	if mockWeatherStorage.Saved != 20 {
+		t.Errorf("expected 20 weather rows saved, got %d", mockWeatherStorage.Saved)
+	}
+	if got := maxInFlight.Load(); got > concurrency {
+		t.Errorf("expected at most %d requests at a time, got %d", concurrency, got)
+	}
+}
+
+func TestWeatherFetcher_Run_Failures(t *testing.T) {
+	server := newFakeProvider(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Query().Get("lat") == "41" {
+			http.Error(w, "upstream error", http.StatusBadGateway)
+			return true
+		}
+		return false
+	})
+	defer server.Close()
+
+	mockWeatherStorage := &MockWeatherStorage{}
+	fetcher := weather.NewWeatherFetcher(weather.NewOpenWeatherProvider(server.URL, "dummy"), mockWeatherStorage, nil, &MockAgriUnitStorage{Units: newUnits(3)},
+		weather.FetcherConfig{Concurrency: 2})
+
+	summary, err := fetcher.HandleWeatherIngest(context.Background())
+	if err != nil {
+		t.Fatalf("HandleWeatherIngest returned an unexpected error: %v", err)
+	}
+	if summary != (weather.IngestSummary{Units: 3, Fetched: 2, Failed: 1}) {
+		t.Errorf("expected 2 units fetched and 1 failed, got %+v", summary)
+	}
+}
+
+func TestWeatherFetcher_Run_DailyQuota(t *testing.T) {
+	var calls atomic.Int32
+	server := newFakeProvider(func(w http.ResponseWriter, r *http.Request) bool {
+		calls.Add(1)
+		return false
+	})
+	defer server.Close()
+
+	fetcher := weather.NewWeatherFetcher(weather.NewOpenWeatherProvider(server.URL, "dummy"), &MockWeatherStorage{}, nil, &MockAgriUnitStorage{Units: newUnits(5)},
+		weather.FetcherConfig{Concurrency: 2, Limiter: weather.NewRateLimiter(weather.RateLimit{PerDay: 2})})
+
+	summary, err := fetcher.HandleWeatherIngest(context.Background())
+	if err != nil {
+		t.Fatalf("HandleWeatherIngest returned an unexpected error: %v", err)
+	}
+	if summary != (weather.IngestSummary{Units: 5, Fetched: 2, Skipped: 3}) {
+		t.Errorf("expected 2 units fetched and 3 skipped, got %+v", summary)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected 2 calls to the provider, got %d", calls.Load())
+	}
+}
+
+func TestWeatherFetcher_Run_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	server := newFakeProvider(func(w http.ResponseWriter, r *http.Request) bool {
+		cancel()
+		<-r.Context().Done()
+		return true
+	})
+	defer server.Close()
+
+	mockWeatherStorage := &MockWeatherStorage{}
+	fetcher := weather.NewWeatherFetcher(weather.NewOpenWeatherProvider(server.URL, "dummy"), mockWeatherStorage, nil, &MockAgriUnitStorage{Units: newUnits(10)},
+		weather.FetcherConfig{Concurrency: 1})
+
+	summary, err := fetcher.HandleWeatherIngest(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if summary.Fetched != 0 || summary.Failed != 0 || summary.Skipped != summary.Units {
+		t.Errorf("expected every unit read to be skipped, got %+v", summary)
+	}
+	if mockWeatherStorage.Called {
+		t.Error("expected no weather to be saved after cancellation")
+	}
+}
+
+func TestWeatherFetcher_Run_Forecast(t *testing.T) {
+	server := newFakeProvider(func(w http.ResponseWriter, r *http.Request) bool {
+		if !strings.HasSuffix(r.URL.Path, "/forecast") {
+			return false
+		}
+		if r.URL.Query().Get("cnt") != "40" {
+			t.Errorf("expected a 5-day forecast, got %s", r.URL)
+		}
+		w.Write([]byte(`{"list": [
+			{"dt": 1717243200, "main": {"temp": 20, "humidity": 50}, "weather": [{"main": "Clear", "description": "clear sky"}]},
+			{"dt": 1717254000, "main": {"temp": 17.5, "humidity": 70}, "weather": [{"main": "Rain", "description": "light rain"}]}
+		]}`))
+		return true
+	})
+	defer server.Close()
+
+	units := newUnits(3)
+	forecastStorage := &MockWeatherForecastStorage{}
+	fetcher := weather.NewWeatherFetcher(weather.NewOpenWeatherProvider(server.URL, "dummy"), &MockWeatherStorage{}, forecastStorage,
+		&MockAgriUnitStorage{Units: units}, weather.FetcherConfig{Concurrency: 2, ForecastDays: 5})
+
+	summary, err := fetcher.HandleWeatherIngest(context.Background())
+	if err != nil {
+		t.Fatalf("HandleWeatherIngest returned an unexpected error: %v", err)
+	}
+	if summary != (weather.IngestSummary{Units: 3, Fetched: 3, Forecasts: 3}) {
+		t.Errorf("expected 3 units fetched with their forecast, got %+v", summary)
+	}
+	if len(forecastStorage.Steps) != 6 {
+		t.Fatalf("expected 2 steps per unit, got %d", len(forecastStorage.Steps))
+	}
+
+	issues := make(map[uuid.UUID]time.Time)
+	for _, step := range forecastStorage.Steps {
+		if issuedAt, seen := issues[step.AgriculturalUnitId]; seen && !issuedAt.Equal(step.IssuedAt) {
+			t.Errorf("expected the steps of a unit to share their issue time, got %v and %v", issuedAt, step.IssuedAt)
+		}
+		issues[step.AgriculturalUnitId] = step.IssuedAt
+		if step.IssuedAt.IsZero() || step.Provider != weather.ProviderOpenWeather {
+			t.Errorf("unexpected step %+v", step)
+		}
+	}
+	if len(issues) != 3 {
+		t.Errorf("expected a forecast for each of the 3 units, got %d", len(issues))
+	}
+}
+
+// gridPrecision groups units in cells of about 1.2 by 0.6 km.
+const gridPrecision = 6
+
+func TestWeatherFetcher_Run_GridCells(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	server := newFakeProvider(func(w http.ResponseWriter, r *http.Request) bool {
+		mu.Lock()
+		calls[r.URL.Path]++
+		mu.Unlock()
+		if strings.HasSuffix(r.URL.Path, "/forecast") {
+			w.Write([]byte(`{"list": [{"dt": 1717243200, "main": {"temp": 20, "humidity": 50}, "weather": [{"main": "Clear", "description": "clear sky"}]}]}`))
+			return true
+		}
+		return false
+	})
+	defer server.Close()
+
+	// Three farms within a few hundred metres near Lyon, and one in Paris.
+	units := []agri.AgriculturalUnit{
+		{ID: uuid.New(), IDNum: 1, Latitude: 45.7640, Longitude: 4.8357},
+		{ID: uuid.New(), IDNum: 2, Latitude: 45.7650, Longitude: 4.8370},
+		{ID: uuid.New(), IDNum: 3, Latitude: 45.7645, Longitude: 4.8362},
+		{ID: uuid.New(), IDNum: 4, Latitude: 48.8566, Longitude: 2.3522},
+	}
+	weatherStorage := &MockWeatherStorage{}
+	forecastStorage := &MockWeatherForecastStorage{}
+	fetcher := weather.NewWeatherFetcher(weather.NewOpenWeatherProvider(server.URL, "dummy"), weatherStorage, forecastStorage,
+		&MockAgriUnitStorage{Units: units}, weather.FetcherConfig{Concurrency: 4, ForecastDays: 1, GridPrecision: gridPrecision})
+
+	summary, err := fetcher.HandleWeatherIngest(context.Background())
+	if err != nil {
+		t.Fatalf("HandleWeatherIngest returned an unexpected error: %v", err)
+	}
+	if summary != (weather.IngestSummary{Units: 4, Fetched: 4, Forecasts: 4, CallsSaved: 4}) {
+		t.Errorf("expected 4 units fetched with 4 calls saved, got %+v", summary)
+	}
+	if calls["/weather"] != 2 || calls["/forecast"] != 2 {
+		t.Errorf("expected a call of each kind per cell, got %v", calls)
+	}
+
+	if len(weatherStorage.Stored) != 4 || len(forecastStorage.Steps) != 4 {
+		t.Fatalf("expected the weather and forecast of every unit, got %d and %d", len(weatherStorage.Stored), len(forecastStorage.Steps))
+	}
+	cells := make(map[uuid.UUID]string)
+	for _, stored := range weatherStorage.Stored {
+		if len(stored.CellID) != gridPrecision {
+			t.Errorf("expected a cell ID of %d characters, got '%s'", gridPrecision, stored.CellID)
+		}
+		cells[stored.AgriculturalUnitId] = stored.CellID
+	}
+	if cells[units[0].ID] != cells[units[1].ID] || cells[units[0].ID] != cells[units[2].ID] {
+		t.Errorf("expected the farms near Lyon in one cell, got %v", cells)
+	}
+	if cells[units[3].ID] == cells[units[0].ID] {
+		t.Errorf("expected the farm in Paris in another cell, got %v", cells)
+	}
+
+	// Rows hold the coordinates the weather was fetched at, the cell's center.
+	for _, stored := range weatherStorage.Stored {
+		if stored.AgriculturalUnitId == units[3].ID {
+			continue
+		}
+		if stored.Latitude == units[0].Latitude || math.Abs(stored.Latitude-45.7640) > 0.003 || math.Abs(stored.Longitude-4.8357) > 0.006 {
+			t.Errorf("expected the weather of the farms near Lyon at the center of their cell, got (%v, %v)", stored.Latitude, stored.Longitude)
+		}
+	}
+	centers := make(map[[2]float64]bool)
+	for _, step := range forecastStorage.Steps {
+		if step.AgriculturalUnitId != units[3].ID {
+			centers[[2]float64{step.Latitude, step.Longitude}] = true
+		}
+	}
+	if len(centers) != 1 {
+		t.Errorf("expected the forecasts of the farms near Lyon at one point, got %v", centers)
+	}
+}
+
+func TestWeatherFetcher_Run_GridCellFailure(t *testing.T) {
+	server := newFakeProvider(func(w http.ResponseWriter, r *http.Request) bool {
+		if strings.HasPrefix(r.URL.Query().Get("lat"), "45.") {
+			http.Error(w, "upstream error", http.StatusBadGateway)
+			return true
+		}
+		return false
+	})
+	defer server.Close()
+
+	// Three farms near Lyon share the failing fetch, the one in Paris does not.
+	units := []agri.AgriculturalUnit{
+		{ID: uuid.New(), IDNum: 1, Latitude: 45.7640, Longitude: 4.8357},
+		{ID: uuid.New(), IDNum: 2, Latitude: 45.7650, Longitude: 4.8370},
+		{ID: uuid.New(), IDNum: 3, Latitude: 45.7645, Longitude: 4.8362},
+		{ID: uuid.New(), IDNum: 4, Latitude: 48.8566, Longitude: 2.3522},
+	}
+	fetcher := weather.NewWeatherFetcher(weather.NewOpenWeatherProvider(server.URL, "dummy"), &MockWeatherStorage{}, nil,
+		&MockAgriUnitStorage{Units: units}, weather.FetcherConfig{Concurrency: 4, GridPrecision: gridPrecision})
+
+	summary, err := fetcher.HandleWeatherIngest(context.Background())
+	if err != nil {
+		t.Fatalf("HandleWeatherIngest returned an unexpected error: %v", err)
+	}
+	if summary != (weather.IngestSummary{Units: 4, Fetched: 1, Failed: 1, Skipped: 2}) {
+		t.Errorf("expected the failed fetch of the cell counted once and its other farms skipped, got %+v", summary)
+	}
 }
@@ -1,29 +1,31 @@
 package weather
 
 import (
+	"common/storage"
 	"database/sql"
 	"fmt"
 	"time"
-	"weather-ingestor/storage"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
 )
 
 type WeatherSqlView struct {
-	ID                 uuid.UUID    `db:"id"`
-	CreatedAt          time.Time    `db:"created_at"`
-	UpdatedAt          time.Time    `db:"updated_at"`
-	ArchivedAt         sql.NullTime `db:"archived_at"`
-	Latitude           float64      `db:"latitude"`
-	Longitude          float64      `db:"longitude"`
-	Temperature        float64      `db:"temperature"`
-	Humidity           int          `db:"humidity"`
-	WindSpeed          float64      `db:"wind_speed"`
-	Clouds             int          `db:"clouds"`
-	WeatherMain        string       `db:"weather_main"`
-	WeatherDesc        string       `db:"weather_desc"`
-	AgriculturalUnitId uuid.UUID    `db:"agricultural_unit_id"`
+	ID                 uuid.UUID      `db:"id"`
+	CreatedAt          time.Time      `db:"created_at"`
+	UpdatedAt          time.Time      `db:"updated_at"`
+	ArchivedAt         sql.NullTime   `db:"archived_at"`
+	Latitude           float64        `db:"latitude"`
+	Longitude          float64        `db:"longitude"`
+	Temperature        float64        `db:"temperature"`
+	Humidity           int            `db:"humidity"`
+	WindSpeed          float64        `db:"wind_speed"`
+	Clouds             int            `db:"clouds"`
+	WeatherMain        string         `db:"weather_main"`
+	WeatherDesc        string         `db:"weather_desc"`
+	Provider           string         `db:"provider"`
+	CellID             sql.NullString `db:"cell_id"`
+	AgriculturalUnitId uuid.UUID      `db:"agricultural_unit_id"`
 }
 
 func WeatherToSqlView(w Weather) WeatherSqlView {
@@ -34,6 +36,11 @@ func WeatherToSqlView(w Weather) WeatherSqlView {
 		archived = sql.NullTime{Valid: false}
 	}
 
+	var cellID sql.NullString
+	if w.CellID != "" {
+		cellID = sql.NullString{String: w.CellID, Valid: true}
+	}
+
 	return WeatherSqlView{
 		ID:                 w.ID,
 		CreatedAt:          w.CreatedAt,
@@ -47,6 +54,8 @@ func WeatherToSqlView(w Weather) WeatherSqlView {
 		Clouds:             w.Clouds,
 		WeatherMain:        w.WeatherMain,
 		WeatherDesc:        w.WeatherDesc,
+		Provider:           w.Provider,
+		CellID:             cellID,
 		AgriculturalUnitId: w.AgriculturalUnitId,
 	}
 }
@@ -71,6 +80,8 @@ func WeatherFromSqlView(sqlView WeatherSqlView) (Weather, error) {
 		Clouds:             sqlView.Clouds,
 		WeatherMain:        sqlView.WeatherMain,
 		WeatherDesc:        sqlView.WeatherDesc,
+		Provider:           sqlView.Provider,
+		CellID:             sqlView.CellID.String,
 		AgriculturalUnitId: sqlView.AgriculturalUnitId,
 	}, nil
 }
@@ -108,6 +119,8 @@ func (s *weatherStorage) InsertOrUpdate(w Weather) error {
 			"clouds",
 			"weather_main",
 			"weather_desc",
+			"provider",
+			"cell_id",
 			"agricultural_unit_id",
 		).
 		Values(
@@ -123,6 +136,8 @@ func (s *weatherStorage) InsertOrUpdate(w Weather) error {
 			sqlView.Clouds,
 			sqlView.WeatherMain,
 			sqlView.WeatherDesc,
+			sqlView.Provider,
+			sqlView.CellID,
 			sqlView.AgriculturalUnitId,
 		).
 		Suffix(`
@@ -137,6 +152,8 @@ func (s *weatherStorage) InsertOrUpdate(w Weather) error {
                 clouds = EXCLUDED.clouds,
                 weather_main = EXCLUDED.weather_main,
                 weather_desc = EXCLUDED.weather_desc,
+                provider = EXCLUDED.provider,
+                cell_id = EXCLUDED.cell_id,
                 agricultural_unit_id = EXCLUDED.agricultural_unit_id
         `)
 
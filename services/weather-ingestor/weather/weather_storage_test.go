@@ -1,11 +1,11 @@
 package weather
 
 import (
+	"common/testutils"
 	"database/sql"
 	"regexp"
 	"testing"
 	"time"
-	"weather-ingestor/misc"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/uuid"
@@ -28,6 +28,8 @@ func TestWeatherToSqlViewAndBack(t *testing.T) {
 		Clouds:             75,
 		WeatherMain:        "Clouds",
 		WeatherDesc:        "scattered clouds",
+		Provider:           ProviderOpenMeteo,
+		CellID:             "u09tvw",
 		AgriculturalUnitId: uuid.New(),
 	}
 
@@ -71,6 +73,12 @@ func TestWeatherToSqlViewAndBack(t *testing.T) {
 	if weather.WeatherDesc != sqlView.WeatherDesc {
 		t.Errorf("WeatherDesc mismatch: got %v want %v", sqlView.WeatherDesc, weather.WeatherDesc)
 	}
+	if weather.Provider != sqlView.Provider {
+		t.Errorf("Provider mismatch: got %v want %v", sqlView.Provider, weather.Provider)
+	}
+	if !sqlView.CellID.Valid || weather.CellID != sqlView.CellID.String {
+		t.Errorf("CellID mismatch: got %v want %v", sqlView.CellID, weather.CellID)
+	}
 	if weather.AgriculturalUnitId != sqlView.AgriculturalUnitId {
 		t.Errorf("AgriculturalUnitId mismatch: got %v want %v", sqlView.AgriculturalUnitId, weather.AgriculturalUnitId)
 	}
@@ -118,6 +126,12 @@ func TestWeatherToSqlViewAndBack(t *testing.T) {
 	if weather.WeatherDesc != converted.WeatherDesc {
 		t.Errorf("Converted WeatherDesc mismatch: got %v want %v", converted.WeatherDesc, weather.WeatherDesc)
 	}
+	if weather.Provider != converted.Provider {
+		t.Errorf("Converted Provider mismatch: got %v want %v", converted.Provider, weather.Provider)
+	}
+	if weather.CellID != converted.CellID {
+		t.Errorf("Converted CellID mismatch: got %v want %v", converted.CellID, weather.CellID)
+	}
 	if weather.AgriculturalUnitId != converted.AgriculturalUnitId {
 		t.Errorf("Converted AgriculturalUnitId mismatch: got %v want %v", converted.AgriculturalUnitId, weather.AgriculturalUnitId)
 	}
@@ -159,7 +173,7 @@ func TestWeatherToSqlViewArchivedNil(t *testing.T) {
 }
 
 func TestInsertOrUpdate_Weather_Success(t *testing.T) {
-	mockQuerierInstance, sqlMock, err := misc.NewMockQuerier(t)
+	mockQuerierInstance, sqlMock, err := testutils.NewMockQuerier(t)
 	if err != nil {
 		t.Fatalf("failed to create mock querier: %v", err)
 	}
@@ -184,10 +198,12 @@ func TestInsertOrUpdate_Weather_Success(t *testing.T) {
 		Clouds:             20,
 		WeatherMain:        "Rain",
 		WeatherDesc:        "light rain",
+		Provider:           ProviderOpenWeather,
+		CellID:             "u0fw4y",
 		AgriculturalUnitId: agriID,
 	}
 
-	expectedSQL := "INSERT INTO weather (id,created_at,updated_at,archived_at,latitude,longitude,temperature,humidity,wind_speed,clouds,weather_main,weather_desc,agricultural_unit_id) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13) ON CONFLICT (id) DO UPDATE SET updated_at = EXCLUDED.updated_at, archived_at = EXCLUDED.archived_at, latitude = EXCLUDED.latitude, longitude = EXCLUDED.longitude, temperature = EXCLUDED.temperature, humidity = EXCLUDED.humidity, wind_speed = EXCLUDED.wind_speed, clouds = EXCLUDED.clouds, weather_main = EXCLUDED.weather_main, weather_desc = EXCLUDED.weather_desc, agricultural_unit_id = EXCLUDED.agricultural_unit_id"
+	expectedSQL := "INSERT INTO weather (id,created_at,updated_at,archived_at,latitude,longitude,temperature,humidity,wind_speed,clouds,weather_main,weather_desc,provider,cell_id,agricultural_unit_id) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15) ON CONFLICT (id) DO UPDATE SET updated_at = EXCLUDED.updated_at, archived_at = EXCLUDED.archived_at, latitude = EXCLUDED.latitude, longitude = EXCLUDED.longitude, temperature = EXCLUDED.temperature, humidity = EXCLUDED.humidity, wind_speed = EXCLUDED.wind_speed, clouds = EXCLUDED.clouds, weather_main = EXCLUDED.weather_main, weather_desc = EXCLUDED.weather_desc, provider = EXCLUDED.provider, cell_id = EXCLUDED.cell_id, agricultural_unit_id = EXCLUDED.agricultural_unit_id"
 
 	sqlMock.ExpectExec(regexp.QuoteMeta(expectedSQL)).
 		WithArgs(
@@ -203,6 +219,8 @@ func TestInsertOrUpdate_Weather_Success(t *testing.T) {
 			w.Clouds,
 			w.WeatherMain,
 			w.WeatherDesc,
+			w.Provider,
+			sql.NullString{String: "u0fw4y", Valid: true},
 			w.AgriculturalUnitId,
 		).
 		WillReturnResult(sqlmock.NewResult(1, 1))
@@ -19,6 +19,7 @@ func TestCreateWeather(t *testing.T) {
 		Clouds:             75,
 		WeatherMain:        "Clouds",
 		WeatherDesc:        "broken clouds",
+		Provider:           weather.ProviderOpenWeather,
 		AgriculturalUnitId: agriUnitID,
 	}
 